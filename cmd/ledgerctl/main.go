@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"btp_tokens/internal/ledger"
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+
+	"github.com/joho/godotenv"
+)
+
+const usage = `usage: ledgerctl <command>
+
+commands:
+  verify       walk the journal hash chain and report the first broken link
+               (needs LEDGER_VERIFY_KEY or LEDGER_SIGNING_KEY)
+  checkpoint   sign the current chain head (needs LEDGER_SIGNING_KEY)
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("cant load .env")
+	}
+
+	dbURL := os.Getenv("DATABASE_URL")
+	if dbURL == "" {
+		log.Fatalf("error: couldnt get database url variable")
+	}
+
+	database.InitDB(dbURL)
+	defer database.CloseDB()
+
+	ledgerService := &ledger.LedgerService{DB: database.Db}
+	if seed := os.Getenv("LEDGER_SIGNING_KEY"); seed != "" {
+		key, err := ledger.ParseSigningKey(seed)
+		if err != nil {
+			log.Fatalf("error: %v", err)
+		}
+		ledgerService.SigningKey = key
+	}
+	if publicKey := os.Getenv("LEDGER_VERIFY_KEY"); publicKey != "" {
+		key, err := ledger.ParseVerifyKey(publicKey)
+		if err != nil {
+			log.Fatalf("error: %v", err)
+		}
+		ledgerService.VerifyKey = key
+	}
+	if err := ledgerService.CheckKeys(); err != nil {
+		log.Fatalf("error: %v", err)
+	}
+
+	ctx := context.Background()
+
+	switch os.Args[1] {
+	case "verify":
+		os.Exit(verify(ctx, ledgerService))
+	case "checkpoint":
+		c, err := ledgerService.Checkpoint(ctx)
+		if err != nil {
+			log.Fatalf("error: checkpoint fail: %v", err)
+		}
+		fmt.Printf("checkpoint %d: entry %d hash %s\n", c.ID, c.EntryID, c.Hash)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func verify(ctx context.Context, ledgerService *ledger.LedgerService) int {
+	if ledgerService.VerifyKey == nil && ledgerService.SigningKey == nil {
+		log.Printf("error: set LEDGER_VERIFY_KEY (hex ed25519 public key) to check checkpoint signatures")
+		return 1
+	}
+
+	result, err := ledgerService.VerifyChain(ctx)
+	if err != nil {
+		log.Printf("error: verification fail: %v", err)
+		return 1
+	}
+
+	if !result.Valid {
+		fmt.Printf("BROKEN at entry %d", result.BrokenEntryID)
+		if result.BrokenCheckpointID != 0 {
+			fmt.Printf(" (checkpoint %d)", result.BrokenCheckpointID)
+		}
+		fmt.Printf(": %s\n", result.Reason)
+		return 1
+	}
+
+	fmt.Printf("OK: %d entries, %d checkpoints, head %s\n", result.EntriesChecked, result.CheckpointsChecked, result.HeadHash)
+	return 0
+}
@@ -0,0 +1,666 @@
+package graph
+
+import (
+	"btp_tokens/graph/model"
+	"btp_tokens/internal/alerts"
+	"btp_tokens/internal/compliance"
+	"btp_tokens/internal/fees"
+	"btp_tokens/internal/ledger"
+	"btp_tokens/internal/limits"
+	"btp_tokens/internal/merkle"
+	"btp_tokens/internal/policy"
+	"btp_tokens/internal/snapshots"
+	"btp_tokens/internal/tokens"
+	"btp_tokens/internal/wallets"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func parseID(id string) (int64, error) {
+	value, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid id %q", id)
+	}
+	return value, nil
+}
+
+func formatID(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+func optionalID(id int64) *string {
+	if id == 0 {
+		return nil
+	}
+	s := formatID(id)
+	return &s
+}
+
+func toLedgerCheckpoint(c ledger.Checkpoint) *model.LedgerCheckpoint {
+	return &model.LedgerCheckpoint{
+		ID:        formatID(c.ID),
+		EntryID:   formatID(c.EntryID),
+		Hash:      c.Hash,
+		Signature: c.Signature,
+		CreatedAt: c.CreatedAt,
+	}
+}
+
+func toSnapshot(s snapshots.Snapshot) *model.Snapshot {
+	snapshot := &model.Snapshot{
+		ID:        formatID(s.ID),
+		CreatedAt: s.CreatedAt,
+		Roots:     make([]*model.SnapshotRoot, 0, len(s.Roots)),
+	}
+	for _, root := range s.Roots {
+		snapshot.Roots = append(snapshot.Roots, &model.SnapshotRoot{
+			Token:       root.Token,
+			MerkleRoot:  root.MerkleRoot,
+			WalletCount: int32(root.WalletCount),
+		})
+	}
+	if root, ok := s.Root(tokens.DefaultSymbol); ok {
+		snapshot.MerkleRoot = root.MerkleRoot
+		snapshot.WalletCount = int32(root.WalletCount)
+	}
+	return snapshot
+}
+
+func toToken(t tokens.Token) *model.Token {
+	token := &model.Token{
+		Symbol:   t.Symbol,
+		Name:     t.Name,
+		Decimals: t.Decimals,
+	}
+	if t.SupplyCap != nil {
+		supplyCap := model.Decimal(*t.SupplyCap)
+		token.SupplyCap = &supplyCap
+	}
+	if t.Issuer != "" {
+		token.Issuer = &t.Issuer
+	}
+	return token
+}
+
+func toWallet(w wallets.Wallet) *model.Wallet {
+	return &model.Wallet{
+		Address:          w.Address,
+		Token:            w.Token,
+		Balance:          model.Decimal(w.Balance),
+		HeldBalance:      model.Decimal(w.Held),
+		StakedBalance:    model.Decimal(w.Staked),
+		AvailableBalance: model.Decimal(w.Available()),
+	}
+}
+
+func toHold(h wallets.Hold) *model.Hold {
+	hold := &model.Hold{
+		ID:             formatID(h.ID),
+		Address:        h.Address,
+		Token:          h.Token,
+		Payee:          h.Payee,
+		Amount:         model.Decimal(h.Amount),
+		CapturedAmount: model.Decimal(h.CapturedAmount),
+		Status:         model.HoldStatus(strings.ToUpper(h.Status)),
+		TransferID:     optionalID(h.TransferEntryID),
+		ExpiresAt:      h.ExpiresAt,
+		CreatedAt:      h.CreatedAt,
+		UpdatedAt:      h.UpdatedAt,
+	}
+	hold.Reference = optionalString(h.Reference)
+	return hold
+}
+
+func toEscrow(e wallets.Escrow) *model.Escrow {
+	return &model.Escrow{
+		ID:             formatID(e.ID),
+		Token:          e.Token,
+		Buyer:          e.Buyer,
+		Seller:         e.Seller,
+		Arbiter:        e.Arbiter,
+		Amount:         model.Decimal(e.Amount),
+		Status:         model.EscrowStatus(strings.ToUpper(e.Status)),
+		BuyerApproved:  e.BuyerApproved,
+		SellerApproved: e.SellerApproved,
+		Reference:      optionalString(e.Reference),
+		Deadline:       e.Deadline,
+		CreatedAt:      e.CreatedAt,
+		UpdatedAt:      e.UpdatedAt,
+	}
+}
+
+func toEscrowEvent(e wallets.EscrowEvent) *model.EscrowEvent {
+	event := &model.EscrowEvent{
+		Action:     e.Action,
+		ToStatus:   model.EscrowStatus(strings.ToUpper(e.ToStatus)),
+		Actor:      optionalString(e.Actor),
+		Note:       optionalString(e.Note),
+		TransferID: optionalID(e.TransferEntryID),
+		CreatedAt:  e.CreatedAt,
+	}
+	if e.FromStatus != "" {
+		from := model.EscrowStatus(strings.ToUpper(e.FromStatus))
+		event.FromStatus = &from
+	}
+	return event
+}
+
+func toHtlc(h wallets.Htlc) *model.Htlc {
+	return &model.Htlc{
+		ID:         formatID(h.ID),
+		Token:      h.Token,
+		Sender:     h.Sender,
+		Recipient:  h.Recipient,
+		Amount:     model.Decimal(h.Amount),
+		Hashlock:   h.Hashlock,
+		Preimage:   optionalString(h.Preimage),
+		Status:     model.HtlcStatus(strings.ToUpper(h.Status)),
+		TransferID: optionalID(h.TransferEntryID),
+		TimeoutAt:  h.TimeoutAt,
+		CreatedAt:  h.CreatedAt,
+		UpdatedAt:  h.UpdatedAt,
+	}
+}
+
+func toScheduledTransfer(t wallets.ScheduledTransfer) *model.ScheduledTransfer {
+	schedule := &model.ScheduledTransfer{
+		ID:          formatID(t.ID),
+		Token:       t.Token,
+		FromAddress: t.FromAddress,
+		ToAddress:   t.ToAddress,
+		Amount:      model.Decimal(t.Amount),
+		Cron:        optionalString(t.Cron),
+		EndsAt:      t.EndsAt,
+		RunCount:    int32(t.RunCount),
+		NextRunAt:   t.NextRunAt,
+		Status:      model.ScheduleStatus(strings.ToUpper(t.Status)),
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.UpdatedAt,
+	}
+	if t.Interval != 0 {
+		seconds := int32(t.Interval / time.Second)
+		schedule.IntervalSeconds = &seconds
+	}
+	if t.MaxRuns != 0 {
+		maxRuns := int32(t.MaxRuns)
+		schedule.MaxRuns = &maxRuns
+	}
+	return schedule
+}
+
+func toScheduleRun(r wallets.ScheduleRun) *model.ScheduleRun {
+	return &model.ScheduleRun{
+		ID:           formatID(r.ID),
+		ScheduledFor: r.ScheduledFor,
+		Status:       model.ScheduleRunStatus(strings.ToUpper(r.Status)),
+		TransferID:   optionalID(r.TransferEntryID),
+		Error:        optionalString(r.Error),
+		CreatedAt:    r.CreatedAt,
+	}
+}
+
+func toTransferReceipt(r wallets.Receipt) *model.TransferReceipt {
+	return &model.TransferReceipt{
+		ID:          formatID(r.EntryID),
+		Token:       r.Token,
+		FromAddress: r.FromAddress,
+		ToAddress:   r.ToAddress,
+		Amount:      model.Decimal(r.Amount),
+		Fee:         model.Decimal(r.Fee),
+		NetAmount:   model.Decimal(r.NetAmount),
+		ReversalOf:  optionalID(r.ReversalOf),
+		ReversedBy:  optionalID(r.ReversedBy),
+		CreatedAt:   r.CreatedAt,
+	}
+}
+
+func toTransferReversal(r wallets.Reversal) *model.TransferReversal {
+	return &model.TransferReversal{
+		ID:         formatID(r.ID),
+		TransferID: formatID(r.TransferEntryID),
+		ReversalID: formatID(r.ReversalEntryID),
+		Amount:     model.Decimal(r.Amount),
+		Reason:     r.Reason,
+		Operator:   r.Operator,
+		CreatedAt:  r.CreatedAt,
+	}
+}
+
+func toAddressStatus(s compliance.AddressStatus) *model.AddressStatus {
+	return &model.AddressStatus{
+		Address:   s.Address,
+		Status:    model.WalletStatus(strings.ToUpper(s.Status)),
+		Reason:    optionalString(s.Reason),
+		UpdatedBy: optionalString(s.Operator),
+		UpdatedAt: s.UpdatedAt,
+		DenyList:  optionalString(s.DenyList),
+	}
+}
+
+func toComplianceEvent(e compliance.Event) *model.ComplianceEvent {
+	event := &model.ComplianceEvent{
+		Action:    e.Action,
+		List:      optionalString(e.List),
+		Reason:    e.Reason,
+		Operator:  e.Operator,
+		CreatedAt: e.CreatedAt,
+	}
+	if e.Status != "" {
+		status := model.WalletStatus(strings.ToUpper(e.Status))
+		event.Status = &status
+	}
+	return event
+}
+
+func toTierLimits(l limits.Limits) *model.TierLimits {
+	return &model.TierLimits{
+		Tier:        l.Tier,
+		Token:       l.Token,
+		PerTransfer: optionalModelDecimal(l.PerTransfer),
+		Daily:       optionalModelDecimal(l.Daily),
+		Monthly:     optionalModelDecimal(l.Monthly),
+		UpdatedAt:   l.UpdatedAt,
+	}
+}
+
+func toWalletLimitUsage(u limits.Usage) *model.WalletLimitUsage {
+	return &model.WalletLimitUsage{
+		Address:          u.Address,
+		Token:            u.Token,
+		Tier:             u.Tier,
+		PerTransferLimit: optionalModelDecimal(u.PerTransfer),
+		DailyLimit:       optionalModelDecimal(u.Daily),
+		DailyUsed:        model.Decimal(u.SentDaily),
+		MonthlyLimit:     optionalModelDecimal(u.Monthly),
+		MonthlyUsed:      model.Decimal(u.SentMonthly),
+		Remaining:        optionalModelDecimal(u.Remaining()),
+	}
+}
+
+func toPolicyRule(r policy.Rule) *model.PolicyRule {
+	return &model.PolicyRule{
+		ID:         formatID(r.ID),
+		Name:       r.Name,
+		Version:    int32(r.Version),
+		Expression: r.Expression,
+		Action:     model.PolicyAction(strings.ToUpper(r.Action)),
+		Priority:   int32(r.Priority),
+		Message:    optionalString(r.Message),
+		Enabled:    r.Enabled,
+		Operator:   r.Operator,
+		CreatedAt:  r.CreatedAt,
+	}
+}
+
+func fromPolicyRuleInput(input *model.PolicyRuleInput) policy.Rule {
+	rule := policy.Rule{
+		Name:       input.Name,
+		Expression: input.Expression,
+		Action:     strings.ToLower(string(input.Action)),
+		Priority:   100,
+		Enabled:    true,
+	}
+	if input.Priority != nil {
+		rule.Priority = int(*input.Priority)
+	}
+	if input.Message != nil {
+		rule.Message = *input.Message
+	}
+	if input.Enabled != nil {
+		rule.Enabled = *input.Enabled
+	}
+	return rule
+}
+
+func toPolicyEvaluation(d policy.Decision) *model.PolicyEvaluation {
+	evaluation := &model.PolicyEvaluation{
+		Action:     model.PolicyAction(strings.ToUpper(d.Action)),
+		Flags:      make([]*model.PolicyRule, 0, len(d.Flags)),
+		Results:    make([]*model.PolicyRuleResult, 0, len(d.Results)),
+		Attributes: []*model.PolicyAttribute{},
+	}
+	if d.Rule != nil {
+		evaluation.Rule = toPolicyRule(*d.Rule)
+	}
+	for _, r := range d.Flags {
+		evaluation.Flags = append(evaluation.Flags, toPolicyRule(*r))
+	}
+	for _, result := range d.Results {
+		item := &model.PolicyRuleResult{Rule: toPolicyRule(*result.Rule), Matched: result.Matched}
+		if result.Err != nil {
+			msg := result.Err.Error()
+			item.Error = &msg
+		}
+		evaluation.Results = append(evaluation.Results, item)
+	}
+	for name, value := range d.Attributes.Map() {
+		evaluation.Attributes = append(evaluation.Attributes, &model.PolicyAttribute{Name: name, Value: value})
+	}
+	sort.Slice(evaluation.Attributes, func(i, j int) bool {
+		return evaluation.Attributes[i].Name < evaluation.Attributes[j].Name
+	})
+	return evaluation
+}
+
+func toMultisigWallet(w wallets.MultisigWallet) *model.MultisigWallet {
+	wallet := &model.MultisigWallet{
+		Address:   w.Address,
+		Threshold: int32(w.Threshold),
+		Signers:   make([]*model.MultisigSigner, 0, len(w.Signers)),
+		CreatedAt: w.CreatedAt,
+	}
+	for _, signer := range w.Signers {
+		wallet.Signers = append(wallet.Signers, &model.MultisigSigner{Address: signer.Address, PublicKey: signer.PublicKey})
+	}
+	return wallet
+}
+
+func toMultisigProposal(p wallets.MultisigProposal) *model.MultisigProposal {
+	proposal := &model.MultisigProposal{
+		ID:              formatID(p.ID),
+		Wallet:          p.Wallet,
+		Token:           p.Token,
+		ToAddress:       p.ToAddress,
+		Amount:          model.Decimal(p.Amount),
+		Proposer:        p.Proposer,
+		Status:          model.MultisigProposalStatus(strings.ToUpper(p.Status)),
+		Threshold:       int32(p.Threshold),
+		Approvals:       make([]*model.MultisigApproval, 0, len(p.Approvals)),
+		ApprovalMessage: p.ApprovalMessage(),
+		CancelMessage:   p.CancelMessage(),
+		TransferID:      optionalID(p.TransferEntryID),
+		Error:           optionalString(p.Error),
+		ExpiresAt:       p.ExpiresAt,
+		CreatedAt:       p.CreatedAt,
+		UpdatedAt:       p.UpdatedAt,
+	}
+	for _, approval := range p.Approvals {
+		proposal.Approvals = append(proposal.Approvals, &model.MultisigApproval{Signer: approval.Signer, CreatedAt: approval.CreatedAt})
+	}
+	return proposal
+}
+
+// toVestingGrant reports the vested amounts of g as of at.
+func toVestingGrant(g wallets.VestingGrant, at time.Time) *model.VestingGrant {
+	vested := g.VestedAt(at)
+	claimable := decimal.Max(vested.Sub(g.Claimed), decimal.Zero)
+	return &model.VestingGrant{
+		ID:          formatID(g.ID),
+		Token:       g.Token,
+		Funder:      g.Funder,
+		Beneficiary: g.Beneficiary,
+		Total:       model.Decimal(g.Total),
+		StartAt:     g.StartAt,
+		CliffAt:     g.StartAt.Add(g.Cliff),
+		EndAt:       g.StartAt.Add(g.Duration),
+		Revocable:   g.Revocable,
+		RevokedAt:   g.RevokedAt,
+		Returned:    model.Decimal(g.Returned),
+		At:          at,
+		Vested:      model.Decimal(vested),
+		Unvested:    model.Decimal(g.Total.Sub(vested)),
+		Claimed:     model.Decimal(g.Claimed),
+		Claimable:   model.Decimal(claimable),
+		CreatedAt:   g.CreatedAt,
+		UpdatedAt:   g.UpdatedAt,
+	}
+}
+
+func toStakingPool(p wallets.StakingPool) *model.StakingPool {
+	return &model.StakingPool{
+		Token:            p.Token,
+		Apr:              optionalModelDecimal(p.APR),
+		RewardRate:       optionalModelDecimal(p.RewardRate),
+		UnbondingSeconds: int32(p.Unbonding / time.Second),
+		TotalStaked:      model.Decimal(p.TotalStaked),
+		RewardsAvailable: model.Decimal(p.RewardsAvailable),
+		UpdatedAt:        p.UpdatedAt,
+	}
+}
+
+// toStake reports the rewards of s pending at the given time.
+func toStake(s wallets.Stake, pool wallets.StakingPool, at time.Time) *model.Stake {
+	return &model.Stake{
+		ID:             formatID(s.ID),
+		Token:          s.Token,
+		Address:        s.Address,
+		Amount:         model.Decimal(s.Amount),
+		Status:         model.StakeStatus(strings.ToUpper(s.Status)),
+		PendingRewards: model.Decimal(s.PendingRewards(pool, at)),
+		ClaimedRewards: model.Decimal(s.ClaimedRewards),
+		UnbondsAt:      s.UnbondsAt,
+		CreatedAt:      s.CreatedAt,
+		UpdatedAt:      s.UpdatedAt,
+	}
+}
+
+func toDistribution(d wallets.Distribution) *model.Distribution {
+	return &model.Distribution{
+		ID:             formatID(d.ID),
+		Token:          d.Token,
+		FromAddress:    d.FromAddress,
+		Total:          model.Decimal(d.Total),
+		SnapshotID:     optionalID(d.SnapshotID),
+		WeightToken:    optionalString(d.WeightToken),
+		Status:         model.DistributionStatus(strings.ToUpper(d.Status)),
+		Operator:       d.Operator,
+		RecipientCount: int32(d.RecipientCount),
+		PaidCount:      int32(d.PaidCount),
+		FailedCount:    int32(d.FailedCount),
+		PaidAmount:     model.Decimal(d.PaidAmount),
+		Returned:       model.Decimal(d.Returned),
+		StartedAt:      d.StartedAt,
+		CompletedAt:    d.CompletedAt,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+	}
+}
+
+func toDistributionRecipient(r wallets.DistributionRecipient) *model.DistributionRecipient {
+	return &model.DistributionRecipient{
+		Address:    r.Address,
+		Weight:     model.Decimal(r.Weight),
+		Amount:     model.Decimal(r.Amount),
+		Status:     model.DistributionRecipientStatus(strings.ToUpper(r.Status)),
+		TransferID: optionalID(r.TransferEntryID),
+		Error:      optionalString(r.Error),
+		UpdatedAt:  r.UpdatedAt,
+	}
+}
+
+func toAirdrop(a wallets.Airdrop) *model.Airdrop {
+	return &model.Airdrop{
+		ID:         formatID(a.ID),
+		Token:      a.Token,
+		Funder:     a.Funder,
+		Root:       a.Root,
+		Total:      model.Decimal(a.Total),
+		LeafCount:  int32(a.LeafCount),
+		Claimed:    model.Decimal(a.Claimed),
+		ClaimCount: int32(a.ClaimCount),
+		ExpiresAt:  a.ExpiresAt,
+		Swept:      model.Decimal(a.Swept),
+		SweptAt:    a.SweptAt,
+		Operator:   a.Operator,
+		CreatedAt:  a.CreatedAt,
+		UpdatedAt:  a.UpdatedAt,
+	}
+}
+
+func toAirdropClaim(c wallets.AirdropClaim) *model.AirdropClaim {
+	return &model.AirdropClaim{
+		AirdropID:  formatID(c.AirdropID),
+		Address:    c.Address,
+		Amount:     model.Decimal(c.Amount),
+		Root:       c.Root,
+		Proof:      toProofSteps(c.Proof),
+		ClaimedAt:  c.ClaimedAt,
+		TransferID: optionalID(c.TransferEntryID),
+	}
+}
+
+func toProofSteps(proof []merkle.ProofStep) []*model.MerkleProofStep {
+	steps := make([]*model.MerkleProofStep, 0, len(proof))
+	for _, step := range proof {
+		side := model.MerkleSideRight
+		if step.Left {
+			side = model.MerkleSideLeft
+		}
+		steps = append(steps, &model.MerkleProofStep{Hash: step.Hash, Side: side})
+	}
+	return steps
+}
+
+func toPaymentRequest(r wallets.PaymentRequest) *model.PaymentRequest {
+	return &model.PaymentRequest{
+		ID:          formatID(r.ID),
+		Token:       r.Token,
+		Payee:       r.Payee,
+		Amount:      model.Decimal(r.Amount),
+		PaidAmount:  model.Decimal(r.PaidAmount),
+		Outstanding: model.Decimal(r.Outstanding()),
+		Memo:        optionalString(r.Memo),
+		Reference:   r.Reference,
+		Status:      model.PaymentRequestStatus(strings.ToUpper(r.Status)),
+		ExpiresAt:   r.ExpiresAt,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
+
+func toAlert(a alerts.Alert) *model.Alert {
+	alert := &model.Alert{
+		ID:                  formatID(a.ID),
+		Detector:            a.Detector,
+		Severity:            model.AlertSeverity(strings.ToUpper(a.Severity)),
+		Status:              model.AlertStatus(strings.ToUpper(a.Status)),
+		Address:             a.Address,
+		Token:               a.Token,
+		TransferID:          formatID(a.EntryID),
+		Evidence:            a.Evidence,
+		EvidenceTransferIds: make([]string, 0, len(a.EvidenceEntryIDs)),
+		CreatedAt:           a.CreatedAt,
+		UpdatedAt:           a.UpdatedAt,
+	}
+	for _, id := range a.EvidenceEntryIDs {
+		alert.EvidenceTransferIds = append(alert.EvidenceTransferIds, formatID(id))
+	}
+	return alert
+}
+
+func toAlertEvent(e alerts.Event) *model.AlertEvent {
+	return &model.AlertEvent{
+		Action:    e.Action,
+		Status:    model.AlertStatus(strings.ToUpper(e.Status)),
+		Note:      optionalString(e.Note),
+		Operator:  e.Operator,
+		CreatedAt: e.CreatedAt,
+	}
+}
+
+func toAlertDetector(d alerts.Detector) *model.AlertDetector {
+	detector := &model.AlertDetector{
+		Name:       d.Name,
+		Enabled:    d.Enabled,
+		Severity:   model.AlertSeverity(strings.ToUpper(d.Severity)),
+		Thresholds: make([]*model.AlertThreshold, 0, len(d.Thresholds)),
+		UpdatedBy:  optionalString(d.Operator),
+		UpdatedAt:  d.UpdatedAt,
+	}
+	for name, value := range d.Thresholds {
+		detector.Thresholds = append(detector.Thresholds, &model.AlertThreshold{Name: name, Value: model.Decimal(value)})
+	}
+	sort.Slice(detector.Thresholds, func(i, j int) bool {
+		return detector.Thresholds[i].Name < detector.Thresholds[j].Name
+	})
+	return detector
+}
+
+func toTransferQuote(q fees.Quote) *model.TransferQuote {
+	quote := &model.TransferQuote{
+		Token:     q.Token,
+		Amount:    model.Decimal(q.Amount),
+		Fee:       model.Decimal(q.Fee),
+		NetAmount: model.Decimal(q.NetAmount),
+	}
+	if q.TreasuryAddress != "" {
+		quote.TreasuryAddress = &q.TreasuryAddress
+	}
+	return quote
+}
+
+func toFeePolicy(p fees.Policy) *model.FeePolicy {
+	policy := &model.FeePolicy{
+		Token:           p.Token,
+		TreasuryAddress: p.TreasuryAddress,
+		Flat:            model.Decimal(p.Flat),
+		BasisPoints:     p.BasisPoints,
+		MinFee:          optionalModelDecimal(p.MinFee),
+		MaxFee:          optionalModelDecimal(p.MaxFee),
+		Tiers:           make([]*model.FeeTier, 0, len(p.Tiers)),
+	}
+	for _, tier := range p.Tiers {
+		policy.Tiers = append(policy.Tiers, &model.FeeTier{
+			From:        model.Decimal(tier.From),
+			Flat:        model.Decimal(tier.Flat),
+			BasisPoints: tier.BasisPoints,
+		})
+	}
+	return policy
+}
+
+func optionalModelDecimal(d *decimal.Decimal) *model.Decimal {
+	if d == nil {
+		return nil
+	}
+	value := model.Decimal(*d)
+	return &value
+}
+
+func optionalDecimal(d *model.Decimal) *decimal.Decimal {
+	if d == nil {
+		return nil
+	}
+	value := decimal.Decimal(*d)
+	return &value
+}
+
+func decimalOrZero(d *model.Decimal) decimal.Decimal {
+	if d == nil {
+		return decimal.Zero
+	}
+	return decimal.Decimal(*d)
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func int32OrZero(i *int32) int32 {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
+
+func timeOrNow(t *time.Time) time.Time {
+	if t == nil {
+		return time.Now()
+	}
+	return *t
+}
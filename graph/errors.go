@@ -0,0 +1,276 @@
+package graph
+
+import (
+	"btp_tokens/internal/alerts"
+	"btp_tokens/internal/auth"
+	"btp_tokens/internal/compliance"
+	"btp_tokens/internal/fees"
+	"btp_tokens/internal/limits"
+	"btp_tokens/internal/policy"
+	"btp_tokens/internal/snapshots"
+	"btp_tokens/internal/tokens"
+	"btp_tokens/internal/wallets"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Error codes clients can rely on, sent in the "code" extension of errors
+// and by simulateTransfer.
+const (
+	CodeInvalidAmount       = "INVALID_AMOUNT"
+	CodeTokenNotFound       = "TOKEN_NOT_FOUND"
+	CodeInsufficientBalance = "INSUFFICIENT_BALANCE"
+	CodeSameAddress         = "SAME_ADDRESS"
+	CodeSenderNotFound      = "SENDER_NOT_FOUND"
+	CodeFeeExceedsAmount    = "FEE_EXCEEDS_AMOUNT"
+	CodeUnauthorized        = "UNAUTHORIZED"
+	CodeHoldNotFound        = "HOLD_NOT_FOUND"
+	CodeHoldNotActive       = "HOLD_NOT_ACTIVE"
+	CodeHoldExpired         = "HOLD_EXPIRED"
+	CodeInvalidExpiry       = "INVALID_EXPIRY"
+	CodeEscrowNotFound      = "ESCROW_NOT_FOUND"
+	CodeInvalidEscrowState  = "INVALID_ESCROW_STATE"
+	CodeNotEscrowParty      = "NOT_ESCROW_PARTY"
+	CodeInvalidEscrow       = "INVALID_ESCROW"
+	CodeReservedAddress     = "RESERVED_ADDRESS"
+	CodeDeadlineNotReached  = "DEADLINE_NOT_REACHED"
+	CodeHtlcNotFound        = "HTLC_NOT_FOUND"
+	CodeHtlcNotLocked       = "HTLC_NOT_LOCKED"
+	CodeInvalidHashlock     = "INVALID_HASHLOCK"
+	CodeInvalidPreimage     = "INVALID_PREIMAGE"
+	CodeHtlcTimedOut        = "HTLC_TIMED_OUT"
+	CodeHtlcNotTimedOut     = "HTLC_NOT_TIMED_OUT"
+	CodeScheduleNotFound    = "SCHEDULE_NOT_FOUND"
+	CodeScheduleNotActive   = "SCHEDULE_NOT_ACTIVE"
+	CodeInvalidSchedule     = "INVALID_SCHEDULE"
+	CodeTransferNotFound    = "TRANSFER_NOT_FOUND"
+	CodeAlreadyReversed     = "ALREADY_REVERSED"
+	CodeInvalidReversal     = "INVALID_REVERSAL"
+	CodeWalletFrozen        = "WALLET_FROZEN"
+	CodeAddressDenied       = "ADDRESS_DENIED"
+	CodeNotDenied           = "NOT_DENIED"
+	CodeInvalidCompliance   = "INVALID_COMPLIANCE_CHANGE"
+	CodeLimitExceeded       = "LIMIT_EXCEEDED"
+	CodeInvalidLimits       = "INVALID_LIMITS"
+	CodeLimitsNotFound      = "LIMITS_NOT_FOUND"
+	CodePolicyDenied        = "POLICY_DENIED"
+	CodePolicyError         = "POLICY_ERROR"
+	CodeInvalidPolicyRule   = "INVALID_POLICY_RULE"
+	CodePolicyRuleNotFound  = "POLICY_RULE_NOT_FOUND"
+	CodeAlertNotFound       = "ALERT_NOT_FOUND"
+	CodeInvalidAlertChange  = "INVALID_ALERT_CHANGE"
+	CodeInvalidDetector     = "INVALID_DETECTOR"
+	CodeMultisigRequired    = "MULTISIG_REQUIRED"
+	CodeMultisigNotFound    = "MULTISIG_NOT_FOUND"
+	CodeInvalidMultisig     = "INVALID_MULTISIG"
+	CodeProposalNotFound    = "PROPOSAL_NOT_FOUND"
+	CodeProposalNotPending  = "PROPOSAL_NOT_PENDING"
+	CodeNotSigner           = "NOT_SIGNER"
+	CodeInvalidSignature    = "INVALID_SIGNATURE"
+	CodeAlreadyApproved     = "ALREADY_APPROVED"
+	CodeThresholdNotMet     = "THRESHOLD_NOT_MET"
+	CodeGrantNotFound       = "VESTING_GRANT_NOT_FOUND"
+	CodeInvalidGrant        = "INVALID_VESTING_GRANT"
+	CodeNothingToClaim      = "NOTHING_TO_CLAIM"
+	CodeGrantNotRevocable   = "GRANT_NOT_REVOCABLE"
+	CodeStakingPoolNotFound = "STAKING_POOL_NOT_FOUND"
+	CodeInvalidStakingPool  = "INVALID_STAKING_POOL"
+	CodeStakeNotFound       = "STAKE_NOT_FOUND"
+	CodeInvalidStakeState   = "INVALID_STAKE_STATE"
+	CodeStillUnbonding      = "STILL_UNBONDING"
+	CodeNoRewards           = "NO_REWARDS"
+	CodeInsufficientRewards = "INSUFFICIENT_REWARDS"
+	CodeSnapshotNotFound    = "SNAPSHOT_NOT_FOUND"
+	CodeInternal            = "INTERNAL"
+
+	CodeDistributionNotFound   = "DISTRIBUTION_NOT_FOUND"
+	CodeDistributionNotPending = "DISTRIBUTION_NOT_PENDING"
+	CodeInvalidDistribution    = "INVALID_DISTRIBUTION"
+	CodeDistributionState      = "INVALID_DISTRIBUTION_STATE"
+	CodeAirdropNotFound        = "AIRDROP_NOT_FOUND"
+	CodeInvalidAirdrop         = "INVALID_AIRDROP"
+	CodeInvalidProof           = "INVALID_PROOF"
+	CodeAlreadyClaimed         = "ALREADY_CLAIMED"
+	CodeAirdropExpired         = "AIRDROP_EXPIRED"
+	CodeAirdropNotExpired      = "AIRDROP_NOT_EXPIRED"
+	CodeAirdropSwept           = "AIRDROP_SWEPT"
+	CodeRequestNotFound        = "PAYMENT_REQUEST_NOT_FOUND"
+	CodeRequestNotOpen         = "PAYMENT_REQUEST_NOT_OPEN"
+	CodeRequestExpired         = "PAYMENT_REQUEST_EXPIRED"
+	CodeReferenceExists        = "REFERENCE_EXISTS"
+)
+
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{wallets.ErrorInsufficientBalance, CodeInsufficientBalance},
+	{wallets.ErrorSameAddress, CodeSameAddress},
+	{wallets.ErrorSenderNotFound, CodeSenderNotFound},
+	{wallets.ErrorNonPositiveAmount, CodeInvalidAmount},
+	{tokens.ErrorTokenNotFound, CodeTokenNotFound},
+	{tokens.ErrorAmountPrecision, CodeInvalidAmount},
+	{tokens.ErrorInvalidBaseUnits, CodeInvalidAmount},
+	{fees.ErrorFeeExceedsAmount, CodeFeeExceedsAmount},
+	{auth.ErrorOperatorRequired, CodeUnauthorized},
+	{wallets.ErrorHoldNotFound, CodeHoldNotFound},
+	{wallets.ErrorHoldNotActive, CodeHoldNotActive},
+	{wallets.ErrorHoldExpired, CodeHoldExpired},
+	{wallets.ErrorCaptureExceedsHold, CodeInvalidAmount},
+	{wallets.ErrorInvalidExpiry, CodeInvalidExpiry},
+	{wallets.ErrorEscrowNotFound, CodeEscrowNotFound},
+	{wallets.ErrorInvalidEscrowTransition, CodeInvalidEscrowState},
+	{wallets.ErrorNotEscrowParty, CodeNotEscrowParty},
+	{wallets.ErrorNotEscrowArbiter, CodeNotEscrowParty},
+	{wallets.ErrorInvalidEscrowParties, CodeInvalidEscrow},
+	{wallets.ErrorInvalidDeadline, CodeInvalidEscrow},
+	{wallets.ErrorReservedAddress, CodeReservedAddress},
+	{wallets.ErrorDeadlineNotReached, CodeDeadlineNotReached},
+	{wallets.ErrorHtlcNotFound, CodeHtlcNotFound},
+	{wallets.ErrorHtlcNotLocked, CodeHtlcNotLocked},
+	{wallets.ErrorInvalidHashlock, CodeInvalidHashlock},
+	{wallets.ErrorInvalidPreimage, CodeInvalidPreimage},
+	{wallets.ErrorHtlcTimedOut, CodeHtlcTimedOut},
+	{wallets.ErrorHtlcNotTimedOut, CodeHtlcNotTimedOut},
+	{wallets.ErrorInvalidTimeout, CodeInvalidExpiry},
+	{wallets.ErrorScheduleNotFound, CodeScheduleNotFound},
+	{wallets.ErrorScheduleNotActive, CodeScheduleNotActive},
+	{wallets.ErrorInvalidSchedule, CodeInvalidSchedule},
+	{wallets.ErrorTransferNotFound, CodeTransferNotFound},
+	{wallets.ErrorAlreadyReversed, CodeAlreadyReversed},
+	{wallets.ErrorReversalExceedsTransfer, CodeInvalidAmount},
+	{wallets.ErrorReversalReasonRequired, CodeInvalidReversal},
+	{wallets.ErrorPayoutNotReversible, CodeInvalidReversal},
+	{wallets.ErrorReceiverBalanceTooLow, CodeInsufficientBalance},
+	{compliance.ErrorSenderFrozen, CodeWalletFrozen},
+	{compliance.ErrorReceiverFrozen, CodeWalletFrozen},
+	{compliance.ErrorAddressDenied, CodeAddressDenied},
+	{compliance.ErrorNotDenied, CodeNotDenied},
+	{compliance.ErrorInvalidStatus, CodeInvalidCompliance},
+	{compliance.ErrorReasonRequired, CodeInvalidCompliance},
+	{compliance.ErrorListRequired, CodeInvalidCompliance},
+	{limits.ErrorLimitExceeded, CodeLimitExceeded},
+	{limits.ErrorInvalidLimits, CodeInvalidLimits},
+	{limits.ErrorLimitsNotFound, CodeLimitsNotFound},
+	{policy.ErrorPolicyDenied, CodePolicyDenied},
+	{policy.ErrorPolicyFailed, CodePolicyError},
+	{policy.ErrorInvalidRule, CodeInvalidPolicyRule},
+	{policy.ErrorInvalidExpression, CodeInvalidPolicyRule},
+	{policy.ErrorRuleNotFound, CodePolicyRuleNotFound},
+	{alerts.ErrorAlertNotFound, CodeAlertNotFound},
+	{alerts.ErrorInvalidTransition, CodeInvalidAlertChange},
+	{alerts.ErrorNoteRequired, CodeInvalidAlertChange},
+	{alerts.ErrorInvalidDetector, CodeInvalidDetector},
+	{alerts.ErrorUnknownDetector, CodeInvalidDetector},
+	{wallets.ErrorMultisigRequired, CodeMultisigRequired},
+	{wallets.ErrorMultisigNotFound, CodeMultisigNotFound},
+	{wallets.ErrorMultisigExists, CodeInvalidMultisig},
+	{wallets.ErrorInvalidMultisig, CodeInvalidMultisig},
+	{wallets.ErrorInvalidProposalExpiry, CodeInvalidExpiry},
+	{wallets.ErrorProposalNotFound, CodeProposalNotFound},
+	{wallets.ErrorProposalNotPending, CodeProposalNotPending},
+	{wallets.ErrorNotSigner, CodeNotSigner},
+	{wallets.ErrorInvalidSignature, CodeInvalidSignature},
+	{wallets.ErrorSignatureUsed, CodeInvalidSignature},
+	{wallets.ErrorAlreadyApproved, CodeAlreadyApproved},
+	{wallets.ErrorThresholdNotMet, CodeThresholdNotMet},
+	{wallets.ErrorVestingGrantNotFound, CodeGrantNotFound},
+	{wallets.ErrorInvalidVestingGrant, CodeInvalidGrant},
+	{wallets.ErrorNothingToClaim, CodeNothingToClaim},
+	{wallets.ErrorGrantNotRevocable, CodeGrantNotRevocable},
+	{wallets.ErrorGrantRevoked, CodeGrantNotRevocable},
+	{wallets.ErrorStakingPoolNotFound, CodeStakingPoolNotFound},
+	{wallets.ErrorInvalidStakingPool, CodeInvalidStakingPool},
+	{wallets.ErrorStakeNotFound, CodeStakeNotFound},
+	{wallets.ErrorStakeNotStaked, CodeInvalidStakeState},
+	{wallets.ErrorStakeNotUnbonding, CodeInvalidStakeState},
+	{wallets.ErrorStillUnbonding, CodeStillUnbonding},
+	{wallets.ErrorNoRewards, CodeNoRewards},
+	{wallets.ErrorInsufficientRewards, CodeInsufficientRewards},
+	{snapshots.ErrorSnapshotNotFound, CodeSnapshotNotFound},
+	{wallets.ErrorDistributionNotFound, CodeDistributionNotFound},
+	{wallets.ErrorDistributionNotPending, CodeDistributionNotPending},
+	{wallets.ErrorInvalidDistribution, CodeInvalidDistribution},
+	{wallets.ErrorInvalidRecipientList, CodeInvalidDistribution},
+	{wallets.ErrorNoRecipients, CodeInvalidDistribution},
+	{wallets.ErrorDistributionNotStarted, CodeDistributionState},
+	{wallets.ErrorNoFailedRecipients, CodeDistributionState},
+	{wallets.ErrorDistributionNotCompleted, CodeDistributionState},
+	{wallets.ErrorAirdropNotFound, CodeAirdropNotFound},
+	{wallets.ErrorInvalidAirdropList, CodeInvalidAirdrop},
+	{wallets.ErrorInvalidAirdropProof, CodeInvalidProof},
+	{wallets.ErrorAirdropClaimed, CodeAlreadyClaimed},
+	{wallets.ErrorAirdropExpired, CodeAirdropExpired},
+	{wallets.ErrorAirdropNotExpired, CodeAirdropNotExpired},
+	{wallets.ErrorAirdropSwept, CodeAirdropSwept},
+	{wallets.ErrorPaymentRequestNotFound, CodeRequestNotFound},
+	{wallets.ErrorPaymentRequestNotOpen, CodeRequestNotOpen},
+	{wallets.ErrorPaymentRequestExpired, CodeRequestExpired},
+	{wallets.ErrorPaymentExceedsRequest, CodeInvalidAmount},
+	{wallets.ErrorReferenceExists, CodeReferenceExists},
+}
+
+// codedError replaces the message of an error while keeping its code.
+type codedError struct {
+	code string
+	msg  string
+}
+
+func (e *codedError) Error() string {
+	return e.msg
+}
+
+func coded(code string, msg string) error {
+	return &codedError{code: code, msg: msg}
+}
+
+// errorCode finds the code of err, CodeInternal when it has none.
+func errorCode(err error) string {
+	var c *codedError
+	if errors.As(err, &c) {
+		return c.code
+	}
+	for _, known := range errorCodes {
+		if errors.Is(err, known.err) {
+			return known.code
+		}
+	}
+	return CodeInternal
+}
+
+// failure returns errors with a known code as they are and wraps the others
+// with what failed.
+func failure(what string, err error) error {
+	if err == nil || errorCode(err) != CodeInternal {
+		return err
+	}
+	return fmt.Errorf("%s fail: %w", what, err)
+}
+
+// ErrorPresenter adds the error code to every error with a known code, the
+// limit and remaining allowance to LIMIT_EXCEEDED errors and the rule to
+// POLICY_DENIED errors.
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	presented := graphql.DefaultErrorPresenter(ctx, err)
+	if code := errorCode(err); code != CodeInternal {
+		if presented.Extensions == nil {
+			presented.Extensions = make(map[string]interface{})
+		}
+		presented.Extensions["code"] = code
+	}
+	var exceeded *limits.ExceededError
+	if errors.As(err, &exceeded) {
+		presented.Extensions["limit"] = exceeded.Limit
+		presented.Extensions["remaining"] = exceeded.Remaining.String()
+	}
+	var denied *policy.DeniedError
+	if errors.As(err, &denied) {
+		presented.Extensions["rule"] = denied.Rule
+		presented.Extensions["rule_version"] = denied.Version
+	}
+	return presented
+}
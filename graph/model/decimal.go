@@ -1,30 +1,74 @@
 package model
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"strconv"
+	"strings"
 
 	"github.com/shopspring/decimal"
 )
 
 type Decimal decimal.Decimal
 
+// MaxDecimalDigits bounds the digits of a decimal input, counted in its plain
+// (not exponent) notation, so a short input like "1e999999999" cannot make
+// the server build a huge number.
+const MaxDecimalDigits = 96
+
+// float64 keeps every decimal with at most 15 significant digits exact and
+// every integer up to 2^53
+const exactFloatDigits = 15
+const exactFloatInteger = 1 << 53
+
 func (d *Decimal) UnmarshalGQL(v interface{}) error {
-	var strVal string
-	
+	var dec decimal.Decimal
+
 	switch val := v.(type) {
 	case string:
-		strVal = val
+		parsed, err := parseDecimal(val)
+		if err != nil {
+			return err
+		}
+		dec = parsed
+	case json.Number:
+		parsed, err := parseDecimal(string(val))
+		if err != nil {
+			return err
+		}
+		dec = parsed
 	case int, int8, int16, int32, int64:
-        strVal = fmt.Sprint(val)
-    default:
-		return fmt.Errorf("decimal must be given as an int or a string, received %T", v)	
+		dec = decimal.NewFromInt(toInt64(val))
+	case uint, uint8, uint16, uint32, uint64, uintptr:
+		dec = decimal.NewFromBigInt(new(big.Int).SetUint64(toUint64(val)), 0)
+	case *big.Int:
+		if val == nil {
+			return fmt.Errorf("decimal must not be null")
+		}
+		dec = decimal.NewFromBigInt(val, 0)
+	case big.Int:
+		dec = decimal.NewFromBigInt(&val, 0)
+	case float32:
+		parsed, err := exactFloat(float64(val), 32)
+		if err != nil {
+			return err
+		}
+		dec = parsed
+	case float64:
+		parsed, err := exactFloat(val, 64)
+		if err != nil {
+			return err
+		}
+		dec = parsed
+	default:
+		return fmt.Errorf("decimal must be given as a number or a string, received %T", v)
 	}
 
-	dec, err := decimal.NewFromString(strVal)
-	if err != nil {
-		return fmt.Errorf("incorrect decimal format: %w", err)
+	if err := checkDigits(dec); err != nil {
+		return err
 	}
 
 	*d = Decimal(dec)
@@ -33,4 +77,101 @@ func (d *Decimal) UnmarshalGQL(v interface{}) error {
 
 func (d Decimal) MarshalGQL(w io.Writer) {
 	_, _ = io.WriteString(w, strconv.Quote(decimal.Decimal(d).String()))
-}
\ No newline at end of file
+}
+
+func parseDecimal(s string) (decimal.Decimal, error) {
+	// sign, point and exponent take a few characters on top of the digits
+	if len(s) > MaxDecimalDigits+8 {
+		return decimal.Decimal{}, fmt.Errorf("decimal must have at most %d digits", MaxDecimalDigits)
+	}
+
+	dec, err := decimal.NewFromString(s)
+	if err != nil {
+		return decimal.Decimal{}, fmt.Errorf("incorrect decimal format: %w", err)
+	}
+	return dec, nil
+}
+
+// checkDigits counts the digits dec has when written out in plain notation.
+func checkDigits(dec decimal.Decimal) error {
+	exp := dec.Exponent()
+	if exp > MaxDecimalDigits || exp < -MaxDecimalDigits {
+		return fmt.Errorf("decimal must have at most %d digits", MaxDecimalDigits)
+	}
+
+	digits := len(strings.TrimPrefix(dec.Coefficient().String(), "-"))
+	if exp > 0 {
+		digits += int(exp)
+	} else if int(-exp) > digits {
+		// leading zeros after the decimal point, e.g. 0.001
+		digits = int(-exp)
+	}
+
+	if digits > MaxDecimalDigits {
+		return fmt.Errorf("decimal must have at most %d digits", MaxDecimalDigits)
+	}
+	return nil
+}
+
+// exactFloat accepts a float only when it certainly holds the number the
+// client wrote: integers up to 2^53 and decimals short enough to survive the
+// conversion to binary. Anything longer has to be sent as a string.
+func exactFloat(f float64, bits int) (decimal.Decimal, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return decimal.Decimal{}, fmt.Errorf("decimal must be a finite number")
+	}
+
+	maxDigits, maxInteger := exactFloatDigits, float64(exactFloatInteger)
+	if bits == 32 {
+		maxDigits, maxInteger = 6, 1<<24
+	}
+
+	if f == math.Trunc(f) {
+		if math.Abs(f) > maxInteger {
+			return decimal.Decimal{}, fmt.Errorf("decimal %s is too large to be sent as a float, send it as a string", strconv.FormatFloat(f, 'f', -1, bits))
+		}
+		return decimal.NewFromInt(int64(f)), nil
+	}
+
+	// shortest representation that reads back as the same float
+	dec, err := decimal.NewFromString(strconv.FormatFloat(f, 'g', -1, bits))
+	if err != nil {
+		return decimal.Decimal{}, fmt.Errorf("incorrect decimal format: %w", err)
+	}
+
+	significant := strings.TrimRight(strings.TrimPrefix(dec.Coefficient().String(), "-"), "0")
+	if len(significant) > maxDigits {
+		return decimal.Decimal{}, fmt.Errorf("decimal %s cannot be represented exactly as a float, send it as a string", dec.String())
+	}
+	return dec, nil
+}
+
+func toInt64(v interface{}) int64 {
+	switch val := v.(type) {
+	case int:
+		return int64(val)
+	case int8:
+		return int64(val)
+	case int16:
+		return int64(val)
+	case int32:
+		return int64(val)
+	}
+	return v.(int64)
+}
+
+func toUint64(v interface{}) uint64 {
+	switch val := v.(type) {
+	case uint:
+		return uint64(val)
+	case uint8:
+		return uint64(val)
+	case uint16:
+		return uint64(val)
+	case uint32:
+		return uint64(val)
+	case uintptr:
+		return uint64(val)
+	}
+	return v.(uint64)
+}
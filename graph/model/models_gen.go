@@ -2,19 +2,1699 @@
 
 package model
 
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+type AccountBalance struct {
+	Token   string  `json:"token"`
+	Account string  `json:"account"`
+	Debit   Decimal `json:"debit"`
+	Credit  Decimal `json:"credit"`
+}
+
+type AddressStatus struct {
+	Address   string       `json:"address"`
+	Status    WalletStatus `json:"status"`
+	Reason    *string      `json:"reason,omitempty"`
+	UpdatedBy *string      `json:"updated_by,omitempty"`
+	UpdatedAt *time.Time   `json:"updated_at,omitempty"`
+	DenyList  *string      `json:"deny_list,omitempty"`
+}
+
+type Airdrop struct {
+	ID         string     `json:"id"`
+	Token      string     `json:"token"`
+	Funder     string     `json:"funder"`
+	Root       string     `json:"root"`
+	Total      Decimal    `json:"total"`
+	LeafCount  int32      `json:"leaf_count"`
+	Claimed    Decimal    `json:"claimed"`
+	ClaimCount int32      `json:"claim_count"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	Swept      Decimal    `json:"swept"`
+	SweptAt    *time.Time `json:"swept_at,omitempty"`
+	Operator   string     `json:"operator"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type AirdropClaim struct {
+	AirdropID  string             `json:"airdrop_id"`
+	Address    string             `json:"address"`
+	Amount     Decimal            `json:"amount"`
+	Root       string             `json:"root"`
+	Proof      []*MerkleProofStep `json:"proof"`
+	ClaimedAt  *time.Time         `json:"claimed_at,omitempty"`
+	TransferID *string            `json:"transfer_id,omitempty"`
+}
+
+type Alert struct {
+	ID                  string        `json:"id"`
+	Detector            string        `json:"detector"`
+	Severity            AlertSeverity `json:"severity"`
+	Status              AlertStatus   `json:"status"`
+	Address             string        `json:"address"`
+	Token               string        `json:"token"`
+	TransferID          string        `json:"transfer_id"`
+	Evidence            string        `json:"evidence"`
+	EvidenceTransferIds []string      `json:"evidence_transfer_ids"`
+	CreatedAt           time.Time     `json:"created_at"`
+	UpdatedAt           time.Time     `json:"updated_at"`
+}
+
+type AlertDetector struct {
+	Name       string            `json:"name"`
+	Enabled    bool              `json:"enabled"`
+	Severity   AlertSeverity     `json:"severity"`
+	Thresholds []*AlertThreshold `json:"thresholds"`
+	UpdatedBy  *string           `json:"updated_by,omitempty"`
+	UpdatedAt  *time.Time        `json:"updated_at,omitempty"`
+}
+
+type AlertDetectorInput struct {
+	Name       string                 `json:"name"`
+	Enabled    *bool                  `json:"enabled,omitempty"`
+	Severity   *AlertSeverity         `json:"severity,omitempty"`
+	Thresholds []*AlertThresholdInput `json:"thresholds,omitempty"`
+}
+
+type AlertEvent struct {
+	Action    string      `json:"action"`
+	Status    AlertStatus `json:"status"`
+	Note      *string     `json:"note,omitempty"`
+	Operator  string      `json:"operator"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+type AlertThreshold struct {
+	Name  string  `json:"name"`
+	Value Decimal `json:"value"`
+}
+
+type AlertThresholdInput struct {
+	Name  string  `json:"name"`
+	Value Decimal `json:"value"`
+}
+
+type BalanceMismatch struct {
+	Address       string  `json:"address"`
+	Token         string  `json:"token"`
+	Balance       Decimal `json:"balance"`
+	LedgerBalance Decimal `json:"ledger_balance"`
+}
+
+type BalanceProof struct {
+	SnapshotID string             `json:"snapshot_id"`
+	Address    string             `json:"address"`
+	Token      string             `json:"token"`
+	Balance    Decimal            `json:"balance"`
+	Root       string             `json:"root"`
+	Proof      []*MerkleProofStep `json:"proof"`
+}
+
+type Burn struct {
+	FromAddress string      `json:"from_address"`
+	Amount      Decimal     `json:"amount"`
+	Unit        *AmountUnit `json:"unit,omitempty"`
+	Token       *string     `json:"token,omitempty"`
+}
+
+type ClaimAirdrop struct {
+	AirdropID string                  `json:"airdrop_id"`
+	Address   string                  `json:"address"`
+	Amount    Decimal                 `json:"amount"`
+	Proof     []*MerkleProofStepInput `json:"proof"`
+}
+
+type ComplianceEvent struct {
+	Action    string        `json:"action"`
+	Status    *WalletStatus `json:"status,omitempty"`
+	List      *string       `json:"list,omitempty"`
+	Reason    string        `json:"reason"`
+	Operator  string        `json:"operator"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+type Distribution struct {
+	ID             string             `json:"id"`
+	Token          string             `json:"token"`
+	FromAddress    string             `json:"from_address"`
+	Total          Decimal            `json:"total"`
+	SnapshotID     *string            `json:"snapshot_id,omitempty"`
+	WeightToken    *string            `json:"weight_token,omitempty"`
+	Status         DistributionStatus `json:"status"`
+	Operator       string             `json:"operator"`
+	RecipientCount int32              `json:"recipient_count"`
+	PaidCount      int32              `json:"paid_count"`
+	FailedCount    int32              `json:"failed_count"`
+	PaidAmount     Decimal            `json:"paid_amount"`
+	Returned       Decimal            `json:"returned"`
+	StartedAt      *time.Time         `json:"started_at,omitempty"`
+	CompletedAt    *time.Time         `json:"completed_at,omitempty"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+}
+
+type DistributionRecipient struct {
+	Address    string                      `json:"address"`
+	Weight     Decimal                     `json:"weight"`
+	Amount     Decimal                     `json:"amount"`
+	Status     DistributionRecipientStatus `json:"status"`
+	TransferID *string                     `json:"transfer_id,omitempty"`
+	Error      *string                     `json:"error,omitempty"`
+	UpdatedAt  time.Time                   `json:"updated_at"`
+}
+
+type Escrow struct {
+	ID             string       `json:"id"`
+	Token          string       `json:"token"`
+	Buyer          string       `json:"buyer"`
+	Seller         string       `json:"seller"`
+	Arbiter        string       `json:"arbiter"`
+	Amount         Decimal      `json:"amount"`
+	Status         EscrowStatus `json:"status"`
+	BuyerApproved  bool         `json:"buyer_approved"`
+	SellerApproved bool         `json:"seller_approved"`
+	Reference      *string      `json:"reference,omitempty"`
+	Deadline       time.Time    `json:"deadline"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+type EscrowEvent struct {
+	Action     string        `json:"action"`
+	FromStatus *EscrowStatus `json:"from_status,omitempty"`
+	ToStatus   EscrowStatus  `json:"to_status"`
+	Actor      *string       `json:"actor,omitempty"`
+	Note       *string       `json:"note,omitempty"`
+	TransferID *string       `json:"transfer_id,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+type FeePolicy struct {
+	Token           string     `json:"token"`
+	TreasuryAddress string     `json:"treasury_address"`
+	Flat            Decimal    `json:"flat"`
+	BasisPoints     int32      `json:"basis_points"`
+	MinFee          *Decimal   `json:"min_fee,omitempty"`
+	MaxFee          *Decimal   `json:"max_fee,omitempty"`
+	Tiers           []*FeeTier `json:"tiers"`
+}
+
+type FeePolicyInput struct {
+	Token           *string         `json:"token,omitempty"`
+	TreasuryAddress string          `json:"treasury_address"`
+	Flat            *Decimal        `json:"flat,omitempty"`
+	BasisPoints     *int32          `json:"basis_points,omitempty"`
+	MinFee          *Decimal        `json:"min_fee,omitempty"`
+	MaxFee          *Decimal        `json:"max_fee,omitempty"`
+	Tiers           []*FeeTierInput `json:"tiers,omitempty"`
+}
+
+type FeeTier struct {
+	From        Decimal `json:"from"`
+	Flat        Decimal `json:"flat"`
+	BasisPoints int32   `json:"basis_points"`
+}
+
+type FeeTierInput struct {
+	From        Decimal  `json:"from"`
+	Flat        *Decimal `json:"flat,omitempty"`
+	BasisPoints *int32   `json:"basis_points,omitempty"`
+}
+
+type Hold struct {
+	ID             string     `json:"id"`
+	Address        string     `json:"address"`
+	Token          string     `json:"token"`
+	Payee          string     `json:"payee"`
+	Amount         Decimal    `json:"amount"`
+	CapturedAmount Decimal    `json:"captured_amount"`
+	Reference      *string    `json:"reference,omitempty"`
+	Status         HoldStatus `json:"status"`
+	TransferID     *string    `json:"transfer_id,omitempty"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+type Htlc struct {
+	ID         string     `json:"id"`
+	Token      string     `json:"token"`
+	Sender     string     `json:"sender"`
+	Recipient  string     `json:"recipient"`
+	Amount     Decimal    `json:"amount"`
+	Hashlock   string     `json:"hashlock"`
+	Preimage   *string    `json:"preimage,omitempty"`
+	Status     HtlcStatus `json:"status"`
+	TransferID *string    `json:"transfer_id,omitempty"`
+	TimeoutAt  time.Time  `json:"timeout_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type LedgerCheckpoint struct {
+	ID        string    `json:"id"`
+	EntryID   string    `json:"entry_id"`
+	Hash      string    `json:"hash"`
+	Signature string    `json:"signature"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type LedgerVerification struct {
+	Valid              bool    `json:"valid"`
+	EntriesChecked     int32   `json:"entries_checked"`
+	CheckpointsChecked int32   `json:"checkpoints_checked"`
+	HeadHash           string  `json:"head_hash"`
+	BrokenEntryID      *string `json:"broken_entry_id,omitempty"`
+	BrokenCheckpointID *string `json:"broken_checkpoint_id,omitempty"`
+	Reason             *string `json:"reason,omitempty"`
+}
+
+type MerkleProofStep struct {
+	Hash string     `json:"hash"`
+	Side MerkleSide `json:"side"`
+}
+
+type MerkleProofStepInput struct {
+	Hash string     `json:"hash"`
+	Side MerkleSide `json:"side"`
+}
+
+type Mint struct {
+	ToAddress string      `json:"to_address"`
+	Amount    Decimal     `json:"amount"`
+	Unit      *AmountUnit `json:"unit,omitempty"`
+	Token     *string     `json:"token,omitempty"`
+}
+
+type MultisigApproval struct {
+	Signer    string    `json:"signer"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type MultisigProposal struct {
+	ID              string                 `json:"id"`
+	Wallet          string                 `json:"wallet"`
+	Token           string                 `json:"token"`
+	ToAddress       string                 `json:"to_address"`
+	Amount          Decimal                `json:"amount"`
+	Proposer        string                 `json:"proposer"`
+	Status          MultisigProposalStatus `json:"status"`
+	Threshold       int32                  `json:"threshold"`
+	Approvals       []*MultisigApproval    `json:"approvals"`
+	ApprovalMessage string                 `json:"approval_message"`
+	CancelMessage   string                 `json:"cancel_message"`
+	TransferID      *string                `json:"transfer_id,omitempty"`
+	Error           *string                `json:"error,omitempty"`
+	ExpiresAt       time.Time              `json:"expires_at"`
+	CreatedAt       time.Time              `json:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at"`
+}
+
+type MultisigSigner struct {
+	Address   string `json:"address"`
+	PublicKey string `json:"public_key"`
+}
+
+type MultisigSignerInput struct {
+	Address   string `json:"address"`
+	PublicKey string `json:"public_key"`
+}
+
+type MultisigWallet struct {
+	Address   string            `json:"address"`
+	Threshold int32             `json:"threshold"`
+	Signers   []*MultisigSigner `json:"signers"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
 type Mutation struct {
 }
 
+type NewAirdrop struct {
+	Funder     string    `json:"funder"`
+	Token      *string   `json:"token,omitempty"`
+	Recipients string    `json:"recipients"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type NewDistribution struct {
+	FromAddress string      `json:"from_address"`
+	Total       Decimal     `json:"total"`
+	Unit        *AmountUnit `json:"unit,omitempty"`
+	Token       *string     `json:"token,omitempty"`
+	SnapshotID  *string     `json:"snapshot_id,omitempty"`
+	WeightToken *string     `json:"weight_token,omitempty"`
+	Recipients  *string     `json:"recipients,omitempty"`
+}
+
+type NewEscrow struct {
+	Buyer     string      `json:"buyer"`
+	Seller    string      `json:"seller"`
+	Arbiter   string      `json:"arbiter"`
+	Amount    Decimal     `json:"amount"`
+	Unit      *AmountUnit `json:"unit,omitempty"`
+	Token     *string     `json:"token,omitempty"`
+	Deadline  time.Time   `json:"deadline"`
+	Reference *string     `json:"reference,omitempty"`
+}
+
+type NewHold struct {
+	Address   string      `json:"address"`
+	Payee     string      `json:"payee"`
+	Amount    Decimal     `json:"amount"`
+	Unit      *AmountUnit `json:"unit,omitempty"`
+	Token     *string     `json:"token,omitempty"`
+	Reference *string     `json:"reference,omitempty"`
+	ExpiresAt *time.Time  `json:"expires_at,omitempty"`
+}
+
+type NewHtlc struct {
+	Sender    string      `json:"sender"`
+	Recipient string      `json:"recipient"`
+	Amount    Decimal     `json:"amount"`
+	Unit      *AmountUnit `json:"unit,omitempty"`
+	Token     *string     `json:"token,omitempty"`
+	Hashlock  string      `json:"hashlock"`
+	TimeoutAt time.Time   `json:"timeout_at"`
+}
+
+type NewMultisigProposal struct {
+	Wallet    string      `json:"wallet"`
+	ToAddress string      `json:"to_address"`
+	Amount    Decimal     `json:"amount"`
+	Unit      *AmountUnit `json:"unit,omitempty"`
+	Token     *string     `json:"token,omitempty"`
+	Proposer  string      `json:"proposer"`
+	ExpiresAt time.Time   `json:"expires_at"`
+	Signature string      `json:"signature"`
+}
+
+type NewMultisigWallet struct {
+	Address   string                 `json:"address"`
+	Threshold int32                  `json:"threshold"`
+	Signers   []*MultisigSignerInput `json:"signers"`
+}
+
+type NewPaymentRequest struct {
+	Payee     string      `json:"payee"`
+	Amount    Decimal     `json:"amount"`
+	Unit      *AmountUnit `json:"unit,omitempty"`
+	Token     *string     `json:"token,omitempty"`
+	Memo      *string     `json:"memo,omitempty"`
+	Reference *string     `json:"reference,omitempty"`
+	ExpiresAt *time.Time  `json:"expires_at,omitempty"`
+}
+
+type NewScheduledTransfer struct {
+	FromAddress     string      `json:"from_address"`
+	ToAddress       string      `json:"to_address"`
+	Amount          Decimal     `json:"amount"`
+	Unit            *AmountUnit `json:"unit,omitempty"`
+	Token           *string     `json:"token,omitempty"`
+	RunAt           *time.Time  `json:"run_at,omitempty"`
+	Cron            *string     `json:"cron,omitempty"`
+	IntervalSeconds *int32      `json:"interval_seconds,omitempty"`
+	EndsAt          *time.Time  `json:"ends_at,omitempty"`
+	MaxRuns         *int32      `json:"max_runs,omitempty"`
+}
+
+type NewStake struct {
+	Address string      `json:"address"`
+	Amount  Decimal     `json:"amount"`
+	Unit    *AmountUnit `json:"unit,omitempty"`
+	Token   *string     `json:"token,omitempty"`
+}
+
+type NewToken struct {
+	Symbol    string   `json:"symbol"`
+	Name      string   `json:"name"`
+	Decimals  int32    `json:"decimals"`
+	SupplyCap *Decimal `json:"supply_cap,omitempty"`
+	Issuer    *string  `json:"issuer,omitempty"`
+}
+
+type NewVestingGrant struct {
+	Funder          string      `json:"funder"`
+	Beneficiary     string      `json:"beneficiary"`
+	Total           Decimal     `json:"total"`
+	Unit            *AmountUnit `json:"unit,omitempty"`
+	Token           *string     `json:"token,omitempty"`
+	StartAt         *time.Time  `json:"start_at,omitempty"`
+	CliffSeconds    *int32      `json:"cliff_seconds,omitempty"`
+	DurationSeconds int32       `json:"duration_seconds"`
+	Revocable       *bool       `json:"revocable,omitempty"`
+}
+
+type Payment struct {
+	Payer      string    `json:"payer"`
+	Amount     Decimal   `json:"amount"`
+	TransferID string    `json:"transfer_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type PaymentRequest struct {
+	ID          string               `json:"id"`
+	Token       string               `json:"token"`
+	Payee       string               `json:"payee"`
+	Amount      Decimal              `json:"amount"`
+	PaidAmount  Decimal              `json:"paid_amount"`
+	Outstanding Decimal              `json:"outstanding"`
+	Memo        *string              `json:"memo,omitempty"`
+	Reference   string               `json:"reference"`
+	Status      PaymentRequestStatus `json:"status"`
+	ExpiresAt   time.Time            `json:"expires_at"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+}
+
+type PolicyAttribute struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type PolicyEvaluation struct {
+	Action     PolicyAction        `json:"action"`
+	Rule       *PolicyRule         `json:"rule,omitempty"`
+	Flags      []*PolicyRule       `json:"flags"`
+	Results    []*PolicyRuleResult `json:"results"`
+	Attributes []*PolicyAttribute  `json:"attributes"`
+}
+
+type PolicyFlag struct {
+	TransferID string      `json:"transfer_id"`
+	Rule       *PolicyRule `json:"rule"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+type PolicyRule struct {
+	ID         string       `json:"id"`
+	Name       string       `json:"name"`
+	Version    int32        `json:"version"`
+	Expression string       `json:"expression"`
+	Action     PolicyAction `json:"action"`
+	Priority   int32        `json:"priority"`
+	Message    *string      `json:"message,omitempty"`
+	Enabled    bool         `json:"enabled"`
+	Operator   string       `json:"operator"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+type PolicyRuleInput struct {
+	Name       string       `json:"name"`
+	Expression string       `json:"expression"`
+	Action     PolicyAction `json:"action"`
+	Priority   *int32       `json:"priority,omitempty"`
+	Message    *string      `json:"message,omitempty"`
+	Enabled    *bool        `json:"enabled,omitempty"`
+}
+
+type PolicyRuleResult struct {
+	Rule    *PolicyRule `json:"rule"`
+	Matched bool        `json:"matched"`
+	Error   *string     `json:"error,omitempty"`
+}
+
 type Query struct {
 }
 
+type ScheduleRun struct {
+	ID           string            `json:"id"`
+	ScheduledFor time.Time         `json:"scheduled_for"`
+	Status       ScheduleRunStatus `json:"status"`
+	TransferID   *string           `json:"transfer_id,omitempty"`
+	Error        *string           `json:"error,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+}
+
+type ScheduledTransfer struct {
+	ID              string         `json:"id"`
+	Token           string         `json:"token"`
+	FromAddress     string         `json:"from_address"`
+	ToAddress       string         `json:"to_address"`
+	Amount          Decimal        `json:"amount"`
+	Cron            *string        `json:"cron,omitempty"`
+	IntervalSeconds *int32         `json:"interval_seconds,omitempty"`
+	EndsAt          *time.Time     `json:"ends_at,omitempty"`
+	MaxRuns         *int32         `json:"max_runs,omitempty"`
+	RunCount        int32          `json:"run_count"`
+	NextRunAt       *time.Time     `json:"next_run_at,omitempty"`
+	Status          ScheduleStatus `json:"status"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+}
+
+type Snapshot struct {
+	ID          string          `json:"id"`
+	MerkleRoot  string          `json:"merkle_root"`
+	WalletCount int32           `json:"wallet_count"`
+	Roots       []*SnapshotRoot `json:"roots"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+type SnapshotRoot struct {
+	Token       string `json:"token"`
+	MerkleRoot  string `json:"merkle_root"`
+	WalletCount int32  `json:"wallet_count"`
+}
+
+type Stake struct {
+	ID             string      `json:"id"`
+	Token          string      `json:"token"`
+	Address        string      `json:"address"`
+	Amount         Decimal     `json:"amount"`
+	Status         StakeStatus `json:"status"`
+	PendingRewards Decimal     `json:"pending_rewards"`
+	ClaimedRewards Decimal     `json:"claimed_rewards"`
+	UnbondsAt      *time.Time  `json:"unbonds_at,omitempty"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+}
+
+type StakingPool struct {
+	Token            string    `json:"token"`
+	Apr              *Decimal  `json:"apr,omitempty"`
+	RewardRate       *Decimal  `json:"reward_rate,omitempty"`
+	UnbondingSeconds int32     `json:"unbonding_seconds"`
+	TotalStaked      Decimal   `json:"total_staked"`
+	RewardsAvailable Decimal   `json:"rewards_available"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+type StakingPoolInput struct {
+	Token            *string  `json:"token,omitempty"`
+	Apr              *Decimal `json:"apr,omitempty"`
+	RewardRate       *Decimal `json:"reward_rate,omitempty"`
+	UnbondingSeconds *int32   `json:"unbonding_seconds,omitempty"`
+}
+
+type TierLimits struct {
+	Tier        string    `json:"tier"`
+	Token       string    `json:"token"`
+	PerTransfer *Decimal  `json:"per_transfer,omitempty"`
+	Daily       *Decimal  `json:"daily,omitempty"`
+	Monthly     *Decimal  `json:"monthly,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type TierLimitsInput struct {
+	Tier        string   `json:"tier"`
+	Token       *string  `json:"token,omitempty"`
+	PerTransfer *Decimal `json:"per_transfer,omitempty"`
+	Daily       *Decimal `json:"daily,omitempty"`
+	Monthly     *Decimal `json:"monthly,omitempty"`
+}
+
+type Token struct {
+	Symbol    string   `json:"symbol"`
+	Name      string   `json:"name"`
+	Decimals  int32    `json:"decimals"`
+	SupplyCap *Decimal `json:"supply_cap,omitempty"`
+	Issuer    *string  `json:"issuer,omitempty"`
+}
+
 type Transfer struct {
-	FromAddress string  `json:"from_address"`
-	ToAddress   string  `json:"to_address"`
-	Amount      Decimal `json:"amount"`
+	FromAddress string      `json:"from_address"`
+	ToAddress   string      `json:"to_address"`
+	Amount      Decimal     `json:"amount"`
+	Unit        *AmountUnit `json:"unit,omitempty"`
+	Token       *string     `json:"token,omitempty"`
+}
+
+type TransferQuote struct {
+	Token           string  `json:"token"`
+	Amount          Decimal `json:"amount"`
+	Fee             Decimal `json:"fee"`
+	NetAmount       Decimal `json:"net_amount"`
+	TreasuryAddress *string `json:"treasury_address,omitempty"`
+}
+
+type TransferReceipt struct {
+	ID            string    `json:"id"`
+	Token         string    `json:"token"`
+	FromAddress   string    `json:"from_address"`
+	ToAddress     string    `json:"to_address"`
+	Amount        Decimal   `json:"amount"`
+	Fee           Decimal   `json:"fee"`
+	NetAmount     Decimal   `json:"net_amount"`
+	SenderBalance *Decimal  `json:"sender_balance,omitempty"`
+	ReversalOf    *string   `json:"reversal_of,omitempty"`
+	ReversedBy    *string   `json:"reversed_by,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type TransferReversal struct {
+	ID         string    `json:"id"`
+	TransferID string    `json:"transfer_id"`
+	ReversalID string    `json:"reversal_id"`
+	Amount     Decimal   `json:"amount"`
+	Reason     string    `json:"reason"`
+	Operator   string    `json:"operator"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type TransferSimulation struct {
+	Ok              bool     `json:"ok"`
+	ErrorCode       *string  `json:"error_code,omitempty"`
+	Error           *string  `json:"error,omitempty"`
+	Token           string   `json:"token"`
+	Amount          *Decimal `json:"amount,omitempty"`
+	Fee             *Decimal `json:"fee,omitempty"`
+	NetAmount       *Decimal `json:"net_amount,omitempty"`
+	SenderBalance   *Decimal `json:"sender_balance,omitempty"`
+	ReceiverBalance *Decimal `json:"receiver_balance,omitempty"`
+}
+
+type TrialBalance struct {
+	TotalDebit  Decimal           `json:"total_debit"`
+	TotalCredit Decimal           `json:"total_credit"`
+	Balanced    bool              `json:"balanced"`
+	Accounts    []*AccountBalance `json:"accounts"`
+}
+
+type VestingGrant struct {
+	ID          string     `json:"id"`
+	Token       string     `json:"token"`
+	Funder      string     `json:"funder"`
+	Beneficiary string     `json:"beneficiary"`
+	Total       Decimal    `json:"total"`
+	StartAt     time.Time  `json:"start_at"`
+	CliffAt     time.Time  `json:"cliff_at"`
+	EndAt       time.Time  `json:"end_at"`
+	Revocable   bool       `json:"revocable"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	Returned    Decimal    `json:"returned"`
+	At          time.Time  `json:"at"`
+	Vested      Decimal    `json:"vested"`
+	Unvested    Decimal    `json:"unvested"`
+	Claimed     Decimal    `json:"claimed"`
+	Claimable   Decimal    `json:"claimable"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
 }
 
 type Wallet struct {
-	Address string  `json:"address"`
-	Balance Decimal `json:"balance"`
+	Address          string  `json:"address"`
+	Token            string  `json:"token"`
+	Balance          Decimal `json:"balance"`
+	HeldBalance      Decimal `json:"held_balance"`
+	StakedBalance    Decimal `json:"staked_balance"`
+	AvailableBalance Decimal `json:"available_balance"`
+}
+
+type WalletLimitUsage struct {
+	Address          string   `json:"address"`
+	Token            string   `json:"token"`
+	Tier             string   `json:"tier"`
+	PerTransferLimit *Decimal `json:"per_transfer_limit,omitempty"`
+	DailyLimit       *Decimal `json:"daily_limit,omitempty"`
+	DailyUsed        Decimal  `json:"daily_used"`
+	MonthlyLimit     *Decimal `json:"monthly_limit,omitempty"`
+	MonthlyUsed      Decimal  `json:"monthly_used"`
+	Remaining        *Decimal `json:"remaining,omitempty"`
+}
+
+type AlertSeverity string
+
+const (
+	AlertSeverityLow      AlertSeverity = "LOW"
+	AlertSeverityMedium   AlertSeverity = "MEDIUM"
+	AlertSeverityHigh     AlertSeverity = "HIGH"
+	AlertSeverityCritical AlertSeverity = "CRITICAL"
+)
+
+var AllAlertSeverity = []AlertSeverity{
+	AlertSeverityLow,
+	AlertSeverityMedium,
+	AlertSeverityHigh,
+	AlertSeverityCritical,
+}
+
+func (e AlertSeverity) IsValid() bool {
+	switch e {
+	case AlertSeverityLow, AlertSeverityMedium, AlertSeverityHigh, AlertSeverityCritical:
+		return true
+	}
+	return false
+}
+
+func (e AlertSeverity) String() string {
+	return string(e)
+}
+
+func (e *AlertSeverity) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = AlertSeverity(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid AlertSeverity", str)
+	}
+	return nil
+}
+
+func (e AlertSeverity) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *AlertSeverity) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e AlertSeverity) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type AlertStatus string
+
+const (
+	AlertStatusOpen         AlertStatus = "OPEN"
+	AlertStatusAcknowledged AlertStatus = "ACKNOWLEDGED"
+	AlertStatusEscalated    AlertStatus = "ESCALATED"
+	AlertStatusClosed       AlertStatus = "CLOSED"
+)
+
+var AllAlertStatus = []AlertStatus{
+	AlertStatusOpen,
+	AlertStatusAcknowledged,
+	AlertStatusEscalated,
+	AlertStatusClosed,
+}
+
+func (e AlertStatus) IsValid() bool {
+	switch e {
+	case AlertStatusOpen, AlertStatusAcknowledged, AlertStatusEscalated, AlertStatusClosed:
+		return true
+	}
+	return false
+}
+
+func (e AlertStatus) String() string {
+	return string(e)
+}
+
+func (e *AlertStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = AlertStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid AlertStatus", str)
+	}
+	return nil
+}
+
+func (e AlertStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *AlertStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e AlertStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type AmountUnit string
+
+const (
+	AmountUnitToken AmountUnit = "TOKEN"
+	AmountUnitBase  AmountUnit = "BASE"
+)
+
+var AllAmountUnit = []AmountUnit{
+	AmountUnitToken,
+	AmountUnitBase,
+}
+
+func (e AmountUnit) IsValid() bool {
+	switch e {
+	case AmountUnitToken, AmountUnitBase:
+		return true
+	}
+	return false
+}
+
+func (e AmountUnit) String() string {
+	return string(e)
+}
+
+func (e *AmountUnit) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = AmountUnit(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid AmountUnit", str)
+	}
+	return nil
+}
+
+func (e AmountUnit) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *AmountUnit) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e AmountUnit) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type DistributionRecipientStatus string
+
+const (
+	DistributionRecipientStatusPending DistributionRecipientStatus = "PENDING"
+	DistributionRecipientStatusPaid    DistributionRecipientStatus = "PAID"
+	DistributionRecipientStatusFailed  DistributionRecipientStatus = "FAILED"
+)
+
+var AllDistributionRecipientStatus = []DistributionRecipientStatus{
+	DistributionRecipientStatusPending,
+	DistributionRecipientStatusPaid,
+	DistributionRecipientStatusFailed,
+}
+
+func (e DistributionRecipientStatus) IsValid() bool {
+	switch e {
+	case DistributionRecipientStatusPending, DistributionRecipientStatusPaid, DistributionRecipientStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e DistributionRecipientStatus) String() string {
+	return string(e)
+}
+
+func (e *DistributionRecipientStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = DistributionRecipientStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid DistributionRecipientStatus", str)
+	}
+	return nil
+}
+
+func (e DistributionRecipientStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *DistributionRecipientStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e DistributionRecipientStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type DistributionStatus string
+
+const (
+	DistributionStatusPending   DistributionStatus = "PENDING"
+	DistributionStatusRunning   DistributionStatus = "RUNNING"
+	DistributionStatusCompleted DistributionStatus = "COMPLETED"
+	DistributionStatusClosed    DistributionStatus = "CLOSED"
+)
+
+var AllDistributionStatus = []DistributionStatus{
+	DistributionStatusPending,
+	DistributionStatusRunning,
+	DistributionStatusCompleted,
+	DistributionStatusClosed,
+}
+
+func (e DistributionStatus) IsValid() bool {
+	switch e {
+	case DistributionStatusPending, DistributionStatusRunning, DistributionStatusCompleted, DistributionStatusClosed:
+		return true
+	}
+	return false
+}
+
+func (e DistributionStatus) String() string {
+	return string(e)
+}
+
+func (e *DistributionStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = DistributionStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid DistributionStatus", str)
+	}
+	return nil
+}
+
+func (e DistributionStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *DistributionStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e DistributionStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type EscrowOutcome string
+
+const (
+	EscrowOutcomeRelease EscrowOutcome = "RELEASE"
+	EscrowOutcomeRefund  EscrowOutcome = "REFUND"
+)
+
+var AllEscrowOutcome = []EscrowOutcome{
+	EscrowOutcomeRelease,
+	EscrowOutcomeRefund,
+}
+
+func (e EscrowOutcome) IsValid() bool {
+	switch e {
+	case EscrowOutcomeRelease, EscrowOutcomeRefund:
+		return true
+	}
+	return false
+}
+
+func (e EscrowOutcome) String() string {
+	return string(e)
+}
+
+func (e *EscrowOutcome) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = EscrowOutcome(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid EscrowOutcome", str)
+	}
+	return nil
+}
+
+func (e EscrowOutcome) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *EscrowOutcome) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e EscrowOutcome) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type EscrowStatus string
+
+const (
+	EscrowStatusFunded   EscrowStatus = "FUNDED"
+	EscrowStatusApproved EscrowStatus = "APPROVED"
+	EscrowStatusDisputed EscrowStatus = "DISPUTED"
+	EscrowStatusReleased EscrowStatus = "RELEASED"
+	EscrowStatusRefunded EscrowStatus = "REFUNDED"
+)
+
+var AllEscrowStatus = []EscrowStatus{
+	EscrowStatusFunded,
+	EscrowStatusApproved,
+	EscrowStatusDisputed,
+	EscrowStatusReleased,
+	EscrowStatusRefunded,
+}
+
+func (e EscrowStatus) IsValid() bool {
+	switch e {
+	case EscrowStatusFunded, EscrowStatusApproved, EscrowStatusDisputed, EscrowStatusReleased, EscrowStatusRefunded:
+		return true
+	}
+	return false
+}
+
+func (e EscrowStatus) String() string {
+	return string(e)
+}
+
+func (e *EscrowStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = EscrowStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid EscrowStatus", str)
+	}
+	return nil
+}
+
+func (e EscrowStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *EscrowStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e EscrowStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type HoldStatus string
+
+const (
+	HoldStatusActive   HoldStatus = "ACTIVE"
+	HoldStatusCaptured HoldStatus = "CAPTURED"
+	HoldStatusReleased HoldStatus = "RELEASED"
+	HoldStatusExpired  HoldStatus = "EXPIRED"
+)
+
+var AllHoldStatus = []HoldStatus{
+	HoldStatusActive,
+	HoldStatusCaptured,
+	HoldStatusReleased,
+	HoldStatusExpired,
+}
+
+func (e HoldStatus) IsValid() bool {
+	switch e {
+	case HoldStatusActive, HoldStatusCaptured, HoldStatusReleased, HoldStatusExpired:
+		return true
+	}
+	return false
+}
+
+func (e HoldStatus) String() string {
+	return string(e)
+}
+
+func (e *HoldStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = HoldStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid HoldStatus", str)
+	}
+	return nil
+}
+
+func (e HoldStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *HoldStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e HoldStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type HtlcStatus string
+
+const (
+	HtlcStatusLocked   HtlcStatus = "LOCKED"
+	HtlcStatusClaimed  HtlcStatus = "CLAIMED"
+	HtlcStatusRefunded HtlcStatus = "REFUNDED"
+)
+
+var AllHtlcStatus = []HtlcStatus{
+	HtlcStatusLocked,
+	HtlcStatusClaimed,
+	HtlcStatusRefunded,
+}
+
+func (e HtlcStatus) IsValid() bool {
+	switch e {
+	case HtlcStatusLocked, HtlcStatusClaimed, HtlcStatusRefunded:
+		return true
+	}
+	return false
+}
+
+func (e HtlcStatus) String() string {
+	return string(e)
+}
+
+func (e *HtlcStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = HtlcStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid HtlcStatus", str)
+	}
+	return nil
+}
+
+func (e HtlcStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *HtlcStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e HtlcStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type MerkleSide string
+
+const (
+	MerkleSideLeft  MerkleSide = "LEFT"
+	MerkleSideRight MerkleSide = "RIGHT"
+)
+
+var AllMerkleSide = []MerkleSide{
+	MerkleSideLeft,
+	MerkleSideRight,
+}
+
+func (e MerkleSide) IsValid() bool {
+	switch e {
+	case MerkleSideLeft, MerkleSideRight:
+		return true
+	}
+	return false
+}
+
+func (e MerkleSide) String() string {
+	return string(e)
+}
+
+func (e *MerkleSide) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = MerkleSide(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid MerkleSide", str)
+	}
+	return nil
+}
+
+func (e MerkleSide) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *MerkleSide) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e MerkleSide) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type MultisigProposalStatus string
+
+const (
+	MultisigProposalStatusPending   MultisigProposalStatus = "PENDING"
+	MultisigProposalStatusExecuted  MultisigProposalStatus = "EXECUTED"
+	MultisigProposalStatusCancelled MultisigProposalStatus = "CANCELLED"
+	MultisigProposalStatusExpired   MultisigProposalStatus = "EXPIRED"
+)
+
+var AllMultisigProposalStatus = []MultisigProposalStatus{
+	MultisigProposalStatusPending,
+	MultisigProposalStatusExecuted,
+	MultisigProposalStatusCancelled,
+	MultisigProposalStatusExpired,
+}
+
+func (e MultisigProposalStatus) IsValid() bool {
+	switch e {
+	case MultisigProposalStatusPending, MultisigProposalStatusExecuted, MultisigProposalStatusCancelled, MultisigProposalStatusExpired:
+		return true
+	}
+	return false
+}
+
+func (e MultisigProposalStatus) String() string {
+	return string(e)
+}
+
+func (e *MultisigProposalStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = MultisigProposalStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid MultisigProposalStatus", str)
+	}
+	return nil
+}
+
+func (e MultisigProposalStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *MultisigProposalStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e MultisigProposalStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type PaymentRequestStatus string
+
+const (
+	PaymentRequestStatusOpen      PaymentRequestStatus = "OPEN"
+	PaymentRequestStatusPaid      PaymentRequestStatus = "PAID"
+	PaymentRequestStatusExpired   PaymentRequestStatus = "EXPIRED"
+	PaymentRequestStatusCancelled PaymentRequestStatus = "CANCELLED"
+)
+
+var AllPaymentRequestStatus = []PaymentRequestStatus{
+	PaymentRequestStatusOpen,
+	PaymentRequestStatusPaid,
+	PaymentRequestStatusExpired,
+	PaymentRequestStatusCancelled,
+}
+
+func (e PaymentRequestStatus) IsValid() bool {
+	switch e {
+	case PaymentRequestStatusOpen, PaymentRequestStatusPaid, PaymentRequestStatusExpired, PaymentRequestStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func (e PaymentRequestStatus) String() string {
+	return string(e)
+}
+
+func (e *PaymentRequestStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PaymentRequestStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PaymentRequestStatus", str)
+	}
+	return nil
+}
+
+func (e PaymentRequestStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *PaymentRequestStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e PaymentRequestStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type PolicyAction string
+
+const (
+	PolicyActionAllow PolicyAction = "ALLOW"
+	PolicyActionDeny  PolicyAction = "DENY"
+	PolicyActionFlag  PolicyAction = "FLAG"
+)
+
+var AllPolicyAction = []PolicyAction{
+	PolicyActionAllow,
+	PolicyActionDeny,
+	PolicyActionFlag,
+}
+
+func (e PolicyAction) IsValid() bool {
+	switch e {
+	case PolicyActionAllow, PolicyActionDeny, PolicyActionFlag:
+		return true
+	}
+	return false
+}
+
+func (e PolicyAction) String() string {
+	return string(e)
+}
+
+func (e *PolicyAction) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PolicyAction(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PolicyAction", str)
+	}
+	return nil
+}
+
+func (e PolicyAction) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *PolicyAction) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e PolicyAction) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ScheduleRunStatus string
+
+const (
+	ScheduleRunStatusSucceeded ScheduleRunStatus = "SUCCEEDED"
+	ScheduleRunStatusFailed    ScheduleRunStatus = "FAILED"
+)
+
+var AllScheduleRunStatus = []ScheduleRunStatus{
+	ScheduleRunStatusSucceeded,
+	ScheduleRunStatusFailed,
+}
+
+func (e ScheduleRunStatus) IsValid() bool {
+	switch e {
+	case ScheduleRunStatusSucceeded, ScheduleRunStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e ScheduleRunStatus) String() string {
+	return string(e)
+}
+
+func (e *ScheduleRunStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ScheduleRunStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ScheduleRunStatus", str)
+	}
+	return nil
+}
+
+func (e ScheduleRunStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ScheduleRunStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ScheduleRunStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ScheduleStatus string
+
+const (
+	ScheduleStatusActive    ScheduleStatus = "ACTIVE"
+	ScheduleStatusCompleted ScheduleStatus = "COMPLETED"
+	ScheduleStatusCancelled ScheduleStatus = "CANCELLED"
+)
+
+var AllScheduleStatus = []ScheduleStatus{
+	ScheduleStatusActive,
+	ScheduleStatusCompleted,
+	ScheduleStatusCancelled,
+}
+
+func (e ScheduleStatus) IsValid() bool {
+	switch e {
+	case ScheduleStatusActive, ScheduleStatusCompleted, ScheduleStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func (e ScheduleStatus) String() string {
+	return string(e)
+}
+
+func (e *ScheduleStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ScheduleStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ScheduleStatus", str)
+	}
+	return nil
+}
+
+func (e ScheduleStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ScheduleStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ScheduleStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type StakeStatus string
+
+const (
+	StakeStatusStaked    StakeStatus = "STAKED"
+	StakeStatusUnbonding StakeStatus = "UNBONDING"
+	StakeStatusWithdrawn StakeStatus = "WITHDRAWN"
+)
+
+var AllStakeStatus = []StakeStatus{
+	StakeStatusStaked,
+	StakeStatusUnbonding,
+	StakeStatusWithdrawn,
+}
+
+func (e StakeStatus) IsValid() bool {
+	switch e {
+	case StakeStatusStaked, StakeStatusUnbonding, StakeStatusWithdrawn:
+		return true
+	}
+	return false
+}
+
+func (e StakeStatus) String() string {
+	return string(e)
+}
+
+func (e *StakeStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = StakeStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid StakeStatus", str)
+	}
+	return nil
+}
+
+func (e StakeStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *StakeStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e StakeStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type WalletStatus string
+
+const (
+	WalletStatusActive         WalletStatus = "ACTIVE"
+	WalletStatusFrozenIncoming WalletStatus = "FROZEN_INCOMING"
+	WalletStatusFrozenOutgoing WalletStatus = "FROZEN_OUTGOING"
+	WalletStatusFrozen         WalletStatus = "FROZEN"
+)
+
+var AllWalletStatus = []WalletStatus{
+	WalletStatusActive,
+	WalletStatusFrozenIncoming,
+	WalletStatusFrozenOutgoing,
+	WalletStatusFrozen,
+}
+
+func (e WalletStatus) IsValid() bool {
+	switch e {
+	case WalletStatusActive, WalletStatusFrozenIncoming, WalletStatusFrozenOutgoing, WalletStatusFrozen:
+		return true
+	}
+	return false
+}
+
+func (e WalletStatus) String() string {
+	return string(e)
+}
+
+func (e *WalletStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WalletStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WalletStatus", str)
+	}
+	return nil
+}
+
+func (e WalletStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *WalletStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e WalletStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
 }
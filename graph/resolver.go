@@ -1,6 +1,14 @@
 package graph
 
 import (
+	"btp_tokens/internal/alerts"
+	"btp_tokens/internal/compliance"
+	"btp_tokens/internal/fees"
+	"btp_tokens/internal/ledger"
+	"btp_tokens/internal/limits"
+	"btp_tokens/internal/policy"
+	"btp_tokens/internal/snapshots"
+	"btp_tokens/internal/tokens"
 	"btp_tokens/internal/wallets"
 )
 
@@ -11,4 +19,14 @@ import (
 
 type Resolver struct{
 	WalletsService *wallets.WalletsService
+	LedgerService *ledger.LedgerService
+	SnapshotsService *snapshots.SnapshotsService
+	TokensService *tokens.TokensService
+	FeesService *fees.FeesService
+	ComplianceService *compliance.ComplianceService
+	LimitsService *limits.LimitsService
+	PolicyService *policy.PolicyService
+	AlertsService *alerts.AlertsService
+	// TransferBatcher is optional, when set transfers go through batched commits
+	TransferBatcher *wallets.TransferBatcher
 }
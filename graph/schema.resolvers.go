@@ -7,49 +7,2224 @@ package graph
 
 import (
 	"btp_tokens/graph/model"
+	"btp_tokens/internal/alerts"
+	"btp_tokens/internal/auth"
+	"btp_tokens/internal/compliance"
+	"btp_tokens/internal/fees"
+	"btp_tokens/internal/limits"
+	"btp_tokens/internal/merkle"
+	"btp_tokens/internal/policy"
+	"btp_tokens/internal/snapshots"
+	"btp_tokens/internal/tokens"
 	"btp_tokens/internal/wallets"
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/shopspring/decimal"
 )
 
+// History is the resolver for the history field.
+func (r *addressStatusResolver) History(ctx context.Context, obj *model.AddressStatus) ([]*model.ComplianceEvent, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	history, err := r.ComplianceService.History(ctx, obj.Address)
+	if err != nil {
+		return nil, fmt.Errorf("compliance history fail: %w", err)
+	}
+
+	result := make([]*model.ComplianceEvent, 0, len(history))
+	for _, event := range history {
+		result = append(result, toComplianceEvent(event))
+	}
+	return result, nil
+}
+
+// History is the resolver for the history field.
+func (r *alertResolver) History(ctx context.Context, obj *model.Alert) ([]*model.AlertEvent, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	id, err := parseID(obj.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := r.AlertsService.History(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("alert history fail: %w", err)
+	}
+
+	result := make([]*model.AlertEvent, 0, len(history))
+	for _, event := range history {
+		result = append(result, toAlertEvent(event))
+	}
+	return result, nil
+}
+
+// Recipients is the resolver for the recipients field.
+func (r *distributionResolver) Recipients(ctx context.Context, obj *model.Distribution, status *model.DistributionRecipientStatus, limit *int32) ([]*model.DistributionRecipient, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	id, err := parseID(obj.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var filter string
+	if status != nil {
+		filter = strings.ToLower(status.String())
+	}
+
+	recipients, err := r.WalletsService.DistributionRecipients(ctx, id, filter, historyLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("distribution recipients fail: %w", err)
+	}
+
+	result := make([]*model.DistributionRecipient, 0, len(recipients))
+	for _, recipient := range recipients {
+		result = append(result, toDistributionRecipient(recipient))
+	}
+	return result, nil
+}
+
+// History is the resolver for the history field.
+func (r *escrowResolver) History(ctx context.Context, obj *model.Escrow) ([]*model.EscrowEvent, error) {
+	id, err := parseID(obj.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := r.WalletsService.EscrowHistory(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("escrow history fail: %w", err)
+	}
+
+	result := make([]*model.EscrowEvent, 0, len(history))
+	for _, event := range history {
+		result = append(result, toEscrowEvent(event))
+	}
+	return result, nil
+}
+
 // Transfer is the resolver for the transfer field.
 func (r *mutationResolver) Transfer(ctx context.Context, input model.Transfer) (string, error) {
-	amount := decimal.Decimal(input.Amount)
+	receipt, err := r.send(ctx, input)
+	if err != nil {
+		return "", err
+	}
+
+	return receipt.SenderBalance.String(), nil
+}
+
+// SendTransfer is the resolver for the sendTransfer field.
+func (r *mutationResolver) SendTransfer(ctx context.Context, input model.Transfer) (*model.TransferReceipt, error) {
+	receipt, err := r.send(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	result := toTransferReceipt(receipt)
+	senderBalance := model.Decimal(receipt.SenderBalance)
+	result.SenderBalance = &senderBalance
+	return result, nil
+}
+
+// CheckpointLedger is the resolver for the checkpointLedger field.
+func (r *mutationResolver) CheckpointLedger(ctx context.Context) (*model.LedgerCheckpoint, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	checkpoint, err := r.LedgerService.Checkpoint(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ledger checkpoint fail: %w", err)
+	}
+	return toLedgerCheckpoint(checkpoint), nil
+}
+
+// CreateSnapshot is the resolver for the createSnapshot field.
+func (r *mutationResolver) CreateSnapshot(ctx context.Context) (*model.Snapshot, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	snapshot, err := r.SnapshotsService.Create(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot fail: %w", err)
+	}
+	return toSnapshot(snapshot), nil
+}
+
+// RegisterToken is the resolver for the registerToken field.
+func (r *mutationResolver) RegisterToken(ctx context.Context, input model.NewToken) (*model.Token, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	token := tokens.Token{
+		Symbol:   input.Symbol,
+		Name:     input.Name,
+		Decimals: input.Decimals,
+	}
+	if input.SupplyCap != nil {
+		supplyCap := decimal.Decimal(*input.SupplyCap)
+		token.SupplyCap = &supplyCap
+	}
+	if input.Issuer != nil {
+		token.Issuer = *input.Issuer
+	}
+
+	registered, err := r.TokensService.Register(ctx, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, tokens.ErrorTokenExists), errors.Is(err, tokens.ErrorInvalidSymbol),
+			errors.Is(err, tokens.ErrorInvalidDecimals), errors.Is(err, tokens.ErrorInvalidSupplyCap):
+			return nil, err
+		}
+		return nil, fmt.Errorf("register token fail: %w", err)
+	}
+	return toToken(registered), nil
+}
+
+// Mint is the resolver for the mint field.
+func (r *mutationResolver) Mint(ctx context.Context, input model.Mint) (*model.Wallet, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	token := tokenOrDefault(input.Token)
+	amount, err := r.parseAmount(ctx, token, input.Amount, input.Unit)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := r.WalletsService.Mint(ctx, token, input.ToAddress, amount); err != nil {
+		if errors.Is(err, tokens.ErrorTokenNotFound) || errors.Is(err, tokens.ErrorSupplyCapExceeded) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("mint fail: %w", err)
+	}
+
+	wallet, err := r.WalletsService.GetWallet(ctx, input.ToAddress, token)
+	if err != nil {
+		return nil, fmt.Errorf("mint fail: %w", err)
+	}
+	return toWallet(wallet), nil
+}
+
+// Burn is the resolver for the burn field.
+func (r *mutationResolver) Burn(ctx context.Context, input model.Burn) (*model.Wallet, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	token := tokenOrDefault(input.Token)
+	amount, err := r.parseAmount(ctx, token, input.Amount, input.Unit)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := r.WalletsService.Burn(ctx, token, input.FromAddress, amount); err != nil {
+		if errors.Is(err, wallets.ErrorInsufficientBalance) {
+			return nil, coded(CodeInsufficientBalance, "insufficient balance")
+		}
+		if errors.Is(err, tokens.ErrorTokenNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("burn fail: %w", err)
+	}
+
+	wallet, err := r.WalletsService.GetWallet(ctx, input.FromAddress, token)
+	if err != nil {
+		return nil, fmt.Errorf("burn fail: %w", err)
+	}
+	return toWallet(wallet), nil
+}
+
+// CreateHold is the resolver for the createHold field.
+func (r *mutationResolver) CreateHold(ctx context.Context, input model.NewHold) (*model.Hold, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	token := tokenOrDefault(input.Token)
+	amount, err := r.parseAmount(ctx, token, input.Amount, input.Unit)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(wallets.DefaultHoldDuration)
+	if input.ExpiresAt != nil {
+		expiresAt = *input.ExpiresAt
+	}
+	reference := ""
+	if input.Reference != nil {
+		reference = *input.Reference
+	}
+
+	hold, err := r.WalletsService.CreateHold(ctx, token, input.Address, input.Payee, amount, reference, expiresAt)
+	if err != nil {
+		if errors.Is(err, wallets.ErrorInsufficientBalance) {
+			return nil, coded(CodeInsufficientBalance, "insufficient balance")
+		}
+		return nil, failure("create hold", err)
+	}
+	return toHold(hold), nil
+}
+
+// CaptureHold is the resolver for the captureHold field.
+func (r *mutationResolver) CaptureHold(ctx context.Context, id string, amount *model.Decimal) (*model.Hold, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	holdID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	hold, _, err := r.WalletsService.CaptureHold(ctx, holdID, optionalDecimal(amount))
+	if err != nil {
+		return nil, failure("capture hold", err)
+	}
+	return toHold(hold), nil
+}
+
+// ReleaseHold is the resolver for the releaseHold field.
+func (r *mutationResolver) ReleaseHold(ctx context.Context, id string) (*model.Hold, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	holdID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	hold, err := r.WalletsService.ReleaseHold(ctx, holdID)
+	if err != nil {
+		return nil, failure("release hold", err)
+	}
+	return toHold(hold), nil
+}
+
+// CreateEscrow is the resolver for the createEscrow field.
+func (r *mutationResolver) CreateEscrow(ctx context.Context, input model.NewEscrow) (*model.Escrow, error) {
+	token := tokenOrDefault(input.Token)
+	amount, err := r.parseAmount(ctx, token, input.Amount, input.Unit)
+	if err != nil {
+		return nil, err
+	}
+
+	escrow, err := r.WalletsService.CreateEscrow(ctx, wallets.Escrow{
+		Token:     token,
+		Buyer:     input.Buyer,
+		Seller:    input.Seller,
+		Arbiter:   input.Arbiter,
+		Amount:    amount,
+		Reference: stringOrEmpty(input.Reference),
+		Deadline:  input.Deadline,
+	})
+	if err != nil {
+		return nil, failure("create escrow", err)
+	}
+	return toEscrow(escrow), nil
+}
+
+// ApproveEscrow is the resolver for the approveEscrow field.
+func (r *mutationResolver) ApproveEscrow(ctx context.Context, id string, party string) (*model.Escrow, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	escrowID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	escrow, err := r.WalletsService.ApproveEscrow(ctx, escrowID, party)
+	if err != nil {
+		return nil, failure("approve escrow", err)
+	}
+	return toEscrow(escrow), nil
+}
+
+// DisputeEscrow is the resolver for the disputeEscrow field.
+func (r *mutationResolver) DisputeEscrow(ctx context.Context, id string, party string, reason *string) (*model.Escrow, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	escrowID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	escrow, err := r.WalletsService.DisputeEscrow(ctx, escrowID, party, stringOrEmpty(reason))
+	if err != nil {
+		return nil, failure("dispute escrow", err)
+	}
+	return toEscrow(escrow), nil
+}
+
+// ArbitrateEscrow is the resolver for the arbitrateEscrow field.
+func (r *mutationResolver) ArbitrateEscrow(ctx context.Context, id string, arbiter string, outcome model.EscrowOutcome, note *string) (*model.Escrow, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	escrowID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	escrow, err := r.WalletsService.ArbitrateEscrow(ctx, escrowID, arbiter, outcome == model.EscrowOutcomeRelease, stringOrEmpty(note))
+	if err != nil {
+		return nil, failure("arbitrate escrow", err)
+	}
+	return toEscrow(escrow), nil
+}
+
+// ReleaseEscrow is the resolver for the releaseEscrow field.
+func (r *mutationResolver) ReleaseEscrow(ctx context.Context, id string) (*model.Escrow, error) {
+	escrowID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	escrow, err := r.WalletsService.ReleaseEscrow(ctx, escrowID)
+	if err != nil {
+		return nil, failure("release escrow", err)
+	}
+	return toEscrow(escrow), nil
+}
+
+// RefundEscrow is the resolver for the refundEscrow field.
+func (r *mutationResolver) RefundEscrow(ctx context.Context, id string) (*model.Escrow, error) {
+	escrowID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	escrow, err := r.WalletsService.RefundEscrow(ctx, escrowID)
+	if err != nil {
+		return nil, failure("refund escrow", err)
+	}
+	return toEscrow(escrow), nil
+}
+
+// LockHtlc is the resolver for the lockHtlc field.
+func (r *mutationResolver) LockHtlc(ctx context.Context, input model.NewHtlc) (*model.Htlc, error) {
+	token := tokenOrDefault(input.Token)
+	amount, err := r.parseAmount(ctx, token, input.Amount, input.Unit)
+	if err != nil {
+		return nil, err
+	}
+
+	htlc, err := r.WalletsService.LockHtlc(ctx, token, input.Sender, input.Recipient, amount, input.Hashlock, input.TimeoutAt)
+	if err != nil {
+		return nil, failure("lock htlc", err)
+	}
+	return toHtlc(htlc), nil
+}
+
+// ClaimHtlc is the resolver for the claimHtlc field.
+func (r *mutationResolver) ClaimHtlc(ctx context.Context, id string, preimage string) (*model.Htlc, error) {
+	htlcID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	htlc, err := r.WalletsService.ClaimHtlc(ctx, htlcID, preimage)
+	if err != nil {
+		return nil, failure("claim htlc", err)
+	}
+	return toHtlc(htlc), nil
+}
+
+// RefundHtlc is the resolver for the refundHtlc field.
+func (r *mutationResolver) RefundHtlc(ctx context.Context, id string) (*model.Htlc, error) {
+	htlcID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	htlc, err := r.WalletsService.RefundHtlc(ctx, htlcID)
+	if err != nil {
+		return nil, failure("refund htlc", err)
+	}
+	return toHtlc(htlc), nil
+}
+
+// ScheduleTransfer is the resolver for the scheduleTransfer field.
+func (r *mutationResolver) ScheduleTransfer(ctx context.Context, input model.NewScheduledTransfer) (*model.ScheduledTransfer, error) {
+	token := tokenOrDefault(input.Token)
+	amount, err := r.parseAmount(ctx, token, input.Amount, input.Unit)
+	if err != nil {
+		return nil, err
+	}
+
+	terms := wallets.ScheduledTransfer{
+		Token:       token,
+		FromAddress: input.FromAddress,
+		ToAddress:   input.ToAddress,
+		Amount:      amount,
+		Cron:        stringOrEmpty(input.Cron),
+		Interval:    time.Duration(int32OrZero(input.IntervalSeconds)) * time.Second,
+		EndsAt:      input.EndsAt,
+		MaxRuns:     int(int32OrZero(input.MaxRuns)),
+		NextRunAt:   input.RunAt,
+	}
+
+	schedule, err := r.WalletsService.ScheduleTransfer(ctx, terms)
+	if err != nil {
+		return nil, failure("schedule transfer", err)
+	}
+	return toScheduledTransfer(schedule), nil
+}
+
+// CreateMultisigWallet is the resolver for the createMultisigWallet field.
+func (r *mutationResolver) CreateMultisigWallet(ctx context.Context, input model.NewMultisigWallet) (*model.MultisigWallet, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	signers := make([]wallets.MultisigSigner, 0, len(input.Signers))
+	for _, signer := range input.Signers {
+		signers = append(signers, wallets.MultisigSigner{Address: signer.Address, PublicKey: signer.PublicKey})
+	}
+
+	wallet, err := r.WalletsService.CreateMultisigWallet(ctx, input.Address, int(input.Threshold), signers)
+	if err != nil {
+		return nil, failure("create multisig wallet", err)
+	}
+	return toMultisigWallet(wallet), nil
+}
+
+// ProposeMultisigTransfer is the resolver for the proposeMultisigTransfer field.
+func (r *mutationResolver) ProposeMultisigTransfer(ctx context.Context, input model.NewMultisigProposal) (*model.MultisigProposal, error) {
+	token := tokenOrDefault(input.Token)
+	amount, err := r.parseAmount(ctx, token, input.Amount, input.Unit)
+	if err != nil {
+		return nil, err
+	}
+
+	proposal, err := r.WalletsService.ProposeMultisigTransfer(ctx, input.Wallet, token, input.ToAddress, amount, input.Proposer, input.ExpiresAt, input.Signature)
+	if err != nil {
+		return nil, failure("propose multisig transfer", err)
+	}
+	return toMultisigProposal(proposal), nil
+}
+
+// ApproveMultisigTransfer is the resolver for the approveMultisigTransfer field.
+func (r *mutationResolver) ApproveMultisigTransfer(ctx context.Context, id string, signer string, signature string) (*model.MultisigProposal, error) {
+	proposalID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	proposal, err := r.WalletsService.ApproveMultisigTransfer(ctx, proposalID, signer, signature)
+	if err != nil {
+		if errors.Is(err, wallets.ErrorInsufficientBalance) {
+			return nil, coded(CodeInsufficientBalance, "insufficient balance")
+		}
+		return nil, failure("approve multisig transfer", err)
+	}
+	return toMultisigProposal(proposal), nil
+}
+
+// ExecuteMultisigTransfer is the resolver for the executeMultisigTransfer field.
+func (r *mutationResolver) ExecuteMultisigTransfer(ctx context.Context, id string) (*model.MultisigProposal, error) {
+	proposalID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	proposal, err := r.WalletsService.ExecuteMultisigTransfer(ctx, proposalID)
+	if err != nil {
+		if errors.Is(err, wallets.ErrorInsufficientBalance) {
+			return nil, coded(CodeInsufficientBalance, "insufficient balance")
+		}
+		return nil, failure("execute multisig transfer", err)
+	}
+	return toMultisigProposal(proposal), nil
+}
+
+// CancelMultisigTransfer is the resolver for the cancelMultisigTransfer field.
+func (r *mutationResolver) CancelMultisigTransfer(ctx context.Context, id string, signer string, signature string) (*model.MultisigProposal, error) {
+	proposalID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	proposal, err := r.WalletsService.CancelMultisigTransfer(ctx, proposalID, signer, signature)
+	if err != nil {
+		if errors.Is(err, wallets.ErrorInsufficientBalance) {
+			return nil, coded(CodeInsufficientBalance, "insufficient balance")
+		}
+		return nil, failure("cancel multisig transfer", err)
+	}
+	return toMultisigProposal(proposal), nil
+}
+
+// CreateVestingGrant is the resolver for the createVestingGrant field.
+func (r *mutationResolver) CreateVestingGrant(ctx context.Context, input model.NewVestingGrant) (*model.VestingGrant, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	token := tokenOrDefault(input.Token)
+	total, err := r.parseAmount(ctx, token, input.Total, input.Unit)
+	if err != nil {
+		return nil, err
+	}
+	cliff, err := wallets.VestingSeconds(int64(int32OrZero(input.CliffSeconds)))
+	if err != nil {
+		return nil, failure("create vesting grant", err)
+	}
+	duration, err := wallets.VestingSeconds(int64(input.DurationSeconds))
+	if err != nil {
+		return nil, failure("create vesting grant", err)
+	}
+
+	terms := wallets.VestingGrant{
+		Token:       token,
+		Funder:      input.Funder,
+		Beneficiary: input.Beneficiary,
+		Total:       total,
+		StartAt:     timeOrNow(input.StartAt),
+		Cliff:       cliff,
+		Duration:    duration,
+	}
+	if input.Revocable != nil {
+		terms.Revocable = *input.Revocable
+	}
+
+	grant, err := r.WalletsService.CreateVestingGrant(ctx, terms)
+	if err != nil {
+		if errors.Is(err, wallets.ErrorInsufficientBalance) {
+			return nil, coded(CodeInsufficientBalance, "insufficient balance")
+		}
+		return nil, failure("create vesting grant", err)
+	}
+	return toVestingGrant(grant, time.Now()), nil
+}
+
+// ClaimVested is the resolver for the claimVested field.
+func (r *mutationResolver) ClaimVested(ctx context.Context, id string) (*model.VestingGrant, error) {
+	grantID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	grant, err := r.WalletsService.ClaimVested(ctx, grantID)
+	if err != nil {
+		return nil, failure("claim vested", err)
+	}
+	return toVestingGrant(grant, time.Now()), nil
+}
+
+// RevokeVestingGrant is the resolver for the revokeVestingGrant field.
+func (r *mutationResolver) RevokeVestingGrant(ctx context.Context, id string) (*model.VestingGrant, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	grantID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	grant, err := r.WalletsService.RevokeVestingGrant(ctx, grantID)
+	if err != nil {
+		return nil, failure("revoke vesting grant", err)
+	}
+	return toVestingGrant(grant, time.Now()), nil
+}
+
+// ConfigureStakingPool is the resolver for the configureStakingPool field.
+func (r *mutationResolver) ConfigureStakingPool(ctx context.Context, input model.StakingPoolInput) (*model.StakingPool, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	pool, err := r.WalletsService.ConfigureStakingPool(ctx, wallets.StakingPool{
+		Token:      tokenOrDefault(input.Token),
+		APR:        optionalDecimal(input.Apr),
+		RewardRate: optionalDecimal(input.RewardRate),
+		Unbonding:  time.Duration(int32OrZero(input.UnbondingSeconds)) * time.Second,
+	})
+	if err != nil {
+		return nil, failure("configure staking pool", err)
+	}
+	return toStakingPool(pool), nil
+}
+
+// FundStakingRewards is the resolver for the fundStakingRewards field.
+func (r *mutationResolver) FundStakingRewards(ctx context.Context, fromAddress string, amount model.Decimal, unit *model.AmountUnit, token *string) (*model.StakingPool, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	symbol := tokenOrDefault(token)
+	value, err := r.parseAmount(ctx, symbol, amount, unit)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := r.WalletsService.FundStakingRewards(ctx, symbol, fromAddress, value)
+	if err != nil {
+		if errors.Is(err, wallets.ErrorInsufficientBalance) {
+			return nil, coded(CodeInsufficientBalance, "insufficient balance")
+		}
+		return nil, failure("fund staking rewards", err)
+	}
+	return toStakingPool(pool), nil
+}
+
+// Stake is the resolver for the stake field.
+func (r *mutationResolver) Stake(ctx context.Context, input model.NewStake) (*model.Stake, error) {
+	token := tokenOrDefault(input.Token)
+	amount, err := r.parseAmount(ctx, token, input.Amount, input.Unit)
+	if err != nil {
+		return nil, err
+	}
+
+	stake, err := r.WalletsService.Stake(ctx, token, input.Address, amount)
+	if err != nil {
+		if errors.Is(err, wallets.ErrorInsufficientBalance) {
+			return nil, coded(CodeInsufficientBalance, "insufficient balance")
+		}
+		return nil, failure("stake", err)
+	}
+	return r.toOneStake(ctx, stake)
+}
+
+// Unstake is the resolver for the unstake field.
+func (r *mutationResolver) Unstake(ctx context.Context, id string) (*model.Stake, error) {
+	stakeID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	stake, err := r.WalletsService.Unstake(ctx, stakeID)
+	if err != nil {
+		return nil, failure("unstake", err)
+	}
+	return r.toOneStake(ctx, stake)
+}
+
+// WithdrawStake is the resolver for the withdrawStake field.
+func (r *mutationResolver) WithdrawStake(ctx context.Context, id string) (*model.Stake, error) {
+	stakeID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	stake, err := r.WalletsService.WithdrawStake(ctx, stakeID)
+	if err != nil {
+		return nil, failure("withdraw stake", err)
+	}
+	return r.toOneStake(ctx, stake)
+}
+
+// ClaimStakingRewards is the resolver for the claimStakingRewards field.
+func (r *mutationResolver) ClaimStakingRewards(ctx context.Context, id string) (*model.Stake, error) {
+	stakeID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	stake, err := r.WalletsService.ClaimStakingRewards(ctx, stakeID)
+	if err != nil {
+		return nil, failure("claim staking rewards", err)
+	}
+	return r.toOneStake(ctx, stake)
+}
+
+// CreateDistribution is the resolver for the createDistribution field.
+func (r *mutationResolver) CreateDistribution(ctx context.Context, input model.NewDistribution) (*model.Distribution, error) {
+	operator, err := auth.RequireOperator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token := tokenOrDefault(input.Token)
+	total, err := r.parseAmount(ctx, token, input.Total, input.Unit)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshotID int64
+	if input.SnapshotID != nil {
+		if snapshotID, err = parseID(*input.SnapshotID); err != nil {
+			return nil, err
+		}
+	}
+	weightToken := token
+	if input.WeightToken != nil {
+		weightToken = *input.WeightToken
+	}
+
+	distribution, err := r.WalletsService.CreateDistribution(ctx, token, input.FromAddress, total, snapshotID, weightToken, stringOrEmpty(input.Recipients), operator)
+	if err != nil {
+		return nil, failure("create distribution", err)
+	}
+	return toDistribution(distribution), nil
+}
+
+// StartDistribution is the resolver for the startDistribution field.
+func (r *mutationResolver) StartDistribution(ctx context.Context, id string) (*model.Distribution, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	distributionID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	distribution, err := r.WalletsService.StartDistribution(ctx, distributionID)
+	if err != nil {
+		if errors.Is(err, wallets.ErrorInsufficientBalance) {
+			return nil, coded(CodeInsufficientBalance, "insufficient balance")
+		}
+		return nil, failure("start distribution", err)
+	}
+	return toDistribution(distribution), nil
+}
+
+// RetryDistribution is the resolver for the retryDistribution field.
+func (r *mutationResolver) RetryDistribution(ctx context.Context, id string) (*model.Distribution, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	distributionID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	distribution, err := r.WalletsService.RetryDistribution(ctx, distributionID)
+	if err != nil {
+		return nil, failure("retry distribution", err)
+	}
+	return toDistribution(distribution), nil
+}
+
+// CloseDistribution is the resolver for the closeDistribution field.
+func (r *mutationResolver) CloseDistribution(ctx context.Context, id string) (*model.Distribution, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	distributionID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	distribution, err := r.WalletsService.CloseDistribution(ctx, distributionID)
+	if err != nil {
+		return nil, failure("close distribution", err)
+	}
+	return toDistribution(distribution), nil
+}
+
+// CreateAirdrop is the resolver for the createAirdrop field.
+func (r *mutationResolver) CreateAirdrop(ctx context.Context, input model.NewAirdrop) (*model.Airdrop, error) {
+	operator, err := auth.RequireOperator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	airdrop, err := r.WalletsService.CreateAirdrop(ctx, tokenOrDefault(input.Token), input.Funder, input.Recipients, input.ExpiresAt, operator)
+	if err != nil {
+		if errors.Is(err, wallets.ErrorInsufficientBalance) {
+			return nil, coded(CodeInsufficientBalance, "insufficient balance")
+		}
+		return nil, failure("create airdrop", err)
+	}
+	return toAirdrop(airdrop), nil
+}
+
+// ClaimAirdrop is the resolver for the claimAirdrop field.
+func (r *mutationResolver) ClaimAirdrop(ctx context.Context, input model.ClaimAirdrop) (*model.AirdropClaim, error) {
+	airdropID, err := parseID(input.AirdropID)
+	if err != nil {
+		return nil, err
+	}
+
+	proof := make([]merkle.ProofStep, 0, len(input.Proof))
+	for _, step := range input.Proof {
+		proof = append(proof, merkle.ProofStep{Hash: step.Hash, Left: step.Side == model.MerkleSideLeft})
+	}
+
+	claim, err := r.WalletsService.ClaimAirdrop(ctx, airdropID, input.Address, decimal.Decimal(input.Amount), proof)
+	if err != nil {
+		return nil, failure("claim airdrop", err)
+	}
+	return toAirdropClaim(claim), nil
+}
+
+// SweepAirdrop is the resolver for the sweepAirdrop field.
+func (r *mutationResolver) SweepAirdrop(ctx context.Context, id string) (*model.Airdrop, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	airdropID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	airdrop, err := r.WalletsService.SweepAirdrop(ctx, airdropID)
+	if err != nil {
+		return nil, failure("sweep airdrop", err)
+	}
+	return toAirdrop(airdrop), nil
+}
+
+// CreatePaymentRequest is the resolver for the createPaymentRequest field.
+func (r *mutationResolver) CreatePaymentRequest(ctx context.Context, input model.NewPaymentRequest) (*model.PaymentRequest, error) {
+	token := tokenOrDefault(input.Token)
+	amount, err := r.parseAmount(ctx, token, input.Amount, input.Unit)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(wallets.DefaultPaymentRequestDuration)
+	if input.ExpiresAt != nil {
+		expiresAt = *input.ExpiresAt
+	}
+
+	request, err := r.WalletsService.CreatePaymentRequest(ctx, token, input.Payee, amount, stringOrEmpty(input.Memo), stringOrEmpty(input.Reference), expiresAt)
+	if err != nil {
+		return nil, failure("create payment request", err)
+	}
+	return toPaymentRequest(request), nil
+}
+
+// PayRequest is the resolver for the payRequest field.
+func (r *mutationResolver) PayRequest(ctx context.Context, reference string, payer string, amount *model.Decimal) (*model.PaymentRequest, error) {
+	request, _, err := r.WalletsService.PayRequest(ctx, reference, payer, optionalDecimal(amount))
+	if err != nil {
+		if errors.Is(err, wallets.ErrorInsufficientBalance) {
+			return nil, coded(CodeInsufficientBalance, "insufficient balance")
+		}
+		return nil, failure("pay request", err)
+	}
+	return toPaymentRequest(request), nil
+}
+
+// CancelPaymentRequest is the resolver for the cancelPaymentRequest field.
+func (r *mutationResolver) CancelPaymentRequest(ctx context.Context, id string) (*model.PaymentRequest, error) {
+	requestID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := r.WalletsService.CancelPaymentRequest(ctx, requestID)
+	if err != nil {
+		return nil, failure("cancel payment request", err)
+	}
+	return toPaymentRequest(request), nil
+}
+
+// CancelScheduledTransfer is the resolver for the cancelScheduledTransfer field.
+func (r *mutationResolver) CancelScheduledTransfer(ctx context.Context, id string) (*model.ScheduledTransfer, error) {
+	scheduleID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := r.WalletsService.CancelScheduledTransfer(ctx, scheduleID)
+	if err != nil {
+		return nil, failure("cancel scheduled transfer", err)
+	}
+	return toScheduledTransfer(schedule), nil
+}
+
+// ReverseTransfer is the resolver for the reverseTransfer field.
+func (r *mutationResolver) ReverseTransfer(ctx context.Context, transferID string, reason string, amount *model.Decimal) (*model.TransferReversal, error) {
+	operator, err := auth.RequireOperator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entryID, err := parseID(transferID)
+	if err != nil {
+		return nil, err
+	}
+
+	reversal, err := r.WalletsService.ReverseTransfer(ctx, entryID, optionalDecimal(amount), reason, operator)
+	if err != nil {
+		return nil, failure("reverse transfer", err)
+	}
+	return toTransferReversal(reversal), nil
+}
+
+// FreezeWallet is the resolver for the freezeWallet field.
+func (r *mutationResolver) FreezeWallet(ctx context.Context, address string, status *model.WalletStatus, reason string) (*model.AddressStatus, error) {
+	operator, err := auth.RequireOperator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	walletStatus := compliance.StatusFrozen
+	if status != nil {
+		walletStatus = strings.ToLower(status.String())
+	}
+	updated, err := r.ComplianceService.SetStatus(ctx, address, walletStatus, reason, operator)
+	if err != nil {
+		return nil, failure("freeze wallet", err)
+	}
+	return toAddressStatus(updated), nil
+}
+
+// UnfreezeWallet is the resolver for the unfreezeWallet field.
+func (r *mutationResolver) UnfreezeWallet(ctx context.Context, address string, reason string) (*model.AddressStatus, error) {
+	operator, err := auth.RequireOperator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := r.ComplianceService.SetStatus(ctx, address, compliance.StatusActive, reason, operator)
+	if err != nil {
+		return nil, failure("unfreeze wallet", err)
+	}
+	return toAddressStatus(updated), nil
+}
+
+// ImportDenyList is the resolver for the importDenyList field.
+func (r *mutationResolver) ImportDenyList(ctx context.Context, list string, addresses []string, reason string, replace *bool) (int32, error) {
+	operator, err := auth.RequireOperator(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	added, err := r.ComplianceService.ImportDenyList(ctx, list, addresses, replace != nil && *replace, reason, operator)
+	if err != nil {
+		return 0, failure("import deny-list", err)
+	}
+	return int32(added), nil
+}
+
+// RemoveFromDenyList is the resolver for the removeFromDenyList field.
+func (r *mutationResolver) RemoveFromDenyList(ctx context.Context, address string, reason string) (*model.AddressStatus, error) {
+	operator, err := auth.RequireOperator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.ComplianceService.RemoveFromDenyList(ctx, address, reason, operator); err != nil {
+		return nil, failure("remove from deny-list", err)
+	}
+	status, err := r.ComplianceService.GetStatus(ctx, address)
+	if err != nil {
+		return nil, fmt.Errorf("address status fail: %w", err)
+	}
+	return toAddressStatus(status), nil
+}
+
+// SetTierLimits is the resolver for the setTierLimits field.
+func (r *mutationResolver) SetTierLimits(ctx context.Context, input model.TierLimitsInput) (*model.TierLimits, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	tierLimits, err := r.LimitsService.SetLimits(ctx, limits.Limits{
+		Tier:        input.Tier,
+		Token:       tokenOrDefault(input.Token),
+		PerTransfer: optionalDecimal(input.PerTransfer),
+		Daily:       optionalDecimal(input.Daily),
+		Monthly:     optionalDecimal(input.Monthly),
+	})
+	if err != nil {
+		return nil, failure("set tier limits", err)
+	}
+	return toTierLimits(tierLimits), nil
+}
+
+// RemoveTierLimits is the resolver for the removeTierLimits field.
+func (r *mutationResolver) RemoveTierLimits(ctx context.Context, tier string, token *string) (bool, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return false, err
+	}
+
+	if err := r.LimitsService.RemoveLimits(ctx, tier, tokenOrDefault(token)); err != nil {
+		return false, failure("remove tier limits", err)
+	}
+	return true, nil
+}
+
+// SetWalletTier is the resolver for the setWalletTier field.
+func (r *mutationResolver) SetWalletTier(ctx context.Context, address string, tier string) (string, error) {
+	operator, err := auth.RequireOperator(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.LimitsService.SetTier(ctx, address, tier, operator); err != nil {
+		return "", failure("set wallet tier", err)
+	}
+	return tier, nil
+}
+
+// SavePolicyRule is the resolver for the savePolicyRule field.
+func (r *mutationResolver) SavePolicyRule(ctx context.Context, input model.PolicyRuleInput) (*model.PolicyRule, error) {
+	operator, err := auth.RequireOperator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rule, err := r.PolicyService.SaveRule(ctx, fromPolicyRuleInput(&input), operator)
+	if err != nil {
+		return nil, failure("save policy rule", err)
+	}
+	return toPolicyRule(rule), nil
+}
+
+// DisablePolicyRule is the resolver for the disablePolicyRule field.
+func (r *mutationResolver) DisablePolicyRule(ctx context.Context, name string) (*model.PolicyRule, error) {
+	operator, err := auth.RequireOperator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rule, err := r.PolicyService.DisableRule(ctx, name, operator)
+	if err != nil {
+		return nil, failure("disable policy rule", err)
+	}
+	return toPolicyRule(rule), nil
+}
+
+// ConfigureAlertDetector is the resolver for the configureAlertDetector field.
+func (r *mutationResolver) ConfigureAlertDetector(ctx context.Context, input model.AlertDetectorInput) (*model.AlertDetector, error) {
+	operator, err := auth.RequireOperator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var severity string
+	if input.Severity != nil {
+		severity = strings.ToLower(input.Severity.String())
+	}
+	thresholds := make(map[string]decimal.Decimal, len(input.Thresholds))
+	for _, threshold := range input.Thresholds {
+		thresholds[threshold.Name] = decimal.Decimal(threshold.Value)
+	}
+
+	detector, err := r.AlertsService.ConfigureDetector(ctx, input.Name, input.Enabled, severity, thresholds, operator)
+	if err != nil {
+		return nil, failure("configure alert detector", err)
+	}
+	return toAlertDetector(detector), nil
+}
+
+// AcknowledgeAlert is the resolver for the acknowledgeAlert field.
+func (r *mutationResolver) AcknowledgeAlert(ctx context.Context, id string, note *string) (*model.Alert, error) {
+	operator, err := auth.RequireOperator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	alertID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var text string
+	if note != nil {
+		text = *note
+	}
+	alert, err := r.AlertsService.Acknowledge(ctx, alertID, text, operator)
+	if err != nil {
+		return nil, failure("acknowledge alert", err)
+	}
+	return toAlert(alert), nil
+}
+
+// EscalateAlert is the resolver for the escalateAlert field.
+func (r *mutationResolver) EscalateAlert(ctx context.Context, id string, note string, severity *model.AlertSeverity) (*model.Alert, error) {
+	operator, err := auth.RequireOperator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	alertID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var raise string
+	if severity != nil {
+		raise = strings.ToLower(severity.String())
+	}
+	alert, err := r.AlertsService.Escalate(ctx, alertID, note, raise, operator)
+	if err != nil {
+		return nil, failure("escalate alert", err)
+	}
+	return toAlert(alert), nil
+}
+
+// CloseAlert is the resolver for the closeAlert field.
+func (r *mutationResolver) CloseAlert(ctx context.Context, id string, note string) (*model.Alert, error) {
+	operator, err := auth.RequireOperator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	alertID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	alert, err := r.AlertsService.Close(ctx, alertID, note, operator)
+	if err != nil {
+		return nil, failure("close alert", err)
+	}
+	return toAlert(alert), nil
+}
+
+// SetFeePolicy is the resolver for the setFeePolicy field.
+func (r *mutationResolver) SetFeePolicy(ctx context.Context, input model.FeePolicyInput) (*model.FeePolicy, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	policy := fees.Policy{
+		Token:           tokenOrDefault(input.Token),
+		TreasuryAddress: input.TreasuryAddress,
+		Flat:            decimalOrZero(input.Flat),
+		BasisPoints:     int32OrZero(input.BasisPoints),
+		MinFee:          optionalDecimal(input.MinFee),
+		MaxFee:          optionalDecimal(input.MaxFee),
+	}
+	for _, tier := range input.Tiers {
+		policy.Tiers = append(policy.Tiers, fees.Tier{
+			From:        decimal.Decimal(tier.From),
+			Flat:        decimalOrZero(tier.Flat),
+			BasisPoints: int32OrZero(tier.BasisPoints),
+		})
+	}
+
+	policy, err := r.FeesService.Set(ctx, policy)
+	if err != nil {
+		if errors.Is(err, fees.ErrorInvalidFeePolicy) || errors.Is(err, tokens.ErrorTokenNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("set fee policy fail: %w", err)
+	}
+	return toFeePolicy(policy), nil
+}
+
+// RemoveFeePolicy is the resolver for the removeFeePolicy field.
+func (r *mutationResolver) RemoveFeePolicy(ctx context.Context, token *string) (bool, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return false, err
+	}
+
+	if err := r.FeesService.Remove(ctx, tokenOrDefault(token)); err != nil {
+		if errors.Is(err, fees.ErrorFeePolicyNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("remove fee policy fail: %w", err)
+	}
+	return true, nil
+}
+
+// Payments is the resolver for the payments field.
+func (r *paymentRequestResolver) Payments(ctx context.Context, obj *model.PaymentRequest) ([]*model.Payment, error) {
+	id, err := parseID(obj.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	payments, err := r.WalletsService.Payments(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("payments fail: %w", err)
+	}
+
+	result := make([]*model.Payment, 0, len(payments))
+	for _, payment := range payments {
+		result = append(result, &model.Payment{
+			Payer:      payment.Payer,
+			Amount:     model.Decimal(payment.Amount),
+			TransferID: formatID(payment.TransferEntryID),
+			CreatedAt:  payment.CreatedAt,
+		})
+	}
+	return result, nil
+}
+
+// Empty is the resolver for the _empty field.
+func (r *queryResolver) Empty(ctx context.Context) (*string, error) {
+	// panic(fmt.Errorf("not implemented: Empty - _empty"))
+	msg := "empty"
+	return &msg, nil
+}
+
+// Wallet is the resolver for the wallet field.
+func (r *queryResolver) Wallet(ctx context.Context, address string, token *string) (*model.Wallet, error) {
+	wallet, err := r.WalletsService.GetWallet(ctx, address, tokenOrDefault(token))
+	if err != nil {
+		if errors.Is(err, wallets.ErrorWalletNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("wallet fail: %w", err)
+	}
+	return toWallet(wallet), nil
+}
+
+// Wallets is the resolver for the wallets field.
+func (r *queryResolver) Wallets(ctx context.Context, address string) ([]*model.Wallet, error) {
+	list, err := r.WalletsService.ListWallets(ctx, address)
+	if err != nil {
+		return nil, fmt.Errorf("wallets fail: %w", err)
+	}
+
+	result := make([]*model.Wallet, 0, len(list))
+	for _, w := range list {
+		result = append(result, toWallet(w))
+	}
+	return result, nil
+}
+
+// Token is the resolver for the token field.
+func (r *queryResolver) Token(ctx context.Context, symbol string) (*model.Token, error) {
+	token, err := r.TokensService.Get(ctx, symbol)
+	if err != nil {
+		if errors.Is(err, tokens.ErrorTokenNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("token fail: %w", err)
+	}
+	return toToken(token), nil
+}
+
+// Tokens is the resolver for the tokens field.
+func (r *queryResolver) Tokens(ctx context.Context) ([]*model.Token, error) {
+	list, err := r.TokensService.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("tokens fail: %w", err)
+	}
+
+	result := make([]*model.Token, 0, len(list))
+	for _, token := range list {
+		result = append(result, toToken(token))
+	}
+	return result, nil
+}
+
+// TrialBalance is the resolver for the trialBalance field.
+func (r *queryResolver) TrialBalance(ctx context.Context, at *time.Time) (*model.TrialBalance, error) {
+	trial, err := r.LedgerService.TrialBalance(ctx, at)
+	if err != nil {
+		return nil, fmt.Errorf("trial balance fail: %w", err)
+	}
+
+	accounts := make([]*model.AccountBalance, 0, len(trial.Accounts))
+	for _, a := range trial.Accounts {
+		accounts = append(accounts, &model.AccountBalance{
+			Token:   a.Token,
+			Account: a.Account,
+			Debit:   model.Decimal(a.Debit),
+			Credit:  model.Decimal(a.Credit),
+		})
+	}
+
+	return &model.TrialBalance{
+		TotalDebit:  model.Decimal(trial.TotalDebit),
+		TotalCredit: model.Decimal(trial.TotalCredit),
+		Balanced:    trial.Balanced(),
+		Accounts:    accounts,
+	}, nil
+}
+
+// LedgerMismatches is the resolver for the ledgerMismatches field.
+func (r *queryResolver) LedgerMismatches(ctx context.Context) ([]*model.BalanceMismatch, error) {
+	mismatches, err := r.LedgerService.VerifyBalances(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ledger verification fail: %w", err)
+	}
+
+	result := make([]*model.BalanceMismatch, 0, len(mismatches))
+	for _, m := range mismatches {
+		result = append(result, &model.BalanceMismatch{
+			Address:       m.Address,
+			Token:         m.Token,
+			Balance:       model.Decimal(m.Balance),
+			LedgerBalance: model.Decimal(m.LedgerBalance),
+		})
+	}
+	return result, nil
+}
+
+// VerifyLedger is the resolver for the verifyLedger field.
+func (r *queryResolver) VerifyLedger(ctx context.Context) (*model.LedgerVerification, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	verification, err := r.LedgerService.VerifyChain(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ledger verification fail: %w", err)
+	}
+
+	result := &model.LedgerVerification{
+		Valid:              verification.Valid,
+		EntriesChecked:     int32(verification.EntriesChecked),
+		CheckpointsChecked: int32(verification.CheckpointsChecked),
+		HeadHash:           verification.HeadHash,
+		BrokenEntryID:      optionalID(verification.BrokenEntryID),
+		BrokenCheckpointID: optionalID(verification.BrokenCheckpointID),
+	}
+	if verification.Reason != "" {
+		result.Reason = &verification.Reason
+	}
+	return result, nil
+}
+
+// LedgerCheckpoints is the resolver for the ledgerCheckpoints field.
+func (r *queryResolver) LedgerCheckpoints(ctx context.Context) ([]*model.LedgerCheckpoint, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	checkpoints, err := r.LedgerService.Checkpoints(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ledger checkpoints fail: %w", err)
+	}
+
+	result := make([]*model.LedgerCheckpoint, 0, len(checkpoints))
+	for _, c := range checkpoints {
+		result = append(result, toLedgerCheckpoint(c))
+	}
+	return result, nil
+}
+
+// Snapshot is the resolver for the snapshot field.
+func (r *queryResolver) Snapshot(ctx context.Context, id string) (*model.Snapshot, error) {
+	snapshotID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := r.SnapshotsService.Get(ctx, snapshotID)
+	if err != nil {
+		if errors.Is(err, snapshots.ErrorSnapshotNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("snapshot fail: %w", err)
+	}
+	return toSnapshot(snapshot), nil
+}
+
+// Snapshots is the resolver for the snapshots field.
+func (r *queryResolver) Snapshots(ctx context.Context) ([]*model.Snapshot, error) {
+	list, err := r.SnapshotsService.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("snapshots fail: %w", err)
+	}
+
+	result := make([]*model.Snapshot, 0, len(list))
+	for _, snapshot := range list {
+		result = append(result, toSnapshot(snapshot))
+	}
+	return result, nil
+}
+
+// BalanceProof is the resolver for the balanceProof field.
+func (r *queryResolver) BalanceProof(ctx context.Context, address string, snapshot string, token *string) (*model.BalanceProof, error) {
+	snapshotID, err := parseID(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	proof, err := r.SnapshotsService.Proof(ctx, address, tokenOrDefault(token), snapshotID)
+	if err != nil {
+		if errors.Is(err, snapshots.ErrorSnapshotNotFound) || errors.Is(err, merkle.ErrorLeafNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("balance proof fail: %w", err)
+	}
+
+	return &model.BalanceProof{
+		SnapshotID: formatID(proof.SnapshotID),
+		Address:    proof.Address,
+		Token:      proof.Token,
+		Balance:    model.Decimal(proof.Balance),
+		Root:       proof.Root,
+		Proof:      toProofSteps(proof.Proof),
+	}, nil
+}
+
+// Hold is the resolver for the hold field.
+func (r *queryResolver) Hold(ctx context.Context, id string) (*model.Hold, error) {
+	holdID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	hold, err := r.WalletsService.GetHold(ctx, holdID)
+	if err != nil {
+		if errors.Is(err, wallets.ErrorHoldNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("hold fail: %w", err)
+	}
+	return toHold(hold), nil
+}
+
+// MultisigWallet is the resolver for the multisigWallet field.
+func (r *queryResolver) MultisigWallet(ctx context.Context, address string) (*model.MultisigWallet, error) {
+	wallet, err := r.WalletsService.GetMultisigWallet(ctx, address)
+	if err != nil {
+		if errors.Is(err, wallets.ErrorMultisigNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("multisig wallet fail: %w", err)
+	}
+	return toMultisigWallet(wallet), nil
+}
+
+// MultisigProposal is the resolver for the multisigProposal field.
+func (r *queryResolver) MultisigProposal(ctx context.Context, id string) (*model.MultisigProposal, error) {
+	proposalID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	proposal, err := r.WalletsService.GetMultisigProposal(ctx, proposalID)
+	if err != nil {
+		if errors.Is(err, wallets.ErrorProposalNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("multisig proposal fail: %w", err)
+	}
+	return toMultisigProposal(proposal), nil
+}
+
+// MultisigProposals is the resolver for the multisigProposals field.
+func (r *queryResolver) MultisigProposals(ctx context.Context, wallet string, status *model.MultisigProposalStatus) ([]*model.MultisigProposal, error) {
+	var filter string
+	if status != nil {
+		filter = strings.ToLower(status.String())
+	}
+
+	proposals, err := r.WalletsService.ListMultisigProposals(ctx, wallet, filter)
+	if err != nil {
+		return nil, fmt.Errorf("multisig proposals fail: %w", err)
+	}
+
+	result := make([]*model.MultisigProposal, 0, len(proposals))
+	for _, proposal := range proposals {
+		result = append(result, toMultisigProposal(proposal))
+	}
+	return result, nil
+}
+
+// VestingGrant is the resolver for the vestingGrant field.
+func (r *queryResolver) VestingGrant(ctx context.Context, id string, at *time.Time) (*model.VestingGrant, error) {
+	grantID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	grant, err := r.WalletsService.GetVestingGrant(ctx, grantID)
+	if err != nil {
+		if errors.Is(err, wallets.ErrorVestingGrantNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("vesting grant fail: %w", err)
+	}
+	return toVestingGrant(grant, timeOrNow(at)), nil
+}
+
+// VestingGrants is the resolver for the vestingGrants field.
+func (r *queryResolver) VestingGrants(ctx context.Context, beneficiary string, at *time.Time) ([]*model.VestingGrant, error) {
+	grants, err := r.WalletsService.ListVestingGrants(ctx, beneficiary)
+	if err != nil {
+		return nil, fmt.Errorf("vesting grants fail: %w", err)
+	}
+
+	result := make([]*model.VestingGrant, 0, len(grants))
+	for _, grant := range grants {
+		result = append(result, toVestingGrant(grant, timeOrNow(at)))
+	}
+	return result, nil
+}
+
+// StakingPool is the resolver for the stakingPool field.
+func (r *queryResolver) StakingPool(ctx context.Context, token *string) (*model.StakingPool, error) {
+	pool, err := r.WalletsService.GetStakingPool(ctx, tokenOrDefault(token))
+	if err != nil {
+		if errors.Is(err, wallets.ErrorStakingPoolNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("staking pool fail: %w", err)
+	}
+	return toStakingPool(pool), nil
+}
+
+// Stake is the resolver for the stake field.
+func (r *queryResolver) Stake(ctx context.Context, id string) (*model.Stake, error) {
+	stakeID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	stake, err := r.WalletsService.GetStake(ctx, stakeID)
+	if err != nil {
+		if errors.Is(err, wallets.ErrorStakeNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("stake fail: %w", err)
+	}
+	return r.toOneStake(ctx, stake)
+}
+
+// Stakes is the resolver for the stakes field.
+func (r *queryResolver) Stakes(ctx context.Context, address string, token *string) ([]*model.Stake, error) {
+	symbol := tokenOrDefault(token)
+	stakes, err := r.WalletsService.ListStakes(ctx, address, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("stakes fail: %w", err)
+	}
+	return r.toStakes(ctx, symbol, stakes...)
+}
+
+// PendingStakingRewards is the resolver for the pendingStakingRewards field.
+func (r *queryResolver) PendingStakingRewards(ctx context.Context, address string, token *string) (*model.Decimal, error) {
+	symbol := tokenOrDefault(token)
+	stakes, err := r.WalletsService.ListStakes(ctx, address, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("pending staking rewards fail: %w", err)
+	}
+	result, err := r.toStakes(ctx, symbol, stakes...)
+	if err != nil {
+		return nil, err
+	}
 
-	if amount.IsNegative() || amount.IsZero() {
-		return "", errors.New("amount must be positive")
+	total := decimal.Zero
+	for _, stake := range result {
+		total = total.Add(decimal.Decimal(stake.PendingRewards))
 	}
+	pending := model.Decimal(total)
+	return &pending, nil
+}
 
-	if !amount.Equal(amount.Truncate(0)) {
-		return "", errors.New("amount must be an integer (cant be floating point)")
+// Distribution is the resolver for the distribution field.
+func (r *queryResolver) Distribution(ctx context.Context, id string) (*model.Distribution, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
 	}
 
-	updatedBalance, err := r.WalletsService.Transfer(ctx, input.FromAddress, input.ToAddress, amount)
+	distributionID, err := parseID(id)
 	if err != nil {
-		if errors.Is(err, wallets.ErrorInsufficientBalance) {
-			return "", errors.New("insufficient balance")
+		return nil, err
+	}
+
+	distribution, err := r.WalletsService.GetDistribution(ctx, distributionID)
+	if err != nil {
+		if errors.Is(err, wallets.ErrorDistributionNotFound) {
+			return nil, nil
 		}
-		return "", fmt.Errorf("transfer fail: %w", err)
+		return nil, fmt.Errorf("distribution fail: %w", err)
+	}
+	return toDistribution(distribution), nil
+}
+
+// Distributions is the resolver for the distributions field.
+func (r *queryResolver) Distributions(ctx context.Context, limit *int32) ([]*model.Distribution, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	list, err := r.WalletsService.ListDistributions(ctx, historyLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("distributions fail: %w", err)
+	}
+
+	result := make([]*model.Distribution, 0, len(list))
+	for _, distribution := range list {
+		result = append(result, toDistribution(distribution))
+	}
+	return result, nil
+}
+
+// Airdrop is the resolver for the airdrop field.
+func (r *queryResolver) Airdrop(ctx context.Context, id string) (*model.Airdrop, error) {
+	airdropID, err := parseID(id)
+	if err != nil {
+		return nil, err
 	}
 
-	return updatedBalance.String(), nil
+	airdrop, err := r.WalletsService.GetAirdrop(ctx, airdropID)
+	if err != nil {
+		if errors.Is(err, wallets.ErrorAirdropNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("airdrop fail: %w", err)
+	}
+	return toAirdrop(airdrop), nil
 }
 
-// Empty is the resolver for the _empty field.
-func (r *queryResolver) Empty(ctx context.Context) (*string, error) {
-	// panic(fmt.Errorf("not implemented: Empty - _empty"))
-	msg := "empty"
-	return &msg, nil
+// Airdrops is the resolver for the airdrops field.
+func (r *queryResolver) Airdrops(ctx context.Context, limit *int32) ([]*model.Airdrop, error) {
+	list, err := r.WalletsService.ListAirdrops(ctx, historyLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("airdrops fail: %w", err)
+	}
+
+	result := make([]*model.Airdrop, 0, len(list))
+	for _, airdrop := range list {
+		result = append(result, toAirdrop(airdrop))
+	}
+	return result, nil
+}
+
+// AirdropProof is the resolver for the airdropProof field.
+func (r *queryResolver) AirdropProof(ctx context.Context, id string, address string) (*model.AirdropClaim, error) {
+	airdropID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	claim, err := r.WalletsService.AirdropProof(ctx, airdropID, address)
+	if err != nil {
+		if errors.Is(err, wallets.ErrorAirdropNotFound) || errors.Is(err, merkle.ErrorLeafNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("airdrop proof fail: %w", err)
+	}
+	return toAirdropClaim(claim), nil
+}
+
+// PaymentRequest is the resolver for the paymentRequest field.
+func (r *queryResolver) PaymentRequest(ctx context.Context, reference string) (*model.PaymentRequest, error) {
+	request, err := r.WalletsService.GetPaymentRequestByReference(ctx, reference)
+	if err != nil {
+		if errors.Is(err, wallets.ErrorPaymentRequestNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("payment request fail: %w", err)
+	}
+	return toPaymentRequest(request), nil
+}
+
+// PaymentRequests is the resolver for the paymentRequests field.
+func (r *queryResolver) PaymentRequests(ctx context.Context, payee string, status *model.PaymentRequestStatus, limit *int32) ([]*model.PaymentRequest, error) {
+	var filter string
+	if status != nil {
+		filter = strings.ToLower(status.String())
+	}
+
+	requests, err := r.WalletsService.ListPaymentRequests(ctx, payee, filter, historyLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("payment requests fail: %w", err)
+	}
+
+	result := make([]*model.PaymentRequest, 0, len(requests))
+	for _, request := range requests {
+		result = append(result, toPaymentRequest(request))
+	}
+	return result, nil
+}
+
+// Holds is the resolver for the holds field.
+func (r *queryResolver) Holds(ctx context.Context, address string, token *string, status *model.HoldStatus) ([]*model.Hold, error) {
+	filter := ""
+	if status != nil {
+		filter = strings.ToLower(string(*status))
+	}
+
+	holds, err := r.WalletsService.ListHolds(ctx, address, tokenOrDefault(token), filter)
+	if err != nil {
+		return nil, fmt.Errorf("holds fail: %w", err)
+	}
+
+	result := make([]*model.Hold, 0, len(holds))
+	for _, hold := range holds {
+		result = append(result, toHold(hold))
+	}
+	return result, nil
+}
+
+// Escrow is the resolver for the escrow field.
+func (r *queryResolver) Escrow(ctx context.Context, id string) (*model.Escrow, error) {
+	escrowID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	escrow, err := r.WalletsService.GetEscrow(ctx, escrowID)
+	if err != nil {
+		if errors.Is(err, wallets.ErrorEscrowNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("escrow fail: %w", err)
+	}
+	return toEscrow(escrow), nil
+}
+
+// Escrows is the resolver for the escrows field.
+func (r *queryResolver) Escrows(ctx context.Context, address string) ([]*model.Escrow, error) {
+	escrows, err := r.WalletsService.ListEscrows(ctx, address)
+	if err != nil {
+		return nil, fmt.Errorf("escrows fail: %w", err)
+	}
+
+	result := make([]*model.Escrow, 0, len(escrows))
+	for _, escrow := range escrows {
+		result = append(result, toEscrow(escrow))
+	}
+	return result, nil
+}
+
+// Htlc is the resolver for the htlc field.
+func (r *queryResolver) Htlc(ctx context.Context, id string) (*model.Htlc, error) {
+	htlcID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	htlc, err := r.WalletsService.GetHtlc(ctx, htlcID)
+	if err != nil {
+		if errors.Is(err, wallets.ErrorHtlcNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("htlc fail: %w", err)
+	}
+	return toHtlc(htlc), nil
+}
+
+// Htlcs is the resolver for the htlcs field.
+func (r *queryResolver) Htlcs(ctx context.Context, address string) ([]*model.Htlc, error) {
+	htlcs, err := r.WalletsService.ListHtlcs(ctx, address)
+	if err != nil {
+		return nil, fmt.Errorf("htlcs fail: %w", err)
+	}
+
+	result := make([]*model.Htlc, 0, len(htlcs))
+	for _, htlc := range htlcs {
+		result = append(result, toHtlc(htlc))
+	}
+	return result, nil
+}
+
+// ScheduledTransfer is the resolver for the scheduledTransfer field.
+func (r *queryResolver) ScheduledTransfer(ctx context.Context, id string) (*model.ScheduledTransfer, error) {
+	scheduleID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := r.WalletsService.GetScheduledTransfer(ctx, scheduleID)
+	if err != nil {
+		if errors.Is(err, wallets.ErrorScheduleNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("scheduled transfer fail: %w", err)
+	}
+	return toScheduledTransfer(schedule), nil
+}
+
+// ScheduledTransfers is the resolver for the scheduledTransfers field.
+func (r *queryResolver) ScheduledTransfers(ctx context.Context, address string) ([]*model.ScheduledTransfer, error) {
+	schedules, err := r.WalletsService.ListScheduledTransfers(ctx, address)
+	if err != nil {
+		return nil, fmt.Errorf("scheduled transfers fail: %w", err)
+	}
+
+	result := make([]*model.ScheduledTransfer, 0, len(schedules))
+	for _, schedule := range schedules {
+		result = append(result, toScheduledTransfer(schedule))
+	}
+	return result, nil
+}
+
+// Transfers is the resolver for the transfers field.
+func (r *queryResolver) Transfers(ctx context.Context, address string, token *string, limit *int32) ([]*model.TransferReceipt, error) {
+	history, err := r.WalletsService.History(ctx, address, tokenOrDefault(token), historyLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("transfers fail: %w", err)
+	}
+
+	result := make([]*model.TransferReceipt, 0, len(history))
+	for _, receipt := range history {
+		result = append(result, toTransferReceipt(receipt))
+	}
+	return result, nil
+}
+
+// TransferReversal is the resolver for the transferReversal field.
+func (r *queryResolver) TransferReversal(ctx context.Context, transferID string) (*model.TransferReversal, error) {
+	entryID, err := parseID(transferID)
+	if err != nil {
+		return nil, err
+	}
+
+	reversal, err := r.WalletsService.GetReversal(ctx, entryID)
+	if err != nil {
+		if errors.Is(err, wallets.ErrorTransferNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("transfer reversal fail: %w", err)
+	}
+	return toTransferReversal(reversal), nil
+}
+
+// AddressStatus is the resolver for the addressStatus field.
+func (r *queryResolver) AddressStatus(ctx context.Context, address string) (*model.AddressStatus, error) {
+	status, err := r.ComplianceService.GetStatus(ctx, address)
+	if err != nil {
+		return nil, fmt.Errorf("address status fail: %w", err)
+	}
+	return toAddressStatus(status), nil
+}
+
+// WalletLimits is the resolver for the walletLimits field.
+func (r *queryResolver) WalletLimits(ctx context.Context, address string, token *string) (*model.WalletLimitUsage, error) {
+	usage, err := r.LimitsService.Usage(ctx, address, tokenOrDefault(token))
+	if err != nil {
+		return nil, fmt.Errorf("wallet limits fail: %w", err)
+	}
+	return toWalletLimitUsage(usage), nil
+}
+
+// TierLimits is the resolver for the tierLimits field.
+func (r *queryResolver) TierLimits(ctx context.Context) ([]*model.TierLimits, error) {
+	list, err := r.LimitsService.ListLimits(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("tier limits fail: %w", err)
+	}
+
+	result := make([]*model.TierLimits, 0, len(list))
+	for _, tierLimits := range list {
+		result = append(result, toTierLimits(tierLimits))
+	}
+	return result, nil
+}
+
+// PolicyRules is the resolver for the policyRules field.
+func (r *queryResolver) PolicyRules(ctx context.Context) ([]*model.PolicyRule, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	rules, err := r.PolicyService.Rules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("policy rules fail: %w", err)
+	}
+
+	result := make([]*model.PolicyRule, 0, len(rules))
+	for _, rule := range rules {
+		result = append(result, toPolicyRule(rule))
+	}
+	return result, nil
+}
+
+// PolicyRuleVersions is the resolver for the policyRuleVersions field.
+func (r *queryResolver) PolicyRuleVersions(ctx context.Context, name string) ([]*model.PolicyRule, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	rules, err := r.PolicyService.RuleVersions(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("policy rule versions fail: %w", err)
+	}
+
+	result := make([]*model.PolicyRule, 0, len(rules))
+	for _, rule := range rules {
+		result = append(result, toPolicyRule(rule))
+	}
+	return result, nil
+}
+
+// EvaluatePolicies is the resolver for the evaluatePolicies field.
+func (r *queryResolver) EvaluatePolicies(ctx context.Context, input model.Transfer, rules []*model.PolicyRuleInput) (*model.PolicyEvaluation, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	token := tokenOrDefault(input.Token)
+	amount, err := r.parseAmount(ctx, token, input.Amount, input.Unit)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]policy.Rule, 0, len(rules))
+	for _, rule := range rules {
+		candidates = append(candidates, fromPolicyRuleInput(rule))
+	}
+
+	decision, err := r.PolicyService.Evaluate(ctx, policy.Transfer{
+		Token:       token,
+		FromAddress: input.FromAddress,
+		ToAddress:   input.ToAddress,
+		Amount:      amount,
+		Time:        time.Now(),
+	}, candidates)
+	if err != nil {
+		return nil, failure("evaluate policies", err)
+	}
+	return toPolicyEvaluation(decision), nil
+}
+
+// FlaggedTransfers is the resolver for the flaggedTransfers field.
+func (r *queryResolver) FlaggedTransfers(ctx context.Context, limit *int32) ([]*model.PolicyFlag, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	flags, err := r.PolicyService.Flags(ctx, historyLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("flagged transfers fail: %w", err)
+	}
+
+	result := make([]*model.PolicyFlag, 0, len(flags))
+	for _, flag := range flags {
+		result = append(result, &model.PolicyFlag{
+			TransferID: formatID(flag.EntryID),
+			Rule:       toPolicyRule(flag.Rule),
+			CreatedAt:  flag.CreatedAt,
+		})
+	}
+	return result, nil
+}
+
+// Alerts is the resolver for the alerts field.
+func (r *queryResolver) Alerts(ctx context.Context, status *model.AlertStatus, address *string, limit *int32) ([]*model.Alert, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	var filter, filterAddress string
+	if status != nil {
+		filter = strings.ToLower(status.String())
+	}
+	if address != nil {
+		filterAddress = *address
+	}
+
+	list, err := r.AlertsService.Alerts(ctx, filter, filterAddress, historyLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("alerts fail: %w", err)
+	}
+
+	result := make([]*model.Alert, 0, len(list))
+	for _, alert := range list {
+		result = append(result, toAlert(alert))
+	}
+	return result, nil
+}
+
+// Alert is the resolver for the alert field.
+func (r *queryResolver) Alert(ctx context.Context, id string) (*model.Alert, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	alertID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	alert, err := r.AlertsService.GetAlert(ctx, alertID)
+	if err != nil {
+		if errors.Is(err, alerts.ErrorAlertNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("alert fail: %w", err)
+	}
+	return toAlert(alert), nil
+}
+
+// AlertDetectors is the resolver for the alertDetectors field.
+func (r *queryResolver) AlertDetectors(ctx context.Context) ([]*model.AlertDetector, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	detectors, err := r.AlertsService.Detectors(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("alert detectors fail: %w", err)
+	}
+
+	result := make([]*model.AlertDetector, 0, len(detectors))
+	for _, detector := range detectors {
+		result = append(result, toAlertDetector(detector))
+	}
+	return result, nil
+}
+
+// DeniedAddresses is the resolver for the deniedAddresses field.
+func (r *queryResolver) DeniedAddresses(ctx context.Context, list *string) ([]string, error) {
+	if _, err := auth.RequireOperator(ctx); err != nil {
+		return nil, err
+	}
+
+	addresses, err := r.ComplianceService.DeniedAddresses(ctx, stringOrEmpty(list))
+	if err != nil {
+		return nil, fmt.Errorf("denied addresses fail: %w", err)
+	}
+	if addresses == nil {
+		addresses = []string{}
+	}
+	return addresses, nil
+}
+
+// FeePolicy is the resolver for the feePolicy field.
+func (r *queryResolver) FeePolicy(ctx context.Context, token *string) (*model.FeePolicy, error) {
+	policy, err := r.FeesService.Get(ctx, tokenOrDefault(token))
+	if err != nil {
+		if errors.Is(err, fees.ErrorFeePolicyNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("fee policy fail: %w", err)
+	}
+	return toFeePolicy(policy), nil
+}
+
+// SimulateTransfer is the resolver for the simulateTransfer field.
+func (r *queryResolver) SimulateTransfer(ctx context.Context, input model.Transfer) (*model.TransferSimulation, error) {
+	return r.simulate(ctx, input)
+}
+
+// QuoteTransfer is the resolver for the quoteTransfer field.
+func (r *queryResolver) QuoteTransfer(ctx context.Context, amount model.Decimal, unit *model.AmountUnit, token *string) (*model.TransferQuote, error) {
+	symbol := tokenOrDefault(token)
+	value, err := r.parseAmount(ctx, symbol, amount, unit)
+	if err != nil {
+		return nil, err
+	}
+
+	quote, err := r.FeesService.Quote(ctx, symbol, value)
+	if err != nil {
+		if errors.Is(err, fees.ErrorFeeExceedsAmount) || errors.Is(err, tokens.ErrorTokenNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("quote fail: %w", err)
+	}
+	return toTransferQuote(quote), nil
+}
+
+// Runs is the resolver for the runs field.
+func (r *scheduledTransferResolver) Runs(ctx context.Context, obj *model.ScheduledTransfer, limit *int32) ([]*model.ScheduleRun, error) {
+	id, err := parseID(obj.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	runs, err := r.WalletsService.ScheduleRuns(ctx, id, historyLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("schedule runs fail: %w", err)
+	}
+
+	result := make([]*model.ScheduleRun, 0, len(runs))
+	for _, run := range runs {
+		result = append(result, toScheduleRun(run))
+	}
+	return result, nil
+}
+
+// TotalSupply is the resolver for the total_supply field.
+func (r *tokenResolver) TotalSupply(ctx context.Context, obj *model.Token) (*model.Decimal, error) {
+	supply, err := r.TokensService.TotalSupply(ctx, obj.Symbol)
+	if err != nil {
+		return nil, fmt.Errorf("total supply fail: %w", err)
+	}
+	result := model.Decimal(supply)
+	return &result, nil
+}
+
+// BalanceBaseUnits is the resolver for the balance_base_units field.
+func (r *walletResolver) BalanceBaseUnits(ctx context.Context, obj *model.Wallet) (*model.Decimal, error) {
+	token, err := r.TokensService.Get(ctx, obj.Token)
+	if err != nil {
+		return nil, fmt.Errorf("token fail: %w", err)
+	}
+	result := model.Decimal(token.ToBaseUnits(decimal.Decimal(obj.Balance)))
+	return &result, nil
+}
+
+// BalanceAt is the resolver for the balanceAt field.
+func (r *walletResolver) BalanceAt(ctx context.Context, obj *model.Wallet, time time.Time) (*model.Decimal, error) {
+	balance, err := r.SnapshotsService.BalanceAt(ctx, obj.Address, obj.Token, time)
+	if err != nil {
+		return nil, fmt.Errorf("historical balance fail: %w", err)
+	}
+	result := model.Decimal(balance)
+	return &result, nil
+}
+
+// BalanceAtSnapshot is the resolver for the balanceAtSnapshot field.
+func (r *walletResolver) BalanceAtSnapshot(ctx context.Context, obj *model.Wallet, id string) (*model.Decimal, error) {
+	snapshotID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := r.SnapshotsService.BalanceAtSnapshot(ctx, obj.Address, obj.Token, snapshotID)
+	if err != nil {
+		if errors.Is(err, snapshots.ErrorSnapshotNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("historical balance fail: %w", err)
+	}
+	result := model.Decimal(balance)
+	return &result, nil
 }
 
+// AddressStatus returns AddressStatusResolver implementation.
+func (r *Resolver) AddressStatus() AddressStatusResolver { return &addressStatusResolver{r} }
+
+// Alert returns AlertResolver implementation.
+func (r *Resolver) Alert() AlertResolver { return &alertResolver{r} }
+
+// Distribution returns DistributionResolver implementation.
+func (r *Resolver) Distribution() DistributionResolver { return &distributionResolver{r} }
+
+// Escrow returns EscrowResolver implementation.
+func (r *Resolver) Escrow() EscrowResolver { return &escrowResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
+// PaymentRequest returns PaymentRequestResolver implementation.
+func (r *Resolver) PaymentRequest() PaymentRequestResolver { return &paymentRequestResolver{r} }
+
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// ScheduledTransfer returns ScheduledTransferResolver implementation.
+func (r *Resolver) ScheduledTransfer() ScheduledTransferResolver {
+	return &scheduledTransferResolver{r}
+}
+
+// Token returns TokenResolver implementation.
+func (r *Resolver) Token() TokenResolver { return &tokenResolver{r} }
+
+// Wallet returns WalletResolver implementation.
+func (r *Resolver) Wallet() WalletResolver { return &walletResolver{r} }
+
+type addressStatusResolver struct{ *Resolver }
+type alertResolver struct{ *Resolver }
+type distributionResolver struct{ *Resolver }
+type escrowResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
+type paymentRequestResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type scheduledTransferResolver struct{ *Resolver }
+type tokenResolver struct{ *Resolver }
+type walletResolver struct{ *Resolver }
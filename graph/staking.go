@@ -0,0 +1,37 @@
+package graph
+
+import (
+	"btp_tokens/graph/model"
+	"btp_tokens/internal/wallets"
+	"context"
+	"fmt"
+	"time"
+)
+
+// toStakes converts stakes of a single token with their pending rewards,
+// which need the token's staking pool.
+func (r *Resolver) toStakes(ctx context.Context, token string, stakes ...wallets.Stake) ([]*model.Stake, error) {
+	result := make([]*model.Stake, 0, len(stakes))
+	if len(stakes) == 0 {
+		return result, nil
+	}
+
+	pool, err := r.WalletsService.GetStakingPool(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("staking pool fail: %w", err)
+	}
+	now := time.Now()
+	for _, stake := range stakes {
+		result = append(result, toStake(stake, pool, now))
+	}
+	return result, nil
+}
+
+// toOneStake converts a stake returned by a mutation.
+func (r *Resolver) toOneStake(ctx context.Context, stake wallets.Stake) (*model.Stake, error) {
+	result, err := r.toStakes(ctx, stake.Token, stake)
+	if err != nil {
+		return nil, err
+	}
+	return result[0], nil
+}
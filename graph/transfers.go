@@ -0,0 +1,94 @@
+package graph
+
+import (
+	"btp_tokens/graph/model"
+	"btp_tokens/internal/fees"
+	"btp_tokens/internal/limits"
+	"btp_tokens/internal/policy"
+	"btp_tokens/internal/tokens"
+	"btp_tokens/internal/wallets"
+	"context"
+	"errors"
+	"fmt"
+)
+
+const maxHistoryLimit = 500
+
+// historyLimit caps the limit argument of list queries at maxHistoryLimit.
+func historyLimit(limit *int32) int {
+	if limit != nil && *limit > 0 && int(*limit) < maxHistoryLimit {
+		return int(*limit)
+	}
+	return maxHistoryLimit
+}
+
+// send validates and executes a transfer input, through the batcher when it
+// is enabled.
+func (r *Resolver) send(ctx context.Context, input model.Transfer) (wallets.Receipt, error) {
+	token := tokenOrDefault(input.Token)
+	amount, err := r.parseAmount(ctx, token, input.Amount, input.Unit)
+	if err != nil {
+		return wallets.Receipt{}, err
+	}
+
+	var receipt wallets.Receipt
+	if r.TransferBatcher != nil {
+		receipt, err = r.TransferBatcher.Send(ctx, token, input.FromAddress, input.ToAddress, amount)
+	} else {
+		receipt, err = r.WalletsService.Send(ctx, token, input.FromAddress, input.ToAddress, amount)
+	}
+	if err != nil {
+		if errors.Is(err, wallets.ErrorInsufficientBalance) {
+			return wallets.Receipt{}, coded(CodeInsufficientBalance, "insufficient balance")
+		}
+		if errors.Is(err, tokens.ErrorTokenNotFound) || errors.Is(err, fees.ErrorFeeExceedsAmount) || errors.Is(err, limits.ErrorLimitExceeded) || errors.Is(err, policy.ErrorPolicyDenied) {
+			return wallets.Receipt{}, err
+		}
+		return wallets.Receipt{}, fmt.Errorf("transfer fail: %w", err)
+	}
+	return receipt, nil
+}
+
+// simulate runs the validation of send and a rolled back transfer. Rejections
+// are reported in the result, only unexpected failures are returned as errors.
+func (r *Resolver) simulate(ctx context.Context, input model.Transfer) (*model.TransferSimulation, error) {
+	token := tokenOrDefault(input.Token)
+	result := &model.TransferSimulation{Token: token}
+
+	reject := func(err error) (*model.TransferSimulation, error) {
+		code := errorCode(err)
+		if code == CodeInternal {
+			return nil, fmt.Errorf("simulation fail: %w", err)
+		}
+		msg := err.Error()
+		if code == CodeInsufficientBalance {
+			msg = "insufficient balance"
+		}
+		result.ErrorCode = &code
+		result.Error = &msg
+		return result, nil
+	}
+
+	amount, err := r.parseAmount(ctx, token, input.Amount, input.Unit)
+	if err != nil {
+		return reject(err)
+	}
+	value := model.Decimal(amount)
+	result.Amount = &value
+
+	simulation, err := r.WalletsService.Simulate(ctx, token, input.FromAddress, input.ToAddress, amount)
+	if err != nil {
+		return reject(err)
+	}
+
+	fee := model.Decimal(simulation.Receipt.Fee)
+	netAmount := model.Decimal(simulation.Receipt.NetAmount)
+	senderBalance := model.Decimal(simulation.Receipt.SenderBalance)
+	receiverBalance := model.Decimal(simulation.ReceiverBalance)
+	result.Ok = true
+	result.Fee = &fee
+	result.NetAmount = &netAmount
+	result.SenderBalance = &senderBalance
+	result.ReceiverBalance = &receiverBalance
+	return result, nil
+}
@@ -0,0 +1,47 @@
+package graph
+
+import (
+	"btp_tokens/graph/model"
+	"btp_tokens/internal/tokens"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+func tokenOrDefault(token *string) string {
+	if token == nil || *token == "" {
+		return tokens.DefaultSymbol
+	}
+	return *token
+}
+
+// parseAmount converts an input amount given in unit to a token amount and
+// checks it against the token's decimals.
+func (r *Resolver) parseAmount(ctx context.Context, symbol string, input model.Decimal, unit *model.AmountUnit) (decimal.Decimal, error) {
+	amount := decimal.Decimal(input)
+	if !amount.IsPositive() {
+		return decimal.Decimal{}, coded(CodeInvalidAmount, "amount must be positive")
+	}
+
+	token, err := r.TokensService.Get(ctx, symbol)
+	if err != nil {
+		if errors.Is(err, tokens.ErrorTokenNotFound) {
+			return decimal.Decimal{}, err
+		}
+		return decimal.Decimal{}, fmt.Errorf("token fail: %w", err)
+	}
+
+	if unit != nil && *unit == model.AmountUnitBase {
+		return token.FromBaseUnits(amount)
+	}
+
+	if err := token.CheckPrecision(amount); err != nil {
+		if token.Decimals == 0 {
+			return decimal.Decimal{}, coded(CodeInvalidAmount, "amount must be an integer (cant be floating point)")
+		}
+		return decimal.Decimal{}, coded(CodeInvalidAmount, fmt.Sprintf("amount must have at most %d decimal places", token.Decimals))
+	}
+	return amount, nil
+}
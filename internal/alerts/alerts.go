@@ -0,0 +1,501 @@
+// Package alerts runs detectors of suspicious activity over committed
+// transfers and keeps the alerts they raise for operators to review.
+package alerts
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"btp_tokens/internal/ledger"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// Alert severities, from the least to the most severe.
+const (
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+// Alert states.
+const (
+	StatusOpen         = "open"
+	StatusAcknowledged = "acknowledged"
+	StatusEscalated    = "escalated"
+	StatusClosed       = "closed"
+)
+
+// Actions recorded in an alert's history.
+const (
+	ActionRaise       = "raise"
+	ActionAcknowledge = "acknowledge"
+	ActionEscalate    = "escalate"
+	ActionClose       = "close"
+)
+
+// systemOperator is the operator of the events recorded by detectors.
+const systemOperator = "system"
+
+// scanBatch is the number of journal entries a scan reads at most.
+const scanBatch = 500
+
+var severities = []string{SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+// Alert is raised by a detector on the transfer EntryID of Address.
+type Alert struct {
+	ID       int64
+	Detector string
+	Severity string
+	Status   string
+	Address  string
+	Token    string
+	EntryID  int64
+	// Evidence explains the alert, EvidenceEntryIDs are the transfers it is
+	// based on
+	Evidence         string
+	EvidenceEntryIDs []int64
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// Event is a change of an alert's state.
+type Event struct {
+	Action    string
+	Status    string
+	Note      string
+	Operator  string
+	CreatedAt time.Time
+}
+
+// Detector is the configuration of a detector, Thresholds holds every
+// threshold with the defaults of those that were not configured.
+type Detector struct {
+	Name       string
+	Enabled    bool
+	Severity   string
+	Thresholds map[string]decimal.Decimal
+	Operator   string
+	UpdatedAt  *time.Time
+}
+
+type AlertsService struct {
+	DB *sql.DB
+}
+
+var ErrorAlertNotFound = errors.New("alert not found")
+var ErrorInvalidTransition = errors.New("invalid alert transition")
+var ErrorNoteRequired = errors.New("note required")
+var ErrorInvalidDetector = errors.New("invalid detector configuration")
+var ErrorUnknownDetector = errors.New("unknown detector")
+
+func validSeverity(severity string) bool {
+	for _, s := range severities {
+		if s == severity {
+			return true
+		}
+	}
+	return false
+}
+
+func severityRank(severity string) int {
+	for i, s := range severities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// Detectors returns the configuration of every detector.
+func (s *AlertsService) Detectors(ctx context.Context) ([]Detector, error) {
+	configured, err := loadDetectors(ctx, s.DB)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Detector, 0, len(detectors))
+	for _, d := range detectors {
+		list = append(list, configured[d.name])
+	}
+	return list, nil
+}
+
+func loadDetectors(ctx context.Context, q queryer) (map[string]Detector, error) {
+	configured := make(map[string]Detector, len(detectors))
+	for _, d := range detectors {
+		configured[d.name] = d.defaults()
+	}
+
+	rows, err := q.QueryContext(ctx, "SELECT Name, Enabled, Severity, Thresholds, Operator, Updated_At FROM Alert_Detectors")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, severity, operator string
+		var enabled bool
+		var thresholds []byte
+		var updatedAt time.Time
+		if err := rows.Scan(&name, &enabled, &severity, &thresholds, &operator, &updatedAt); err != nil {
+			return nil, err
+		}
+		d, ok := configured[name]
+		if !ok {
+			continue
+		}
+		var overrides map[string]decimal.Decimal
+		if err := json.Unmarshal(thresholds, &overrides); err != nil {
+			return nil, err
+		}
+		// values stored before a threshold was bounded keep the default
+		for threshold, value := range overrides {
+			if validThreshold(name, threshold, value) {
+				d.Thresholds[threshold] = value
+			}
+		}
+		d.Enabled, d.Severity, d.Operator, d.UpdatedAt = enabled, severity, operator, &updatedAt
+		configured[name] = d
+	}
+	return configured, rows.Err()
+}
+
+// ConfigureDetector changes the configuration of the detector name, what is
+// left out keeps its value.
+func (s *AlertsService) ConfigureDetector(ctx context.Context, name string, enabled *bool, severity string, thresholds map[string]decimal.Decimal, operator string) (Detector, error) {
+	configured, err := loadDetectors(ctx, s.DB)
+	if err != nil {
+		return Detector{}, err
+	}
+	d, ok := configured[name]
+	if !ok {
+		return Detector{}, ErrorUnknownDetector
+	}
+
+	if enabled != nil {
+		d.Enabled = *enabled
+	}
+	if severity != "" {
+		if !validSeverity(severity) {
+			return Detector{}, ErrorInvalidDetector
+		}
+		d.Severity = severity
+	}
+	for threshold, value := range thresholds {
+		if !validThreshold(name, threshold, value) {
+			return Detector{}, ErrorInvalidDetector
+		}
+		d.Thresholds[threshold] = value
+	}
+
+	encoded, err := json.Marshal(d.Thresholds)
+	if err != nil {
+		return Detector{}, err
+	}
+	_, err = s.DB.ExecContext(ctx, `
+		INSERT INTO Alert_Detectors (Name, Enabled, Severity, Thresholds, Operator) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (Name) DO UPDATE SET
+			Enabled = EXCLUDED.Enabled, Severity = EXCLUDED.Severity, Thresholds = EXCLUDED.Thresholds,
+			Operator = EXCLUDED.Operator, Updated_At = now()
+	`, d.Name, d.Enabled, d.Severity, encoded, operator)
+	if err != nil {
+		return Detector{}, err
+	}
+
+	configured, err = loadDetectors(ctx, s.DB)
+	if err != nil {
+		return Detector{}, err
+	}
+	return configured[name], nil
+}
+
+// Scan runs the enabled detectors on the journal entries committed since the
+// last scan, at most scanBatch of them, and returns the alerts raised.
+// Entries are chained under a lock held until they commit, so their ids
+// grow in commit order and the cursor never skips one. Concurrent scans
+// wait for each other on the cursor row.
+func (s *AlertsService) Scan(ctx context.Context) ([]Alert, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO Alert_Cursor DEFAULT VALUES ON CONFLICT DO NOTHING"); err != nil {
+		return nil, err
+	}
+	var last int64
+	if err := tx.QueryRowContext(ctx, "SELECT Last_Entry_Id FROM Alert_Cursor FOR UPDATE").Scan(&last); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT Id, Kind, Token, COALESCE(From_Address, ''), COALESCE(To_Address, ''), Amount, Created_At
+		FROM Journal_Entries WHERE Id > $1 ORDER BY Id ASC LIMIT $2
+	`, last, scanBatch)
+	if err != nil {
+		return nil, err
+	}
+	var entries []ledger.Entry
+	for rows.Next() {
+		var e ledger.Entry
+		if err := rows.Scan(&e.ID, &e.Kind, &e.Token, &e.FromAddress, &e.ToAddress, &e.Amount, &e.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	configured, err := loadDetectors(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var raised []Alert
+	for _, entry := range entries {
+		if entry.Kind != ledger.KindTransfer {
+			continue
+		}
+		for _, d := range detectors {
+			config := configured[d.name]
+			if !config.Enabled {
+				continue
+			}
+			finding, err := d.detect(ctx, tx, entry, config.Thresholds)
+			if err != nil {
+				return nil, err
+			}
+			if finding == nil {
+				continue
+			}
+			alert, err := raise(ctx, tx, config, entry, *finding)
+			if err != nil {
+				return nil, err
+			}
+			if alert != nil {
+				raised = append(raised, *alert)
+			}
+		}
+	}
+
+	last = entries[len(entries)-1].ID
+	if _, err := tx.ExecContext(ctx, "UPDATE Alert_Cursor SET Last_Entry_Id = $1", last); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return raised, nil
+}
+
+// raise stores an alert for finding, unless the detector already has one
+// for the address under review.
+func raise(ctx context.Context, tx *sql.Tx, d Detector, entry ledger.Entry, f finding) (*Alert, error) {
+	var open bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM Alerts WHERE Address = $1 AND Detector = $2 AND Status <> $3)
+	`, f.address, d.Name, StatusClosed).Scan(&open)
+	if err != nil || open {
+		return nil, err
+	}
+
+	alert := &Alert{
+		Detector:         d.Name,
+		Severity:         d.Severity,
+		Status:           StatusOpen,
+		Address:          f.address,
+		Token:            entry.Token,
+		EntryID:          entry.ID,
+		Evidence:         f.evidence,
+		EvidenceEntryIDs: f.entries,
+	}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO Alerts (Detector, Severity, Address, Token, Entry_Id, Evidence, Evidence_Entry_Ids)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING Id, Created_At, Updated_At
+	`, alert.Detector, alert.Severity, alert.Address, alert.Token, alert.EntryID, alert.Evidence, pq.Array(alert.EvidenceEntryIDs)).Scan(&alert.ID, &alert.CreatedAt, &alert.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordEvent(ctx, tx, alert.ID, Event{Action: ActionRaise, Status: StatusOpen, Operator: systemOperator}); err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+// RunScanner scans new entries every interval until ctx is done.
+func (s *AlertsService) RunScanner(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Scan(ctx); err != nil {
+				log.Println("alert scan fail:", err)
+			}
+		}
+	}
+}
+
+const alertColumns = "Id, Detector, Severity, Status, Address, Token, Entry_Id, Evidence, Evidence_Entry_Ids, Created_At, Updated_At"
+
+func scanAlert(row interface{ Scan(...any) error }) (Alert, error) {
+	var a Alert
+	err := row.Scan(&a.ID, &a.Detector, &a.Severity, &a.Status, &a.Address, &a.Token, &a.EntryID, &a.Evidence, pq.Array(&a.EvidenceEntryIDs), &a.CreatedAt, &a.UpdatedAt)
+	return a, err
+}
+
+// GetAlert returns the alert id.
+func (s *AlertsService) GetAlert(ctx context.Context, id int64) (Alert, error) {
+	alert, err := scanAlert(s.DB.QueryRowContext(ctx, "SELECT "+alertColumns+" FROM Alerts WHERE Id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Alert{}, ErrorAlertNotFound
+	}
+	return alert, err
+}
+
+// Alerts returns the latest alerts, newest first, optionally only those in
+// status or about address.
+func (s *AlertsService) Alerts(ctx context.Context, status string, address string, limit int) ([]Alert, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+alertColumns+` FROM Alerts
+		WHERE ($1 = '' OR Status = $1) AND ($2 = '' OR Address = $2)
+		ORDER BY Id DESC
+		LIMIT $3
+	`, status, address, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []Alert
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
+
+// Acknowledge marks an open alert as being reviewed.
+func (s *AlertsService) Acknowledge(ctx context.Context, id int64, note string, operator string) (Alert, error) {
+	return s.transition(ctx, id, ActionAcknowledge, note, "", operator)
+}
+
+// Escalate hands an open or acknowledged alert on for further review,
+// optionally raising its severity.
+func (s *AlertsService) Escalate(ctx context.Context, id int64, note string, severity string, operator string) (Alert, error) {
+	if strings.TrimSpace(note) == "" {
+		return Alert{}, ErrorNoteRequired
+	}
+	if severity != "" && !validSeverity(severity) {
+		return Alert{}, ErrorInvalidTransition
+	}
+	return s.transition(ctx, id, ActionEscalate, note, severity, operator)
+}
+
+// Close resolves an alert, note records the outcome of the review.
+func (s *AlertsService) Close(ctx context.Context, id int64, note string, operator string) (Alert, error) {
+	if strings.TrimSpace(note) == "" {
+		return Alert{}, ErrorNoteRequired
+	}
+	return s.transition(ctx, id, ActionClose, note, "", operator)
+}
+
+// transitions are the states each action can be taken from, and the state
+// it leads to.
+var transitions = map[string]struct {
+	from []string
+	to   string
+}{
+	ActionAcknowledge: {[]string{StatusOpen}, StatusAcknowledged},
+	ActionEscalate:    {[]string{StatusOpen, StatusAcknowledged}, StatusEscalated},
+	ActionClose:       {[]string{StatusOpen, StatusAcknowledged, StatusEscalated}, StatusClosed},
+}
+
+func (s *AlertsService) transition(ctx context.Context, id int64, action string, note string, severity string, operator string) (Alert, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Alert{}, err
+	}
+	defer tx.Rollback()
+
+	alert, err := scanAlert(tx.QueryRowContext(ctx, "SELECT "+alertColumns+" FROM Alerts WHERE Id = $1 FOR UPDATE", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Alert{}, ErrorAlertNotFound
+	}
+	if err != nil {
+		return Alert{}, err
+	}
+
+	t := transitions[action]
+	allowed := false
+	for _, from := range t.from {
+		allowed = allowed || alert.Status == from
+	}
+	if !allowed {
+		return Alert{}, ErrorInvalidTransition
+	}
+	alert.Status = t.to
+	if severityRank(severity) > severityRank(alert.Severity) {
+		alert.Severity = severity
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		UPDATE Alerts SET Status = $2, Severity = $3, Updated_At = now() WHERE Id = $1 RETURNING Updated_At
+	`, id, alert.Status, alert.Severity).Scan(&alert.UpdatedAt)
+	if err != nil {
+		return Alert{}, err
+	}
+	if err := recordEvent(ctx, tx, id, Event{Action: action, Status: alert.Status, Note: note, Operator: operator}); err != nil {
+		return Alert{}, err
+	}
+	return alert, tx.Commit()
+}
+
+// History returns the changes of the alert id, oldest first.
+func (s *AlertsService) History(ctx context.Context, id int64) ([]Event, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT Action, Status, COALESCE(Note, ''), Operator, Created_At
+		FROM Alert_Events WHERE Alert_Id = $1 ORDER BY Id ASC
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.Action, &e.Status, &e.Note, &e.Operator, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func recordEvent(ctx context.Context, tx *sql.Tx, alertID int64, e Event) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO Alert_Events (Alert_Id, Action, Status, Note, Operator) VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+	`, alertID, e.Action, e.Status, e.Note, e.Operator)
+	return err
+}
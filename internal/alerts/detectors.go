@@ -0,0 +1,231 @@
+package alerts
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"btp_tokens/internal/ledger"
+	"btp_tokens/internal/limits"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// Names of the detectors.
+const (
+	DetectorStructuring = "structuring"
+	DetectorFanOut      = "fan_out"
+	DetectorCircular    = "circular"
+)
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// finding is what a detector reports on a transfer.
+type finding struct {
+	address  string
+	evidence string
+	entries  []int64
+}
+
+// detector looks at one committed transfer, with the journal as it was when
+// the transfer was recorded.
+type detector struct {
+	name       string
+	severity   string
+	thresholds map[string]decimal.Decimal
+	// maxima bound thresholds the detector's queries grow too fast with
+	maxima map[string]decimal.Decimal
+	detect func(ctx context.Context, q queryer, e ledger.Entry, t thresholds) (*finding, error)
+}
+
+// validThreshold reports whether value may be set for threshold of the
+// detector name.
+func validThreshold(name string, threshold string, value decimal.Decimal) bool {
+	for _, d := range detectors {
+		if d.name != name {
+			continue
+		}
+		if _, known := d.thresholds[threshold]; !known || value.IsNegative() {
+			return false
+		}
+		max, bounded := d.maxima[threshold]
+		return !bounded || value.LessThanOrEqual(max)
+	}
+	return false
+}
+
+type thresholds map[string]decimal.Decimal
+
+func (t thresholds) duration(name string) time.Duration {
+	return time.Duration(t[name].IntPart()) * time.Second
+}
+
+func (t thresholds) count(name string) int64 {
+	return t[name].IntPart()
+}
+
+func (d detector) defaults() Detector {
+	config := Detector{Name: d.name, Enabled: true, Severity: d.severity, Thresholds: make(map[string]decimal.Decimal)}
+	for name, value := range d.thresholds {
+		config.Thresholds[name] = value
+	}
+	return config
+}
+
+var detectors = []detector{
+	{
+		// many transfers just under the sender's per transfer limit in a
+		// short time, limit replaces the tier's limit when it is not zero
+		name:     DetectorStructuring,
+		severity: SeverityMedium,
+		thresholds: map[string]decimal.Decimal{
+			"window_seconds": decimal.NewFromInt(3600),
+			"count":          decimal.NewFromInt(3),
+			"ratio":          decimal.RequireFromString("0.9"),
+			"limit":          decimal.Zero,
+		},
+		detect: detectStructuring,
+	},
+	{
+		// a wallet sending to many receivers soon after it first received
+		// funds
+		name:     DetectorFanOut,
+		severity: SeverityHigh,
+		thresholds: map[string]decimal.Decimal{
+			"funded_within_seconds": decimal.NewFromInt(86400),
+			"receivers":             decimal.NewFromInt(5),
+		},
+		detect: detectFanOut,
+	},
+	{
+		// funds coming back to the sender through at most max_length
+		// transfers, the one scanned included; the paths followed grow
+		// exponentially with it
+		name:     DetectorCircular,
+		severity: SeverityHigh,
+		thresholds: map[string]decimal.Decimal{
+			"window_seconds": decimal.NewFromInt(86400),
+			"max_length":     decimal.NewFromInt(3),
+		},
+		maxima: map[string]decimal.Decimal{
+			"max_length": decimal.NewFromInt(6),
+		},
+		detect: detectCircular,
+	},
+}
+
+func detectStructuring(ctx context.Context, q queryer, e ledger.Entry, t thresholds) (*finding, error) {
+	limit := t["limit"]
+	if limit.IsZero() {
+		usages, err := limits.Load(ctx, q, []string{e.FromAddress}, []string{e.Token})
+		if err != nil {
+			return nil, err
+		}
+		usage := usages[limits.Key{Address: e.FromAddress, Token: e.Token}]
+		if usage == nil || usage.PerTransfer == nil {
+			return nil, nil
+		}
+		limit = *usage.PerTransfer
+	}
+	floor := limit.Mul(t["ratio"])
+	if e.Amount.LessThan(floor) || e.Amount.GreaterThan(limit) {
+		return nil, nil
+	}
+
+	ids, err := entryIDs(ctx, q, `
+		SELECT Id FROM Journal_Entries
+		WHERE From_Address = $1 AND Token = $2 AND Kind = $3 AND Id <= $4
+			AND Created_At > $5 AND Amount >= $6 AND Amount <= $7
+		ORDER BY Id ASC
+	`, e.FromAddress, e.Token, ledger.KindTransfer, e.ID, e.CreatedAt.Add(-t.duration("window_seconds")), floor, limit)
+	if err != nil || int64(len(ids)) < t.count("count") {
+		return nil, err
+	}
+	return &finding{
+		address:  e.FromAddress,
+		evidence: fmt.Sprintf("%d transfers between %s and %s %s within %s", len(ids), floor, limit, e.Token, t.duration("window_seconds")),
+		entries:  ids,
+	}, nil
+}
+
+func detectFanOut(ctx context.Context, q queryer, e ledger.Entry, t thresholds) (*finding, error) {
+	var funded sql.NullTime
+	err := q.QueryRowContext(ctx, `
+		SELECT MIN(Created_At) FROM Journal_Entries WHERE To_Address = $1 AND Token = $2 AND Id < $3
+	`, e.FromAddress, e.Token, e.ID).Scan(&funded)
+	if err != nil || !funded.Valid || e.CreatedAt.Sub(funded.Time) > t.duration("funded_within_seconds") {
+		return nil, err
+	}
+
+	ids, err := entryIDs(ctx, q, `
+		SELECT MIN(Id) FROM Journal_Entries
+		WHERE From_Address = $1 AND Token = $2 AND Kind = $3 AND Id <= $4 AND Created_At >= $5
+		GROUP BY To_Address
+		ORDER BY MIN(Id) ASC
+	`, e.FromAddress, e.Token, ledger.KindTransfer, e.ID, funded.Time)
+	if err != nil || int64(len(ids)) < t.count("receivers") {
+		return nil, err
+	}
+	return &finding{
+		address:  e.FromAddress,
+		evidence: fmt.Sprintf("sent to %d receivers within %s of first being funded", len(ids), e.CreatedAt.Sub(funded.Time).Round(time.Second)),
+		entries:  ids,
+	}, nil
+}
+
+func detectCircular(ctx context.Context, q queryer, e ledger.Entry, t thresholds) (*finding, error) {
+	maxLength := t.count("max_length")
+	if maxLength < 2 {
+		return nil, nil
+	}
+
+	// paths of earlier transfers from the receiver back to the sender
+	var path []int64
+	err := q.QueryRowContext(ctx, `
+		WITH RECURSIVE paths (Address, Ids) AS (
+			SELECT To_Address, ARRAY[Id] FROM Journal_Entries
+			WHERE From_Address = $1 AND Token = $3 AND Kind = $4 AND Id < $5 AND Created_At > $6
+			UNION ALL
+			SELECT j.To_Address, p.Ids || j.Id
+			FROM paths p JOIN Journal_Entries j ON j.From_Address = p.Address
+			WHERE p.Address <> $2 AND cardinality(p.Ids) < $7
+				AND j.Token = $3 AND j.Kind = $4 AND j.Id < $5 AND j.Created_At > $6 AND NOT j.Id = ANY(p.Ids)
+		)
+		SELECT Ids FROM paths WHERE Address = $2 ORDER BY cardinality(Ids) ASC LIMIT 1
+	`, e.ToAddress, e.FromAddress, e.Token, ledger.KindTransfer, e.ID, e.CreatedAt.Add(-t.duration("window_seconds")), maxLength-1).Scan(pq.Array(&path))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &finding{
+		address:  e.FromAddress,
+		evidence: fmt.Sprintf("funds returned to the sender through %d transfers", len(path)+1),
+		entries:  append(path, e.ID),
+	}, nil
+}
+
+func entryIDs(ctx context.Context, q queryer, query string, args ...any) ([]int64, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
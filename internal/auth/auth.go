@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type operatorKey struct{}
+
+var ErrorOperatorRequired = errors.New("operator authorization required")
+
+// Operators maps an operator name to its API token.
+type Operators map[string]string
+
+// ParseOperators reads comma separated name:token pairs, e.g.
+// "alice:secret1,bob:secret2".
+func ParseOperators(value string) (Operators, error) {
+	operators := make(Operators)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, token, ok := strings.Cut(pair, ":")
+		if !ok || name == "" || token == "" {
+			return nil, fmt.Errorf("invalid operator entry %q, expected name:token", pair)
+		}
+		operators[name] = token
+	}
+	return operators, nil
+}
+
+func (o Operators) lookup(token string) (string, bool) {
+	found := ""
+	for name, t := range o {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			found = name
+		}
+	}
+	return found, found != ""
+}
+
+// Middleware puts the operator authenticated by an "Authorization: Bearer"
+// header into the request context. Requests without a valid token are passed
+// on anonymously, resolvers decide what needs an operator.
+func Middleware(operators Operators) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if ok {
+				if name, found := operators.lookup(token); found {
+					r = r.WithContext(WithOperator(r.Context(), name))
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func WithOperator(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, operatorKey{}, name)
+}
+
+// RequireOperator returns the name of the authenticated operator.
+func RequireOperator(ctx context.Context) (string, error) {
+	name, ok := ctx.Value(operatorKey{}).(string)
+	if !ok || name == "" {
+		return "", ErrorOperatorRequired
+	}
+	return name, nil
+}
@@ -0,0 +1,420 @@
+// Package compliance keeps the freeze status of addresses and the deny-list
+// of sanctioned addresses that transfers are checked against.
+package compliance
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	StatusActive         = "active"
+	StatusFrozenIncoming = "frozen_incoming"
+	StatusFrozenOutgoing = "frozen_outgoing"
+	StatusFrozen         = "frozen"
+)
+
+// Event actions
+const (
+	ActionFreeze   = "freeze"
+	ActionUnfreeze = "unfreeze"
+	ActionDeny     = "deny"
+	ActionAllow    = "allow"
+)
+
+// Restriction is what limits an address, the zero value is an active address
+// that is not denied.
+type Restriction struct {
+	Status string
+	// DenyList is the list the address is denied by, empty when it is not
+	DenyList string
+}
+
+// CheckDenied only checks the deny-list, for operator corrections that are
+// allowed to move funds of frozen addresses.
+func (r Restriction) CheckDenied() error {
+	if r.DenyList != "" {
+		return ErrorAddressDenied
+	}
+	return nil
+}
+
+func (r Restriction) CheckSend() error {
+	if err := r.CheckDenied(); err != nil {
+		return err
+	}
+	if r.Status == StatusFrozen || r.Status == StatusFrozenOutgoing {
+		return ErrorSenderFrozen
+	}
+	return nil
+}
+
+func (r Restriction) CheckReceive() error {
+	if err := r.CheckDenied(); err != nil {
+		return err
+	}
+	if r.Status == StatusFrozen || r.Status == StatusFrozenIncoming {
+		return ErrorReceiverFrozen
+	}
+	return nil
+}
+
+// AddressStatus is the compliance state of an address.
+type AddressStatus struct {
+	Address  string
+	Status   string
+	Reason   string
+	Operator string
+	// DenyList is the list the address is denied by, empty when it is not
+	DenyList  string
+	UpdatedAt *time.Time
+}
+
+// Event records a status change or deny-list change of an address.
+type Event struct {
+	Address   string
+	Action    string
+	Status    string
+	List      string
+	Reason    string
+	Operator  string
+	CreatedAt time.Time
+}
+
+type ComplianceService struct {
+	DB *sql.DB
+}
+
+var ErrorSenderFrozen = errors.New("sender wallet is frozen")
+var ErrorReceiverFrozen = errors.New("receiver wallet is frozen")
+var ErrorAddressDenied = errors.New("address is on the deny-list")
+var ErrorInvalidStatus = errors.New("invalid wallet status")
+var ErrorReasonRequired = errors.New("reason is required")
+var ErrorNotDenied = errors.New("address is not on the deny-list")
+var ErrorListRequired = errors.New("deny-list name is required")
+
+// addressLockClass is the first key of the per address advisory locks
+// that order freezes and deny-list changes with transfers.
+const addressLockClass = 7_028_003
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// lockAddresses takes the advisory lock of every address for the rest of
+// the transaction, shared for transfers and exclusive for compliance
+// changes. Addresses are locked in sorted order, so concurrent
+// transactions cannot deadlock.
+func lockAddresses(ctx context.Context, q queryer, addresses []string, shared bool) error {
+	sorted := append([]string(nil), addresses...)
+	sort.Strings(sorted)
+	unique := sorted[:0]
+	for i, address := range sorted {
+		if i == 0 || address != sorted[i-1] {
+			unique = append(unique, address)
+		}
+	}
+
+	lock := "pg_advisory_xact_lock"
+	if shared {
+		lock = "pg_advisory_xact_lock_shared"
+	}
+	rows, err := q.QueryContext(ctx, `
+		SELECT `+lock+`($1, hashtext(a.Address)) FROM unnest($2::TEXT[]) WITH ORDINALITY AS a(Address, N) ORDER BY a.N
+	`, addressLockClass, pq.Array(unique))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+// Load returns the restrictions of addresses that have any. It takes the
+// shared lock of each address, which a freeze or deny-list change holds
+// exclusively, so such a change waits for transfers in flight and is seen
+// by every later one, also when it adds the first row of an address.
+func Load(ctx context.Context, q queryer, addresses []string) (map[string]Restriction, error) {
+	restrictions := make(map[string]Restriction)
+
+	if err := lockAddresses(ctx, q, addresses, true); err != nil {
+		return nil, err
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT Address, Status FROM Address_Statuses WHERE Address = ANY($1) AND Status <> $2
+	`, pq.Array(addresses), StatusActive)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var address string
+		var r Restriction
+		if err := rows.Scan(&address, &r.Status); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		restrictions[address] = r
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = q.QueryContext(ctx, "SELECT Address, List FROM Deny_List WHERE Address = ANY($1)", pq.Array(addresses))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var address, list string
+		if err := rows.Scan(&address, &list); err != nil {
+			return nil, err
+		}
+		r := restrictions[address]
+		r.DenyList = list
+		restrictions[address] = r
+	}
+	return restrictions, rows.Err()
+}
+
+func validStatus(status string) bool {
+	switch status {
+	case StatusActive, StatusFrozenIncoming, StatusFrozenOutgoing, StatusFrozen:
+		return true
+	}
+	return false
+}
+
+// SetStatus freezes (or with StatusActive unfreezes) address and records
+// which operator did it and why.
+func (s *ComplianceService) SetStatus(ctx context.Context, address string, status string, reason string, operator string) (AddressStatus, error) {
+	if !validStatus(status) {
+		return AddressStatus{}, ErrorInvalidStatus
+	}
+	if strings.TrimSpace(reason) == "" {
+		return AddressStatus{}, ErrorReasonRequired
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return AddressStatus{}, err
+	}
+	defer tx.Rollback()
+
+	if err = lockAddresses(ctx, tx, []string{address}, false); err != nil {
+		return AddressStatus{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO Address_Statuses (Address, Status, Reason, Operator) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (Address) DO UPDATE SET
+			Status = EXCLUDED.Status, Reason = EXCLUDED.Reason, Operator = EXCLUDED.Operator, Updated_At = now()
+	`, address, status, reason, operator)
+	if err != nil {
+		return AddressStatus{}, err
+	}
+
+	action := ActionFreeze
+	if status == StatusActive {
+		action = ActionUnfreeze
+	}
+	if err = recordEvent(ctx, tx, Event{Address: address, Action: action, Status: status, Reason: reason, Operator: operator}); err != nil {
+		return AddressStatus{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return AddressStatus{}, err
+	}
+	return s.GetStatus(ctx, address)
+}
+
+// GetStatus returns the status of address, addresses that were never frozen
+// are active.
+func (s *ComplianceService) GetStatus(ctx context.Context, address string) (AddressStatus, error) {
+	status := AddressStatus{Address: address, Status: StatusActive}
+	var current, reason, operator, list sql.NullString
+	var updatedAt sql.NullTime
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT s.Status, s.Reason, s.Operator, s.Updated_At, d.List
+		FROM (SELECT $1::TEXT AS Address) a
+		LEFT JOIN Address_Statuses s ON s.Address = a.Address
+		LEFT JOIN Deny_List d ON d.Address = a.Address
+	`, address).Scan(&current, &reason, &operator, &updatedAt, &list)
+	if err != nil {
+		return AddressStatus{}, err
+	}
+	if current.Valid {
+		status.Status = current.String
+	}
+	status.Reason, status.Operator, status.DenyList = reason.String, operator.String, list.String
+	if updatedAt.Valid {
+		status.UpdatedAt = &updatedAt.Time
+	}
+	return status, nil
+}
+
+// ImportDenyList adds addresses to list and returns how many were not on
+// the deny-list yet. With replace the addresses of list that are missing
+// from the import are removed, so a sanctions list can be refreshed as a
+// whole.
+func (s *ComplianceService) ImportDenyList(ctx context.Context, list string, addresses []string, replace bool, reason string, operator string) (int, error) {
+	if strings.TrimSpace(list) == "" {
+		return 0, ErrorListRequired
+	}
+	if strings.TrimSpace(reason) == "" {
+		return 0, ErrorReasonRequired
+	}
+
+	imported := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		if address = strings.TrimSpace(address); address != "" {
+			imported = append(imported, address)
+		}
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// only the denied addresses are locked, allowing one again cannot break
+	// a transfer in flight
+	if err = lockAddresses(ctx, tx, imported, false); err != nil {
+		return 0, err
+	}
+
+	if replace {
+		rows, err := tx.QueryContext(ctx, `
+			DELETE FROM Deny_List WHERE List = $1 AND NOT (Address = ANY($2)) RETURNING Address
+		`, list, pq.Array(imported))
+		if err != nil {
+			return 0, err
+		}
+		var removed []string
+		for rows.Next() {
+			var address string
+			if err := rows.Scan(&address); err != nil {
+				rows.Close()
+				return 0, err
+			}
+			removed = append(removed, address)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+		for _, address := range removed {
+			if err := recordEvent(ctx, tx, Event{Address: address, Action: ActionAllow, List: list, Reason: reason, Operator: operator}); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	added := 0
+	for _, address := range imported {
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO Deny_List (Address, List, Reason, Operator) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (Address) DO NOTHING
+		`, address, list, reason, operator)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			continue
+		}
+		added++
+		if err := recordEvent(ctx, tx, Event{Address: address, Action: ActionDeny, List: list, Reason: reason, Operator: operator}); err != nil {
+			return 0, err
+		}
+	}
+
+	return added, tx.Commit()
+}
+
+// RemoveFromDenyList allows address again.
+func (s *ComplianceService) RemoveFromDenyList(ctx context.Context, address string, reason string, operator string) error {
+	if strings.TrimSpace(reason) == "" {
+		return ErrorReasonRequired
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var list string
+	err = tx.QueryRowContext(ctx, "DELETE FROM Deny_List WHERE Address = $1 RETURNING List", address).Scan(&list)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrorNotDenied
+	}
+	if err != nil {
+		return err
+	}
+	if err = recordEvent(ctx, tx, Event{Address: address, Action: ActionAllow, List: list, Reason: reason, Operator: operator}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeniedAddresses returns the addresses on list, or on any list when list is
+// empty.
+func (s *ComplianceService) DeniedAddresses(ctx context.Context, list string) ([]string, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT Address FROM Deny_List WHERE $1 = '' OR List = $1 ORDER BY Address ASC
+	`, list)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var addresses []string
+	for rows.Next() {
+		var address string
+		if err := rows.Scan(&address); err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, rows.Err()
+}
+
+// History returns the freezes and deny-list changes of address, oldest
+// first.
+func (s *ComplianceService) History(ctx context.Context, address string) ([]Event, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT Address, Action, COALESCE(Status, ''), COALESCE(List, ''), Reason, Operator, Created_At
+		FROM Compliance_Events WHERE Address = $1 ORDER BY Id ASC
+	`, address)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.Address, &e.Action, &e.Status, &e.List, &e.Reason, &e.Operator, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func recordEvent(ctx context.Context, tx *sql.Tx, e Event) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO Compliance_Events (Address, Action, Status, List, Reason, Operator)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5, $6)
+	`, e.Address, e.Action, e.Status, e.List, e.Reason, e.Operator)
+	return err
+}
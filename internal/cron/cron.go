@@ -0,0 +1,172 @@
+// Package cron parses standard five field cron expressions
+// ("minute hour day-of-month month day-of-week").
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression, every field is a bit set of the
+// values it matches.
+type Schedule struct {
+	minutes, hours, days, months, weekdays uint64
+	// cron matches a day on either field when both are restricted
+	anyDay, anyWeekday bool
+}
+
+type bounds struct {
+	name     string
+	min, max int
+}
+
+var fields = []bounds{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// searchLimit bounds Next for expressions that (almost) never match, like
+// February 30th.
+const searchLimit = 5 * 366 * 24 * time.Hour
+
+var ErrorNoNextTime = errors.New("cron expression has no time within the next 5 years")
+
+// Parse reads an expression such as "*/15 9-17 * * 1-5" or "@daily".
+func Parse(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := macros[expr]; ok {
+		expr = macro
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return Schedule{}, fmt.Errorf("cron expression must have %d fields, got %d", len(fields), len(parts))
+	}
+
+	var sets [5]uint64
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return Schedule{}, err
+		}
+		sets[i] = set
+	}
+
+	// 7 is another name for Sunday
+	if sets[4]&(1<<7) != 0 {
+		sets[4] = sets[4]&^(1<<7) | 1
+	}
+
+	return Schedule{
+		minutes:    sets[0],
+		hours:      sets[1],
+		days:       sets[2],
+		months:     sets[3],
+		weekdays:   sets[4],
+		anyDay:     parts[2] == "*",
+		anyWeekday: parts[4] == "*",
+	}, nil
+}
+
+// parseField reads a comma separated list of "*", "n", "a-b" items, each
+// optionally followed by "/step".
+func parseField(field string, b bounds) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, step := item, 1
+		if i := strings.IndexByte(item, '/'); i >= 0 {
+			var err error
+			rangePart = item[:i]
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %s field %q", b.name, item)
+			}
+		}
+
+		low, high := b.min, b.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			ends := strings.SplitN(rangePart, "-", 2)
+			var err1, err2 error
+			low, err1 = strconv.Atoi(ends[0])
+			high, err2 = strconv.Atoi(ends[1])
+			if err1 != nil || err2 != nil || low > high {
+				return 0, fmt.Errorf("invalid range in %s field %q", b.name, item)
+			}
+		default:
+			value, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value in %s field %q", b.name, item)
+			}
+			low = value
+			// "5/10" means from 5 to the end every 10
+			if step == 1 {
+				high = value
+			}
+		}
+
+		if low < b.min || high > b.max {
+			return 0, fmt.Errorf("%s field %q out of range %d-%d", b.name, item, b.min, b.max)
+		}
+		for v := low; v <= high; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<uint(v)) != 0
+}
+
+func (s Schedule) matchesDay(t time.Time) bool {
+	day, weekday := has(s.days, t.Day()), has(s.weekdays, int(t.Weekday()))
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	}
+	return day || weekday
+}
+
+// Next returns the first matching minute strictly after t, in t's location.
+func (s Schedule) Next(t time.Time) (time.Time, error) {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(searchLimit)
+
+	for next.Before(limit) {
+		switch {
+		case !has(s.months, int(next.Month())):
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, next.Location())
+		case !s.matchesDay(next):
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location())
+		case !has(s.hours, next.Hour()):
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, next.Location())
+		case !has(s.minutes, next.Minute()):
+			next = next.Add(time.Minute)
+		default:
+			return next, nil
+		}
+	}
+	return time.Time{}, ErrorNoNextTime
+}
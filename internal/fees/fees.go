@@ -0,0 +1,288 @@
+package fees
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+
+	"btp_tokens/internal/tokens"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+const MaxBasisPoints = 10000
+
+// Policy is the transfer fee charged in one token. The fee is taken out of
+// the transferred amount and credited to the treasury wallet.
+type Policy struct {
+	Token           string
+	TreasuryAddress string
+	Flat            decimal.Decimal
+	BasisPoints     int32
+	// MinFee and MaxFee clamp the computed fee when set
+	MinFee *decimal.Decimal
+	MaxFee *decimal.Decimal
+	// Tiers replace Flat and BasisPoints for amounts of at least their From
+	Tiers []Tier
+}
+
+type Tier struct {
+	From        decimal.Decimal
+	Flat        decimal.Decimal
+	BasisPoints int32
+}
+
+// Quote is the outcome of charging a policy on an amount.
+type Quote struct {
+	Token           string
+	Amount          decimal.Decimal
+	Fee             decimal.Decimal
+	NetAmount       decimal.Decimal
+	TreasuryAddress string
+}
+
+type FeesService struct {
+	DB *sql.DB
+}
+
+var ErrorFeePolicyNotFound = errors.New("fee policy not found")
+var ErrorInvalidFeePolicy = errors.New("invalid fee policy")
+var ErrorFeeExceedsAmount = errors.New("transfer amount does not cover the fee")
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func (p Policy) Validate() error {
+	if p.TreasuryAddress == "" || p.Flat.IsNegative() || p.BasisPoints < 0 || p.BasisPoints > MaxBasisPoints {
+		return ErrorInvalidFeePolicy
+	}
+	if p.MinFee != nil && p.MinFee.IsNegative() || p.MaxFee != nil && p.MaxFee.IsNegative() {
+		return ErrorInvalidFeePolicy
+	}
+	if p.MinFee != nil && p.MaxFee != nil && p.MinFee.GreaterThan(*p.MaxFee) {
+		return ErrorInvalidFeePolicy
+	}
+
+	seen := make(map[string]bool)
+	for _, tier := range p.Tiers {
+		if tier.From.IsNegative() || tier.Flat.IsNegative() || tier.BasisPoints < 0 || tier.BasisPoints > MaxBasisPoints {
+			return ErrorInvalidFeePolicy
+		}
+		if seen[tier.From.String()] {
+			return ErrorInvalidFeePolicy
+		}
+		seen[tier.From.String()] = true
+	}
+	return nil
+}
+
+// Fee computes the fee for amount, rounded down to the token's decimals,
+// then clamps it. The basis points are applied exactly, whatever the
+// precision of the token.
+func (p Policy) Fee(amount decimal.Decimal, decimals int32) decimal.Decimal {
+	flat, bps := p.Flat, p.BasisPoints
+	for _, tier := range p.Tiers {
+		// tiers are sorted by From, the last one reached applies
+		if amount.GreaterThanOrEqual(tier.From) {
+			flat, bps = tier.Flat, tier.BasisPoints
+		}
+	}
+
+	fee := flat.Add(amount.Mul(decimal.NewFromInt32(bps)).Shift(-4)).Truncate(decimals)
+	if p.MinFee != nil && fee.LessThan(*p.MinFee) {
+		fee = *p.MinFee
+	}
+	if p.MaxFee != nil && fee.GreaterThan(*p.MaxFee) {
+		fee = *p.MaxFee
+	}
+	return fee
+}
+
+// checkPrecision rejects fee amounts the token cannot represent, which
+// would otherwise be rounded down when charged.
+func (p Policy) checkPrecision(t tokens.Token) error {
+	amounts := []decimal.Decimal{p.Flat}
+	if p.MinFee != nil {
+		amounts = append(amounts, *p.MinFee)
+	}
+	if p.MaxFee != nil {
+		amounts = append(amounts, *p.MaxFee)
+	}
+	for _, tier := range p.Tiers {
+		amounts = append(amounts, tier.Flat)
+	}
+	for _, amount := range amounts {
+		if t.CheckPrecision(amount) != nil {
+			return ErrorInvalidFeePolicy
+		}
+	}
+	return nil
+}
+
+// Charge quotes amount under the policy, a nil policy makes the transfer
+// free.
+func Charge(p *Policy, token tokens.Token, amount decimal.Decimal) (Quote, error) {
+	quote := Quote{Token: token.Symbol, Amount: amount, Fee: decimal.Zero, NetAmount: amount}
+	if p == nil {
+		return quote, nil
+	}
+
+	quote.TreasuryAddress = p.TreasuryAddress
+	quote.Fee = p.Fee(amount, token.Decimals)
+	quote.NetAmount = amount.Sub(quote.Fee)
+	if !quote.NetAmount.IsPositive() {
+		return Quote{}, ErrorFeeExceedsAmount
+	}
+	return quote, nil
+}
+
+// Load returns the policies of the given tokens, tokens without a policy
+// are missing from the map.
+func Load(ctx context.Context, q queryer, symbols []string) (map[string]*Policy, error) {
+	policies := make(map[string]*Policy)
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT Token, Treasury_Address, Flat, Basis_Points, Min_Fee, Max_Fee FROM Fee_Policies WHERE Token = ANY($1)
+	`, pq.Array(symbols))
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var p Policy
+		var minFee, maxFee decimal.NullDecimal
+		if err := rows.Scan(&p.Token, &p.TreasuryAddress, &p.Flat, &p.BasisPoints, &minFee, &maxFee); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if minFee.Valid {
+			p.MinFee = &minFee.Decimal
+		}
+		if maxFee.Valid {
+			p.MaxFee = &maxFee.Decimal
+		}
+		policies[p.Token] = &p
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(policies) == 0 {
+		return policies, nil
+	}
+
+	rows, err = q.QueryContext(ctx, `
+		SELECT Token, From_Amount, Flat, Basis_Points FROM Fee_Tiers WHERE Token = ANY($1) ORDER BY Token ASC, From_Amount ASC
+	`, pq.Array(symbols))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var token string
+		var tier Tier
+		if err := rows.Scan(&token, &tier.From, &tier.Flat, &tier.BasisPoints); err != nil {
+			return nil, err
+		}
+		if p, ok := policies[token]; ok {
+			p.Tiers = append(p.Tiers, tier)
+		}
+	}
+	return policies, rows.Err()
+}
+
+func (s *FeesService) Get(ctx context.Context, token string) (Policy, error) {
+	policies, err := Load(ctx, s.DB, []string{token})
+	if err != nil {
+		return Policy{}, err
+	}
+	p, ok := policies[token]
+	if !ok {
+		return Policy{}, ErrorFeePolicyNotFound
+	}
+	return *p, nil
+}
+
+// Set creates or replaces the fee policy of a token.
+func (s *FeesService) Set(ctx context.Context, p Policy) (Policy, error) {
+	if err := p.Validate(); err != nil {
+		return Policy{}, err
+	}
+	sort.Slice(p.Tiers, func(i, j int) bool { return p.Tiers[i].From.LessThan(p.Tiers[j].From) })
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Policy{}, err
+	}
+	defer tx.Rollback()
+
+	t, err := tokens.GetShared(ctx, tx, p.Token)
+	if err != nil {
+		return Policy{}, err
+	}
+	if err := p.checkPrecision(t); err != nil {
+		return Policy{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO Fee_Policies (Token, Treasury_Address, Flat, Basis_Points, Min_Fee, Max_Fee)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (Token) DO UPDATE SET
+			Treasury_Address = EXCLUDED.Treasury_Address, Flat = EXCLUDED.Flat, Basis_Points = EXCLUDED.Basis_Points,
+			Min_Fee = EXCLUDED.Min_Fee, Max_Fee = EXCLUDED.Max_Fee, Updated_At = now()
+	`, p.Token, p.TreasuryAddress, p.Flat, p.BasisPoints, nullDecimal(p.MinFee), nullDecimal(p.MaxFee))
+	if err != nil {
+		return Policy{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM Fee_Tiers WHERE Token = $1", p.Token); err != nil {
+		return Policy{}, err
+	}
+	for _, tier := range p.Tiers {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO Fee_Tiers (Token, From_Amount, Flat, Basis_Points) VALUES ($1, $2, $3, $4)
+		`, p.Token, tier.From, tier.Flat, tier.BasisPoints)
+		if err != nil {
+			return Policy{}, err
+		}
+	}
+
+	return p, tx.Commit()
+}
+
+// Remove makes transfers of token free again.
+func (s *FeesService) Remove(ctx context.Context, token string) error {
+	result, err := s.DB.ExecContext(ctx, "DELETE FROM Fee_Policies WHERE Token = $1", token)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrorFeePolicyNotFound
+	}
+	return err
+}
+
+// Quote previews the fee and net amount of transferring amount of token.
+func (s *FeesService) Quote(ctx context.Context, token string, amount decimal.Decimal) (Quote, error) {
+	t, err := tokens.Get(ctx, s.DB, token)
+	if err != nil {
+		return Quote{}, err
+	}
+
+	policies, err := Load(ctx, s.DB, []string{token})
+	if err != nil {
+		return Quote{}, err
+	}
+	return Charge(policies[token], t, amount)
+}
+
+func nullDecimal(d *decimal.Decimal) decimal.NullDecimal {
+	if d == nil {
+		return decimal.NullDecimal{}
+	}
+	return decimal.NullDecimal{Decimal: *d, Valid: true}
+}
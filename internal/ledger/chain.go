@@ -0,0 +1,421 @@
+package ledger
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"log"
+	"sort"
+	"time"
+
+	"btp_tokens/internal/tokens"
+)
+
+// GenesisHash is the previous hash of the first journal entry.
+const GenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// chainLockKey identifies the advisory lock serialising appends to the
+// journal, so every entry is chained to the one committed before it.
+const chainLockKey = 7_028_001
+
+var ErrorSigningKeyMissing = errors.New("ledger signing key is not configured")
+var ErrorVerifyKeyMissing = errors.New("ledger verify key is not configured, checkpoint signatures cannot be checked")
+var ErrorKeyMismatch = errors.New("ledger verify key is not the public key of the signing key")
+
+type Checkpoint struct {
+	ID        int64
+	EntryID   int64
+	Hash      string
+	Signature string
+	CreatedAt time.Time
+}
+
+// ChainVerification is the outcome of walking the hash chain. When Valid is
+// false BrokenEntryID points at the first entry (or checkpoint) that does
+// not match.
+type ChainVerification struct {
+	Valid              bool
+	EntriesChecked     int
+	CheckpointsChecked int
+	HeadHash           string
+	BrokenEntryID      int64
+	BrokenCheckpointID int64
+	Reason             string
+}
+
+// lockChain takes the chain lock for the rest of tx and returns the hash of
+// the current chain head.
+func lockChain(ctx context.Context, tx *sql.Tx) (string, error) {
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", chainLockKey); err != nil {
+		return "", err
+	}
+
+	var head sql.NullString
+	err := tx.QueryRowContext(ctx, "SELECT Hash FROM Journal_Entries WHERE Hash IS NOT NULL ORDER BY Id DESC LIMIT 1").Scan(&head)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !head.Valid) {
+		return GenesisHash, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return head.String, nil
+}
+
+func writeField(h hash.Hash, value string) {
+	fmt.Fprintf(h, "%d:%s", len(value), value)
+}
+
+// EntryHash is the SHA-256 of the entry's canonical contents followed by
+// the previous entry's hash. Postings are hashed in a fixed order, so the
+// order they were stored in does not matter. The token and the fee are only
+// part of the contents when they are not the default ones, which keeps
+// hashes written before multi-token support and fees valid.
+func EntryHash(entry *Entry, prevHash string) string {
+	postings := append([]Posting(nil), entry.Postings...)
+	sort.Slice(postings, func(i, j int) bool {
+		if postings[i].Account != postings[j].Account {
+			return postings[i].Account < postings[j].Account
+		}
+		if !postings[i].Debit.Equal(postings[j].Debit) {
+			return postings[i].Debit.LessThan(postings[j].Debit)
+		}
+		return postings[i].Credit.LessThan(postings[j].Credit)
+	})
+
+	h := sha256.New()
+	writeField(h, fmt.Sprint(entry.ID))
+	writeField(h, entry.Kind)
+	if entry.Token != "" && entry.Token != tokens.DefaultSymbol {
+		writeField(h, "token="+entry.Token)
+	}
+	writeField(h, entry.FromAddress)
+	writeField(h, entry.ToAddress)
+	writeField(h, entry.Amount.String())
+	if !entry.Fee.IsZero() {
+		writeField(h, "fee="+entry.Fee.String())
+	}
+	writeField(h, entry.CreatedAt.UTC().Format(time.RFC3339Nano))
+	for _, p := range postings {
+		writeField(h, p.Account)
+		writeField(h, p.Debit.String())
+		writeField(h, p.Credit.String())
+	}
+	writeField(h, prevHash)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func seal(ctx context.Context, tx *sql.Tx, entry *Entry, prevHash string) error {
+	entry.PrevHash = prevHash
+	entry.Hash = EntryHash(entry, prevHash)
+	_, err := tx.ExecContext(ctx, "UPDATE Journal_Entries SET Prev_Hash = $1, Hash = $2 WHERE Id = $3", entry.PrevHash, entry.Hash, entry.ID)
+	return err
+}
+
+// SealUnhashed chains entries written without a hash (the opening entries
+// created by migrations). It is safe to call on every start.
+func (s *LedgerService) SealUnhashed(ctx context.Context) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockChain(ctx, tx); err != nil {
+		return err
+	}
+
+	var unsealed []*Entry
+	err = walkEntries(ctx, tx, "WHERE e.Hash IS NULL", func(entry *Entry) error {
+		unsealed = append(unsealed, entry)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range unsealed {
+		var prev sql.NullString
+		err := tx.QueryRowContext(ctx, "SELECT Hash FROM Journal_Entries WHERE Id < $1 ORDER BY Id DESC LIMIT 1", entry.ID).Scan(&prev)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		prevHash := GenesisHash
+		if prev.Valid {
+			prevHash = prev.String
+		}
+		if err := seal(ctx, tx, entry, prevHash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// walkEntries streams entries with their postings in chain order.
+func walkEntries(ctx context.Context, q queryer, where string, fn func(*Entry) error) error {
+	rows, err := q.QueryContext(ctx, `
+		SELECT e.Id, e.Kind, e.Token, COALESCE(e.From_Address, ''), COALESCE(e.To_Address, ''), e.Amount, e.Fee, e.Created_At,
+			COALESCE(e.Prev_Hash, ''), COALESCE(e.Hash, ''), p.Account, p.Debit, p.Credit
+		FROM Journal_Entries e
+		JOIN Postings p ON p.Entry_Id = e.Id
+		`+where+`
+		ORDER BY e.Id ASC, p.Id ASC
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var current *Entry
+	for rows.Next() {
+		var entry Entry
+		var posting Posting
+		err := rows.Scan(&entry.ID, &entry.Kind, &entry.Token, &entry.FromAddress, &entry.ToAddress, &entry.Amount, &entry.Fee, &entry.CreatedAt,
+			&entry.PrevHash, &entry.Hash, &posting.Account, &posting.Debit, &posting.Credit)
+		if err != nil {
+			return err
+		}
+
+		if current == nil || current.ID != entry.ID {
+			if current != nil {
+				if err := fn(current); err != nil {
+					return err
+				}
+			}
+			current = &entry
+		}
+		current.Postings = append(current.Postings, posting)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if current != nil {
+		return fn(current)
+	}
+	return nil
+}
+
+// VerifyChain recomputes every entry hash from the genesis hash onwards and
+// checks the signature of every checkpoint against the chain. Checkpoints
+// are never skipped: without a verify or signing key it fails with
+// ErrorVerifyKeyMissing when there are any.
+func (s *LedgerService) VerifyChain(ctx context.Context) (ChainVerification, error) {
+	// one snapshot for entries and checkpoints, so checkpoints of entries
+	// appended meanwhile are not reported as broken
+	tx, err := s.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return ChainVerification{}, err
+	}
+	defer tx.Rollback()
+
+	result := ChainVerification{Valid: true, HeadHash: GenesisHash}
+	hashes := make(map[int64]string)
+
+	errBroken := errors.New("broken")
+	err = walkEntries(ctx, tx, "", func(entry *Entry) error {
+		result.EntriesChecked++
+		switch {
+		case entry.Hash == "":
+			result.Reason = "entry is not sealed"
+		case entry.PrevHash != result.HeadHash:
+			result.Reason = "previous hash does not match the preceding entry"
+		case EntryHash(entry, entry.PrevHash) != entry.Hash:
+			result.Reason = "entry contents do not match its hash"
+		default:
+			result.HeadHash = entry.Hash
+			hashes[entry.ID] = entry.Hash
+			return nil
+		}
+		result.Valid = false
+		result.BrokenEntryID = entry.ID
+		return errBroken
+	})
+	if errors.Is(err, errBroken) {
+		return result, nil
+	}
+	if err != nil {
+		return ChainVerification{}, err
+	}
+
+	checkpoints, err := listCheckpoints(ctx, tx)
+	if err != nil {
+		return ChainVerification{}, err
+	}
+
+	publicKey := s.publicKey()
+	if publicKey == nil && len(checkpoints) > 0 {
+		return ChainVerification{}, ErrorVerifyKeyMissing
+	}
+
+	for _, c := range checkpoints {
+		result.CheckpointsChecked++
+		if hashes[c.EntryID] != c.Hash {
+			result.Reason = "checkpoint hash does not match the chain"
+		} else if !verifyCheckpoint(publicKey, c) {
+			result.Reason = "checkpoint signature is invalid"
+		} else {
+			continue
+		}
+		result.Valid = false
+		result.BrokenEntryID = c.EntryID
+		result.BrokenCheckpointID = c.ID
+		break
+	}
+
+	return result, nil
+}
+
+// CheckKeys makes sure a configured VerifyKey is the public half of a
+// configured SigningKey, otherwise every checkpoint signed would fail
+// verification as if it had been tampered with.
+func (s *LedgerService) CheckKeys() error {
+	if s.SigningKey == nil || s.VerifyKey == nil {
+		return nil
+	}
+	if !s.VerifyKey.Equal(s.SigningKey.Public()) {
+		return ErrorKeyMismatch
+	}
+	return nil
+}
+
+// publicKey returns the key checkpoint signatures are checked with.
+func (s *LedgerService) publicKey() ed25519.PublicKey {
+	if s.VerifyKey != nil {
+		return s.VerifyKey
+	}
+	if s.SigningKey != nil {
+		return s.SigningKey.Public().(ed25519.PublicKey)
+	}
+	return nil
+}
+
+func checkpointMessage(entryID int64, hash string) []byte {
+	return []byte(fmt.Sprintf("btp-ledger-checkpoint:%d:%s", entryID, hash))
+}
+
+func verifyCheckpoint(publicKey ed25519.PublicKey, c Checkpoint) bool {
+	signature, err := hex.DecodeString(c.Signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(publicKey, checkpointMessage(c.EntryID, c.Hash), signature)
+}
+
+// Checkpoint signs the current chain head. Nothing is written when the head
+// was already checkpointed.
+func (s *LedgerService) Checkpoint(ctx context.Context) (Checkpoint, error) {
+	if s.SigningKey == nil {
+		return Checkpoint{}, ErrorSigningKeyMissing
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockChain(ctx, tx); err != nil {
+		return Checkpoint{}, err
+	}
+
+	var c Checkpoint
+	err = tx.QueryRowContext(ctx, "SELECT Id, Hash FROM Journal_Entries WHERE Hash IS NOT NULL ORDER BY Id DESC LIMIT 1").Scan(&c.EntryID, &c.Hash)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+
+	var last Checkpoint
+	err = tx.QueryRowContext(ctx, `
+		SELECT Id, Entry_Id, Hash, Signature, Created_At FROM Ledger_Checkpoints ORDER BY Id DESC LIMIT 1
+	`).Scan(&last.ID, &last.EntryID, &last.Hash, &last.Signature, &last.CreatedAt)
+	if err == nil && last.EntryID == c.EntryID {
+		return last, nil
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Checkpoint{}, err
+	}
+
+	c.Signature = hex.EncodeToString(ed25519.Sign(s.SigningKey, checkpointMessage(c.EntryID, c.Hash)))
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO Ledger_Checkpoints (Entry_Id, Hash, Signature) VALUES ($1, $2, $3)
+		RETURNING Id, Created_At
+	`, c.EntryID, c.Hash, c.Signature).Scan(&c.ID, &c.CreatedAt)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+
+	return c, tx.Commit()
+}
+
+func (s *LedgerService) Checkpoints(ctx context.Context) ([]Checkpoint, error) {
+	return listCheckpoints(ctx, s.DB)
+}
+
+func listCheckpoints(ctx context.Context, q queryer) ([]Checkpoint, error) {
+	rows, err := q.QueryContext(ctx, "SELECT Id, Entry_Id, Hash, Signature, Created_At FROM Ledger_Checkpoints ORDER BY Id ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checkpoints []Checkpoint
+	for rows.Next() {
+		var c Checkpoint
+		if err := rows.Scan(&c.ID, &c.EntryID, &c.Hash, &c.Signature, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, c)
+	}
+	return checkpoints, rows.Err()
+}
+
+// RunCheckpoints signs the chain head every interval until ctx is done.
+func (s *LedgerService) RunCheckpoints(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Checkpoint(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				log.Println("ledger checkpoint fail:", err)
+			}
+		}
+	}
+}
+
+// ParseSigningKey reads a hex encoded ed25519 seed.
+func ParseSigningKey(seedHex string) (ed25519.PrivateKey, error) {
+	seed, err := hex.DecodeString(seedHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid signing key: expected %d bytes, got %d", ed25519.SeedSize, len(seed))
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// ParseVerifyKey reads a hex encoded ed25519 public key.
+func ParseVerifyKey(keyHex string) (ed25519.PublicKey, error) {
+	key, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid verify key: %w", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid verify key: expected %d bytes, got %d", ed25519.PublicKeySize, len(key))
+	}
+	return ed25519.PublicKey(key), nil
+}
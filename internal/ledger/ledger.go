@@ -0,0 +1,229 @@
+package ledger
+
+import (
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"errors"
+	"time"
+
+	"btp_tokens/internal/tokens"
+
+	"github.com/shopspring/decimal"
+)
+
+// Kinds of journal entries.
+const (
+	KindTransfer     = "transfer"
+	KindMint         = "mint"
+	KindBurn         = "burn"
+	KindFee          = "fee"
+	KindEscrow       = "escrow"
+	KindHtlc         = "htlc"
+	KindVesting      = "vesting"
+	KindStaking      = "staking"
+	KindAirdrop      = "airdrop"
+	KindDistribution = "distribution"
+	KindReversal     = "reversal"
+)
+
+// IssuanceAccount is the counterpart of every mint and burn. Its debit
+// balance in a token equals the amount of that token in circulation.
+const IssuanceAccount = "system:issuance"
+
+// Wallet accounts carry credit balances: a wallet's balance is the sum of
+// its credits minus the sum of its debits.
+type Posting struct {
+	Account string
+	Debit   decimal.Decimal
+	Credit  decimal.Decimal
+}
+
+// Entry is a journal entry in a single token; all its postings are in that
+// token.
+type Entry struct {
+	ID          int64
+	Kind        string
+	Token       string
+	FromAddress string
+	ToAddress   string
+	Amount      decimal.Decimal
+	// Fee is the part of Amount paid to the treasury instead of ToAddress
+	Fee       decimal.Decimal
+	CreatedAt time.Time
+	Postings  []Posting
+	PrevHash  string
+	Hash      string
+}
+
+type AccountBalance struct {
+	Token   string
+	Account string
+	Debit   decimal.Decimal
+	Credit  decimal.Decimal
+}
+
+type TrialBalance struct {
+	TotalDebit  decimal.Decimal
+	TotalCredit decimal.Decimal
+	Accounts    []AccountBalance
+}
+
+type BalanceMismatch struct {
+	Address       string
+	Token         string
+	Balance       decimal.Decimal
+	LedgerBalance decimal.Decimal
+}
+
+type LedgerService struct {
+	DB *sql.DB
+	// SigningKey signs chain checkpoints
+	SigningKey ed25519.PrivateKey
+	// VerifyKey checks checkpoint signatures without the secret seed, the
+	// public half of SigningKey is used when it is not set
+	VerifyKey ed25519.PublicKey
+}
+
+var ErrorUnbalancedEntry = errors.New("journal entry debits do not equal credits")
+var ErrorInvalidPosting = errors.New("posting must have either a positive debit or a positive credit")
+
+func Debit(account string, amount decimal.Decimal) Posting {
+	return Posting{Account: account, Debit: amount}
+}
+
+func Credit(account string, amount decimal.Decimal) Posting {
+	return Posting{Account: account, Credit: amount}
+}
+
+func (t TrialBalance) Balanced() bool {
+	return t.TotalDebit.Equal(t.TotalCredit)
+}
+
+// Validate checks that every posting moves a positive amount in one
+// direction and that the entry's debits equal its credits.
+func (e *Entry) Validate() error {
+	var debits, credits decimal.Decimal
+	for _, p := range e.Postings {
+		if p.Debit.IsNegative() || p.Credit.IsNegative() || p.Debit.IsZero() == p.Credit.IsZero() {
+			return ErrorInvalidPosting
+		}
+		debits = debits.Add(p.Debit)
+		credits = credits.Add(p.Credit)
+	}
+
+	if len(e.Postings) == 0 || !debits.Equal(credits) {
+		return ErrorUnbalancedEntry
+	}
+	return nil
+}
+
+// Record writes a balanced entry and its postings inside tx and chains it to
+// the previous entry. It holds the chain lock until tx ends, so it should be
+// the last lock a transaction takes.
+func Record(ctx context.Context, tx *sql.Tx, entry *Entry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	if entry.Token == "" {
+		entry.Token = tokens.DefaultSymbol
+	}
+
+	prevHash, err := lockChain(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO Journal_Entries (Kind, Token, From_Address, To_Address, Amount, Fee)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5, $6)
+		RETURNING Id, Created_At
+	`, entry.Kind, entry.Token, entry.FromAddress, entry.ToAddress, entry.Amount, entry.Fee).Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range entry.Postings {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO Postings (Entry_Id, Account, Debit, Credit)
+			VALUES ($1, $2, $3, $4)
+		`, entry.ID, p.Account, p.Debit, p.Credit)
+		if err != nil {
+			return err
+		}
+	}
+
+	return seal(ctx, tx, entry, prevHash)
+}
+
+// TrialBalance sums the postings of all entries created up to at (or all
+// entries when at is nil) per token and account. Every entry is balanced
+// within its token, so each token balances on its own as well.
+func (s *LedgerService) TrialBalance(ctx context.Context, at *time.Time) (TrialBalance, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT e.Token, p.Account, SUM(p.Debit), SUM(p.Credit)
+		FROM Postings p
+		JOIN Journal_Entries e ON e.Id = p.Entry_Id
+		WHERE $1::TIMESTAMPTZ IS NULL OR e.Created_At <= $1
+		GROUP BY e.Token, p.Account
+		ORDER BY e.Token ASC, p.Account ASC
+	`, at)
+	if err != nil {
+		return TrialBalance{}, err
+	}
+	defer rows.Close()
+
+	var trial TrialBalance
+	for rows.Next() {
+		var account AccountBalance
+		if err := rows.Scan(&account.Token, &account.Account, &account.Debit, &account.Credit); err != nil {
+			return TrialBalance{}, err
+		}
+		trial.TotalDebit = trial.TotalDebit.Add(account.Debit)
+		trial.TotalCredit = trial.TotalCredit.Add(account.Credit)
+		trial.Accounts = append(trial.Accounts, account)
+	}
+
+	if err := rows.Err(); err != nil {
+		return TrialBalance{}, err
+	}
+	return trial, nil
+}
+
+// VerifyBalances compares every wallet balance with the balance derived from
+// its postings and returns the wallets that disagree.
+func (s *LedgerService) VerifyBalances(ctx context.Context) ([]BalanceMismatch, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		WITH derived AS (
+			SELECT p.Account AS Address, e.Token, SUM(p.Credit) - SUM(p.Debit) AS Balance
+			FROM Postings p
+			JOIN Journal_Entries e ON e.Id = p.Entry_Id
+			WHERE p.Account <> $1
+			GROUP BY p.Account, e.Token
+		)
+		SELECT COALESCE(w.Address, d.Address), COALESCE(w.Token, d.Token), COALESCE(w.Balance, 0), COALESCE(d.Balance, 0)
+		FROM Wallets w
+		FULL OUTER JOIN derived d ON d.Address = w.Address AND d.Token = w.Token
+		WHERE COALESCE(w.Balance, 0) <> COALESCE(d.Balance, 0)
+		ORDER BY 1 ASC, 2 ASC
+	`, IssuanceAccount)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mismatches []BalanceMismatch
+	for rows.Next() {
+		var m BalanceMismatch
+		if err := rows.Scan(&m.Address, &m.Token, &m.Balance, &m.LedgerBalance); err != nil {
+			return nil, err
+		}
+		mismatches = append(mismatches, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return mismatches, nil
+}
@@ -0,0 +1,345 @@
+// Package limits caps what an address can send per transfer and over rolling
+// daily and monthly windows, depending on the tier of the address.
+package limits
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"btp_tokens/internal/ledger"
+	"btp_tokens/internal/tokens"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// DefaultTier applies to addresses that were never given a tier.
+const DefaultTier = "unverified"
+
+// Names of the limits, reported in ExceededError.
+const (
+	PerTransfer = "per_transfer"
+	Daily       = "daily"
+	Monthly     = "monthly"
+)
+
+// outgoingKinds are the journal entries that count as sent by their
+// from address: transfers and the funds locked into escrows, HTLCs and
+// vesting grants.
+var outgoingKinds = []string{ledger.KindTransfer, ledger.KindEscrow, ledger.KindHtlc, ledger.KindVesting}
+
+// Limits of a tier in one token, nil means unlimited.
+type Limits struct {
+	Tier        string
+	Token       string
+	PerTransfer *decimal.Decimal
+	// Daily and Monthly cap the sum sent over the last 24 hours and 30 days
+	Daily     *decimal.Decimal
+	Monthly   *decimal.Decimal
+	UpdatedAt time.Time
+}
+
+func (l Limits) Validate() error {
+	if strings.TrimSpace(l.Tier) == "" {
+		return ErrorInvalidLimits
+	}
+	for _, limit := range []*decimal.Decimal{l.PerTransfer, l.Daily, l.Monthly} {
+		if limit != nil && !limit.IsPositive() {
+			return ErrorInvalidLimits
+		}
+	}
+	return nil
+}
+
+// Usage is what an address sent within the windows of its limits.
+type Usage struct {
+	Limits
+	Address     string
+	SentDaily   decimal.Decimal
+	SentMonthly decimal.Decimal
+}
+
+// Key identifies the usage of one address in one token.
+type Key struct {
+	Address string
+	Token   string
+}
+
+// Remaining is the largest amount that can be sent now, nil when unlimited.
+func (u Usage) Remaining() *decimal.Decimal {
+	var remaining *decimal.Decimal
+	lower := func(limit *decimal.Decimal, sent decimal.Decimal) {
+		if limit == nil {
+			return
+		}
+		left := decimal.Max(limit.Sub(sent), decimal.Zero)
+		if remaining == nil || left.LessThan(*remaining) {
+			remaining = &left
+		}
+	}
+	lower(u.PerTransfer, decimal.Zero)
+	lower(u.Daily, u.SentDaily)
+	lower(u.Monthly, u.SentMonthly)
+	return remaining
+}
+
+// Check returns an *ExceededError when amount does not fit the limits.
+func (u Usage) Check(amount decimal.Decimal) error {
+	exceeded := func(max *decimal.Decimal, sent decimal.Decimal) bool {
+		return max != nil && sent.Add(amount).GreaterThan(*max)
+	}
+	var limit string
+	switch {
+	case exceeded(u.PerTransfer, decimal.Zero):
+		limit = PerTransfer
+	case exceeded(u.Daily, u.SentDaily):
+		limit = Daily
+	case exceeded(u.Monthly, u.SentMonthly):
+		limit = Monthly
+	default:
+		return nil
+	}
+	return &ExceededError{Tier: u.Tier, Limit: limit, Remaining: *u.Remaining()}
+}
+
+// Spend counts amount as sent, for transfers not recorded yet.
+func (u *Usage) Spend(amount decimal.Decimal) {
+	u.SentDaily = u.SentDaily.Add(amount)
+	u.SentMonthly = u.SentMonthly.Add(amount)
+}
+
+// ExceededError is returned for transfers above a limit, Remaining is what
+// the sender can still send.
+type ExceededError struct {
+	Tier      string
+	Limit     string
+	Remaining decimal.Decimal
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("%s limit of tier %s exceeded, remaining allowance %s", e.Limit, e.Tier, e.Remaining)
+}
+
+func (e *ExceededError) Is(target error) bool {
+	return target == ErrorLimitExceeded
+}
+
+type LimitsService struct {
+	DB *sql.DB
+}
+
+var ErrorLimitExceeded = errors.New("transfer limit exceeded")
+var ErrorInvalidLimits = errors.New("invalid tier limits")
+var ErrorLimitsNotFound = errors.New("tier limits not found")
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Load returns the usage of the given addresses in the given tokens (keys
+// are pairs of both slices). Addresses whose tier has no limits in a token
+// are missing from the map. Within a transaction that locked the wallets,
+// the sums include every transfer committed before.
+func Load(ctx context.Context, q queryer, addresses []string, symbols []string) (map[Key]*Usage, error) {
+	usages := make(map[Key]*Usage)
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT k.Address, l.Tier, l.Token, l.Per_Transfer, l.Daily, l.Monthly, l.Updated_At
+		FROM unnest($1::TEXT[], $2::TEXT[]) AS k(Address, Token)
+		LEFT JOIN Address_Tiers t ON t.Address = k.Address
+		JOIN Tier_Limits l ON l.Tier = COALESCE(t.Tier, $3) AND l.Token = k.Token
+	`, pq.Array(addresses), pq.Array(symbols), DefaultTier)
+	if err != nil {
+		return nil, err
+	}
+	var windowed []Key
+	for rows.Next() {
+		usage := &Usage{}
+		var perTransfer, daily, monthly decimal.NullDecimal
+		if err := rows.Scan(&usage.Address, &usage.Tier, &usage.Token, &perTransfer, &daily, &monthly, &usage.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		usage.PerTransfer, usage.Daily, usage.Monthly = optional(perTransfer), optional(daily), optional(monthly)
+		key := Key{usage.Address, usage.Token}
+		usages[key] = usage
+		if usage.Daily != nil || usage.Monthly != nil {
+			windowed = append(windowed, key)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := addSent(ctx, q, usages, windowed); err != nil {
+		return nil, err
+	}
+	return usages, nil
+}
+
+// addSent sums what the addresses of keys sent over the last 24 hours and
+// 30 days into their usages.
+func addSent(ctx context.Context, q queryer, usages map[Key]*Usage, keys []Key) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	addresses := make([]string, 0, len(keys))
+	symbols := make([]string, 0, len(keys))
+	for _, key := range keys {
+		addresses = append(addresses, key.Address)
+		symbols = append(symbols, key.Token)
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT From_Address, Token,
+			COALESCE(SUM(Amount) FILTER (WHERE Created_At > now() - INTERVAL '1 day'), 0),
+			COALESCE(SUM(Amount), 0)
+		FROM Journal_Entries
+		WHERE (From_Address, Token) IN (SELECT * FROM unnest($1::TEXT[], $2::TEXT[]))
+			AND Kind = ANY($3) AND Created_At > now() - INTERVAL '30 days'
+		GROUP BY From_Address, Token
+	`, pq.Array(addresses), pq.Array(symbols), pq.Array(outgoingKinds))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key Key
+		var daily, monthly decimal.Decimal
+		if err := rows.Scan(&key.Address, &key.Token, &daily, &monthly); err != nil {
+			return err
+		}
+		usages[key].SentDaily, usages[key].SentMonthly = daily, monthly
+	}
+	return rows.Err()
+}
+
+// Sent returns what address sent in token over the last 24 hours and 30
+// days.
+func Sent(ctx context.Context, q queryer, address string, token string) (decimal.Decimal, decimal.Decimal, error) {
+	key := Key{address, token}
+	usage := &Usage{}
+	if err := addSent(ctx, q, map[Key]*Usage{key: usage}, []Key{key}); err != nil {
+		return decimal.Decimal{}, decimal.Decimal{}, err
+	}
+	return usage.SentDaily, usage.SentMonthly, nil
+}
+
+func optional(d decimal.NullDecimal) *decimal.Decimal {
+	if !d.Valid {
+		return nil
+	}
+	return &d.Decimal
+}
+
+func nullDecimal(d *decimal.Decimal) decimal.NullDecimal {
+	if d == nil {
+		return decimal.NullDecimal{}
+	}
+	return decimal.NullDecimal{Decimal: *d, Valid: true}
+}
+
+// SetLimits creates or replaces the limits of a tier in a token.
+func (s *LimitsService) SetLimits(ctx context.Context, l Limits) (Limits, error) {
+	if err := l.Validate(); err != nil {
+		return Limits{}, err
+	}
+	if _, err := tokens.Get(ctx, s.DB, l.Token); err != nil {
+		return Limits{}, err
+	}
+	err := s.DB.QueryRowContext(ctx, `
+		INSERT INTO Tier_Limits (Tier, Token, Per_Transfer, Daily, Monthly) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (Tier, Token) DO UPDATE SET
+			Per_Transfer = EXCLUDED.Per_Transfer, Daily = EXCLUDED.Daily, Monthly = EXCLUDED.Monthly, Updated_At = now()
+		RETURNING Updated_At
+	`, l.Tier, l.Token, nullDecimal(l.PerTransfer), nullDecimal(l.Daily), nullDecimal(l.Monthly)).Scan(&l.UpdatedAt)
+	return l, err
+}
+
+// RemoveLimits lifts the limits of a tier in a token.
+func (s *LimitsService) RemoveLimits(ctx context.Context, tier string, token string) error {
+	result, err := s.DB.ExecContext(ctx, "DELETE FROM Tier_Limits WHERE Tier = $1 AND Token = $2", tier, token)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrorLimitsNotFound
+	}
+	return err
+}
+
+// ListLimits returns the limits of every tier.
+func (s *LimitsService) ListLimits(ctx context.Context) ([]Limits, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT Tier, Token, Per_Transfer, Daily, Monthly, Updated_At FROM Tier_Limits ORDER BY Tier ASC, Token ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Limits
+	for rows.Next() {
+		var l Limits
+		var perTransfer, daily, monthly decimal.NullDecimal
+		if err := rows.Scan(&l.Tier, &l.Token, &perTransfer, &daily, &monthly, &l.UpdatedAt); err != nil {
+			return nil, err
+		}
+		l.PerTransfer, l.Daily, l.Monthly = optional(perTransfer), optional(daily), optional(monthly)
+		list = append(list, l)
+	}
+	return list, rows.Err()
+}
+
+// SetTier moves address to tier, e.g. once it is verified.
+func (s *LimitsService) SetTier(ctx context.Context, address string, tier string, operator string) error {
+	if strings.TrimSpace(tier) == "" {
+		return ErrorInvalidLimits
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO Address_Tiers (Address, Tier, Operator) VALUES ($1, $2, $3)
+		ON CONFLICT (Address) DO UPDATE SET Tier = EXCLUDED.Tier, Operator = EXCLUDED.Operator, Updated_At = now()
+	`, address, tier, operator)
+	return err
+}
+
+// Tier returns the tier of address, DefaultTier when it has none.
+func (s *LimitsService) Tier(ctx context.Context, address string) (string, error) {
+	var tier string
+	err := s.DB.QueryRowContext(ctx, "SELECT Tier FROM Address_Tiers WHERE Address = $1", address).Scan(&tier)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DefaultTier, nil
+	}
+	return tier, err
+}
+
+// Usage returns what address sent in token against the limits of its tier.
+func (s *LimitsService) Usage(ctx context.Context, address string, token string) (Usage, error) {
+	key := Key{address, token}
+	usages, err := Load(ctx, s.DB, []string{address}, []string{token})
+	if err != nil {
+		return Usage{}, err
+	}
+	usage, ok := usages[key]
+	if !ok {
+		tier, err := s.Tier(ctx, address)
+		if err != nil {
+			return Usage{}, err
+		}
+		usage = &Usage{Limits: Limits{Tier: tier, Token: token}, Address: address}
+		usages[key] = usage
+	}
+
+	// Load only sums the windows that are limited
+	if usage.Daily == nil || usage.Monthly == nil {
+		if err := addSent(ctx, s.DB, usages, []Key{key}); err != nil {
+			return Usage{}, err
+		}
+	}
+	return *usage, nil
+}
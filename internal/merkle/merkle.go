@@ -0,0 +1,145 @@
+// Package merkle builds Merkle trees over (address, amount) pairs and
+// verifies inclusion proofs against a published root. It has no database
+// dependency, so partners can verify proofs offline.
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+var ErrorEmptyTree = errors.New("merkle tree has no leaves")
+var ErrorLeafNotFound = errors.New("address is not part of the merkle tree")
+
+// Leaf is a single (address, amount) pair of the tree.
+type Leaf struct {
+	Address string
+	Amount  decimal.Decimal
+}
+
+// ProofStep is a sibling hash on the path from a leaf to the root. Left
+// reports whether the sibling is hashed on the left of the current node.
+type ProofStep struct {
+	Hash string
+	Left bool
+}
+
+type Tree struct {
+	leaves []Leaf
+	levels [][][]byte
+}
+
+func writeField(buf *bytes.Buffer, value string) {
+	fmt.Fprintf(buf, "%d:%s", len(value), value)
+}
+
+// LeafHash hashes the canonical form of a pair: the amount is written
+// without trailing zeros, so 10 and 10.00 produce the same leaf.
+func LeafHash(address string, amount decimal.Decimal) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(leafPrefix)
+	writeField(&buf, address)
+	writeField(&buf, amount.String())
+	sum := sha256.Sum256(buf.Bytes())
+	return sum[:]
+}
+
+func nodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{nodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// NewTree builds a tree over leaves sorted by address. A node without a
+// sibling is promoted to the next level unchanged.
+func NewTree(leaves []Leaf) (*Tree, error) {
+	if len(leaves) == 0 {
+		return nil, ErrorEmptyTree
+	}
+
+	sorted := append([]Leaf(nil), leaves...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Address < sorted[j].Address })
+
+	level := make([][]byte, len(sorted))
+	for i, leaf := range sorted {
+		level[i] = LeafHash(leaf.Address, leaf.Amount)
+	}
+
+	tree := &Tree{leaves: sorted, levels: [][][]byte{level}}
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+			} else {
+				next = append(next, nodeHash(level[i], level[i+1]))
+			}
+		}
+		tree.levels = append(tree.levels, next)
+		level = next
+	}
+	return tree, nil
+}
+
+func (t *Tree) Root() string {
+	return hex.EncodeToString(t.levels[len(t.levels)-1][0])
+}
+
+func (t *Tree) Len() int {
+	return len(t.leaves)
+}
+
+// Proof returns the leaf of address and the sibling path to the root.
+func (t *Tree) Proof(address string) (Leaf, []ProofStep, error) {
+	index := sort.Search(len(t.leaves), func(i int) bool { return t.leaves[i].Address >= address })
+	if index == len(t.leaves) || t.leaves[index].Address != address {
+		return Leaf{}, nil, ErrorLeafNotFound
+	}
+
+	leaf := t.leaves[index]
+	var proof []ProofStep
+	for _, level := range t.levels[:len(t.levels)-1] {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			proof = append(proof, ProofStep{Hash: hex.EncodeToString(level[sibling]), Left: sibling < index})
+		}
+		index /= 2
+	}
+	return leaf, proof, nil
+}
+
+// Verify checks that (address, amount) is included in the tree with the
+// given hex encoded root.
+func Verify(root string, address string, amount decimal.Decimal, proof []ProofStep) bool {
+	expected, err := hex.DecodeString(root)
+	if err != nil {
+		return false
+	}
+
+	current := LeafHash(address, amount)
+	for _, step := range proof {
+		sibling, err := hex.DecodeString(step.Hash)
+		if err != nil || len(sibling) != sha256.Size {
+			return false
+		}
+		if step.Left {
+			current = nodeHash(sibling, current)
+		} else {
+			current = nodeHash(current, sibling)
+		}
+	}
+	return bytes.Equal(current, expected)
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS Postings;
+DROP TABLE IF EXISTS Journal_Entries;
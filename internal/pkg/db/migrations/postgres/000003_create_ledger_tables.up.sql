@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS Journal_Entries(
+    Id BIGSERIAL PRIMARY KEY,
+    Kind TEXT NOT NULL,
+    From_Address TEXT,
+    To_Address TEXT,
+    Amount NUMERIC NOT NULL,
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS Postings(
+    Id BIGSERIAL PRIMARY KEY,
+    Entry_Id BIGINT NOT NULL REFERENCES Journal_Entries(Id),
+    Account TEXT NOT NULL,
+    Debit NUMERIC NOT NULL DEFAULT 0,
+    Credit NUMERIC NOT NULL DEFAULT 0,
+    CHECK (Debit >= 0 AND Credit >= 0 AND (Debit = 0) <> (Credit = 0))
+);
+
+CREATE INDEX IF NOT EXISTS Postings_Account_Idx ON Postings (Account);
+CREATE INDEX IF NOT EXISTS Postings_Entry_Idx ON Postings (Entry_Id);
+
+-- existing balances are booked as opening mints against the issuance account
+INSERT INTO Journal_Entries (Kind, To_Address, Amount)
+SELECT 'mint', Address, Balance FROM Wallets WHERE Balance > 0 ORDER BY Address;
+
+INSERT INTO Postings (Entry_Id, Account, Debit)
+SELECT Id, 'system:issuance', Amount FROM Journal_Entries WHERE Kind = 'mint';
+
+INSERT INTO Postings (Entry_Id, Account, Credit)
+SELECT Id, To_Address, Amount FROM Journal_Entries WHERE Kind = 'mint';
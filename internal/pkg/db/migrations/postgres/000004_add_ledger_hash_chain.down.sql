@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS Ledger_Checkpoints;
+ALTER TABLE Journal_Entries DROP COLUMN IF EXISTS Hash;
+ALTER TABLE Journal_Entries DROP COLUMN IF EXISTS Prev_Hash;
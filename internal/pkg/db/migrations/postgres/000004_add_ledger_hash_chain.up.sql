@@ -0,0 +1,10 @@
+ALTER TABLE Journal_Entries ADD COLUMN IF NOT EXISTS Prev_Hash TEXT;
+ALTER TABLE Journal_Entries ADD COLUMN IF NOT EXISTS Hash TEXT;
+
+CREATE TABLE IF NOT EXISTS Ledger_Checkpoints(
+    Id BIGSERIAL PRIMARY KEY,
+    Entry_Id BIGINT NOT NULL REFERENCES Journal_Entries(Id),
+    Hash TEXT NOT NULL,
+    Signature TEXT NOT NULL,
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
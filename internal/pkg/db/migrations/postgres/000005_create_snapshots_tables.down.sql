@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS Snapshot_Balances;
+DROP TABLE IF EXISTS Snapshots;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS Snapshots(
+    Id BIGSERIAL PRIMARY KEY,
+    Merkle_Root TEXT NOT NULL,
+    Wallet_Count INTEGER NOT NULL,
+    Last_Entry_Id BIGINT NOT NULL DEFAULT 0,
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS Snapshot_Balances(
+    Snapshot_Id BIGINT NOT NULL REFERENCES Snapshots(Id),
+    Address TEXT NOT NULL,
+    Balance NUMERIC NOT NULL,
+    PRIMARY KEY (Snapshot_Id, Address)
+);
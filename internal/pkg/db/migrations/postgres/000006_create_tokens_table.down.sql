@@ -0,0 +1,21 @@
+ALTER TABLE Snapshots ADD COLUMN IF NOT EXISTS Merkle_Root TEXT NOT NULL DEFAULT '';
+ALTER TABLE Snapshots ADD COLUMN IF NOT EXISTS Wallet_Count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE Snapshots s SET Merkle_Root = r.Merkle_Root, Wallet_Count = r.Wallet_Count
+FROM Snapshot_Roots r WHERE r.Snapshot_Id = s.Id AND r.Token = 'BTP';
+
+DROP TABLE IF EXISTS Snapshot_Roots;
+
+DELETE FROM Snapshot_Balances WHERE Token <> 'BTP';
+ALTER TABLE Snapshot_Balances DROP CONSTRAINT IF EXISTS snapshot_balances_pkey;
+ALTER TABLE Snapshot_Balances DROP COLUMN IF EXISTS Token;
+ALTER TABLE Snapshot_Balances ADD PRIMARY KEY (Snapshot_Id, Address);
+
+ALTER TABLE Journal_Entries DROP COLUMN IF EXISTS Token;
+
+DELETE FROM Wallets WHERE Token <> 'BTP';
+ALTER TABLE Wallets DROP CONSTRAINT IF EXISTS wallets_pkey;
+ALTER TABLE Wallets DROP COLUMN IF EXISTS Token;
+ALTER TABLE Wallets ADD PRIMARY KEY (Address);
+
+DROP TABLE IF EXISTS Tokens;
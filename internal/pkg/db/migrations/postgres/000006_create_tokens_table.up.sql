@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS Tokens(
+    Symbol TEXT PRIMARY KEY,
+    Name TEXT NOT NULL,
+    Decimals INTEGER NOT NULL DEFAULT 0 CHECK (Decimals >= 0 AND Decimals <= 36),
+    Supply_Cap NUMERIC CHECK (Supply_Cap > 0),
+    Issuer TEXT,
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO Tokens (Symbol, Name, Decimals)
+VALUES ('BTP', 'BTP Token', 0)
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE Wallets ADD COLUMN IF NOT EXISTS Token TEXT NOT NULL DEFAULT 'BTP' REFERENCES Tokens(Symbol);
+ALTER TABLE Wallets DROP CONSTRAINT IF EXISTS wallets_pkey;
+ALTER TABLE Wallets ADD PRIMARY KEY (Address, Token);
+
+ALTER TABLE Journal_Entries ADD COLUMN IF NOT EXISTS Token TEXT NOT NULL DEFAULT 'BTP' REFERENCES Tokens(Symbol);
+
+ALTER TABLE Snapshot_Balances ADD COLUMN IF NOT EXISTS Token TEXT NOT NULL DEFAULT 'BTP';
+ALTER TABLE Snapshot_Balances DROP CONSTRAINT IF EXISTS snapshot_balances_pkey;
+ALTER TABLE Snapshot_Balances ADD PRIMARY KEY (Snapshot_Id, Token, Address);
+
+CREATE TABLE IF NOT EXISTS Snapshot_Roots(
+    Snapshot_Id BIGINT NOT NULL REFERENCES Snapshots(Id),
+    Token TEXT NOT NULL,
+    Merkle_Root TEXT NOT NULL,
+    Wallet_Count INTEGER NOT NULL,
+    PRIMARY KEY (Snapshot_Id, Token)
+);
+
+INSERT INTO Snapshot_Roots (Snapshot_Id, Token, Merkle_Root, Wallet_Count)
+SELECT Id, 'BTP', Merkle_Root, Wallet_Count FROM Snapshots;
+
+ALTER TABLE Snapshots DROP COLUMN IF EXISTS Merkle_Root;
+ALTER TABLE Snapshots DROP COLUMN IF EXISTS Wallet_Count;
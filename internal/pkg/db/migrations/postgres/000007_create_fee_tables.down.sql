@@ -0,0 +1,3 @@
+ALTER TABLE Journal_Entries DROP COLUMN IF EXISTS Fee;
+DROP TABLE IF EXISTS Fee_Tiers;
+DROP TABLE IF EXISTS Fee_Policies;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS Fee_Policies(
+    Token TEXT PRIMARY KEY REFERENCES Tokens(Symbol),
+    Treasury_Address TEXT NOT NULL,
+    Flat NUMERIC NOT NULL DEFAULT 0 CHECK (Flat >= 0),
+    Basis_Points INTEGER NOT NULL DEFAULT 0 CHECK (Basis_Points >= 0 AND Basis_Points <= 10000),
+    Min_Fee NUMERIC CHECK (Min_Fee >= 0),
+    Max_Fee NUMERIC CHECK (Max_Fee >= 0),
+    Updated_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS Fee_Tiers(
+    Token TEXT NOT NULL REFERENCES Fee_Policies(Token) ON DELETE CASCADE,
+    From_Amount NUMERIC NOT NULL CHECK (From_Amount >= 0),
+    Flat NUMERIC NOT NULL DEFAULT 0 CHECK (Flat >= 0),
+    Basis_Points INTEGER NOT NULL DEFAULT 0 CHECK (Basis_Points >= 0 AND Basis_Points <= 10000),
+    PRIMARY KEY (Token, From_Amount)
+);
+
+ALTER TABLE Journal_Entries ADD COLUMN IF NOT EXISTS Fee NUMERIC NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS Holds;
+ALTER TABLE Wallets DROP CONSTRAINT IF EXISTS wallets_held_check;
+ALTER TABLE Wallets DROP COLUMN IF EXISTS Held;
@@ -0,0 +1,20 @@
+ALTER TABLE Wallets ADD COLUMN IF NOT EXISTS Held NUMERIC NOT NULL DEFAULT 0;
+ALTER TABLE Wallets ADD CONSTRAINT wallets_held_check CHECK (Held >= 0 AND Held <= Balance);
+
+CREATE TABLE IF NOT EXISTS Holds(
+    Id BIGSERIAL PRIMARY KEY,
+    Address TEXT NOT NULL,
+    Token TEXT NOT NULL REFERENCES Tokens(Symbol),
+    Payee TEXT NOT NULL,
+    Amount NUMERIC NOT NULL CHECK (Amount > 0),
+    Captured_Amount NUMERIC NOT NULL DEFAULT 0,
+    Reference TEXT,
+    Status TEXT NOT NULL DEFAULT 'active',
+    Transfer_Entry_Id BIGINT REFERENCES Journal_Entries(Id),
+    Expires_At TIMESTAMPTZ NOT NULL,
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now(),
+    Updated_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS holds_active_expiry_idx ON Holds (Expires_At) WHERE Status = 'active';
+CREATE INDEX IF NOT EXISTS holds_address_idx ON Holds (Address, Token);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS Escrow_Events;
+DROP TABLE IF EXISTS Escrows;
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS Escrows(
+    Id BIGSERIAL PRIMARY KEY,
+    Token TEXT NOT NULL REFERENCES Tokens(Symbol),
+    Buyer TEXT NOT NULL,
+    Seller TEXT NOT NULL,
+    Arbiter TEXT NOT NULL,
+    Amount NUMERIC NOT NULL CHECK (Amount > 0),
+    Status TEXT NOT NULL DEFAULT 'funded',
+    Buyer_Approved BOOLEAN NOT NULL DEFAULT FALSE,
+    Seller_Approved BOOLEAN NOT NULL DEFAULT FALSE,
+    Reference TEXT,
+    Deadline TIMESTAMPTZ NOT NULL,
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now(),
+    Updated_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS escrows_buyer_idx ON Escrows (Buyer);
+CREATE INDEX IF NOT EXISTS escrows_seller_idx ON Escrows (Seller);
+
+CREATE TABLE IF NOT EXISTS Escrow_Events(
+    Id BIGSERIAL PRIMARY KEY,
+    Escrow_Id BIGINT NOT NULL REFERENCES Escrows(Id) ON DELETE CASCADE,
+    Action TEXT NOT NULL,
+    From_Status TEXT,
+    To_Status TEXT NOT NULL,
+    Actor TEXT,
+    Note TEXT,
+    Transfer_Entry_Id BIGINT REFERENCES Journal_Entries(Id),
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS escrow_events_escrow_idx ON Escrow_Events (Escrow_Id, Id);
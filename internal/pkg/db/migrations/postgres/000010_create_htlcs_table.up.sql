@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS Htlcs(
+    Id BIGSERIAL PRIMARY KEY,
+    Token TEXT NOT NULL REFERENCES Tokens(Symbol),
+    Sender TEXT NOT NULL,
+    Recipient TEXT NOT NULL,
+    Amount NUMERIC NOT NULL CHECK (Amount > 0),
+    Hashlock TEXT NOT NULL,
+    Preimage TEXT,
+    Status TEXT NOT NULL DEFAULT 'locked',
+    Transfer_Entry_Id BIGINT REFERENCES Journal_Entries(Id),
+    Timeout_At TIMESTAMPTZ NOT NULL,
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now(),
+    Updated_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS htlcs_sender_idx ON Htlcs (Sender);
+CREATE INDEX IF NOT EXISTS htlcs_recipient_idx ON Htlcs (Recipient);
+CREATE INDEX IF NOT EXISTS htlcs_hashlock_idx ON Htlcs (Hashlock);
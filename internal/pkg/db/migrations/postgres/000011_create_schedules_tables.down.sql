@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS Schedule_Runs;
+DROP TABLE IF EXISTS Scheduled_Transfers;
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS Scheduled_Transfers(
+    Id BIGSERIAL PRIMARY KEY,
+    Token TEXT NOT NULL REFERENCES Tokens(Symbol),
+    From_Address TEXT NOT NULL,
+    To_Address TEXT NOT NULL,
+    Amount NUMERIC NOT NULL CHECK (Amount > 0),
+    Cron TEXT,
+    Interval_Seconds BIGINT,
+    Ends_At TIMESTAMPTZ,
+    Max_Runs INTEGER,
+    Run_Count INTEGER NOT NULL DEFAULT 0,
+    Next_Run_At TIMESTAMPTZ,
+    Status TEXT NOT NULL DEFAULT 'active',
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now(),
+    Updated_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS scheduled_transfers_due_idx ON Scheduled_Transfers (Next_Run_At) WHERE Status = 'active';
+CREATE INDEX IF NOT EXISTS scheduled_transfers_from_idx ON Scheduled_Transfers (From_Address);
+
+CREATE TABLE IF NOT EXISTS Schedule_Runs(
+    Id BIGSERIAL PRIMARY KEY,
+    Schedule_Id BIGINT NOT NULL REFERENCES Scheduled_Transfers(Id) ON DELETE CASCADE,
+    Scheduled_For TIMESTAMPTZ NOT NULL,
+    Status TEXT NOT NULL,
+    Transfer_Entry_Id BIGINT REFERENCES Journal_Entries(Id),
+    Error TEXT,
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS schedule_runs_schedule_idx ON Schedule_Runs (Schedule_Id, Id);
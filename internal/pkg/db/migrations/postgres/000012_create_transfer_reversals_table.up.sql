@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS Transfer_Reversals(
+    Id BIGSERIAL PRIMARY KEY,
+    Transfer_Entry_Id BIGINT NOT NULL UNIQUE REFERENCES Journal_Entries(Id),
+    Reversal_Entry_Id BIGINT NOT NULL UNIQUE REFERENCES Journal_Entries(Id),
+    Amount NUMERIC NOT NULL CHECK (Amount > 0),
+    Reason TEXT NOT NULL,
+    Operator TEXT NOT NULL,
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS Compliance_Events;
+DROP TABLE IF EXISTS Deny_List;
+DROP TABLE IF EXISTS Address_Statuses;
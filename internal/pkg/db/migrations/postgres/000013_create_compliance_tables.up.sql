@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS Address_Statuses(
+    Address TEXT PRIMARY KEY,
+    Status TEXT NOT NULL CHECK (Status IN ('active', 'frozen_incoming', 'frozen_outgoing', 'frozen')),
+    Reason TEXT NOT NULL,
+    Operator TEXT NOT NULL,
+    Updated_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS Deny_List(
+    Address TEXT PRIMARY KEY,
+    List TEXT NOT NULL,
+    Reason TEXT NOT NULL,
+    Operator TEXT NOT NULL,
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS deny_list_list_idx ON Deny_List (List);
+
+CREATE TABLE IF NOT EXISTS Compliance_Events(
+    Id BIGSERIAL PRIMARY KEY,
+    Address TEXT NOT NULL,
+    Action TEXT NOT NULL,
+    Status TEXT,
+    List TEXT,
+    Reason TEXT NOT NULL,
+    Operator TEXT NOT NULL,
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS compliance_events_address_idx ON Compliance_Events (Address, Id);
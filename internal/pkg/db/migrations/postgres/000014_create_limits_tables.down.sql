@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS journal_entries_outgoing_idx;
+DROP TABLE IF EXISTS Address_Tiers;
+DROP TABLE IF EXISTS Tier_Limits;
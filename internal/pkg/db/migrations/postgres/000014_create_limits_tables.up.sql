@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS Tier_Limits(
+    Tier TEXT NOT NULL,
+    Token TEXT NOT NULL REFERENCES Tokens(Symbol),
+    Per_Transfer NUMERIC CHECK (Per_Transfer > 0),
+    Daily NUMERIC CHECK (Daily > 0),
+    Monthly NUMERIC CHECK (Monthly > 0),
+    Updated_At TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (Tier, Token)
+);
+
+CREATE TABLE IF NOT EXISTS Address_Tiers(
+    Address TEXT PRIMARY KEY,
+    Tier TEXT NOT NULL,
+    Operator TEXT NOT NULL,
+    Updated_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- rolling window sums of what an address sent
+CREATE INDEX IF NOT EXISTS journal_entries_outgoing_idx ON Journal_Entries (From_Address, Token, Created_At);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS Policy_Flags;
+DROP TABLE IF EXISTS Policy_Rules;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS Policy_Rules(
+    Id BIGSERIAL PRIMARY KEY,
+    Name TEXT NOT NULL,
+    Version INT NOT NULL,
+    Expression TEXT NOT NULL,
+    Action TEXT NOT NULL CHECK (Action IN ('allow', 'deny', 'flag')),
+    Priority INT NOT NULL DEFAULT 100,
+    Message TEXT,
+    Enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    Operator TEXT NOT NULL,
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (Name, Version)
+);
+
+CREATE TABLE IF NOT EXISTS Policy_Flags(
+    Id BIGSERIAL PRIMARY KEY,
+    Entry_Id BIGINT NOT NULL REFERENCES Journal_Entries(Id),
+    Rule_Id BIGINT NOT NULL REFERENCES Policy_Rules(Id),
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS policy_flags_entry_idx ON Policy_Flags (Entry_Id);
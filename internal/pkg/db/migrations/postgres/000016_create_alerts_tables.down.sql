@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS journal_entries_incoming_idx;
+DROP TABLE IF EXISTS Alert_Events;
+DROP TABLE IF EXISTS Alerts;
+DROP TABLE IF EXISTS Alert_Cursor;
+DROP TABLE IF EXISTS Alert_Detectors;
@@ -0,0 +1,48 @@
+-- overrides of the built in detectors' defaults
+CREATE TABLE IF NOT EXISTS Alert_Detectors(
+    Name TEXT PRIMARY KEY,
+    Enabled BOOLEAN NOT NULL,
+    Severity TEXT NOT NULL CHECK (Severity IN ('low', 'medium', 'high', 'critical')),
+    Thresholds JSONB NOT NULL DEFAULT '{}',
+    Operator TEXT NOT NULL,
+    Updated_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- the last journal entry the detectors ran on
+CREATE TABLE IF NOT EXISTS Alert_Cursor(
+    Id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (Id),
+    Last_Entry_Id BIGINT NOT NULL DEFAULT 0
+);
+
+INSERT INTO Alert_Cursor (Last_Entry_Id) SELECT COALESCE(MAX(Id), 0) FROM Journal_Entries ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS Alerts(
+    Id BIGSERIAL PRIMARY KEY,
+    Detector TEXT NOT NULL,
+    Severity TEXT NOT NULL CHECK (Severity IN ('low', 'medium', 'high', 'critical')),
+    Status TEXT NOT NULL DEFAULT 'open' CHECK (Status IN ('open', 'acknowledged', 'escalated', 'closed')),
+    Address TEXT NOT NULL,
+    Token TEXT NOT NULL,
+    Entry_Id BIGINT NOT NULL REFERENCES Journal_Entries(Id),
+    Evidence TEXT NOT NULL,
+    Evidence_Entry_Ids BIGINT[] NOT NULL,
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now(),
+    Updated_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS alerts_status_idx ON Alerts (Status, Id);
+CREATE INDEX IF NOT EXISTS alerts_address_idx ON Alerts (Address, Detector) WHERE Status <> 'closed';
+
+CREATE TABLE IF NOT EXISTS Alert_Events(
+    Id BIGSERIAL PRIMARY KEY,
+    Alert_Id BIGINT NOT NULL REFERENCES Alerts(Id),
+    Action TEXT NOT NULL,
+    Status TEXT NOT NULL,
+    Note TEXT,
+    Operator TEXT NOT NULL,
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS alert_events_alert_idx ON Alert_Events (Alert_Id, Id);
+
+CREATE INDEX IF NOT EXISTS journal_entries_incoming_idx ON Journal_Entries (To_Address, Token, Created_At);
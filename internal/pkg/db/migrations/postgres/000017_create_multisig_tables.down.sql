@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS Multisig_Approvals;
+DROP TABLE IF EXISTS Multisig_Proposals;
+DROP TABLE IF EXISTS Multisig_Signers;
+DROP TABLE IF EXISTS Multisig_Wallets;
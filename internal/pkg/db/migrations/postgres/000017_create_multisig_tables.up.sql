@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS Multisig_Wallets(
+    Address TEXT PRIMARY KEY,
+    Threshold INTEGER NOT NULL CHECK (Threshold > 0),
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS Multisig_Signers(
+    Wallet TEXT NOT NULL REFERENCES Multisig_Wallets(Address),
+    Address TEXT NOT NULL,
+    -- hex encoded ed25519 public key
+    Public_Key TEXT NOT NULL,
+    PRIMARY KEY (Wallet, Address)
+);
+
+CREATE TABLE IF NOT EXISTS Multisig_Proposals(
+    Id BIGSERIAL PRIMARY KEY,
+    Wallet TEXT NOT NULL REFERENCES Multisig_Wallets(Address),
+    Token TEXT NOT NULL REFERENCES Tokens(Symbol),
+    To_Address TEXT NOT NULL,
+    Amount NUMERIC NOT NULL CHECK (Amount > 0),
+    Proposer TEXT NOT NULL,
+    Status TEXT NOT NULL DEFAULT 'pending' CHECK (Status IN ('pending', 'executed', 'cancelled')),
+    Transfer_Entry_Id BIGINT REFERENCES Journal_Entries(Id),
+    -- why the last execution failed
+    Error TEXT,
+    Expires_At TIMESTAMPTZ NOT NULL,
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now(),
+    Updated_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS multisig_proposals_wallet_idx ON Multisig_Proposals (Wallet, Id);
+
+CREATE TABLE IF NOT EXISTS Multisig_Approvals(
+    Proposal_Id BIGINT NOT NULL REFERENCES Multisig_Proposals(Id),
+    Signer TEXT NOT NULL,
+    Signature TEXT NOT NULL,
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (Proposal_Id, Signer)
+);
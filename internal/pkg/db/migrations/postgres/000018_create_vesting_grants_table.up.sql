@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS Vesting_Grants(
+    Id BIGSERIAL PRIMARY KEY,
+    Token TEXT NOT NULL REFERENCES Tokens(Symbol),
+    Funder TEXT NOT NULL,
+    Beneficiary TEXT NOT NULL,
+    Total NUMERIC NOT NULL CHECK (Total > 0),
+    Claimed NUMERIC NOT NULL DEFAULT 0,
+    Returned NUMERIC NOT NULL DEFAULT 0,
+    Start_At TIMESTAMPTZ NOT NULL,
+    Cliff_Seconds BIGINT NOT NULL DEFAULT 0 CHECK (Cliff_Seconds >= 0),
+    Duration_Seconds BIGINT NOT NULL CHECK (Duration_Seconds > 0),
+    Revocable BOOLEAN NOT NULL DEFAULT false,
+    Revoked_At TIMESTAMPTZ,
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now(),
+    Updated_At TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (Cliff_Seconds <= Duration_Seconds),
+    CHECK (Claimed + Returned <= Total)
+);
+
+CREATE INDEX IF NOT EXISTS vesting_grants_beneficiary_idx ON Vesting_Grants (Beneficiary);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS Stakes;
+DROP TABLE IF EXISTS Staking_Pools;
+ALTER TABLE Wallets DROP CONSTRAINT IF EXISTS wallets_held_check;
+ALTER TABLE Wallets ADD CONSTRAINT wallets_held_check CHECK (Held >= 0 AND Held <= Balance);
+ALTER TABLE Wallets DROP COLUMN IF EXISTS Staked;
@@ -0,0 +1,31 @@
+ALTER TABLE Wallets ADD COLUMN IF NOT EXISTS Staked NUMERIC NOT NULL DEFAULT 0;
+ALTER TABLE Wallets DROP CONSTRAINT IF EXISTS wallets_held_check;
+ALTER TABLE Wallets ADD CONSTRAINT wallets_held_check CHECK (Held >= 0 AND Staked >= 0 AND Held + Staked <= Balance);
+
+CREATE TABLE IF NOT EXISTS Staking_Pools(
+    Token TEXT PRIMARY KEY REFERENCES Tokens(Symbol),
+    Apr NUMERIC CHECK (Apr >= 0),
+    Reward_Rate NUMERIC CHECK (Reward_Rate >= 0),
+    Unbonding_Seconds BIGINT NOT NULL DEFAULT 0 CHECK (Unbonding_Seconds >= 0),
+    Total_Staked NUMERIC NOT NULL DEFAULT 0 CHECK (Total_Staked >= 0),
+    Reward_Per_Token NUMERIC NOT NULL DEFAULT 0,
+    Accrued_At TIMESTAMPTZ NOT NULL DEFAULT now(),
+    Updated_At TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK ((Apr IS NULL) <> (Reward_Rate IS NULL))
+);
+
+CREATE TABLE IF NOT EXISTS Stakes(
+    Id BIGSERIAL PRIMARY KEY,
+    Token TEXT NOT NULL REFERENCES Staking_Pools(Token),
+    Address TEXT NOT NULL,
+    Amount NUMERIC NOT NULL CHECK (Amount > 0),
+    Status TEXT NOT NULL DEFAULT 'staked',
+    Reward_Per_Token_Paid NUMERIC NOT NULL,
+    Rewards NUMERIC NOT NULL DEFAULT 0,
+    Claimed_Rewards NUMERIC NOT NULL DEFAULT 0,
+    Unbonds_At TIMESTAMPTZ,
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now(),
+    Updated_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS stakes_address_idx ON Stakes (Address, Token);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS Distribution_Recipients;
+DROP TABLE IF EXISTS Distributions;
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS Distributions(
+    Id BIGSERIAL PRIMARY KEY,
+    Token TEXT NOT NULL REFERENCES Tokens(Symbol),
+    From_Address TEXT NOT NULL,
+    Total NUMERIC NOT NULL CHECK (Total > 0),
+    Snapshot_Id BIGINT REFERENCES Snapshots(Id),
+    Weight_Token TEXT,
+    Status TEXT NOT NULL DEFAULT 'pending',
+    Operator TEXT NOT NULL,
+    Started_At TIMESTAMPTZ,
+    Completed_At TIMESTAMPTZ,
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now(),
+    Updated_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS Distribution_Recipients(
+    Distribution_Id BIGINT NOT NULL REFERENCES Distributions(Id),
+    Address TEXT NOT NULL,
+    Position BIGINT NOT NULL,
+    Weight NUMERIC NOT NULL CHECK (Weight > 0),
+    Amount NUMERIC NOT NULL CHECK (Amount > 0),
+    Status TEXT NOT NULL DEFAULT 'pending',
+    Transfer_Entry_Id BIGINT REFERENCES Journal_Entries(Id),
+    Error TEXT,
+    Updated_At TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (Distribution_Id, Address)
+);
+
+CREATE INDEX IF NOT EXISTS distribution_recipients_status_idx ON Distribution_Recipients (Distribution_Id, Status, Position);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS Airdrop_Leaves;
+DROP TABLE IF EXISTS Airdrops;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS Airdrops(
+    Id BIGSERIAL PRIMARY KEY,
+    Token TEXT NOT NULL REFERENCES Tokens(Symbol),
+    Funder TEXT NOT NULL,
+    Merkle_Root TEXT NOT NULL,
+    Total NUMERIC NOT NULL CHECK (Total > 0),
+    Leaf_Count INTEGER NOT NULL,
+    Claimed NUMERIC NOT NULL DEFAULT 0,
+    Claim_Count INTEGER NOT NULL DEFAULT 0,
+    Expires_At TIMESTAMPTZ NOT NULL,
+    Swept NUMERIC NOT NULL DEFAULT 0,
+    Swept_At TIMESTAMPTZ,
+    Operator TEXT NOT NULL,
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now(),
+    Updated_At TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (Claimed + Swept <= Total)
+);
+
+CREATE TABLE IF NOT EXISTS Airdrop_Leaves(
+    Airdrop_Id BIGINT NOT NULL REFERENCES Airdrops(Id),
+    Address TEXT NOT NULL,
+    Amount NUMERIC NOT NULL CHECK (Amount > 0),
+    Claimed_At TIMESTAMPTZ,
+    Transfer_Entry_Id BIGINT REFERENCES Journal_Entries(Id),
+    PRIMARY KEY (Airdrop_Id, Address)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS Payment_Request_Payments;
+DROP TABLE IF EXISTS Payment_Requests;
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS Payment_Requests(
+    Id BIGSERIAL PRIMARY KEY,
+    Token TEXT NOT NULL REFERENCES Tokens(Symbol),
+    Payee TEXT NOT NULL,
+    Amount NUMERIC NOT NULL CHECK (Amount > 0),
+    Paid_Amount NUMERIC NOT NULL DEFAULT 0,
+    Memo TEXT,
+    Reference TEXT NOT NULL UNIQUE,
+    Status TEXT NOT NULL DEFAULT 'open',
+    Expires_At TIMESTAMPTZ NOT NULL,
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now(),
+    Updated_At TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (Paid_Amount <= Amount)
+);
+
+CREATE INDEX IF NOT EXISTS payment_requests_payee_idx ON Payment_Requests (Payee, Id);
+
+CREATE TABLE IF NOT EXISTS Payment_Request_Payments(
+    Id BIGSERIAL PRIMARY KEY,
+    Request_Id BIGINT NOT NULL REFERENCES Payment_Requests(Id),
+    Payer TEXT NOT NULL,
+    Amount NUMERIC NOT NULL CHECK (Amount > 0),
+    Transfer_Entry_Id BIGINT NOT NULL REFERENCES Journal_Entries(Id),
+    Created_At TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS payment_request_payments_request_idx ON Payment_Request_Payments (Request_Id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS multisig_proposals_signature_idx;
+ALTER TABLE Multisig_Proposals DROP COLUMN IF EXISTS Signature;
@@ -0,0 +1,5 @@
+-- the proposer's signature of the proposal message, unique so it cannot be
+-- replayed to open the same proposal again
+ALTER TABLE Multisig_Proposals ADD COLUMN IF NOT EXISTS Signature TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS multisig_proposals_signature_idx ON Multisig_Proposals (Signature);
@@ -0,0 +1 @@
+ALTER TABLE Distributions DROP COLUMN IF EXISTS Returned;
@@ -0,0 +1,2 @@
+-- what went back to the funder when the distribution was closed
+ALTER TABLE Distributions ADD COLUMN IF NOT EXISTS Returned NUMERIC NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS payment_request_payments_transfer_idx;
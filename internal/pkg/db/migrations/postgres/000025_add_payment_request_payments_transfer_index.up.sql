@@ -0,0 +1,2 @@
+-- reversals look up the payment a transfer made
+CREATE UNIQUE INDEX IF NOT EXISTS payment_request_payments_transfer_idx ON Payment_Request_Payments (Transfer_Entry_Id);
@@ -0,0 +1,623 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/shopspring/decimal"
+)
+
+// The rule language is a small typed expression language:
+//
+//	amount > 1000 && tier == "unverified"
+//	to in ["0xaa", "0xbb"] || (hour < 6 && counterpart_transfers == 0)
+//	starts_with(from, "escrow:")
+//
+// Values are numbers (decimals), strings and booleans. Operators are
+// || && ! == != < <= > >= + - * / and "in" / "not in" a list of literals.
+// Expressions are type checked when compiled and must yield a boolean.
+
+type kind int
+
+const (
+	kindNumber kind = iota
+	kindString
+	kindBool
+	kindList
+)
+
+func (k kind) String() string {
+	return [...]string{"number", "string", "boolean", "list"}[k]
+}
+
+type value struct {
+	kind kind
+	num  decimal.Decimal
+	str  string
+	b    bool
+	list []value
+}
+
+func (v value) equal(other value) bool {
+	switch v.kind {
+	case kindNumber:
+		return v.num.Equal(other.num)
+	case kindString:
+		return v.str == other.str
+	case kindBool:
+		return v.b == other.b
+	}
+	return false
+}
+
+func (v value) String() string {
+	switch v.kind {
+	case kindNumber:
+		return v.num.String()
+	case kindString:
+		return v.str
+	case kindBool:
+		return fmt.Sprint(v.b)
+	}
+	return fmt.Sprint(v.list)
+}
+
+var ErrorInvalidExpression = errors.New("invalid policy expression")
+
+// Expr is a compiled expression.
+type Expr struct {
+	root node
+	// Attributes are the attribute names the expression reads
+	Attributes []string
+}
+
+// Compile parses and type checks source.
+func Compile(source string) (*Expr, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, p.errorf("unexpected %q", p.peek().text)
+	}
+
+	used := make(map[string]bool)
+	k, err := root.check(used)
+	if err != nil {
+		return nil, err
+	}
+	if k != kindBool {
+		return nil, fmt.Errorf("%w: expression must be a boolean, got %s", ErrorInvalidExpression, k)
+	}
+
+	expr := &Expr{root: root}
+	for _, attribute := range attributeNames {
+		if used[attribute] {
+			expr.Attributes = append(expr.Attributes, attribute)
+		}
+	}
+	return expr, nil
+}
+
+// Eval runs the expression against the attributes of a transfer.
+func (e *Expr) Eval(attrs Attributes) (bool, error) {
+	v, err := e.root.eval(attrs)
+	if err != nil {
+		return false, err
+	}
+	return v.b, nil
+}
+
+// lexer
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+var operators = []string{"||", "&&", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "(", ")", "[", "]", ","}
+
+func lex(source string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(source); {
+		c := rune(source[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(source) && (source[i] >= '0' && source[i] <= '9' || source[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokNumber, source[start:i], start})
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(source[i+1:], source[i])
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated string at %d", ErrorInvalidExpression, i)
+			}
+			tokens = append(tokens, token{tokString, source[i+1 : i+1+end], i})
+			i += end + 2
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+			for i < len(source) && (source[i] == '_' || unicode.IsLetter(rune(source[i])) || unicode.IsDigit(rune(source[i]))) {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, source[start:i], start})
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(source[i:], op) {
+					tokens = append(tokens, token{tokOp, op, i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("%w: unexpected %q at %d", ErrorInvalidExpression, c, i)
+			}
+		}
+	}
+	return append(tokens, token{tokEOF, "end of expression", len(source)}), nil
+}
+
+// parser
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) accept(kind tokKind, text string) bool {
+	if t := p.peek(); t.kind == kind && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(tokOp, text) {
+		return p.errorf("expected %q, got %q", text, p.peek().text)
+	}
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s at %d", ErrorInvalidExpression, fmt.Sprintf(format, args...), p.peek().pos)
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.accept(tokOp, "||") {
+		var right node
+		right, err = p.parseAnd()
+		left = &binary{op: "||", left: left, right: right}
+	}
+	return left, err
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	for err == nil && p.accept(tokOp, "&&") {
+		var right node
+		right, err = p.parseNot()
+		left = &binary{op: "&&", left: left, right: right}
+	}
+	return left, err
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.accept(tokOp, "!") {
+		operand, err := p.parseNot()
+		return &not{operand: operand}, err
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	negate := false
+	if p.peek().kind == tokIdent && p.peek().text == "not" {
+		p.next()
+		negate = true
+		if p.peek().text != "in" {
+			return nil, p.errorf(`expected "in" after "not"`)
+		}
+	}
+	if p.accept(tokIdent, "in") {
+		list, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		var n node = &in{value: left, list: list}
+		if negate {
+			n = &not{operand: n}
+		}
+		return n, nil
+	}
+
+	t := p.peek()
+	switch t.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+		if t.kind == tokOp {
+			p.next()
+			right, err := p.parseSum()
+			return &binary{op: t.text, left: left, right: right}, err
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) parseSum() (node, error) {
+	left, err := p.parseProduct()
+	for err == nil && (p.peek().text == "+" || p.peek().text == "-") && p.peek().kind == tokOp {
+		op := p.next().text
+		var right node
+		right, err = p.parseProduct()
+		left = &binary{op: op, left: left, right: right}
+	}
+	return left, err
+}
+
+func (p *parser) parseProduct() (node, error) {
+	left, err := p.parseUnary()
+	for err == nil && (p.peek().text == "*" || p.peek().text == "/") && p.peek().kind == tokOp {
+		op := p.next().text
+		var right node
+		right, err = p.parseUnary()
+		left = &binary{op: op, left: left, right: right}
+	}
+	return left, err
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.accept(tokOp, "-") {
+		operand, err := p.parseUnary()
+		return &binary{op: "-", left: &literal{value{kind: kindNumber, num: decimal.Zero}}, right: operand}, err
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		num, err := decimal.NewFromString(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid number %q at %d", ErrorInvalidExpression, t.text, t.pos)
+		}
+		return &literal{value{kind: kindNumber, num: num}}, nil
+	case tokString:
+		return &literal{value{kind: kindString, str: t.text}}, nil
+	case tokIdent:
+		switch t.text {
+		case "true", "false":
+			return &literal{value{kind: kindBool, b: t.text == "true"}}, nil
+		}
+		if p.accept(tokOp, "(") {
+			return p.parseCall(t)
+		}
+		return &attribute{name: t.text, pos: t.pos}, nil
+	case tokOp:
+		if t.text == "(" {
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expect(")")
+		}
+	}
+	// next does not move past the end, so only step back over a real token
+	if t.kind != tokEOF {
+		p.pos--
+	}
+	return nil, p.errorf("unexpected %q", t.text)
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	call := &call{name: name.text, pos: name.pos}
+	if !p.accept(tokOp, ")") {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if p.accept(tokOp, ")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return call, nil
+}
+
+func (p *parser) parseList() ([]value, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	var list []value
+	for !p.accept(tokOp, "]") {
+		if len(list) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		item, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		lit, ok := item.(*literal)
+		if !ok {
+			return nil, p.errorf("lists can only hold literals")
+		}
+		list = append(list, lit.value)
+	}
+	return list, nil
+}
+
+// nodes
+
+type node interface {
+	check(used map[string]bool) (kind, error)
+	eval(attrs Attributes) (value, error)
+}
+
+type literal struct {
+	value value
+}
+
+func (n *literal) check(map[string]bool) (kind, error) { return n.value.kind, nil }
+
+func (n *literal) eval(Attributes) (value, error) { return n.value, nil }
+
+type attribute struct {
+	name string
+	pos  int
+}
+
+func (n *attribute) check(used map[string]bool) (kind, error) {
+	k, ok := attributeKinds[n.name]
+	if !ok {
+		return 0, fmt.Errorf("%w: unknown attribute %q at %d", ErrorInvalidExpression, n.name, n.pos)
+	}
+	used[n.name] = true
+	return k, nil
+}
+
+func (n *attribute) eval(attrs Attributes) (value, error) {
+	return attrs.get(n.name), nil
+}
+
+type not struct {
+	operand node
+}
+
+func (n *not) check(used map[string]bool) (kind, error) {
+	k, err := n.operand.check(used)
+	if err == nil && k != kindBool {
+		err = fmt.Errorf("%w: ! needs a boolean, got %s", ErrorInvalidExpression, k)
+	}
+	return kindBool, err
+}
+
+func (n *not) eval(attrs Attributes) (value, error) {
+	v, err := n.operand.eval(attrs)
+	return value{kind: kindBool, b: !v.b}, err
+}
+
+type in struct {
+	value node
+	list  []value
+}
+
+func (n *in) check(used map[string]bool) (kind, error) {
+	k, err := n.value.check(used)
+	if err != nil {
+		return 0, err
+	}
+	for _, item := range n.list {
+		if item.kind != k {
+			return 0, fmt.Errorf("%w: list of a %s holds a %s", ErrorInvalidExpression, k, item.kind)
+		}
+	}
+	return kindBool, nil
+}
+
+func (n *in) eval(attrs Attributes) (value, error) {
+	v, err := n.value.eval(attrs)
+	if err != nil {
+		return value{}, err
+	}
+	for _, item := range n.list {
+		if v.equal(item) {
+			return value{kind: kindBool, b: true}, nil
+		}
+	}
+	return value{kind: kindBool}, nil
+}
+
+type binary struct {
+	op          string
+	left, right node
+}
+
+func (n *binary) check(used map[string]bool) (kind, error) {
+	left, err := n.left.check(used)
+	if err != nil {
+		return 0, err
+	}
+	right, err := n.right.check(used)
+	if err != nil {
+		return 0, err
+	}
+	mismatch := fmt.Errorf("%w: %s cannot combine a %s and a %s", ErrorInvalidExpression, n.op, left, right)
+
+	switch n.op {
+	case "||", "&&":
+		if left != kindBool || right != kindBool {
+			return 0, mismatch
+		}
+		return kindBool, nil
+	case "==", "!=":
+		if left != right {
+			return 0, mismatch
+		}
+		return kindBool, nil
+	case "<", "<=", ">", ">=":
+		if left != kindNumber || right != kindNumber {
+			return 0, mismatch
+		}
+		return kindBool, nil
+	}
+	if left != kindNumber || right != kindNumber {
+		return 0, mismatch
+	}
+	return kindNumber, nil
+}
+
+var ErrorDivisionByZero = errors.New("division by zero")
+
+func (n *binary) eval(attrs Attributes) (value, error) {
+	left, err := n.left.eval(attrs)
+	if err != nil {
+		return value{}, err
+	}
+
+	// short circuit
+	switch n.op {
+	case "||":
+		if left.b {
+			return left, nil
+		}
+		return n.right.eval(attrs)
+	case "&&":
+		if !left.b {
+			return left, nil
+		}
+		return n.right.eval(attrs)
+	}
+
+	right, err := n.right.eval(attrs)
+	if err != nil {
+		return value{}, err
+	}
+	boolean := func(b bool) (value, error) { return value{kind: kindBool, b: b}, nil }
+	number := func(d decimal.Decimal) (value, error) { return value{kind: kindNumber, num: d}, nil }
+
+	switch n.op {
+	case "==":
+		return boolean(left.equal(right))
+	case "!=":
+		return boolean(!left.equal(right))
+	case "<":
+		return boolean(left.num.LessThan(right.num))
+	case "<=":
+		return boolean(left.num.LessThanOrEqual(right.num))
+	case ">":
+		return boolean(left.num.GreaterThan(right.num))
+	case ">=":
+		return boolean(left.num.GreaterThanOrEqual(right.num))
+	case "+":
+		return number(left.num.Add(right.num))
+	case "-":
+		return number(left.num.Sub(right.num))
+	case "*":
+		return number(left.num.Mul(right.num))
+	}
+	if right.num.IsZero() {
+		return value{}, ErrorDivisionByZero
+	}
+	return number(left.num.Div(right.num))
+}
+
+type call struct {
+	name string
+	pos  int
+	args []node
+}
+
+// functions maps the function names to their argument kinds.
+var functions = map[string][]kind{
+	"starts_with": {kindString, kindString},
+	"ends_with":   {kindString, kindString},
+	"lower":       {kindString},
+}
+
+func (n *call) check(used map[string]bool) (kind, error) {
+	params, ok := functions[n.name]
+	if !ok {
+		return 0, fmt.Errorf("%w: unknown function %q at %d", ErrorInvalidExpression, n.name, n.pos)
+	}
+	if len(params) != len(n.args) {
+		return 0, fmt.Errorf("%w: %s takes %d arguments", ErrorInvalidExpression, n.name, len(params))
+	}
+	for i, arg := range n.args {
+		k, err := arg.check(used)
+		if err != nil {
+			return 0, err
+		}
+		if k != params[i] {
+			return 0, fmt.Errorf("%w: argument %d of %s must be a %s", ErrorInvalidExpression, i+1, n.name, params[i])
+		}
+	}
+	if n.name == "lower" {
+		return kindString, nil
+	}
+	return kindBool, nil
+}
+
+func (n *call) eval(attrs Attributes) (value, error) {
+	args := make([]value, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(attrs)
+		if err != nil {
+			return value{}, err
+		}
+		args[i] = v
+	}
+	switch n.name {
+	case "starts_with":
+		return value{kind: kindBool, b: strings.HasPrefix(args[0].str, args[1].str)}, nil
+	case "ends_with":
+		return value{kind: kindBool, b: strings.HasSuffix(args[0].str, args[1].str)}, nil
+	}
+	return value{kind: kindString, str: strings.ToLower(args[0].str)}, nil
+}
@@ -0,0 +1,601 @@
+// Package policy evaluates operator defined rules on transfers. Rules are
+// expressions over the attributes of a transfer that allow, deny or flag it;
+// they are stored in the database with every version kept, and read inside
+// each transfer's transaction, so changes apply to the next transfer.
+package policy
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"btp_tokens/internal/ledger"
+	"btp_tokens/internal/limits"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	ActionAllow = "allow"
+	ActionDeny  = "deny"
+	ActionFlag  = "flag"
+)
+
+// attributeKinds are the attributes rules can read.
+var attributeKinds = map[string]kind{
+	"from":    kindString,
+	"to":      kindString,
+	"token":   kindString,
+	"amount":  kindNumber,
+	"hour":    kindNumber,
+	"weekday": kindNumber,
+	// limit tiers of the sender and the receiver
+	"tier":    kindString,
+	"to_tier": kindString,
+	// what the sender sent over the last 24 hours and 30 days
+	"sent_24h": kindNumber,
+	"sent_30d": kindNumber,
+	// earlier transfers from the sender to the receiver
+	"counterpart_transfers": kindNumber,
+	"counterpart_volume":    kindNumber,
+}
+
+var attributeNames = []string{
+	"from", "to", "token", "amount", "hour", "weekday", "tier", "to_tier",
+	"sent_24h", "sent_30d", "counterpart_transfers", "counterpart_volume",
+}
+
+// Transfer is what rules are evaluated on.
+type Transfer struct {
+	Token       string
+	FromAddress string
+	ToAddress   string
+	Amount      decimal.Decimal
+	Time        time.Time
+}
+
+// Attributes are the values of a transfer's attributes, only those read by
+// the rules are loaded.
+type Attributes struct {
+	values map[string]value
+}
+
+func (a Attributes) get(name string) value {
+	return a.values[name]
+}
+
+// Map returns the loaded attributes as strings.
+func (a Attributes) Map() map[string]string {
+	m := make(map[string]string, len(a.values))
+	for name, v := range a.values {
+		m[name] = v.String()
+	}
+	return m
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type sentKey struct {
+	address string
+	token   string
+}
+
+type counterpartKey struct {
+	from  string
+	to    string
+	token string
+}
+
+type counterpart struct {
+	transfers int64
+	volume    decimal.Decimal
+}
+
+// Pending keeps the transfers made earlier in the same transaction, which
+// are not in the journal yet when the next one is checked. The zero value
+// is empty.
+type Pending struct {
+	sent         map[sentKey]decimal.Decimal
+	counterparts map[counterpartKey]counterpart
+}
+
+// Add counts t once it is applied.
+func (p *Pending) Add(t Transfer) {
+	if p.sent == nil {
+		p.sent = make(map[sentKey]decimal.Decimal)
+		p.counterparts = make(map[counterpartKey]counterpart)
+	}
+	sent := sentKey{t.FromAddress, t.Token}
+	p.sent[sent] = p.sent[sent].Add(t.Amount)
+	key := counterpartKey{t.FromAddress, t.ToAddress, t.Token}
+	c := p.counterparts[key]
+	p.counterparts[key] = counterpart{transfers: c.transfers + 1, volume: c.volume.Add(t.Amount)}
+}
+
+// LoadAttributes computes the attributes of t in needed, counting the
+// transfers in pending (which may be nil) as sent already.
+func LoadAttributes(ctx context.Context, q queryer, t Transfer, pending *Pending, needed []string) (Attributes, error) {
+	if pending == nil {
+		pending = &Pending{}
+	}
+	at := t.Time.UTC()
+	attrs := Attributes{values: map[string]value{
+		"from":    {kind: kindString, str: t.FromAddress},
+		"to":      {kind: kindString, str: t.ToAddress},
+		"token":   {kind: kindString, str: t.Token},
+		"amount":  {kind: kindNumber, num: t.Amount},
+		"hour":    {kind: kindNumber, num: decimal.NewFromInt(int64(at.Hour()))},
+		"weekday": {kind: kindNumber, num: decimal.NewFromInt(int64(at.Weekday()))},
+	}}
+
+	need := make(map[string]bool, len(needed))
+	for _, name := range needed {
+		need[name] = true
+	}
+
+	if need["tier"] || need["to_tier"] {
+		tiers := map[string]string{t.FromAddress: limits.DefaultTier, t.ToAddress: limits.DefaultTier}
+		rows, err := q.QueryContext(ctx, "SELECT Address, Tier FROM Address_Tiers WHERE Address = ANY($1)", pq.Array([]string{t.FromAddress, t.ToAddress}))
+		if err != nil {
+			return Attributes{}, err
+		}
+		for rows.Next() {
+			var address, tier string
+			if err := rows.Scan(&address, &tier); err != nil {
+				rows.Close()
+				return Attributes{}, err
+			}
+			tiers[address] = tier
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return Attributes{}, err
+		}
+		attrs.values["tier"] = value{kind: kindString, str: tiers[t.FromAddress]}
+		attrs.values["to_tier"] = value{kind: kindString, str: tiers[t.ToAddress]}
+	}
+
+	if need["sent_24h"] || need["sent_30d"] {
+		daily, monthly, err := limits.Sent(ctx, q, t.FromAddress, t.Token)
+		if err != nil {
+			return Attributes{}, err
+		}
+		sent := pending.sent[sentKey{t.FromAddress, t.Token}]
+		attrs.values["sent_24h"] = value{kind: kindNumber, num: daily.Add(sent)}
+		attrs.values["sent_30d"] = value{kind: kindNumber, num: monthly.Add(sent)}
+	}
+
+	if need["counterpart_transfers"] || need["counterpart_volume"] {
+		var count int64
+		var volume decimal.Decimal
+		err := q.QueryRowContext(ctx, `
+			SELECT COUNT(*), COALESCE(SUM(Amount), 0) FROM Journal_Entries
+			WHERE Kind = $1 AND From_Address = $2 AND To_Address = $3 AND Token = $4
+		`, ledger.KindTransfer, t.FromAddress, t.ToAddress, t.Token).Scan(&count, &volume)
+		if err != nil {
+			return Attributes{}, err
+		}
+		c := pending.counterparts[counterpartKey{t.FromAddress, t.ToAddress, t.Token}]
+		attrs.values["counterpart_transfers"] = value{kind: kindNumber, num: decimal.NewFromInt(count + c.transfers)}
+		attrs.values["counterpart_volume"] = value{kind: kindNumber, num: volume.Add(c.volume)}
+	}
+
+	return attrs, nil
+}
+
+// Rule is one version of a named rule.
+type Rule struct {
+	ID         int64
+	Name       string
+	Version    int
+	Expression string
+	Action     string
+	// rules are evaluated by ascending priority
+	Priority  int
+	Message   string
+	Enabled   bool
+	Operator  string
+	CreatedAt time.Time
+
+	expr *Expr
+}
+
+func (r *Rule) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return ErrorInvalidRule
+	}
+	switch r.Action {
+	case ActionAllow, ActionDeny, ActionFlag:
+	default:
+		return ErrorInvalidRule
+	}
+	if strings.TrimSpace(r.Expression) == "" {
+		return fmt.Errorf("%w: expression is empty", ErrorInvalidExpression)
+	}
+	return r.compile()
+}
+
+// compiled caches the expressions of stored rule versions by id, versions
+// never change once written.
+var compiled sync.Map
+
+func (r *Rule) compile() error {
+	if r.expr != nil {
+		return nil
+	}
+	if r.ID != 0 {
+		if expr, ok := compiled.Load(r.ID); ok {
+			r.expr = expr.(*Expr)
+			return nil
+		}
+	}
+	expr, err := Compile(r.Expression)
+	if err != nil {
+		return err
+	}
+	r.expr = expr
+	if r.ID != 0 {
+		compiled.Store(r.ID, expr)
+	}
+	return nil
+}
+
+// Result is the outcome of one rule.
+type Result struct {
+	Rule    *Rule
+	Matched bool
+	Err     error
+}
+
+// Decision is the outcome of a set of rules on a transfer.
+type Decision struct {
+	// Action is allow or deny
+	Action string
+	// Rule decided, it is nil when no allow or deny rule matched
+	Rule       *Rule
+	Flags      []*Rule
+	Results    []Result
+	Attributes Attributes
+}
+
+// DeniedError is returned for transfers a deny rule matched.
+type DeniedError struct {
+	Rule    string
+	Version int
+	Message string
+}
+
+func (e *DeniedError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("transfer denied by policy %s: %s", e.Rule, e.Message)
+	}
+	return fmt.Sprintf("transfer denied by policy %s", e.Rule)
+}
+
+func (e *DeniedError) Is(target error) bool {
+	return target == ErrorPolicyDenied
+}
+
+type PolicyService struct {
+	DB *sql.DB
+}
+
+var ErrorPolicyDenied = errors.New("transfer denied by policy")
+var ErrorPolicyFailed = errors.New("policy evaluation failed")
+var ErrorInvalidRule = errors.New("invalid policy rule")
+var ErrorRuleNotFound = errors.New("policy rule not found")
+
+const ruleColumns = "Id, Name, Version, Expression, Action, Priority, COALESCE(Message, ''), Enabled, Operator, Created_At"
+
+func scanRules(rows *sql.Rows) ([]*Rule, error) {
+	defer rows.Close()
+	var rules []*Rule
+	for rows.Next() {
+		r := &Rule{}
+		if err := rows.Scan(&r.ID, &r.Name, &r.Version, &r.Expression, &r.Action, &r.Priority, &r.Message, &r.Enabled, &r.Operator, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// Active returns the latest version of every enabled rule in evaluation
+// order, compiled.
+func Active(ctx context.Context, q queryer) ([]*Rule, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT `+ruleColumns+` FROM (
+			SELECT DISTINCT ON (Name) * FROM Policy_Rules ORDER BY Name, Version DESC
+		) latest
+		WHERE Enabled
+		ORDER BY Priority ASC, Name ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := scanRules(rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rules {
+		if err := r.compile(); err != nil {
+			return nil, fmt.Errorf("%w: rule %s: %v", ErrorPolicyFailed, r.Name, err)
+		}
+	}
+	return rules, nil
+}
+
+func neededAttributes(rules []*Rule) []string {
+	var needed []string
+	seen := make(map[string]bool)
+	for _, r := range rules {
+		for _, attribute := range r.expr.Attributes {
+			if !seen[attribute] {
+				seen[attribute] = true
+				needed = append(needed, attribute)
+			}
+		}
+	}
+	return needed
+}
+
+// evaluate runs rules in order until an allow or deny rule matches, or all
+// of them when every is set. A rule failing to evaluate denies the transfer.
+func evaluate(rules []*Rule, attrs Attributes, every bool) Decision {
+	decision := Decision{Action: ActionAllow, Attributes: attrs}
+	decided := false
+	for _, r := range rules {
+		matched, err := r.expr.Eval(attrs)
+		decision.Results = append(decision.Results, Result{Rule: r, Matched: matched, Err: err})
+		if decided {
+			continue
+		}
+		switch {
+		case err != nil:
+			decision.Action, decision.Rule, decided = ActionDeny, r, true
+		case !matched:
+		case r.Action == ActionFlag:
+			decision.Flags = append(decision.Flags, r)
+		default:
+			decision.Action, decision.Rule, decided = r.Action, r, true
+		}
+		if decided && !every {
+			break
+		}
+	}
+	return decision
+}
+
+// Check evaluates rules on t inside a transfer and returns the flag rules
+// that matched. Denied transfers fail with a *DeniedError, rules that cannot
+// be evaluated with ErrorPolicyFailed. pending holds the earlier transfers
+// of the transaction.
+func Check(ctx context.Context, q queryer, rules []*Rule, t Transfer, pending *Pending) ([]*Rule, error) {
+	attrs, err := LoadAttributes(ctx, q, t, pending, neededAttributes(rules))
+	if err != nil {
+		return nil, err
+	}
+
+	decision := evaluate(rules, attrs, false)
+	if decision.Action == ActionDeny {
+		last := decision.Results[len(decision.Results)-1]
+		if last.Err != nil {
+			return nil, fmt.Errorf("%w: rule %s: %v", ErrorPolicyFailed, last.Rule.Name, last.Err)
+		}
+		return nil, &DeniedError{Rule: decision.Rule.Name, Version: decision.Rule.Version, Message: decision.Rule.Message}
+	}
+	return decision.Flags, nil
+}
+
+// RecordFlags links the flag rules that matched a transfer to its entry.
+func RecordFlags(ctx context.Context, tx *sql.Tx, entryID int64, flags []*Rule) error {
+	for _, r := range flags {
+		_, err := tx.ExecContext(ctx, "INSERT INTO Policy_Flags (Entry_Id, Rule_Id) VALUES ($1, $2)", entryID, r.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ruleLockClass is the first key of the per name advisory locks that
+// number the versions of a rule one save at a time.
+const ruleLockClass = 7_028_004
+
+// SaveRule stores a new version of the rule named r.Name, the first one
+// creates it.
+func (s *PolicyService) SaveRule(ctx context.Context, r Rule, operator string) (Rule, error) {
+	r.ID = 0
+	if err := r.Validate(); err != nil {
+		return Rule{}, err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Rule{}, err
+	}
+	defer tx.Rollback()
+
+	if err = lockRule(ctx, tx, r.Name); err != nil {
+		return Rule{}, err
+	}
+	saved, err := insertRule(ctx, tx, r, operator)
+	if err != nil {
+		return Rule{}, err
+	}
+	return saved, tx.Commit()
+}
+
+// DisableRule stores a disabled version of the latest version of name.
+func (s *PolicyService) DisableRule(ctx context.Context, name string, operator string) (Rule, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Rule{}, err
+	}
+	defer tx.Rollback()
+
+	if err = lockRule(ctx, tx, name); err != nil {
+		return Rule{}, err
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT "+ruleColumns+" FROM Policy_Rules WHERE Name = $1 ORDER BY Version DESC LIMIT 1", name)
+	if err != nil {
+		return Rule{}, err
+	}
+	latest, err := scanRules(rows)
+	if err != nil {
+		return Rule{}, err
+	}
+	if len(latest) == 0 {
+		return Rule{}, ErrorRuleNotFound
+	}
+	latest[0].Enabled = false
+	saved, err := insertRule(ctx, tx, *latest[0], operator)
+	if err != nil {
+		return Rule{}, err
+	}
+	return saved, tx.Commit()
+}
+
+// lockRule takes the advisory lock of the rule name for the rest of tx, so
+// concurrent saves do not compute the same next version.
+func lockRule(ctx context.Context, tx *sql.Tx, name string) error {
+	_, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1, hashtext($2))", ruleLockClass, name)
+	return err
+}
+
+func insertRule(ctx context.Context, tx *sql.Tx, r Rule, operator string) (Rule, error) {
+	rows, err := tx.QueryContext(ctx, `
+		INSERT INTO Policy_Rules (Name, Version, Expression, Action, Priority, Message, Enabled, Operator)
+		SELECT $1, COALESCE(MAX(Version), 0) + 1, $2, $3, $4, NULLIF($5, ''), $6, $7 FROM Policy_Rules WHERE Name = $1
+		RETURNING `+ruleColumns,
+		r.Name, r.Expression, r.Action, r.Priority, r.Message, r.Enabled, operator)
+	if err != nil {
+		return Rule{}, err
+	}
+	saved, err := scanRules(rows)
+	if err != nil {
+		return Rule{}, err
+	}
+	return *saved[0], nil
+}
+
+// Rules returns the latest version of every rule, disabled ones included.
+func (s *PolicyService) Rules(ctx context.Context) ([]Rule, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+ruleColumns+` FROM (
+			SELECT DISTINCT ON (Name) * FROM Policy_Rules ORDER BY Name, Version DESC
+		) latest
+		ORDER BY Priority ASC, Name ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return values(scanRules(rows))
+}
+
+// RuleVersions returns every version of the rule name, oldest first.
+func (s *PolicyService) RuleVersions(ctx context.Context, name string) ([]Rule, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT "+ruleColumns+" FROM Policy_Rules WHERE Name = $1 ORDER BY Version ASC", name)
+	if err != nil {
+		return nil, err
+	}
+	return values(scanRules(rows))
+}
+
+func values(rules []*Rule, err error) ([]Rule, error) {
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Rule, 0, len(rules))
+	for _, r := range rules {
+		list = append(list, *r)
+	}
+	return list, nil
+}
+
+// Evaluate is a dry run of the active rules on t. Candidates replace the
+// active rules of the same name, or are added, so rules can be tried before
+// they are saved. Every rule is evaluated, the decision is the one a
+// transfer would get.
+func (s *PolicyService) Evaluate(ctx context.Context, t Transfer, candidates []Rule) (Decision, error) {
+	active, err := Active(ctx, s.DB)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	replaced := make(map[string]bool)
+	var rules []*Rule
+	for i := range candidates {
+		candidate := candidates[i]
+		candidate.ID = 0
+		if err := candidate.Validate(); err != nil {
+			return Decision{}, fmt.Errorf("rule %s: %w", candidate.Name, err)
+		}
+		replaced[candidate.Name] = true
+		if candidate.Enabled {
+			rules = append(rules, &candidate)
+		}
+	}
+	for _, r := range active {
+		if !replaced[r.Name] {
+			rules = append(rules, r)
+		}
+	}
+	sortRules(rules)
+
+	attrs, err := LoadAttributes(ctx, s.DB, t, nil, neededAttributes(rules))
+	if err != nil {
+		return Decision{}, err
+	}
+	return evaluate(rules, attrs, true), nil
+}
+
+func sortRules(rules []*Rule) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Priority != rules[j].Priority {
+			return rules[i].Priority < rules[j].Priority
+		}
+		return rules[i].Name < rules[j].Name
+	})
+}
+
+// Flag is a transfer a flag rule matched.
+type Flag struct {
+	EntryID   int64
+	Rule      Rule
+	CreatedAt time.Time
+}
+
+// Flags returns the latest flagged transfers, newest first.
+func (s *PolicyService) Flags(ctx context.Context, limit int) ([]Flag, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT f.Entry_Id, f.Created_At, r.Id, r.Name, r.Version, r.Expression, r.Action, r.Priority, COALESCE(r.Message, ''), r.Enabled, r.Operator, r.Created_At
+		FROM Policy_Flags f JOIN Policy_Rules r ON r.Id = f.Rule_Id
+		ORDER BY f.Id DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var flags []Flag
+	for rows.Next() {
+		var f Flag
+		r := &f.Rule
+		if err := rows.Scan(&f.EntryID, &f.CreatedAt, &r.ID, &r.Name, &r.Version, &r.Expression, &r.Action, &r.Priority, &r.Message, &r.Enabled, &r.Operator, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		flags = append(flags, f)
+	}
+	return flags, rows.Err()
+}
@@ -0,0 +1,337 @@
+package snapshots
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"btp_tokens/internal/merkle"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// Snapshot holds the balances of all wallets at one moment, with one Merkle
+// root per token.
+type Snapshot struct {
+	ID int64
+	// LastEntryID is the last journal entry reflected in the balances
+	LastEntryID int64
+	CreatedAt   time.Time
+	Roots       []Root
+}
+
+type Root struct {
+	Token       string
+	MerkleRoot  string
+	WalletCount int
+}
+
+type BalanceProof struct {
+	SnapshotID int64
+	Address    string
+	Token      string
+	Balance    decimal.Decimal
+	Root       string
+	Proof      []merkle.ProofStep
+}
+
+type SnapshotsService struct {
+	DB *sql.DB
+}
+
+var ErrorSnapshotNotFound = errors.New("snapshot not found")
+var ErrorNoWallets = errors.New("there are no wallets to snapshot")
+var ErrorSnapshotCorrupted = errors.New("snapshot balances do not match its merkle root")
+
+func (s Snapshot) Root(token string) (Root, bool) {
+	for _, root := range s.Roots {
+		if root.Token == token {
+			return root, true
+		}
+	}
+	return Root{}, false
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// loadLeaves groups (address, balance) pairs by token.
+func loadLeaves(ctx context.Context, q queryer, query string, args ...any) (map[string][]merkle.Leaf, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leaves := make(map[string][]merkle.Leaf)
+	for rows.Next() {
+		var token string
+		var leaf merkle.Leaf
+		if err := rows.Scan(&token, &leaf.Address, &leaf.Amount); err != nil {
+			return nil, err
+		}
+		leaves[token] = append(leaves[token], leaf)
+	}
+	return leaves, rows.Err()
+}
+
+// Create copies the current balances of all wallets into a new snapshot and
+// stores the Merkle root over them for every token.
+func (s *SnapshotsService) Create(ctx context.Context) (Snapshot, error) {
+	// repeatable read gives one consistent view of balances and the journal
+	tx, err := s.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer tx.Rollback()
+
+	leaves, err := loadLeaves(ctx, tx, "SELECT Token, Address, Balance FROM Wallets")
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	if len(leaves) == 0 {
+		return Snapshot{}, ErrorNoWallets
+	}
+
+	var snapshot Snapshot
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO Snapshots (Last_Entry_Id)
+		VALUES ((SELECT COALESCE(MAX(Id), 0) FROM Journal_Entries))
+		RETURNING Id, Last_Entry_Id, Created_At
+	`).Scan(&snapshot.ID, &snapshot.LastEntryID, &snapshot.CreatedAt)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	symbols := make([]string, 0, len(leaves))
+	for token := range leaves {
+		symbols = append(symbols, token)
+	}
+	sort.Strings(symbols)
+
+	for _, token := range symbols {
+		tree, err := merkle.NewTree(leaves[token])
+		if err != nil {
+			return Snapshot{}, err
+		}
+
+		root := Root{Token: token, MerkleRoot: tree.Root(), WalletCount: tree.Len()}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO Snapshot_Roots (Snapshot_Id, Token, Merkle_Root, Wallet_Count) VALUES ($1, $2, $3, $4)
+		`, snapshot.ID, root.Token, root.MerkleRoot, root.WalletCount)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		snapshot.Roots = append(snapshot.Roots, root)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO Snapshot_Balances (Snapshot_Id, Token, Address, Balance)
+		SELECT $1, Token, Address, Balance FROM Wallets
+	`, snapshot.ID)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	return snapshot, tx.Commit()
+}
+
+func (s *SnapshotsService) loadRoots(ctx context.Context, snapshots []*Snapshot) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*Snapshot, len(snapshots))
+	ids := make([]int64, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		byID[snapshot.ID] = snapshot
+		ids = append(ids, snapshot.ID)
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT Snapshot_Id, Token, Merkle_Root, Wallet_Count FROM Snapshot_Roots
+		WHERE Snapshot_Id = ANY($1) ORDER BY Snapshot_Id ASC, Token ASC
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var root Root
+		if err := rows.Scan(&id, &root.Token, &root.MerkleRoot, &root.WalletCount); err != nil {
+			return err
+		}
+		byID[id].Roots = append(byID[id].Roots, root)
+	}
+	return rows.Err()
+}
+
+func (s *SnapshotsService) Get(ctx context.Context, id int64) (Snapshot, error) {
+	var snapshot Snapshot
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT Id, Last_Entry_Id, Created_At FROM Snapshots WHERE Id = $1
+	`, id).Scan(&snapshot.ID, &snapshot.LastEntryID, &snapshot.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Snapshot{}, ErrorSnapshotNotFound
+	}
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return snapshot, s.loadRoots(ctx, []*Snapshot{&snapshot})
+}
+
+func (s *SnapshotsService) List(ctx context.Context) ([]Snapshot, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT Id, Last_Entry_Id, Created_At FROM Snapshots ORDER BY Id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []Snapshot
+	for rows.Next() {
+		var snapshot Snapshot
+		if err := rows.Scan(&snapshot.ID, &snapshot.LastEntryID, &snapshot.CreatedAt); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	pointers := make([]*Snapshot, len(snapshots))
+	for i := range snapshots {
+		pointers[i] = &snapshots[i]
+	}
+	return snapshots, s.loadRoots(ctx, pointers)
+}
+
+// Proof rebuilds the snapshot's tree of token and returns the inclusion
+// proof of address.
+func (s *SnapshotsService) Proof(ctx context.Context, address string, token string, snapshotID int64) (BalanceProof, error) {
+	snapshot, err := s.Get(ctx, snapshotID)
+	if err != nil {
+		return BalanceProof{}, err
+	}
+
+	root, ok := snapshot.Root(token)
+	if !ok {
+		return BalanceProof{}, merkle.ErrorLeafNotFound
+	}
+
+	leaves, err := loadLeaves(ctx, s.DB, `
+		SELECT Token, Address, Balance FROM Snapshot_Balances WHERE Snapshot_Id = $1 AND Token = $2
+	`, snapshotID, token)
+	if err != nil {
+		return BalanceProof{}, err
+	}
+
+	tree, err := merkle.NewTree(leaves[token])
+	if err != nil {
+		return BalanceProof{}, err
+	}
+
+	if tree.Root() != root.MerkleRoot {
+		return BalanceProof{}, ErrorSnapshotCorrupted
+	}
+
+	leaf, proof, err := tree.Proof(address)
+	if err != nil {
+		return BalanceProof{}, err
+	}
+
+	return BalanceProof{
+		SnapshotID: snapshot.ID,
+		Address:    leaf.Address,
+		Token:      token,
+		Balance:    leaf.Amount,
+		Root:       root.MerkleRoot,
+		Proof:      proof,
+	}, nil
+}
+
+// BalanceAtSnapshot returns the balance of token address had when the
+// snapshot was taken, zero when the wallet did not exist yet.
+func (s *SnapshotsService) BalanceAtSnapshot(ctx context.Context, address string, token string, snapshotID int64) (decimal.Decimal, error) {
+	if _, err := s.Get(ctx, snapshotID); err != nil {
+		return decimal.Decimal{}, err
+	}
+
+	var balance decimal.Decimal
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT Balance FROM Snapshot_Balances WHERE Snapshot_Id = $1 AND Address = $2 AND Token = $3
+	`, snapshotID, address, token).Scan(&balance)
+	if errors.Is(err, sql.ErrNoRows) {
+		return decimal.Zero, nil
+	}
+	return balance, err
+}
+
+// BalanceAt returns the balance of token address had at the given moment:
+// the balance from the latest snapshot taken at or before it, plus the
+// postings of the journal entries after that snapshot up to the moment.
+func (s *SnapshotsService) BalanceAt(ctx context.Context, address string, token string, at time.Time) (decimal.Decimal, error) {
+	tx, err := s.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	defer tx.Rollback()
+
+	var snapshotID, lastEntryID int64
+	err = tx.QueryRowContext(ctx, `
+		SELECT Id, Last_Entry_Id FROM Snapshots WHERE Created_At <= $1 ORDER BY Created_At DESC, Id DESC LIMIT 1
+	`, at).Scan(&snapshotID, &lastEntryID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return decimal.Decimal{}, err
+	}
+
+	base := decimal.Zero
+	if snapshotID != 0 {
+		err = tx.QueryRowContext(ctx, `
+			SELECT Balance FROM Snapshot_Balances WHERE Snapshot_Id = $1 AND Address = $2 AND Token = $3
+		`, snapshotID, address, token).Scan(&base)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return decimal.Decimal{}, err
+		}
+	}
+
+	var change decimal.Decimal
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(p.Credit) - SUM(p.Debit), 0)
+		FROM Postings p
+		JOIN Journal_Entries e ON e.Id = p.Entry_Id
+		WHERE p.Account = $1 AND e.Token = $2 AND e.Id > $3 AND e.Created_At <= $4
+	`, address, token, lastEntryID, at).Scan(&change)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+
+	return base.Add(change), nil
+}
+
+// RunPeriodic takes a snapshot every interval until ctx is done.
+func (s *SnapshotsService) RunPeriodic(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Create(ctx); err != nil && !errors.Is(err, ErrorNoWallets) {
+				log.Println("snapshot fail:", err)
+			}
+		}
+	}
+}
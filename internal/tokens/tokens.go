@@ -0,0 +1,247 @@
+package tokens
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// DefaultSymbol is the token used when a request does not name one.
+const DefaultSymbol = "BTP"
+
+const MaxDecimals = 36
+
+type Token struct {
+	Symbol    string
+	Name      string
+	Decimals  int32
+	SupplyCap *decimal.Decimal
+	Issuer    string
+	CreatedAt time.Time
+}
+
+type TokensService struct {
+	DB *sql.DB
+}
+
+var ErrorTokenNotFound = errors.New("token not found")
+var ErrorTokenExists = errors.New("token already exists")
+var ErrorInvalidSymbol = errors.New("token symbol must be 2-12 upper case letters or digits")
+var ErrorInvalidDecimals = errors.New("token decimals must be between 0 and 36")
+var ErrorInvalidSupplyCap = errors.New("token supply cap must be positive and fit the token decimals")
+var ErrorSupplyCapExceeded = errors.New("token supply cap exceeded")
+var ErrorAmountPrecision = errors.New("amount has more decimal places than the token allows")
+var ErrorInvalidBaseUnits = errors.New("base unit amount must be an integer")
+var ErrorDecimalsTooSmall = errors.New("existing balances or amounts have more decimal places than requested")
+
+var symbolPattern = regexp.MustCompile(`^[A-Z0-9]{2,12}$`)
+
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+const tokenColumns = "Symbol, Name, Decimals, Supply_Cap, COALESCE(Issuer, ''), Created_At"
+
+func scanToken(row interface{ Scan(...any) error }) (Token, error) {
+	var t Token
+	var supplyCap decimal.NullDecimal
+	if err := row.Scan(&t.Symbol, &t.Name, &t.Decimals, &supplyCap, &t.Issuer, &t.CreatedAt); err != nil {
+		return Token{}, err
+	}
+	if supplyCap.Valid {
+		t.SupplyCap = &supplyCap.Decimal
+	}
+	return t, nil
+}
+
+// CheckPrecision rejects amounts with more decimal places than the token has.
+func (t Token) CheckPrecision(amount decimal.Decimal) error {
+	if !amount.Equal(amount.Truncate(t.Decimals)) {
+		return ErrorAmountPrecision
+	}
+	return nil
+}
+
+// FromBaseUnits converts an integer amount of the token's smallest unit
+// (e.g. wei for 18 decimals) to a token amount.
+func (t Token) FromBaseUnits(units decimal.Decimal) (decimal.Decimal, error) {
+	if !units.Equal(units.Truncate(0)) {
+		return decimal.Decimal{}, ErrorInvalidBaseUnits
+	}
+	return units.Shift(-t.Decimals), nil
+}
+
+func (t Token) ToBaseUnits(amount decimal.Decimal) decimal.Decimal {
+	return amount.Shift(t.Decimals)
+}
+
+func (s *TokensService) Register(ctx context.Context, token Token) (Token, error) {
+	if !symbolPattern.MatchString(token.Symbol) {
+		return Token{}, ErrorInvalidSymbol
+	}
+	if token.Decimals < 0 || token.Decimals > MaxDecimals {
+		return Token{}, ErrorInvalidDecimals
+	}
+	if token.SupplyCap != nil && (!token.SupplyCap.IsPositive() || token.CheckPrecision(*token.SupplyCap) != nil) {
+		return Token{}, ErrorInvalidSupplyCap
+	}
+
+	row := s.DB.QueryRowContext(ctx, `
+		INSERT INTO Tokens (Symbol, Name, Decimals, Supply_Cap, Issuer)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+		RETURNING `+tokenColumns,
+		token.Symbol, token.Name, token.Decimals, decimal.NullDecimal{Decimal: derefDecimal(token.SupplyCap), Valid: token.SupplyCap != nil}, token.Issuer)
+
+	registered, err := scanToken(row)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return Token{}, ErrorTokenExists
+	}
+	return registered, err
+}
+
+// storedAmounts are the tables keeping amounts of a token that are paid,
+// compared or charged later, with the column naming the token and the
+// amount columns. Lowering the decimals must not leave any of them finer.
+var storedAmounts = []struct {
+	from    string
+	token   string
+	columns []string
+}{
+	{"Wallets", "Token", []string{"Balance", "Held", "Staked"}},
+	{"Holds", "Token", []string{"Amount", "Captured_Amount"}},
+	{"Fee_Policies", "Token", []string{"Flat", "Min_Fee", "Max_Fee"}},
+	{"Fee_Tiers", "Token", []string{"From_Amount", "Flat"}},
+	{"Tier_Limits", "Token", []string{"Per_Transfer", "Daily", "Monthly"}},
+	{"Escrows", "Token", []string{"Amount"}},
+	{"Htlcs", "Token", []string{"Amount"}},
+	{"Scheduled_Transfers", "Token", []string{"Amount"}},
+	{"Multisig_Proposals", "Token", []string{"Amount"}},
+	{"Vesting_Grants", "Token", []string{"Total", "Claimed", "Returned"}},
+	{"Stakes", "Token", []string{"Amount", "Rewards", "Claimed_Rewards"}},
+	{"Distributions", "Token", []string{"Total", "Returned"}},
+	{"Distribution_Recipients r JOIN Distributions d ON d.Id = r.Distribution_Id", "d.Token", []string{"r.Amount"}},
+	{"Airdrops", "Token", []string{"Total", "Claimed", "Swept"}},
+	{"Airdrop_Leaves l JOIN Airdrops a ON a.Id = l.Airdrop_Id", "a.Token", []string{"l.Amount"}},
+	{"Payment_Requests", "Token", []string{"Amount", "Paid_Amount"}},
+}
+
+// SetDecimals changes the precision of a token. Lowering it is only allowed
+// while neither the supply cap nor any stored amount of the token would
+// lose digits. Transfers read the token under a share lock, so none
+// validated against the old decimals commits after the change.
+func (s *TokensService) SetDecimals(ctx context.Context, symbol string, decimals int32) (Token, error) {
+	if decimals < 0 || decimals > MaxDecimals {
+		return Token{}, ErrorInvalidDecimals
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Token{}, err
+	}
+	defer tx.Rollback()
+
+	token, err := scanToken(tx.QueryRowContext(ctx, "SELECT "+tokenColumns+" FROM Tokens WHERE Symbol = $1 FOR UPDATE", symbol))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Token{}, ErrorTokenNotFound
+	}
+	if err != nil {
+		return Token{}, err
+	}
+
+	if decimals < token.Decimals {
+		if token.SupplyCap != nil && !token.SupplyCap.Equal(token.SupplyCap.Truncate(decimals)) {
+			return Token{}, ErrorDecimalsTooSmall
+		}
+
+		for _, stored := range storedAmounts {
+			finer := make([]string, len(stored.columns))
+			for i, column := range stored.columns {
+				finer[i] = column + " <> trunc(" + column + ", $2)"
+			}
+			var tooPrecise bool
+			err = tx.QueryRowContext(ctx, `
+				SELECT EXISTS (SELECT 1 FROM `+stored.from+` WHERE `+stored.token+` = $1 AND (`+strings.Join(finer, " OR ")+`))
+			`, symbol, decimals).Scan(&tooPrecise)
+			if err != nil {
+				return Token{}, err
+			}
+			if tooPrecise {
+				return Token{}, ErrorDecimalsTooSmall
+			}
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE Tokens SET Decimals = $2 WHERE Symbol = $1", symbol, decimals); err != nil {
+		return Token{}, err
+	}
+	token.Decimals = decimals
+
+	return token, tx.Commit()
+}
+
+func derefDecimal(d *decimal.Decimal) decimal.Decimal {
+	if d == nil {
+		return decimal.Zero
+	}
+	return *d
+}
+
+func (s *TokensService) Get(ctx context.Context, symbol string) (Token, error) {
+	return Get(ctx, s.DB, symbol)
+}
+
+// GetShared is Get under a share lock on the token, held until the
+// transaction ends, for writes that rely on its decimals.
+func GetShared(ctx context.Context, q queryer, symbol string) (Token, error) {
+	token, err := scanToken(q.QueryRowContext(ctx, "SELECT "+tokenColumns+" FROM Tokens WHERE Symbol = $1 FOR SHARE", symbol))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Token{}, ErrorTokenNotFound
+	}
+	return token, err
+}
+
+// Get loads a token with any queryer, so it can be used inside a transaction.
+func Get(ctx context.Context, q queryer, symbol string) (Token, error) {
+	token, err := scanToken(q.QueryRowContext(ctx, "SELECT "+tokenColumns+" FROM Tokens WHERE Symbol = $1", symbol))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Token{}, ErrorTokenNotFound
+	}
+	return token, err
+}
+
+func (s *TokensService) List(ctx context.Context) ([]Token, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT "+tokenColumns+" FROM Tokens ORDER BY Symbol ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Token
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, token)
+	}
+	return list, rows.Err()
+}
+
+// TotalSupply is the sum of all balances held in the token.
+func (s *TokensService) TotalSupply(ctx context.Context, symbol string) (decimal.Decimal, error) {
+	return TotalSupply(ctx, s.DB, symbol)
+}
+
+func TotalSupply(ctx context.Context, q queryer, symbol string) (decimal.Decimal, error) {
+	var supply decimal.Decimal
+	err := q.QueryRowContext(ctx, "SELECT COALESCE(SUM(Balance), 0) FROM Wallets WHERE Token = $1", symbol).Scan(&supply)
+	return supply, err
+}
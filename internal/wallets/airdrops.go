@@ -0,0 +1,384 @@
+package wallets
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"btp_tokens/internal/ledger"
+	"btp_tokens/internal/merkle"
+	"btp_tokens/internal/tokens"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// Airdrop parks Total in a system account and lets every (address, amount)
+// pair of a Merkle tree claim its amount once, proving it against Root,
+// until ExpiresAt. What was not claimed can then be swept back to Funder.
+type Airdrop struct {
+	ID         int64
+	Token      string
+	Funder     string
+	Root       string
+	Total      decimal.Decimal
+	LeafCount  int
+	Claimed    decimal.Decimal
+	ClaimCount int
+	ExpiresAt  time.Time
+	// Swept is the unclaimed amount returned to the funder
+	Swept     decimal.Decimal
+	SweptAt   *time.Time
+	Operator  string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// AirdropClaim is the leaf of an address, with its proof and whether it was
+// claimed.
+type AirdropClaim struct {
+	AirdropID       int64
+	Address         string
+	Amount          decimal.Decimal
+	Root            string
+	Proof           []merkle.ProofStep
+	ClaimedAt       *time.Time
+	TransferEntryID int64
+}
+
+var ErrorAirdropNotFound = errors.New("airdrop not found")
+var ErrorInvalidAirdropList = errors.New("airdrop list must have one \"address,amount\" line per address with a positive amount")
+var ErrorInvalidAirdropProof = errors.New("proof does not match the airdrop root")
+var ErrorAirdropClaimed = errors.New("airdrop was already claimed by this address")
+var ErrorAirdropExpired = errors.New("airdrop has expired")
+var ErrorAirdropNotExpired = errors.New("airdrop cannot be swept before it expires")
+var ErrorAirdropSwept = errors.New("airdrop was already swept")
+var ErrorAirdropCorrupted = errors.New("airdrop leaves do not match its root")
+
+// AirdropAccount is the system account holding the unclaimed tokens of an
+// airdrop.
+func AirdropAccount(id int64) string {
+	return "airdrop:" + strconv.FormatInt(id, 10)
+}
+
+// ParseAirdropList reads "address,amount" lines. A first line whose amount
+// is not a number is taken as a header.
+func ParseAirdropList(list string) ([]merkle.Leaf, error) {
+	reader := csv.NewReader(strings.NewReader(list))
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	var leaves []merkle.Leaf
+	seen := make(map[string]bool)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, ErrorInvalidAirdropList
+		}
+
+		address := strings.TrimSpace(record[0])
+		amount, err := decimal.NewFromString(strings.TrimSpace(record[1]))
+		if err != nil && line == 1 {
+			continue
+		}
+		if err != nil || address == "" || systemAccount(address) || !amount.IsPositive() || seen[address] {
+			return nil, ErrorInvalidAirdropList
+		}
+		seen[address] = true
+		leaves = append(leaves, merkle.Leaf{Address: address, Amount: amount})
+	}
+	if len(leaves) == 0 {
+		return nil, ErrorInvalidAirdropList
+	}
+	return leaves, nil
+}
+
+const airdropColumns = `Id, Token, Funder, Merkle_Root, Total, Leaf_Count, Claimed, Claim_Count, Expires_At,
+	Swept, Swept_At, Operator, Created_At, Updated_At`
+
+func scanAirdrop(row interface{ Scan(...any) error }) (Airdrop, error) {
+	var a Airdrop
+	var sweptAt sql.NullTime
+	err := row.Scan(&a.ID, &a.Token, &a.Funder, &a.Root, &a.Total, &a.LeafCount, &a.Claimed, &a.ClaimCount, &a.ExpiresAt,
+		&a.Swept, &sweptAt, &a.Operator, &a.CreatedAt, &a.UpdatedAt)
+	if sweptAt.Valid {
+		a.SweptAt = &sweptAt.Time
+	}
+	return a, err
+}
+
+// CreateAirdrop publishes the Merkle root of the pairs of list and moves
+// their total from funder into the airdrop's account.
+func (s *WalletsService) CreateAirdrop(ctx context.Context, token string, funder string, list string, expiresAt time.Time, operator string) (Airdrop, error) {
+	if !expiresAt.After(time.Now()) {
+		return Airdrop{}, ErrorInvalidExpiry
+	}
+	leaves, err := ParseAirdropList(list)
+	if err != nil {
+		return Airdrop{}, err
+	}
+	tree, err := merkle.NewTree(leaves)
+	if err != nil {
+		return Airdrop{}, err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Airdrop{}, err
+	}
+	defer tx.Rollback()
+
+	t, err := tokens.Get(ctx, tx, token)
+	if err != nil {
+		return Airdrop{}, err
+	}
+	total := decimal.Zero
+	addresses := make([]string, len(leaves))
+	amounts := make([]string, len(leaves))
+	for i, leaf := range leaves {
+		if err = t.CheckPrecision(leaf.Amount); err != nil {
+			return Airdrop{}, err
+		}
+		total = total.Add(leaf.Amount)
+		addresses[i] = leaf.Address
+		amounts[i] = leaf.Amount.String()
+	}
+
+	airdrop, err := scanAirdrop(tx.QueryRowContext(ctx, `
+		INSERT INTO Airdrops (Token, Funder, Merkle_Root, Total, Leaf_Count, Expires_At, Operator)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+airdropColumns,
+		token, funder, tree.Root(), total, tree.Len(), expiresAt, operator))
+	if err != nil {
+		return Airdrop{}, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO Airdrop_Leaves (Airdrop_Id, Address, Amount)
+		SELECT $1, l.Address, l.Amount FROM unnest($2::TEXT[], $3::NUMERIC[]) AS l(Address, Amount)
+	`, airdrop.ID, pq.Array(addresses), pq.Array(amounts))
+	if err != nil {
+		return Airdrop{}, err
+	}
+
+	account := AirdropAccount(airdrop.ID)
+	sheet, err := lockBalances(ctx, tx, []walletKey{{funder, token}, {account, token}})
+	if err != nil {
+		return Airdrop{}, err
+	}
+	// an operator's payout to many recipients, so neither the funder's
+	// limits nor the policies, which judge one sender and receiver, apply
+	if _, err = sheet.move(ledger.KindAirdrop, token, funder, account, total); err != nil {
+		return Airdrop{}, err
+	}
+	if err = sheet.flush(ctx, tx); err != nil {
+		return Airdrop{}, err
+	}
+
+	return airdrop, tx.Commit()
+}
+
+// ClaimAirdrop pays amount to address when proof shows the pair is part of
+// the airdrop's tree. Each address can claim once, before the airdrop
+// expires.
+func (s *WalletsService) ClaimAirdrop(ctx context.Context, id int64, address string, amount decimal.Decimal, proof []merkle.ProofStep) (AirdropClaim, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return AirdropClaim{}, err
+	}
+	defer tx.Rollback()
+
+	airdrop, err := lockAirdrop(ctx, tx, id)
+	if err != nil {
+		return AirdropClaim{}, err
+	}
+	if airdrop.SweptAt != nil || !time.Now().Before(airdrop.ExpiresAt) {
+		return AirdropClaim{}, ErrorAirdropExpired
+	}
+	if !merkle.Verify(airdrop.Root, address, amount, proof) {
+		return AirdropClaim{}, ErrorInvalidAirdropProof
+	}
+
+	claim := AirdropClaim{AirdropID: id, Address: address, Root: airdrop.Root, Proof: proof}
+	var claimedAt time.Time
+	err = tx.QueryRowContext(ctx, `
+		UPDATE Airdrop_Leaves SET Claimed_At = now()
+		WHERE Airdrop_Id = $1 AND Address = $2 AND Claimed_At IS NULL
+		RETURNING Amount, Claimed_At
+	`, id, address).Scan(&claim.Amount, &claimedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return AirdropClaim{}, ErrorAirdropClaimed
+	}
+	if err != nil {
+		return AirdropClaim{}, err
+	}
+	claim.ClaimedAt = &claimedAt
+
+	account := AirdropAccount(id)
+	sheet, err := lockBalances(ctx, tx, []walletKey{{account, airdrop.Token}, {address, airdrop.Token}})
+	if err != nil {
+		return AirdropClaim{}, err
+	}
+	entry, err := sheet.move(ledger.KindAirdrop, airdrop.Token, account, address, claim.Amount)
+	if err != nil {
+		return AirdropClaim{}, err
+	}
+	if err = sheet.flush(ctx, tx); err != nil {
+		return AirdropClaim{}, err
+	}
+	claim.TransferEntryID = entry.ID
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE Airdrop_Leaves SET Transfer_Entry_Id = $3 WHERE Airdrop_Id = $1 AND Address = $2
+	`, id, address, entry.ID)
+	if err != nil {
+		return AirdropClaim{}, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE Airdrops SET Claimed = Claimed + $2, Claim_Count = Claim_Count + 1, Updated_At = now() WHERE Id = $1
+	`, id, claim.Amount)
+	if err != nil {
+		return AirdropClaim{}, err
+	}
+	return claim, tx.Commit()
+}
+
+// SweepAirdrop returns what was not claimed of an expired airdrop to its
+// funder. No claim is accepted afterwards.
+func (s *WalletsService) SweepAirdrop(ctx context.Context, id int64) (Airdrop, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Airdrop{}, err
+	}
+	defer tx.Rollback()
+
+	airdrop, err := lockAirdrop(ctx, tx, id)
+	if err != nil {
+		return Airdrop{}, err
+	}
+	if airdrop.SweptAt != nil {
+		return Airdrop{}, ErrorAirdropSwept
+	}
+	if time.Now().Before(airdrop.ExpiresAt) {
+		return Airdrop{}, ErrorAirdropNotExpired
+	}
+
+	unclaimed := airdrop.Total.Sub(airdrop.Claimed)
+	if unclaimed.IsPositive() {
+		account := AirdropAccount(id)
+		sheet, err := lockBalances(ctx, tx, []walletKey{{account, airdrop.Token}, {airdrop.Funder, airdrop.Token}})
+		if err != nil {
+			return Airdrop{}, err
+		}
+		if _, err = sheet.move(ledger.KindAirdrop, airdrop.Token, account, airdrop.Funder, unclaimed); err != nil {
+			return Airdrop{}, err
+		}
+		if err = sheet.flush(ctx, tx); err != nil {
+			return Airdrop{}, err
+		}
+	}
+
+	airdrop, err = scanAirdrop(tx.QueryRowContext(ctx, `
+		UPDATE Airdrops SET Swept = $2, Swept_At = now(), Updated_At = now()
+		WHERE Id = $1
+		RETURNING `+airdropColumns,
+		id, unclaimed))
+	if err != nil {
+		return Airdrop{}, err
+	}
+	return airdrop, tx.Commit()
+}
+
+// lockAirdrop locks the airdrop so that claims of the same address and a
+// sweep cannot pay the same tokens twice.
+func lockAirdrop(ctx context.Context, tx *sql.Tx, id int64) (Airdrop, error) {
+	airdrop, err := scanAirdrop(tx.QueryRowContext(ctx, "SELECT "+airdropColumns+" FROM Airdrops WHERE Id = $1 FOR UPDATE", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Airdrop{}, ErrorAirdropNotFound
+	}
+	return airdrop, err
+}
+
+// AirdropProof rebuilds the tree of an airdrop and returns the leaf of
+// address with its proof, merkle.ErrorLeafNotFound when address is not part
+// of the airdrop.
+func (s *WalletsService) AirdropProof(ctx context.Context, id int64, address string) (AirdropClaim, error) {
+	airdrop, err := s.GetAirdrop(ctx, id)
+	if err != nil {
+		return AirdropClaim{}, err
+	}
+
+	rows, err := s.DB.QueryContext(ctx, "SELECT Address, Amount FROM Airdrop_Leaves WHERE Airdrop_Id = $1", id)
+	if err != nil {
+		return AirdropClaim{}, err
+	}
+	defer rows.Close()
+
+	var leaves []merkle.Leaf
+	for rows.Next() {
+		var leaf merkle.Leaf
+		if err := rows.Scan(&leaf.Address, &leaf.Amount); err != nil {
+			return AirdropClaim{}, err
+		}
+		leaves = append(leaves, leaf)
+	}
+	if err := rows.Err(); err != nil {
+		return AirdropClaim{}, err
+	}
+
+	tree, err := merkle.NewTree(leaves)
+	if err != nil {
+		return AirdropClaim{}, err
+	}
+	if tree.Root() != airdrop.Root {
+		return AirdropClaim{}, ErrorAirdropCorrupted
+	}
+	leaf, proof, err := tree.Proof(address)
+	if err != nil {
+		return AirdropClaim{}, err
+	}
+
+	claim := AirdropClaim{AirdropID: id, Address: leaf.Address, Amount: leaf.Amount, Root: airdrop.Root, Proof: proof}
+	var claimedAt sql.NullTime
+	err = s.DB.QueryRowContext(ctx, `
+		SELECT Claimed_At, COALESCE(Transfer_Entry_Id, 0) FROM Airdrop_Leaves WHERE Airdrop_Id = $1 AND Address = $2
+	`, id, address).Scan(&claimedAt, &claim.TransferEntryID)
+	if claimedAt.Valid {
+		claim.ClaimedAt = &claimedAt.Time
+	}
+	return claim, err
+}
+
+func (s *WalletsService) GetAirdrop(ctx context.Context, id int64) (Airdrop, error) {
+	airdrop, err := scanAirdrop(s.DB.QueryRowContext(ctx, "SELECT "+airdropColumns+" FROM Airdrops WHERE Id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Airdrop{}, ErrorAirdropNotFound
+	}
+	return airdrop, err
+}
+
+// ListAirdrops returns the latest airdrops, newest first.
+func (s *WalletsService) ListAirdrops(ctx context.Context, limit int) ([]Airdrop, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT "+airdropColumns+" FROM Airdrops ORDER BY Id DESC LIMIT $1", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Airdrop
+	for rows.Next() {
+		airdrop, err := scanAirdrop(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, airdrop)
+	}
+	return list, rows.Err()
+}
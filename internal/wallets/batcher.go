@@ -0,0 +1,209 @@
+package wallets
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"btp_tokens/internal/tokens"
+
+	"github.com/shopspring/decimal"
+)
+
+const DefaultBatchSize = 64
+const DefaultBatchWait = 5 * time.Millisecond
+
+var ErrorBatcherClosed = errors.New("transfer batcher is closed")
+
+type batchResult struct {
+	receipt Receipt
+	err     error
+}
+
+type batchRequest struct {
+	ctx         context.Context
+	token       string
+	fromAddress string
+	toAddress   string
+	amount      decimal.Decimal
+	result      chan batchResult
+}
+
+// TransferBatcher queues incoming transfers and applies them in micro-batches,
+// each inside a single transaction. Transfers of a batch are validated one
+// after another against the balances locked for the batch, so a failing
+// transfer only fails its own caller.
+type TransferBatcher struct {
+	service      *WalletsService
+	maxBatchSize int
+	maxWait      time.Duration
+
+	mu       sync.RWMutex
+	closed   bool
+	requests chan *batchRequest
+	done     chan struct{}
+}
+
+func NewTransferBatcher(service *WalletsService, maxBatchSize int, maxWait time.Duration) *TransferBatcher {
+	if maxBatchSize <= 0 {
+		maxBatchSize = DefaultBatchSize
+	}
+	if maxWait <= 0 {
+		maxWait = DefaultBatchWait
+	}
+
+	b := &TransferBatcher{
+		service:      service,
+		maxBatchSize: maxBatchSize,
+		maxWait:      maxWait,
+		requests:     make(chan *batchRequest, maxBatchSize),
+		done:         make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Transfer queues a BTP transfer, see TransferToken.
+func (b *TransferBatcher) Transfer(ctx context.Context, fromAddress string, toAddress string, amount decimal.Decimal) (decimal.Decimal, error) {
+	return b.TransferToken(ctx, tokens.DefaultSymbol, fromAddress, toAddress, amount)
+}
+
+// TransferToken queues a transfer and returns the sender's updated balance,
+// see Send.
+func (b *TransferBatcher) TransferToken(ctx context.Context, token string, fromAddress string, toAddress string, amount decimal.Decimal) (decimal.Decimal, error) {
+	receipt, err := b.Send(ctx, token, fromAddress, toAddress, amount)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	return receipt.SenderBalance, nil
+}
+
+// Send queues a transfer and waits until the batch containing it is
+// committed, like WalletsService.Send.
+func (b *TransferBatcher) Send(ctx context.Context, token string, fromAddress string, toAddress string, amount decimal.Decimal) (Receipt, error) {
+	req := &batchRequest{
+		ctx:         ctx,
+		token:       token,
+		fromAddress: fromAddress,
+		toAddress:   toAddress,
+		amount:      amount,
+		result:      make(chan batchResult, 1),
+	}
+
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return Receipt{}, ErrorBatcherClosed
+	}
+	select {
+	case b.requests <- req:
+	case <-ctx.Done():
+		b.mu.RUnlock()
+		return Receipt{}, ctx.Err()
+	}
+	b.mu.RUnlock()
+
+	// once queued the transfer may be committed at any moment, so the
+	// caller always waits for the real outcome
+	res := <-req.result
+	return res.receipt, res.err
+}
+
+// Close stops accepting transfers, applies the ones already queued and
+// waits for the last batch to finish.
+func (b *TransferBatcher) Close() {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.requests)
+	}
+	b.mu.Unlock()
+	<-b.done
+}
+
+func (b *TransferBatcher) run() {
+	defer close(b.done)
+
+	for first := range b.requests {
+		batch := []*batchRequest{first}
+		timer := time.NewTimer(b.maxWait)
+
+	collect:
+		for len(batch) < b.maxBatchSize {
+			select {
+			case req, ok := <-b.requests:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, req)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		b.process(batch)
+	}
+}
+
+func (b *TransferBatcher) process(batch []*batchRequest) {
+	results := make([]batchResult, len(batch))
+	if err := b.apply(batch, results); err != nil {
+		for i := range results {
+			if results[i].err == nil {
+				results[i] = batchResult{err: err}
+			}
+		}
+	}
+
+	for i, req := range batch {
+		req.result <- results[i]
+	}
+}
+
+// apply runs the whole batch in one transaction. Validation errors are stored
+// per transfer; a returned error means nothing in the batch was committed.
+func (b *TransferBatcher) apply(batch []*batchRequest, results []batchResult) error {
+	ctx := context.Background()
+
+	tx, err := b.service.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	keys := make([]walletKey, 0, 2*len(batch))
+	for _, req := range batch {
+		keys = append(keys, walletKey{req.fromAddress, req.token}, walletKey{req.toAddress, req.token})
+	}
+
+	sheet, err := lockBalances(ctx, tx, keys)
+	if err != nil {
+		return err
+	}
+
+	receipts := make([]*Receipt, len(batch))
+	for i, req := range batch {
+		if err := req.ctx.Err(); err != nil {
+			results[i] = batchResult{err: err}
+			continue
+		}
+		receipts[i], results[i].err = sheet.apply(req.token, req.fromAddress, req.toAddress, req.amount)
+	}
+
+	if err := sheet.flush(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for i, receipt := range receipts {
+		if receipt != nil {
+			results[i].receipt = receipt.complete()
+		}
+	}
+	return nil
+}
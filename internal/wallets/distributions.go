@@ -0,0 +1,686 @@
+package wallets
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"btp_tokens/internal/ledger"
+	"btp_tokens/internal/snapshots"
+	"btp_tokens/internal/tokens"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// Distribution states. Pending distributions can be reviewed before they are
+// started, running ones are paid batch by batch until no recipient is left.
+// Closing a completed distribution returns what its failed recipients were
+// not paid.
+const (
+	DistributionPending   = "pending"
+	DistributionRunning   = "running"
+	DistributionCompleted = "completed"
+	DistributionClosed    = "closed"
+)
+
+// Distribution recipient states.
+const (
+	RecipientPending = "pending"
+	RecipientPaid    = "paid"
+	RecipientFailed  = "failed"
+)
+
+const DefaultDistributionBatchSize = 100
+
+// Distribution pays Total of Token from FromAddress to recipients pro rata
+// to their weights: their balances of WeightToken at a snapshot, or the
+// weights of a CSV list. Total is reserved in DistributionAccount when the
+// distribution starts and the shares are paid from there.
+type Distribution struct {
+	ID          int64
+	Token       string
+	FromAddress string
+	Total       decimal.Decimal
+	SnapshotID  int64
+	WeightToken string
+	Status      string
+	Operator    string
+	// counts and amounts of the recipients, by status, and what went back
+	// to FromAddress when it was closed
+	RecipientCount int
+	PaidCount      int
+	FailedCount    int
+	PaidAmount     decimal.Decimal
+	Returned       decimal.Decimal
+	StartedAt      *time.Time
+	CompletedAt    *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type DistributionRecipient struct {
+	Address         string
+	Weight          decimal.Decimal
+	Amount          decimal.Decimal
+	Status          string
+	TransferEntryID int64
+	Error           string
+	UpdatedAt       time.Time
+}
+
+var ErrorDistributionNotFound = errors.New("distribution not found")
+var ErrorDistributionNotPending = errors.New("distribution was already started")
+var ErrorInvalidDistribution = errors.New("distribution needs either a snapshot or a recipient list")
+var ErrorInvalidRecipientList = errors.New("recipient list must have one \"address,weight\" line per address with a positive weight")
+var ErrorNoRecipients = errors.New("distribution has no recipients")
+var ErrorDistributionNotStarted = errors.New("distribution is not running or completed")
+var ErrorNoFailedRecipients = errors.New("distribution has no failed recipients")
+var ErrorDistributionNotCompleted = errors.New("distribution is not completed")
+
+// DistributionAccount is the system account holding the reserved total of
+// a distribution until it is paid.
+func DistributionAccount(id int64) string {
+	return "distribution:" + strconv.FormatInt(id, 10)
+}
+
+// Allocate splits total pro rata to weights, in whole units of the given
+// number of decimal places. total is first truncated to those places, the
+// part below them is never allocated. Every share is then rounded down; the
+// units rounding down left over, fewer than there are weights, go one each
+// to the shares with the largest remainders, ties going to the earlier
+// weight. The shares add up to the truncated total exactly, which is total
+// itself for the totals CreateDistribution accepts.
+func Allocate(total decimal.Decimal, weights []decimal.Decimal, decimals int32) []decimal.Decimal {
+	sum := decimal.Zero
+	for _, weight := range weights {
+		sum = sum.Add(weight)
+	}
+	shares := make([]decimal.Decimal, len(weights))
+	if !sum.IsPositive() {
+		return shares
+	}
+
+	units := total.Shift(decimals).Truncate(0)
+	remainders := make([]decimal.Decimal, len(weights))
+	left := units
+	for i, weight := range weights {
+		shares[i], remainders[i] = units.Mul(weight).QuoRem(sum, 0)
+		left = left.Sub(shares[i])
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]].GreaterThan(remainders[order[b]])
+	})
+	for _, i := range order {
+		if !left.IsPositive() {
+			break
+		}
+		shares[i] = shares[i].Add(decimal.NewFromInt(1))
+		left = left.Sub(decimal.NewFromInt(1))
+	}
+
+	for i := range shares {
+		shares[i] = shares[i].Shift(-decimals)
+	}
+	return shares
+}
+
+// ParseRecipientList reads "address,weight" lines, the weight defaulting to
+// 1. A first line whose weight is not a number, or whose only field is
+// "address", is taken as a header.
+func ParseRecipientList(list string) (map[string]decimal.Decimal, error) {
+	reader := csv.NewReader(strings.NewReader(list))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	weights := make(map[string]decimal.Decimal)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil || len(record) == 0 || len(record) > 2 {
+			return nil, ErrorInvalidRecipientList
+		}
+
+		address := strings.TrimSpace(record[0])
+		if line == 1 && len(record) == 1 && strings.EqualFold(address, "address") {
+			continue
+		}
+		weight := decimal.NewFromInt(1)
+		if len(record) == 2 {
+			weight, err = decimal.NewFromString(strings.TrimSpace(record[1]))
+			if err != nil && line == 1 {
+				continue
+			}
+		}
+		if err != nil || address == "" || !weight.IsPositive() {
+			return nil, ErrorInvalidRecipientList
+		}
+		if _, ok := weights[address]; ok {
+			return nil, ErrorInvalidRecipientList
+		}
+		weights[address] = weight
+	}
+	return weights, nil
+}
+
+const distributionColumns = `d.Id, d.Token, d.From_Address, d.Total, COALESCE(d.Snapshot_Id, 0), COALESCE(d.Weight_Token, ''), d.Status,
+	d.Operator, r.Recipients, r.Paid, r.Failed, r.Paid_Amount, d.Returned, d.Started_At, d.Completed_At, d.Created_At, d.Updated_At`
+
+const distributionFrom = `Distributions d, LATERAL (
+		SELECT COUNT(*) AS Recipients,
+			COUNT(*) FILTER (WHERE Status = 'paid') AS Paid,
+			COUNT(*) FILTER (WHERE Status = 'failed') AS Failed,
+			COALESCE(SUM(Amount) FILTER (WHERE Status = 'paid'), 0) AS Paid_Amount
+		FROM Distribution_Recipients WHERE Distribution_Id = d.Id
+	) r`
+
+func scanDistribution(row interface{ Scan(...any) error }) (Distribution, error) {
+	var d Distribution
+	var startedAt, completedAt sql.NullTime
+	err := row.Scan(&d.ID, &d.Token, &d.FromAddress, &d.Total, &d.SnapshotID, &d.WeightToken, &d.Status,
+		&d.Operator, &d.RecipientCount, &d.PaidCount, &d.FailedCount, &d.PaidAmount, &d.Returned, &startedAt, &completedAt, &d.CreatedAt, &d.UpdatedAt)
+	if startedAt.Valid {
+		d.StartedAt = &startedAt.Time
+	}
+	if completedAt.Valid {
+		d.CompletedAt = &completedAt.Time
+	}
+	return d, err
+}
+
+// CreateDistribution computes the shares of a distribution of total from
+// fromAddress, to the holders of weightToken at a snapshot when snapshotID
+// is set, or to the weights of list otherwise. fromAddress and system
+// accounts never receive a share, neither do recipients whose share rounds
+// down to zero. Nothing is paid until the distribution is started.
+func (s *WalletsService) CreateDistribution(ctx context.Context, token string, fromAddress string, total decimal.Decimal, snapshotID int64, weightToken string, list string, operator string) (Distribution, error) {
+	if !total.IsPositive() {
+		return Distribution{}, ErrorNonPositiveAmount
+	}
+	if (snapshotID == 0) == (list == "") {
+		return Distribution{}, ErrorInvalidDistribution
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Distribution{}, err
+	}
+	defer tx.Rollback()
+
+	t, err := tokens.Get(ctx, tx, token)
+	if err != nil {
+		return Distribution{}, err
+	}
+	if err = t.CheckPrecision(total); err != nil {
+		return Distribution{}, err
+	}
+
+	var weights map[string]decimal.Decimal
+	if snapshotID != 0 {
+		weights, err = snapshotWeights(ctx, tx, snapshotID, weightToken)
+	} else {
+		weightToken = ""
+		weights, err = ParseRecipientList(list)
+	}
+	if err != nil {
+		return Distribution{}, err
+	}
+
+	addresses := make([]string, 0, len(weights))
+	for address := range weights {
+		if address != fromAddress && !systemAccount(address) {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Strings(addresses)
+	ordered := make([]decimal.Decimal, len(addresses))
+	for i, address := range addresses {
+		ordered[i] = weights[address]
+	}
+	shares := Allocate(total, ordered, t.Decimals)
+
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO Distributions (Token, From_Address, Total, Snapshot_Id, Weight_Token, Operator)
+		VALUES ($1, $2, $3, NULLIF($4, 0), NULLIF($5, ''), $6)
+		RETURNING Id
+	`, token, fromAddress, total, snapshotID, weightToken, operator).Scan(&id)
+	if err != nil {
+		return Distribution{}, err
+	}
+
+	var recipients, recipientWeights, amounts []string
+	for i, address := range addresses {
+		if shares[i].IsPositive() {
+			recipients = append(recipients, address)
+			recipientWeights = append(recipientWeights, ordered[i].String())
+			amounts = append(amounts, shares[i].String())
+		}
+	}
+	if len(recipients) == 0 {
+		return Distribution{}, ErrorNoRecipients
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO Distribution_Recipients (Distribution_Id, Position, Address, Weight, Amount)
+		SELECT $1, r.Position, r.Address, r.Weight, r.Amount
+		FROM unnest($2::TEXT[], $3::NUMERIC[], $4::NUMERIC[]) WITH ORDINALITY AS r(Address, Weight, Amount, Position)
+	`, id, pq.Array(recipients), pq.Array(recipientWeights), pq.Array(amounts))
+	if err != nil {
+		return Distribution{}, err
+	}
+
+	distribution, err := getDistribution(ctx, tx, id)
+	if err != nil {
+		return Distribution{}, err
+	}
+	return distribution, tx.Commit()
+}
+
+// snapshotWeights returns the positive balances of token at a snapshot.
+func snapshotWeights(ctx context.Context, tx *sql.Tx, snapshotID int64, token string) (map[string]decimal.Decimal, error) {
+	var exists bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM Snapshots WHERE Id = $1)", snapshotID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, snapshots.ErrorSnapshotNotFound
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT Address, Balance FROM Snapshot_Balances
+		WHERE Snapshot_Id = $1 AND Token = $2 AND Balance > 0
+	`, snapshotID, token)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	weights := make(map[string]decimal.Decimal)
+	for rows.Next() {
+		var address string
+		var balance decimal.Decimal
+		if err := rows.Scan(&address, &balance); err != nil {
+			return nil, err
+		}
+		weights[address] = balance
+	}
+	return weights, rows.Err()
+}
+
+// StartDistribution moves the total of a pending distribution from its
+// funder into DistributionAccount and lets the distribution runner pay it.
+// It fails with ErrorInsufficientBalance when the funder cannot cover it.
+func (s *WalletsService) StartDistribution(ctx context.Context, id int64) (Distribution, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Distribution{}, err
+	}
+	defer tx.Rollback()
+
+	distribution, err := lockDistribution(ctx, tx, id)
+	if err != nil {
+		return Distribution{}, err
+	}
+	if distribution.Status != DistributionPending {
+		return Distribution{}, ErrorDistributionNotPending
+	}
+
+	account := DistributionAccount(id)
+	sheet, err := lockBalances(ctx, tx, []walletKey{{distribution.FromAddress, distribution.Token}, {account, distribution.Token}})
+	if err != nil {
+		return Distribution{}, err
+	}
+	// an operator's payout to many recipients, so neither the funder's
+	// limits nor the policies, which judge one sender and receiver, apply
+	if _, err = sheet.move(ledger.KindDistribution, distribution.Token, distribution.FromAddress, account, distribution.Total); err != nil {
+		return Distribution{}, err
+	}
+	if err = sheet.flush(ctx, tx); err != nil {
+		return Distribution{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE Distributions SET Status = $2, Started_At = now(), Updated_At = now() WHERE Id = $1
+	`, id, DistributionRunning)
+	if err != nil {
+		return Distribution{}, err
+	}
+
+	distribution, err = getDistribution(ctx, tx, id)
+	if err != nil {
+		return Distribution{}, err
+	}
+	return distribution, tx.Commit()
+}
+
+// lockDistribution locks the distribution row, so batches, retries and
+// closing do not overlap.
+func lockDistribution(ctx context.Context, tx *sql.Tx, id int64) (Distribution, error) {
+	var d Distribution
+	err := tx.QueryRowContext(ctx, "SELECT Id, Token, From_Address, Total, Status FROM Distributions WHERE Id = $1 FOR UPDATE", id).
+		Scan(&d.ID, &d.Token, &d.FromAddress, &d.Total, &d.Status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Distribution{}, ErrorDistributionNotFound
+	}
+	return d, err
+}
+
+// RunDistributionBatch pays the next size pending recipients of a running
+// distribution in one transaction and reports whether recipients are left.
+// The shares are paid from DistributionAccount without a fee, so every
+// recipient gets its whole amount, and limits and policies do not apply. A
+// recipient that may not receive is marked failed with the reason; if the
+// batch fails as a whole, including when the account cannot cover it,
+// nothing of it is committed, so a later run retries the same recipients.
+func (s *WalletsService) RunDistributionBatch(ctx context.Context, id int64, size int) (bool, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	distribution, err := lockDistribution(ctx, tx, id)
+	if err != nil || distribution.Status != DistributionRunning {
+		return false, err
+	}
+	token := distribution.Token
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT Address, Amount FROM Distribution_Recipients
+		WHERE Distribution_Id = $1 AND Status = $2
+		ORDER BY Position ASC
+		LIMIT $3
+	`, id, RecipientPending, size)
+	if err != nil {
+		return false, err
+	}
+	var batch []DistributionRecipient
+	for rows.Next() {
+		var r DistributionRecipient
+		if err := rows.Scan(&r.Address, &r.Amount); err != nil {
+			rows.Close()
+			return false, err
+		}
+		batch = append(batch, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	if len(batch) == 0 {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE Distributions SET Status = $2, Completed_At = now(), Updated_At = now() WHERE Id = $1
+		`, id, DistributionCompleted)
+		if err != nil {
+			return false, err
+		}
+		return false, tx.Commit()
+	}
+
+	account := DistributionAccount(id)
+	keys := []walletKey{{account, token}}
+	for _, r := range batch {
+		keys = append(keys, walletKey{r.Address, token})
+	}
+	sheet, err := lockBalances(ctx, tx, keys)
+	if err != nil {
+		return false, err
+	}
+
+	entries := make([]*ledger.Entry, len(batch))
+	for i := range batch {
+		entries[i], err = sheet.move(ledger.KindDistribution, token, account, batch[i].Address, batch[i].Amount)
+		if errors.Is(err, ErrorInsufficientBalance) || errors.Is(err, ErrorSenderNotFound) {
+			return false, err
+		}
+		if err != nil {
+			batch[i].Status, batch[i].Error = RecipientFailed, err.Error()
+		} else {
+			batch[i].Status = RecipientPaid
+		}
+	}
+	if err = sheet.flush(ctx, tx); err != nil {
+		return false, err
+	}
+
+	for i, r := range batch {
+		var entryID int64
+		if entries[i] != nil {
+			entryID = entries[i].ID
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE Distribution_Recipients
+			SET Status = $3, Transfer_Entry_Id = NULLIF($4, 0), Error = NULLIF($5, ''), Updated_At = now()
+			WHERE Distribution_Id = $1 AND Address = $2
+		`, id, r.Address, r.Status, entryID, r.Error)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// RetryDistribution sets the failed recipients of a running or completed
+// distribution back to pending, so the runner pays them again from what is
+// still reserved.
+func (s *WalletsService) RetryDistribution(ctx context.Context, id int64) (Distribution, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Distribution{}, err
+	}
+	defer tx.Rollback()
+
+	distribution, err := lockDistribution(ctx, tx, id)
+	if err != nil {
+		return Distribution{}, err
+	}
+	if distribution.Status != DistributionRunning && distribution.Status != DistributionCompleted {
+		return Distribution{}, ErrorDistributionNotStarted
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE Distribution_Recipients SET Status = $3, Error = NULL, Updated_At = now()
+		WHERE Distribution_Id = $1 AND Status = $2
+	`, id, RecipientFailed, RecipientPending)
+	if err != nil {
+		return Distribution{}, err
+	}
+	if retried, _ := result.RowsAffected(); retried == 0 {
+		return Distribution{}, ErrorNoFailedRecipients
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE Distributions SET Status = $2, Completed_At = NULL, Updated_At = now() WHERE Id = $1
+	`, id, DistributionRunning)
+	if err != nil {
+		return Distribution{}, err
+	}
+
+	distribution, err = getDistribution(ctx, tx, id)
+	if err != nil {
+		return Distribution{}, err
+	}
+	return distribution, tx.Commit()
+}
+
+// CloseDistribution returns what is left in the account of a completed
+// distribution, the shares of its failed recipients, to the funder. The
+// failed recipients can no longer be retried.
+func (s *WalletsService) CloseDistribution(ctx context.Context, id int64) (Distribution, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Distribution{}, err
+	}
+	defer tx.Rollback()
+
+	distribution, err := lockDistribution(ctx, tx, id)
+	if err != nil {
+		return Distribution{}, err
+	}
+	if distribution.Status != DistributionCompleted {
+		return Distribution{}, ErrorDistributionNotCompleted
+	}
+
+	var unpaid decimal.Decimal
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(Amount), 0) FROM Distribution_Recipients WHERE Distribution_Id = $1 AND Status = $2
+	`, id, RecipientFailed).Scan(&unpaid)
+	if err != nil {
+		return Distribution{}, err
+	}
+	if unpaid.IsPositive() {
+		account := DistributionAccount(id)
+		sheet, err := lockBalances(ctx, tx, []walletKey{{account, distribution.Token}, {distribution.FromAddress, distribution.Token}})
+		if err != nil {
+			return Distribution{}, err
+		}
+		if _, err = sheet.move(ledger.KindDistribution, distribution.Token, account, distribution.FromAddress, unpaid); err != nil {
+			return Distribution{}, err
+		}
+		if err = sheet.flush(ctx, tx); err != nil {
+			return Distribution{}, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE Distributions SET Status = $2, Returned = $3, Updated_At = now() WHERE Id = $1
+	`, id, DistributionClosed, unpaid)
+	if err != nil {
+		return Distribution{}, err
+	}
+
+	distribution, err = getDistribution(ctx, tx, id)
+	if err != nil {
+		return Distribution{}, err
+	}
+	return distribution, tx.Commit()
+}
+
+// ProcessDistribution runs batches of a running distribution until every
+// recipient was paid or failed.
+func (s *WalletsService) ProcessDistribution(ctx context.Context, id int64) (Distribution, error) {
+	for {
+		more, err := s.RunDistributionBatch(ctx, id, DefaultDistributionBatchSize)
+		if err != nil {
+			return Distribution{}, err
+		}
+		if !more {
+			return s.GetDistribution(ctx, id)
+		}
+	}
+}
+
+// RunDistributions processes the running distributions every interval,
+// picking up those interrupted by a restart.
+func (s *WalletsService) RunDistributions(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ids, err := s.runningDistributions(ctx)
+			if err != nil {
+				log.Println("distributions fail:", err)
+				continue
+			}
+			for _, id := range ids {
+				if _, err := s.ProcessDistribution(ctx, id); err != nil {
+					log.Println("distribution", id, "fail:", err)
+				}
+			}
+		}
+	}
+}
+
+func (s *WalletsService) runningDistributions(ctx context.Context) ([]int64, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT Id FROM Distributions WHERE Status = $1 ORDER BY Id ASC", DistributionRunning)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func getDistribution(ctx context.Context, q rowQueryer, id int64) (Distribution, error) {
+	distribution, err := scanDistribution(q.QueryRowContext(ctx, "SELECT "+distributionColumns+" FROM "+distributionFrom+" WHERE d.Id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Distribution{}, ErrorDistributionNotFound
+	}
+	return distribution, err
+}
+
+func (s *WalletsService) GetDistribution(ctx context.Context, id int64) (Distribution, error) {
+	return getDistribution(ctx, s.DB, id)
+}
+
+// ListDistributions returns the latest distributions, newest first.
+func (s *WalletsService) ListDistributions(ctx context.Context, limit int) ([]Distribution, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT "+distributionColumns+" FROM "+distributionFrom+" ORDER BY d.Id DESC LIMIT $1", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Distribution
+	for rows.Next() {
+		distribution, err := scanDistribution(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, distribution)
+	}
+	return list, rows.Err()
+}
+
+// DistributionRecipients returns the recipients of a distribution in payment
+// order, only those with status when it is set.
+func (s *WalletsService) DistributionRecipients(ctx context.Context, id int64, status string, limit int) ([]DistributionRecipient, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT Address, Weight, Amount, Status, COALESCE(Transfer_Entry_Id, 0), COALESCE(Error, ''), Updated_At
+		FROM Distribution_Recipients
+		WHERE Distribution_Id = $1 AND ($2 = '' OR Status = $2)
+		ORDER BY Position ASC
+		LIMIT $3
+	`, id, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []DistributionRecipient
+	for rows.Next() {
+		var r DistributionRecipient
+		if err := rows.Scan(&r.Address, &r.Weight, &r.Amount, &r.Status, &r.TransferEntryID, &r.Error, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,387 @@
+package wallets
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"btp_tokens/internal/ledger"
+
+	"github.com/shopspring/decimal"
+)
+
+// Escrow states. Funds sit in the escrow's system account until the escrow
+// is released to the seller or refunded to the buyer.
+const (
+	EscrowFunded   = "funded"
+	EscrowApproved = "approved"
+	EscrowDisputed = "disputed"
+	EscrowReleased = "released"
+	EscrowRefunded = "refunded"
+)
+
+// Escrow actions recorded in the history.
+const (
+	EscrowActionCreate    = "create"
+	EscrowActionApprove   = "approve"
+	EscrowActionDispute   = "dispute"
+	EscrowActionArbitrate = "arbitrate"
+	EscrowActionRelease   = "release"
+	EscrowActionRefund    = "refund"
+)
+
+// escrowTransitions lists the states each state can move to.
+var escrowTransitions = map[string][]string{
+	EscrowFunded:   {EscrowApproved, EscrowDisputed, EscrowRefunded},
+	EscrowApproved: {EscrowDisputed, EscrowReleased},
+	EscrowDisputed: {EscrowReleased, EscrowRefunded},
+}
+
+// Escrow holds Amount paid by Buyer for Seller. It is released once both
+// parties approve or the Arbiter settles a dispute, and can be refunded when
+// the Deadline passes without approval.
+type Escrow struct {
+	ID             int64
+	Token          string
+	Buyer          string
+	Seller         string
+	Arbiter        string
+	Amount         decimal.Decimal
+	Status         string
+	BuyerApproved  bool
+	SellerApproved bool
+	Reference      string
+	Deadline       time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// EscrowEvent is a state change of an escrow. FromStatus is empty for the
+// creation.
+type EscrowEvent struct {
+	ID              int64
+	EscrowID        int64
+	Action          string
+	FromStatus      string
+	ToStatus        string
+	Actor           string
+	Note            string
+	TransferEntryID int64
+	CreatedAt       time.Time
+}
+
+var ErrorEscrowNotFound = errors.New("escrow not found")
+var ErrorInvalidEscrowTransition = errors.New("action is not allowed in the escrow's current state")
+var ErrorNotEscrowParty = errors.New("only the buyer or the seller can do this")
+var ErrorNotEscrowArbiter = errors.New("only the arbiter can settle a dispute")
+var ErrorInvalidEscrowParties = errors.New("buyer, seller and arbiter must be different addresses")
+var ErrorInvalidDeadline = errors.New("escrow deadline must be in the future")
+var ErrorDeadlineNotReached = errors.New("escrow deadline has not passed yet")
+
+// EscrowAccount is the system account holding the funds of an escrow.
+func EscrowAccount(id int64) string {
+	return "escrow:" + strconv.FormatInt(id, 10)
+}
+
+func canTransition(from string, to string) bool {
+	for _, next := range escrowTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+const escrowColumns = `Id, Token, Buyer, Seller, Arbiter, Amount, Status, Buyer_Approved, Seller_Approved,
+	COALESCE(Reference, ''), Deadline, Created_At, Updated_At`
+
+func scanEscrow(row interface{ Scan(...any) error }) (Escrow, error) {
+	var e Escrow
+	err := row.Scan(&e.ID, &e.Token, &e.Buyer, &e.Seller, &e.Arbiter, &e.Amount, &e.Status, &e.BuyerApproved, &e.SellerApproved,
+		&e.Reference, &e.Deadline, &e.CreatedAt, &e.UpdatedAt)
+	return e, err
+}
+
+// CreateEscrow moves the amount from the buyer into a new escrow. Only the
+// Token, Buyer, Seller, Arbiter, Amount, Reference and Deadline of terms are
+// used.
+func (s *WalletsService) CreateEscrow(ctx context.Context, terms Escrow) (Escrow, error) {
+	if !terms.Amount.IsPositive() {
+		return Escrow{}, ErrorNonPositiveAmount
+	}
+	if terms.Buyer == terms.Seller || terms.Arbiter == terms.Buyer || terms.Arbiter == terms.Seller {
+		return Escrow{}, ErrorInvalidEscrowParties
+	}
+	if !terms.Deadline.After(time.Now()) {
+		return Escrow{}, ErrorInvalidDeadline
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Escrow{}, err
+	}
+	defer tx.Rollback()
+
+	escrow, err := scanEscrow(tx.QueryRowContext(ctx, `
+		INSERT INTO Escrows (Token, Buyer, Seller, Arbiter, Amount, Reference, Deadline)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+		RETURNING `+escrowColumns,
+		terms.Token, terms.Buyer, terms.Seller, terms.Arbiter, terms.Amount, terms.Reference, terms.Deadline))
+	if err != nil {
+		return Escrow{}, err
+	}
+
+	account := EscrowAccount(escrow.ID)
+	sheet, err := lockBalances(ctx, tx, []walletKey{{escrow.Buyer, escrow.Token}, {account, escrow.Token}})
+	if err != nil {
+		return Escrow{}, err
+	}
+	// funding an escrow counts against the buyer's transfer limits and is
+	// checked by the policies as a transfer to the seller
+	entry, err := sheet.fund(ledger.KindEscrow, escrow.Token, account, escrow.Buyer, escrow.Seller, escrow.Amount)
+	if err != nil {
+		return Escrow{}, err
+	}
+	if err = sheet.flush(ctx, tx); err != nil {
+		return Escrow{}, err
+	}
+
+	err = recordEscrowEvent(ctx, tx, EscrowEvent{
+		EscrowID:        escrow.ID,
+		Action:          EscrowActionCreate,
+		ToStatus:        EscrowFunded,
+		Actor:           escrow.Buyer,
+		TransferEntryID: entry.ID,
+	})
+	if err != nil {
+		return Escrow{}, err
+	}
+
+	return escrow, tx.Commit()
+}
+
+// ApproveEscrow records the approval of the buyer or the seller. The escrow
+// is approved, ready to be released, once both have approved.
+func (s *WalletsService) ApproveEscrow(ctx context.Context, id int64, party string) (Escrow, error) {
+	return s.updateEscrow(ctx, id, func(tx *sql.Tx, e *Escrow) (EscrowEvent, error) {
+		if e.Status != EscrowFunded {
+			return EscrowEvent{}, ErrorInvalidEscrowTransition
+		}
+
+		switch party {
+		case e.Buyer:
+			e.BuyerApproved = true
+		case e.Seller:
+			e.SellerApproved = true
+		default:
+			return EscrowEvent{}, ErrorNotEscrowParty
+		}
+
+		next := EscrowFunded
+		if e.BuyerApproved && e.SellerApproved {
+			next = EscrowApproved
+		}
+		return EscrowEvent{Action: EscrowActionApprove, ToStatus: next, Actor: party}, nil
+	})
+}
+
+// DisputeEscrow stops the escrow until the arbiter settles it.
+func (s *WalletsService) DisputeEscrow(ctx context.Context, id int64, party string, reason string) (Escrow, error) {
+	return s.updateEscrow(ctx, id, func(tx *sql.Tx, e *Escrow) (EscrowEvent, error) {
+		if party != e.Buyer && party != e.Seller {
+			return EscrowEvent{}, ErrorNotEscrowParty
+		}
+		return EscrowEvent{Action: EscrowActionDispute, ToStatus: EscrowDisputed, Actor: party, Note: reason}, nil
+	})
+}
+
+// ArbitrateEscrow settles a dispute by releasing the funds to the seller or
+// refunding them to the buyer.
+func (s *WalletsService) ArbitrateEscrow(ctx context.Context, id int64, arbiter string, release bool, note string) (Escrow, error) {
+	return s.updateEscrow(ctx, id, func(tx *sql.Tx, e *Escrow) (EscrowEvent, error) {
+		if arbiter != e.Arbiter {
+			return EscrowEvent{}, ErrorNotEscrowArbiter
+		}
+		if e.Status != EscrowDisputed {
+			return EscrowEvent{}, ErrorInvalidEscrowTransition
+		}
+
+		event := EscrowEvent{Action: EscrowActionArbitrate, Actor: arbiter, Note: note}
+		var err error
+		if release {
+			event.ToStatus = EscrowReleased
+			event.TransferEntryID, err = payOutEscrow(ctx, tx, *e)
+		} else {
+			event.ToStatus = EscrowRefunded
+			event.TransferEntryID, err = refundEscrow(ctx, tx, *e)
+		}
+		return event, err
+	})
+}
+
+// ReleaseEscrow pays an approved escrow to the seller. The payment is a
+// regular transfer from the escrow account, fees included.
+func (s *WalletsService) ReleaseEscrow(ctx context.Context, id int64) (Escrow, error) {
+	return s.updateEscrow(ctx, id, func(tx *sql.Tx, e *Escrow) (EscrowEvent, error) {
+		if e.Status != EscrowApproved {
+			return EscrowEvent{}, ErrorInvalidEscrowTransition
+		}
+		entryID, err := payOutEscrow(ctx, tx, *e)
+		return EscrowEvent{Action: EscrowActionRelease, ToStatus: EscrowReleased, TransferEntryID: entryID}, err
+	})
+}
+
+// RefundEscrow returns the funds of an escrow that was not approved before
+// its deadline to the buyer.
+func (s *WalletsService) RefundEscrow(ctx context.Context, id int64) (Escrow, error) {
+	return s.updateEscrow(ctx, id, func(tx *sql.Tx, e *Escrow) (EscrowEvent, error) {
+		if e.Status != EscrowFunded {
+			return EscrowEvent{}, ErrorInvalidEscrowTransition
+		}
+		if time.Now().Before(e.Deadline) {
+			return EscrowEvent{}, ErrorDeadlineNotReached
+		}
+		entryID, err := refundEscrow(ctx, tx, *e)
+		return EscrowEvent{Action: EscrowActionRefund, ToStatus: EscrowRefunded, TransferEntryID: entryID}, err
+	})
+}
+
+// updateEscrow locks the escrow, lets change decide the next state (moving
+// funds if needed) and stores the state together with its history event.
+func (s *WalletsService) updateEscrow(ctx context.Context, id int64, change func(tx *sql.Tx, e *Escrow) (EscrowEvent, error)) (Escrow, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Escrow{}, err
+	}
+	defer tx.Rollback()
+
+	escrow, err := scanEscrow(tx.QueryRowContext(ctx, "SELECT "+escrowColumns+" FROM Escrows WHERE Id = $1 FOR UPDATE", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Escrow{}, ErrorEscrowNotFound
+	}
+	if err != nil {
+		return Escrow{}, err
+	}
+
+	event, err := change(tx, &escrow)
+	if err != nil {
+		return Escrow{}, err
+	}
+	// a single approval keeps the escrow funded
+	stays := event.Action == EscrowActionApprove && event.ToStatus == escrow.Status
+	if !stays && !canTransition(escrow.Status, event.ToStatus) {
+		return Escrow{}, ErrorInvalidEscrowTransition
+	}
+
+	event.EscrowID = escrow.ID
+	event.FromStatus = escrow.Status
+	if err = recordEscrowEvent(ctx, tx, event); err != nil {
+		return Escrow{}, err
+	}
+
+	escrow, err = scanEscrow(tx.QueryRowContext(ctx, `
+		UPDATE Escrows SET Status = $2, Buyer_Approved = $3, Seller_Approved = $4, Updated_At = now()
+		WHERE Id = $1
+		RETURNING `+escrowColumns,
+		escrow.ID, event.ToStatus, escrow.BuyerApproved, escrow.SellerApproved))
+	if err != nil {
+		return Escrow{}, err
+	}
+	return escrow, tx.Commit()
+}
+
+func payOutEscrow(ctx context.Context, tx *sql.Tx, e Escrow) (int64, error) {
+	account := EscrowAccount(e.ID)
+	sheet, err := lockBalances(ctx, tx, []walletKey{{account, e.Token}, {e.Seller, e.Token}})
+	if err != nil {
+		return 0, err
+	}
+	receipt, err := sheet.payOut(e.Token, account, e.Seller, e.Amount)
+	if err != nil {
+		return 0, err
+	}
+	if err = sheet.flush(ctx, tx); err != nil {
+		return 0, err
+	}
+	return receipt.entry.ID, nil
+}
+
+func refundEscrow(ctx context.Context, tx *sql.Tx, e Escrow) (int64, error) {
+	account := EscrowAccount(e.ID)
+	sheet, err := lockBalances(ctx, tx, []walletKey{{account, e.Token}, {e.Buyer, e.Token}})
+	if err != nil {
+		return 0, err
+	}
+	entry, err := sheet.move(ledger.KindEscrow, e.Token, account, e.Buyer, e.Amount)
+	if err != nil {
+		return 0, err
+	}
+	if err = sheet.flush(ctx, tx); err != nil {
+		return 0, err
+	}
+	return entry.ID, nil
+}
+
+func recordEscrowEvent(ctx context.Context, tx *sql.Tx, event EscrowEvent) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO Escrow_Events (Escrow_Id, Action, From_Status, To_Status, Actor, Note, Transfer_Entry_Id)
+		VALUES ($1, $2, NULLIF($3, ''), $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, 0))
+	`, event.EscrowID, event.Action, event.FromStatus, event.ToStatus, event.Actor, event.Note, event.TransferEntryID)
+	return err
+}
+
+func (s *WalletsService) GetEscrow(ctx context.Context, id int64) (Escrow, error) {
+	escrow, err := scanEscrow(s.DB.QueryRowContext(ctx, "SELECT "+escrowColumns+" FROM Escrows WHERE Id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Escrow{}, ErrorEscrowNotFound
+	}
+	return escrow, err
+}
+
+// ListEscrows returns the escrows address takes part in, newest first.
+func (s *WalletsService) ListEscrows(ctx context.Context, address string) ([]Escrow, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+escrowColumns+` FROM Escrows
+		WHERE Buyer = $1 OR Seller = $1 OR Arbiter = $1
+		ORDER BY Id DESC
+	`, address)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var escrows []Escrow
+	for rows.Next() {
+		escrow, err := scanEscrow(rows)
+		if err != nil {
+			return nil, err
+		}
+		escrows = append(escrows, escrow)
+	}
+	return escrows, rows.Err()
+}
+
+// EscrowHistory returns the state changes of an escrow, oldest first.
+func (s *WalletsService) EscrowHistory(ctx context.Context, id int64) ([]EscrowEvent, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT Id, Escrow_Id, Action, COALESCE(From_Status, ''), To_Status, COALESCE(Actor, ''), COALESCE(Note, ''),
+			COALESCE(Transfer_Entry_Id, 0), Created_At
+		FROM Escrow_Events WHERE Escrow_Id = $1 ORDER BY Id ASC
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []EscrowEvent
+	for rows.Next() {
+		var e EscrowEvent
+		if err := rows.Scan(&e.ID, &e.EscrowID, &e.Action, &e.FromStatus, &e.ToStatus, &e.Actor, &e.Note, &e.TransferEntryID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, e)
+	}
+	return history, rows.Err()
+}
@@ -0,0 +1,288 @@
+package wallets
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Hold states. Only active holds reserve funds.
+const (
+	HoldActive   = "active"
+	HoldCaptured = "captured"
+	HoldReleased = "released"
+	HoldExpired  = "expired"
+)
+
+const DefaultHoldDuration = 7 * 24 * time.Hour
+
+// Hold reserves Amount of Address's balance for a later payment to Payee,
+// like a card authorization.
+type Hold struct {
+	ID              int64
+	Address         string
+	Token           string
+	Payee           string
+	Amount          decimal.Decimal
+	CapturedAmount  decimal.Decimal
+	Reference       string
+	Status          string
+	TransferEntryID int64
+	ExpiresAt       time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+var ErrorHoldNotFound = errors.New("hold not found")
+var ErrorHoldNotActive = errors.New("hold is no longer active")
+var ErrorHoldExpired = errors.New("hold has expired")
+var ErrorCaptureExceedsHold = errors.New("capture amount exceeds the held amount")
+var ErrorInvalidExpiry = errors.New("hold expiry must be in the future")
+
+const holdColumns = `Id, Address, Token, Payee, Amount, Captured_Amount, COALESCE(Reference, ''), Status,
+	COALESCE(Transfer_Entry_Id, 0), Expires_At, Created_At, Updated_At`
+
+func scanHold(row interface{ Scan(...any) error }) (Hold, error) {
+	var h Hold
+	err := row.Scan(&h.ID, &h.Address, &h.Token, &h.Payee, &h.Amount, &h.CapturedAmount, &h.Reference, &h.Status,
+		&h.TransferEntryID, &h.ExpiresAt, &h.CreatedAt, &h.UpdatedAt)
+	return h, err
+}
+
+// CreateHold reserves amount of token on address's available balance until
+// expiresAt.
+func (s *WalletsService) CreateHold(ctx context.Context, token string, address string, payee string, amount decimal.Decimal, reference string, expiresAt time.Time) (Hold, error) {
+	if !amount.IsPositive() {
+		return Hold{}, ErrorNonPositiveAmount
+	}
+	if address == payee {
+		return Hold{}, ErrorSameAddress
+	}
+	if !expiresAt.After(time.Now()) {
+		return Hold{}, ErrorInvalidExpiry
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Hold{}, err
+	}
+	defer tx.Rollback()
+
+	key := walletKey{Address: address, Token: token}
+	sheet, err := lockBalances(ctx, tx, []walletKey{key})
+	if err != nil {
+		return Hold{}, err
+	}
+	if err = sheet.checkAmount(token, amount); err != nil {
+		return Hold{}, err
+	}
+	if err = sheet.hold(key, amount); err != nil {
+		return Hold{}, err
+	}
+	if err = sheet.flush(ctx, tx); err != nil {
+		return Hold{}, err
+	}
+
+	hold, err := scanHold(tx.QueryRowContext(ctx, `
+		INSERT INTO Holds (Address, Token, Payee, Amount, Reference, Expires_At)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+		RETURNING `+holdColumns,
+		address, token, payee, amount, reference, expiresAt))
+	if err != nil {
+		return Hold{}, err
+	}
+
+	return hold, tx.Commit()
+}
+
+// lockHold locks an active hold together with the wallets it touches.
+func lockHold(ctx context.Context, tx *sql.Tx, id int64) (Hold, *balanceSheet, error) {
+	hold, err := scanHold(tx.QueryRowContext(ctx, "SELECT "+holdColumns+" FROM Holds WHERE Id = $1 FOR UPDATE", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Hold{}, nil, ErrorHoldNotFound
+	}
+	if err != nil {
+		return Hold{}, nil, err
+	}
+	if hold.Status != HoldActive {
+		return Hold{}, nil, ErrorHoldNotActive
+	}
+
+	sheet, err := lockBalances(ctx, tx, []walletKey{{hold.Address, hold.Token}, {hold.Payee, hold.Token}})
+	if err != nil {
+		return Hold{}, nil, err
+	}
+	return hold, sheet, nil
+}
+
+// CaptureHold pays amount of the hold to its payee (all of it when amount
+// is nil) and releases the rest. The payment is a regular transfer, fees
+// included.
+func (s *WalletsService) CaptureHold(ctx context.Context, id int64, amount *decimal.Decimal) (Hold, Receipt, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Hold{}, Receipt{}, err
+	}
+	defer tx.Rollback()
+
+	hold, sheet, err := lockHold(ctx, tx, id)
+	if err != nil {
+		return Hold{}, Receipt{}, err
+	}
+	if !hold.ExpiresAt.After(time.Now()) {
+		return Hold{}, Receipt{}, ErrorHoldExpired
+	}
+
+	captured := hold.Amount
+	if amount != nil {
+		if !amount.IsPositive() {
+			return Hold{}, Receipt{}, ErrorNonPositiveAmount
+		}
+		if amount.GreaterThan(hold.Amount) {
+			return Hold{}, Receipt{}, ErrorCaptureExceedsHold
+		}
+		captured = *amount
+	}
+
+	sheet.unhold(walletKey{Address: hold.Address, Token: hold.Token}, hold.Amount)
+	receipt, err := sheet.apply(hold.Token, hold.Address, hold.Payee, captured)
+	if err != nil {
+		return Hold{}, Receipt{}, err
+	}
+	if err = sheet.flush(ctx, tx); err != nil {
+		return Hold{}, Receipt{}, err
+	}
+
+	hold, err = scanHold(tx.QueryRowContext(ctx, `
+		UPDATE Holds SET Status = $2, Captured_Amount = $3, Transfer_Entry_Id = $4, Updated_At = now()
+		WHERE Id = $1
+		RETURNING `+holdColumns,
+		id, HoldCaptured, captured, receipt.entry.ID))
+	if err != nil {
+		return Hold{}, Receipt{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return Hold{}, Receipt{}, err
+	}
+	return hold, receipt.complete(), nil
+}
+
+// ReleaseHold cancels an active hold, its funds become available again.
+func (s *WalletsService) ReleaseHold(ctx context.Context, id int64) (Hold, error) {
+	return s.endHold(ctx, id, HoldReleased)
+}
+
+func (s *WalletsService) endHold(ctx context.Context, id int64, status string) (Hold, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Hold{}, err
+	}
+	defer tx.Rollback()
+
+	hold, sheet, err := lockHold(ctx, tx, id)
+	if err != nil {
+		return Hold{}, err
+	}
+
+	sheet.unhold(walletKey{Address: hold.Address, Token: hold.Token}, hold.Amount)
+	if err = sheet.flush(ctx, tx); err != nil {
+		return Hold{}, err
+	}
+
+	hold, err = scanHold(tx.QueryRowContext(ctx, `
+		UPDATE Holds SET Status = $2, Updated_At = now() WHERE Id = $1 RETURNING `+holdColumns, id, status))
+	if err != nil {
+		return Hold{}, err
+	}
+	return hold, tx.Commit()
+}
+
+func (s *WalletsService) GetHold(ctx context.Context, id int64) (Hold, error) {
+	hold, err := scanHold(s.DB.QueryRowContext(ctx, "SELECT "+holdColumns+" FROM Holds WHERE Id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Hold{}, ErrorHoldNotFound
+	}
+	return hold, err
+}
+
+// ListHolds returns the holds on address's token balance, newest first,
+// optionally only the ones in status.
+func (s *WalletsService) ListHolds(ctx context.Context, address string, token string, status string) ([]Hold, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+holdColumns+` FROM Holds
+		WHERE Address = $1 AND Token = $2 AND ($3 = '' OR Status = $3)
+		ORDER BY Id DESC
+	`, address, token, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holds []Hold
+	for rows.Next() {
+		hold, err := scanHold(rows)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, hold)
+	}
+	return holds, rows.Err()
+}
+
+// ExpireHolds releases every active hold past its expiry and returns how
+// many it expired.
+func (s *WalletsService) ExpireHolds(ctx context.Context) (int, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT Id FROM Holds WHERE Status = $1 AND Expires_At <= now() ORDER BY Id ASC", HoldActive)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, id := range ids {
+		_, err := s.endHold(ctx, id, HoldExpired)
+		// captured or released meanwhile
+		if errors.Is(err, ErrorHoldNotActive) {
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// RunHoldExpiry expires holds every interval until ctx is done.
+func (s *WalletsService) RunHoldExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.ExpireHolds(ctx); err != nil {
+				log.Println("hold expiry fail:", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package wallets
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"btp_tokens/internal/ledger"
+
+	"github.com/shopspring/decimal"
+)
+
+// HTLC states.
+const (
+	HtlcLocked   = "locked"
+	HtlcClaimed  = "claimed"
+	HtlcRefunded = "refunded"
+)
+
+// Htlc is a hash time-locked transfer: Amount is locked until Recipient
+// claims it with the preimage of Hashlock before TimeoutAt, after which
+// Sender can take it back. Preimage is revealed once claimed, so the other
+// side of an atomic swap can use it.
+type Htlc struct {
+	ID        int64
+	Token     string
+	Sender    string
+	Recipient string
+	Amount    decimal.Decimal
+	// Hashlock is the hex encoded SHA-256 of the preimage
+	Hashlock        string
+	Preimage        string
+	Status          string
+	TransferEntryID int64
+	TimeoutAt       time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+var ErrorHtlcNotFound = errors.New("hash time-locked transfer not found")
+var ErrorHtlcNotLocked = errors.New("hash time-locked transfer was already claimed or refunded")
+var ErrorInvalidHashlock = errors.New("hashlock must be a hex encoded SHA-256 hash")
+var ErrorInvalidPreimage = errors.New("preimage does not match the hashlock")
+var ErrorHtlcTimedOut = errors.New("hash time-locked transfer has timed out")
+var ErrorHtlcNotTimedOut = errors.New("hash time-locked transfer has not timed out yet")
+var ErrorInvalidTimeout = errors.New("timeout must be in the future")
+
+// HtlcAccount is the system account holding the funds of a locked transfer.
+func HtlcAccount(id int64) string {
+	return "htlc:" + strconv.FormatInt(id, 10)
+}
+
+// HashPreimage returns the hashlock of a hex encoded preimage.
+func HashPreimage(preimage string) (string, error) {
+	raw, err := hex.DecodeString(preimage)
+	if err != nil {
+		return "", ErrorInvalidPreimage
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+const htlcColumns = `Id, Token, Sender, Recipient, Amount, Hashlock, COALESCE(Preimage, ''), Status,
+	COALESCE(Transfer_Entry_Id, 0), Timeout_At, Created_At, Updated_At`
+
+func scanHtlc(row interface{ Scan(...any) error }) (Htlc, error) {
+	var h Htlc
+	err := row.Scan(&h.ID, &h.Token, &h.Sender, &h.Recipient, &h.Amount, &h.Hashlock, &h.Preimage, &h.Status,
+		&h.TransferEntryID, &h.TimeoutAt, &h.CreatedAt, &h.UpdatedAt)
+	return h, err
+}
+
+// LockHtlc moves amount from sender into a new hash time-locked transfer.
+func (s *WalletsService) LockHtlc(ctx context.Context, token string, sender string, recipient string, amount decimal.Decimal, hashlock string, timeoutAt time.Time) (Htlc, error) {
+	if !amount.IsPositive() {
+		return Htlc{}, ErrorNonPositiveAmount
+	}
+	if sender == recipient {
+		return Htlc{}, ErrorSameAddress
+	}
+	if raw, err := hex.DecodeString(hashlock); err != nil || len(raw) != sha256.Size {
+		return Htlc{}, ErrorInvalidHashlock
+	}
+	if !timeoutAt.After(time.Now()) {
+		return Htlc{}, ErrorInvalidTimeout
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Htlc{}, err
+	}
+	defer tx.Rollback()
+
+	htlc, err := scanHtlc(tx.QueryRowContext(ctx, `
+		INSERT INTO Htlcs (Token, Sender, Recipient, Amount, Hashlock, Timeout_At)
+		VALUES ($1, $2, $3, $4, lower($5), $6)
+		RETURNING `+htlcColumns,
+		token, sender, recipient, amount, hashlock, timeoutAt))
+	if err != nil {
+		return Htlc{}, err
+	}
+
+	account := HtlcAccount(htlc.ID)
+	sheet, err := lockBalances(ctx, tx, []walletKey{{sender, token}, {account, token}})
+	if err != nil {
+		return Htlc{}, err
+	}
+	// locking counts against the sender's transfer limits and is checked by
+	// the policies as a transfer to the recipient
+	if _, err = sheet.fund(ledger.KindHtlc, token, account, sender, recipient, amount); err != nil {
+		return Htlc{}, err
+	}
+	if err = sheet.flush(ctx, tx); err != nil {
+		return Htlc{}, err
+	}
+
+	return htlc, tx.Commit()
+}
+
+// ClaimHtlc pays a locked transfer to its recipient when preimage matches
+// the hashlock and the timeout has not passed. The payment is a regular
+// transfer, fees included.
+func (s *WalletsService) ClaimHtlc(ctx context.Context, id int64, preimage string) (Htlc, error) {
+	hash, err := HashPreimage(preimage)
+	if err != nil {
+		return Htlc{}, err
+	}
+
+	return s.settleHtlc(ctx, id, preimage, func(tx *sql.Tx, h Htlc) (string, int64, error) {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(h.Hashlock)) != 1 {
+			return "", 0, ErrorInvalidPreimage
+		}
+		if !time.Now().Before(h.TimeoutAt) {
+			return "", 0, ErrorHtlcTimedOut
+		}
+
+		account := HtlcAccount(h.ID)
+		sheet, err := lockBalances(ctx, tx, []walletKey{{account, h.Token}, {h.Recipient, h.Token}})
+		if err != nil {
+			return "", 0, err
+		}
+		receipt, err := sheet.payOut(h.Token, account, h.Recipient, h.Amount)
+		if err != nil {
+			return "", 0, err
+		}
+		if err = sheet.flush(ctx, tx); err != nil {
+			return "", 0, err
+		}
+		return HtlcClaimed, receipt.entry.ID, nil
+	})
+}
+
+// RefundHtlc returns a locked transfer to its sender once it timed out.
+func (s *WalletsService) RefundHtlc(ctx context.Context, id int64) (Htlc, error) {
+	return s.settleHtlc(ctx, id, "", func(tx *sql.Tx, h Htlc) (string, int64, error) {
+		if time.Now().Before(h.TimeoutAt) {
+			return "", 0, ErrorHtlcNotTimedOut
+		}
+
+		account := HtlcAccount(h.ID)
+		sheet, err := lockBalances(ctx, tx, []walletKey{{account, h.Token}, {h.Sender, h.Token}})
+		if err != nil {
+			return "", 0, err
+		}
+		entry, err := sheet.move(ledger.KindHtlc, h.Token, account, h.Sender, h.Amount)
+		if err != nil {
+			return "", 0, err
+		}
+		if err = sheet.flush(ctx, tx); err != nil {
+			return "", 0, err
+		}
+		return HtlcRefunded, entry.ID, nil
+	})
+}
+
+// settleHtlc locks a transfer that is still locked, so a claim and a refund
+// racing each other cannot both move its funds, and stores the outcome of
+// settle with the revealed preimage.
+func (s *WalletsService) settleHtlc(ctx context.Context, id int64, preimage string, settle func(tx *sql.Tx, h Htlc) (string, int64, error)) (Htlc, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Htlc{}, err
+	}
+	defer tx.Rollback()
+
+	htlc, err := scanHtlc(tx.QueryRowContext(ctx, "SELECT "+htlcColumns+" FROM Htlcs WHERE Id = $1 FOR UPDATE", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Htlc{}, ErrorHtlcNotFound
+	}
+	if err != nil {
+		return Htlc{}, err
+	}
+	if htlc.Status != HtlcLocked {
+		return Htlc{}, ErrorHtlcNotLocked
+	}
+
+	status, entryID, err := settle(tx, htlc)
+	if err != nil {
+		return Htlc{}, err
+	}
+
+	htlc, err = scanHtlc(tx.QueryRowContext(ctx, `
+		UPDATE Htlcs SET Status = $2, Preimage = NULLIF(lower($3), ''), Transfer_Entry_Id = $4, Updated_At = now()
+		WHERE Id = $1
+		RETURNING `+htlcColumns,
+		id, status, preimage, entryID))
+	if err != nil {
+		return Htlc{}, err
+	}
+	return htlc, tx.Commit()
+}
+
+func (s *WalletsService) GetHtlc(ctx context.Context, id int64) (Htlc, error) {
+	htlc, err := scanHtlc(s.DB.QueryRowContext(ctx, "SELECT "+htlcColumns+" FROM Htlcs WHERE Id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Htlc{}, ErrorHtlcNotFound
+	}
+	return htlc, err
+}
+
+// ListHtlcs returns the hash time-locked transfers address sent or
+// receives, newest first.
+func (s *WalletsService) ListHtlcs(ctx context.Context, address string) ([]Htlc, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+htlcColumns+` FROM Htlcs
+		WHERE Sender = $1 OR Recipient = $1
+		ORDER BY Id DESC
+	`, address)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var htlcs []Htlc
+	for rows.Next() {
+		htlc, err := scanHtlc(rows)
+		if err != nil {
+			return nil, err
+		}
+		htlcs = append(htlcs, htlc)
+	}
+	return htlcs, rows.Err()
+}
@@ -0,0 +1,513 @@
+package wallets
+
+import (
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"btp_tokens/internal/tokens"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// Multisig proposal states. Pending proposals past their expiry are
+// reported as expired.
+const (
+	ProposalPending   = "pending"
+	ProposalExecuted  = "executed"
+	ProposalCancelled = "cancelled"
+	ProposalExpired   = "expired"
+)
+
+// MultisigWallet is an address that only sends the transfers approved by
+// Threshold of its Signers.
+type MultisigWallet struct {
+	Address   string
+	Threshold int
+	Signers   []MultisigSigner
+	CreatedAt time.Time
+}
+
+// MultisigSigner approves proposals with signatures made by the ed25519 key
+// PublicKey, hex encoded.
+type MultisigSigner struct {
+	Address   string
+	PublicKey string
+}
+
+// MultisigProposal is a transfer out of a multisig wallet waiting for the
+// approvals of its signers. Error explains why the last execution failed.
+type MultisigProposal struct {
+	ID              int64
+	Wallet          string
+	Token           string
+	ToAddress       string
+	Amount          decimal.Decimal
+	Proposer        string
+	Status          string
+	Threshold       int
+	Approvals       []MultisigApproval
+	TransferEntryID int64
+	Error           string
+	ExpiresAt       time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+type MultisigApproval struct {
+	Signer    string
+	CreatedAt time.Time
+}
+
+var ErrorMultisigRequired = errors.New("multisig wallets only send approved proposals")
+var ErrorMultisigNotFound = errors.New("multisig wallet not found")
+var ErrorMultisigExists = errors.New("address is already a multisig wallet")
+var ErrorInvalidMultisig = errors.New("invalid multisig wallet")
+var ErrorProposalNotFound = errors.New("multisig proposal not found")
+var ErrorProposalNotPending = errors.New("multisig proposal is no longer pending")
+var ErrorNotSigner = errors.New("address is not a signer of the wallet")
+var ErrorInvalidSignature = errors.New("invalid signature")
+var ErrorAlreadyApproved = errors.New("signer already approved the proposal")
+var ErrorInvalidProposalExpiry = errors.New("proposal expiry must be in the future")
+var ErrorThresholdNotMet = errors.New("multisig proposal does not have enough approvals")
+var ErrorSignatureUsed = errors.New("signature was already used for another proposal")
+
+// ProposalMessage is what the proposer signs to propose sending amount of
+// token from wallet to toAddress until expiresAt. The expiry makes each
+// proposal's signature unique, so it cannot be replayed.
+func ProposalMessage(wallet string, token string, toAddress string, amount decimal.Decimal, expiresAt time.Time) string {
+	return fmt.Sprintf("propose multisig transfer: send %s %s from %s to %s, expires %d", amount, token, wallet, toAddress, expiresAt.Unix())
+}
+
+// ApprovalMessage is what signers sign to approve the proposal.
+func (p MultisigProposal) ApprovalMessage() string {
+	return fmt.Sprintf("approve multisig proposal %d: send %s %s from %s to %s", p.ID, p.Amount, p.Token, p.Wallet, p.ToAddress)
+}
+
+// CancelMessage is what signers sign to cancel the proposal.
+func (p MultisigProposal) CancelMessage() string {
+	return fmt.Sprintf("cancel multisig proposal %d", p.ID)
+}
+
+func parsePublicKey(publicKey string) (ed25519.PublicKey, error) {
+	key, err := hex.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, ErrorInvalidMultisig
+	}
+	return key, nil
+}
+
+func verifySignature(publicKey string, message string, signature string) error {
+	key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+	sig, err := hex.DecodeString(signature)
+	if err != nil || !ed25519.Verify(key, []byte(message), sig) {
+		return ErrorInvalidSignature
+	}
+	return nil
+}
+
+// loadMultisig returns the multisig wallets among addresses.
+func loadMultisig(ctx context.Context, tx *sql.Tx, addresses []string) (map[string]bool, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT Address FROM Multisig_Wallets WHERE Address = ANY($1)", pq.Array(addresses))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	multisig := make(map[string]bool)
+	for rows.Next() {
+		var address string
+		if err := rows.Scan(&address); err != nil {
+			return nil, err
+		}
+		multisig[address] = true
+	}
+	return multisig, rows.Err()
+}
+
+// CreateMultisigWallet turns an address into a multisig wallet, from then on
+// it only sends through proposals.
+func (s *WalletsService) CreateMultisigWallet(ctx context.Context, address string, threshold int, signers []MultisigSigner) (MultisigWallet, error) {
+	if strings.TrimSpace(address) == "" || threshold < 1 || threshold > len(signers) {
+		return MultisigWallet{}, ErrorInvalidMultisig
+	}
+	seen := make(map[string]bool)
+	for _, signer := range signers {
+		if signer.Address == address || seen[signer.Address] {
+			return MultisigWallet{}, ErrorInvalidMultisig
+		}
+		if _, err := parsePublicKey(signer.PublicKey); err != nil {
+			return MultisigWallet{}, err
+		}
+		seen[signer.Address] = true
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return MultisigWallet{}, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO Multisig_Wallets (Address, Threshold) VALUES ($1, $2) ON CONFLICT (Address) DO NOTHING
+	`, address, threshold)
+	if err != nil {
+		return MultisigWallet{}, err
+	}
+	if created, _ := result.RowsAffected(); created == 0 {
+		return MultisigWallet{}, ErrorMultisigExists
+	}
+	for _, signer := range signers {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO Multisig_Signers (Wallet, Address, Public_Key) VALUES ($1, $2, $3)
+		`, address, signer.Address, strings.ToLower(signer.PublicKey))
+		if err != nil {
+			return MultisigWallet{}, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return MultisigWallet{}, err
+	}
+	return s.GetMultisigWallet(ctx, address)
+}
+
+func (s *WalletsService) GetMultisigWallet(ctx context.Context, address string) (MultisigWallet, error) {
+	wallet := MultisigWallet{Address: address}
+	err := s.DB.QueryRowContext(ctx, "SELECT Threshold, Created_At FROM Multisig_Wallets WHERE Address = $1", address).
+		Scan(&wallet.Threshold, &wallet.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return MultisigWallet{}, ErrorMultisigNotFound
+	}
+	if err != nil {
+		return MultisigWallet{}, err
+	}
+
+	rows, err := s.DB.QueryContext(ctx, "SELECT Address, Public_Key FROM Multisig_Signers WHERE Wallet = $1 ORDER BY Address ASC", address)
+	if err != nil {
+		return MultisigWallet{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var signer MultisigSigner
+		if err := rows.Scan(&signer.Address, &signer.PublicKey); err != nil {
+			return MultisigWallet{}, err
+		}
+		wallet.Signers = append(wallet.Signers, signer)
+	}
+	return wallet, rows.Err()
+}
+
+type rowQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// signerKey returns the public key of signer on wallet.
+func signerKey(ctx context.Context, q rowQueryer, wallet string, signer string) (string, error) {
+	var publicKey string
+	err := q.QueryRowContext(ctx, "SELECT Public_Key FROM Multisig_Signers WHERE Wallet = $1 AND Address = $2", wallet, signer).Scan(&publicKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrorNotSigner
+	}
+	return publicKey, err
+}
+
+const proposalColumns = `p.Id, p.Wallet, p.Token, p.To_Address, p.Amount, p.Proposer,
+	CASE WHEN p.Status = 'pending' AND p.Expires_At <= now() THEN 'expired' ELSE p.Status END,
+	w.Threshold, COALESCE(p.Transfer_Entry_Id, 0), COALESCE(p.Error, ''), p.Expires_At, p.Created_At, p.Updated_At`
+
+func scanProposal(row interface{ Scan(...any) error }) (MultisigProposal, error) {
+	var p MultisigProposal
+	err := row.Scan(&p.ID, &p.Wallet, &p.Token, &p.ToAddress, &p.Amount, &p.Proposer, &p.Status,
+		&p.Threshold, &p.TransferEntryID, &p.Error, &p.ExpiresAt, &p.CreatedAt, &p.UpdatedAt)
+	return p, err
+}
+
+// ProposeMultisigTransfer proposes sending amount of token from wallet
+// until expiresAt. The proposer has to be one of its signers and signature
+// signs the ProposalMessage; it counts as the proposer's approval, so with
+// a threshold of one the transfer is sent right away.
+func (s *WalletsService) ProposeMultisigTransfer(ctx context.Context, wallet string, token string, toAddress string, amount decimal.Decimal, proposer string, expiresAt time.Time, signature string) (MultisigProposal, error) {
+	if !amount.IsPositive() {
+		return MultisigProposal{}, ErrorNonPositiveAmount
+	}
+	if wallet == toAddress {
+		return MultisigProposal{}, ErrorSameAddress
+	}
+	if !expiresAt.After(time.Now()) {
+		return MultisigProposal{}, ErrorInvalidProposalExpiry
+	}
+	if _, err := s.GetMultisigWallet(ctx, wallet); err != nil {
+		return MultisigProposal{}, err
+	}
+	t, err := tokens.Get(ctx, s.DB, token)
+	if err != nil {
+		return MultisigProposal{}, err
+	}
+	if err := t.CheckPrecision(amount); err != nil {
+		return MultisigProposal{}, err
+	}
+	publicKey, err := signerKey(ctx, s.DB, wallet, proposer)
+	if err != nil {
+		return MultisigProposal{}, err
+	}
+	if err := verifySignature(publicKey, ProposalMessage(wallet, token, toAddress, amount, expiresAt), signature); err != nil {
+		return MultisigProposal{}, err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return MultisigProposal{}, err
+	}
+	defer tx.Rollback()
+
+	p, err := scanProposal(tx.QueryRowContext(ctx, `
+		WITH p AS (
+			INSERT INTO Multisig_Proposals (Wallet, Token, To_Address, Amount, Proposer, Expires_At, Signature)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING *
+		)
+		SELECT `+proposalColumns+` FROM p JOIN Multisig_Wallets w ON w.Address = p.Wallet
+	`, wallet, token, toAddress, amount, proposer, expiresAt, signature))
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return MultisigProposal{}, ErrorSignatureUsed
+	}
+	if err != nil {
+		return MultisigProposal{}, err
+	}
+	if err := approveProposal(ctx, tx, &p, proposer, signature); err != nil {
+		return MultisigProposal{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return MultisigProposal{}, err
+	}
+	return s.GetMultisigProposal(ctx, p.ID)
+}
+
+// ApproveMultisigTransfer records the approval of signer, signature signs
+// the proposal's ApprovalMessage. The approval that meets the threshold
+// sends the transfer; when it cannot be sent, for example for lack of
+// balance, the approval is kept, the reason is recorded in Error and the
+// proposal can be executed later.
+func (s *WalletsService) ApproveMultisigTransfer(ctx context.Context, id int64, signer string, signature string) (MultisigProposal, error) {
+	err := s.updateProposal(ctx, id, func(tx *sql.Tx, p *MultisigProposal) error {
+		publicKey, err := signerKey(ctx, tx, p.Wallet, signer)
+		if err != nil {
+			return err
+		}
+		if err := verifySignature(publicKey, p.ApprovalMessage(), signature); err != nil {
+			return err
+		}
+		return approveProposal(ctx, tx, p, signer, signature)
+	})
+	if err != nil {
+		return MultisigProposal{}, err
+	}
+	return s.GetMultisigProposal(ctx, id)
+}
+
+// approveProposal records the verified approval of signer and sends the
+// transfer once the threshold is met.
+func approveProposal(ctx context.Context, tx *sql.Tx, p *MultisigProposal, signer string, signature string) error {
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO Multisig_Approvals (Proposal_Id, Signer, Signature) VALUES ($1, $2, $3)
+		ON CONFLICT (Proposal_Id, Signer) DO NOTHING
+	`, p.ID, signer, signature)
+	if err != nil {
+		return err
+	}
+	if approved, _ := result.RowsAffected(); approved == 0 {
+		return ErrorAlreadyApproved
+	}
+
+	var approvals int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM Multisig_Approvals WHERE Proposal_Id = $1", p.ID).Scan(&approvals); err != nil {
+		return err
+	}
+	if approvals < p.Threshold {
+		return nil
+	}
+
+	// savepoint, a failed transfer must not abort the approval
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT execute_proposal"); err != nil {
+		return err
+	}
+	failed := executeProposal(ctx, tx, p)
+	if failed == nil {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT execute_proposal"); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE Multisig_Proposals SET Error = $2, Updated_At = now() WHERE Id = $1", p.ID, failed.Error())
+	return err
+}
+
+// ExecuteMultisigTransfer sends the transfer of a pending proposal that has
+// enough approvals, after an earlier execution failed.
+func (s *WalletsService) ExecuteMultisigTransfer(ctx context.Context, id int64) (MultisigProposal, error) {
+	err := s.updateProposal(ctx, id, func(tx *sql.Tx, p *MultisigProposal) error {
+		var approvals int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM Multisig_Approvals WHERE Proposal_Id = $1", id).Scan(&approvals); err != nil {
+			return err
+		}
+		if approvals < p.Threshold {
+			return ErrorThresholdNotMet
+		}
+		return executeProposal(ctx, tx, p)
+	})
+	if err != nil {
+		return MultisigProposal{}, err
+	}
+	return s.GetMultisigProposal(ctx, id)
+}
+
+// CancelMultisigTransfer cancels a pending proposal, signature of signer
+// signs the proposal's CancelMessage.
+func (s *WalletsService) CancelMultisigTransfer(ctx context.Context, id int64, signer string, signature string) (MultisigProposal, error) {
+	err := s.updateProposal(ctx, id, func(tx *sql.Tx, p *MultisigProposal) error {
+		publicKey, err := signerKey(ctx, tx, p.Wallet, signer)
+		if err != nil {
+			return err
+		}
+		if err := verifySignature(publicKey, p.CancelMessage(), signature); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE Multisig_Proposals SET Status = $2, Updated_At = now() WHERE Id = $1", id, ProposalCancelled)
+		return err
+	})
+	if err != nil {
+		return MultisigProposal{}, err
+	}
+	return s.GetMultisigProposal(ctx, id)
+}
+
+// updateProposal runs change on a locked pending proposal and commits.
+func (s *WalletsService) updateProposal(ctx context.Context, id int64, change func(tx *sql.Tx, p *MultisigProposal) error) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	p, err := scanProposal(tx.QueryRowContext(ctx, `
+		SELECT `+proposalColumns+`
+		FROM Multisig_Proposals p JOIN Multisig_Wallets w ON w.Address = p.Wallet
+		WHERE p.Id = $1
+		FOR UPDATE OF p
+	`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrorProposalNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if p.Status != ProposalPending {
+		return ErrorProposalNotPending
+	}
+
+	if err := change(tx, &p); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// executeProposal sends the proposal's transfer as a regular transfer of the
+// wallet, fees, limits and policies included.
+func executeProposal(ctx context.Context, tx *sql.Tx, p *MultisigProposal) error {
+	sheet, err := lockBalances(ctx, tx, []walletKey{{p.Wallet, p.Token}, {p.ToAddress, p.Token}})
+	if err != nil {
+		return err
+	}
+	sheet.approved = p.Wallet
+
+	receipt, err := sheet.apply(p.Token, p.Wallet, p.ToAddress, p.Amount)
+	if err != nil {
+		return err
+	}
+	if err := sheet.flush(ctx, tx); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE Multisig_Proposals SET Status = $2, Transfer_Entry_Id = $3, Error = NULL, Updated_At = now() WHERE Id = $1
+	`, p.ID, ProposalExecuted, receipt.entry.ID)
+	return err
+}
+
+func (s *WalletsService) GetMultisigProposal(ctx context.Context, id int64) (MultisigProposal, error) {
+	proposals, err := s.queryProposals(ctx, "p.Id = $1", id)
+	if err != nil {
+		return MultisigProposal{}, err
+	}
+	if len(proposals) == 0 {
+		return MultisigProposal{}, ErrorProposalNotFound
+	}
+	return proposals[0], nil
+}
+
+// ListMultisigProposals returns the proposals of wallet, newest first,
+// optionally only those in status.
+func (s *WalletsService) ListMultisigProposals(ctx context.Context, wallet string, status string) ([]MultisigProposal, error) {
+	return s.queryProposals(ctx, `p.Wallet = $1 AND ($2 = '' OR
+		CASE WHEN p.Status = 'pending' AND p.Expires_At <= now() THEN 'expired' ELSE p.Status END = $2)`, wallet, status)
+}
+
+func (s *WalletsService) queryProposals(ctx context.Context, where string, args ...any) ([]MultisigProposal, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+proposalColumns+`
+		FROM Multisig_Proposals p JOIN Multisig_Wallets w ON w.Address = p.Wallet
+		WHERE `+where+`
+		ORDER BY p.Id DESC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	var proposals []MultisigProposal
+	index := make(map[int64]int)
+	for rows.Next() {
+		p, err := scanProposal(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[p.ID] = len(proposals)
+		proposals = append(proposals, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(proposals) == 0 {
+		return proposals, err
+	}
+
+	ids := make([]int64, 0, len(proposals))
+	for _, p := range proposals {
+		ids = append(ids, p.ID)
+	}
+	rows, err = s.DB.QueryContext(ctx, `
+		SELECT Proposal_Id, Signer, Created_At FROM Multisig_Approvals WHERE Proposal_Id = ANY($1) ORDER BY Created_At ASC, Signer ASC
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var approval MultisigApproval
+		if err := rows.Scan(&id, &approval.Signer, &approval.CreatedAt); err != nil {
+			return nil, err
+		}
+		p := &proposals[index[id]]
+		p.Approvals = append(p.Approvals, approval)
+	}
+	return proposals, rows.Err()
+}
@@ -0,0 +1,310 @@
+package wallets
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"btp_tokens/internal/tokens"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// Payment request states. The expired state is not stored: an open request
+// is reported as expired once it is past its expiry.
+const (
+	PaymentRequestOpen      = "open"
+	PaymentRequestPaid      = "paid"
+	PaymentRequestExpired   = "expired"
+	PaymentRequestCancelled = "cancelled"
+)
+
+const DefaultPaymentRequestDuration = 30 * 24 * time.Hour
+
+// PaymentRequest asks for Amount of Token to be paid to Payee, in one or
+// several payments, before ExpiresAt. Reference identifies it to payers.
+type PaymentRequest struct {
+	ID         int64
+	Token      string
+	Payee      string
+	Amount     decimal.Decimal
+	PaidAmount decimal.Decimal
+	Memo       string
+	Reference  string
+	Status     string
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// Outstanding is what is left to pay.
+func (r PaymentRequest) Outstanding() decimal.Decimal {
+	return r.Amount.Sub(r.PaidAmount)
+}
+
+type Payment struct {
+	Payer           string
+	Amount          decimal.Decimal
+	TransferEntryID int64
+	CreatedAt       time.Time
+}
+
+var ErrorPaymentRequestNotFound = errors.New("payment request not found")
+var ErrorPaymentRequestNotOpen = errors.New("payment request is no longer open")
+var ErrorPaymentRequestExpired = errors.New("payment request has expired")
+var ErrorPaymentExceedsRequest = errors.New("payment exceeds the outstanding amount of the request")
+var ErrorReferenceExists = errors.New("reference is already used by another payment request")
+
+const paymentRequestStatus = `CASE WHEN Status = 'open' AND Expires_At <= now() THEN 'expired' ELSE Status END`
+
+const paymentRequestColumns = `Id, Token, Payee, Amount, Paid_Amount, COALESCE(Memo, ''), Reference,
+	` + paymentRequestStatus + `, Expires_At, Created_At, Updated_At`
+
+func scanPaymentRequest(row interface{ Scan(...any) error }) (PaymentRequest, error) {
+	var r PaymentRequest
+	err := row.Scan(&r.ID, &r.Token, &r.Payee, &r.Amount, &r.PaidAmount, &r.Memo, &r.Reference,
+		&r.Status, &r.ExpiresAt, &r.CreatedAt, &r.UpdatedAt)
+	return r, err
+}
+
+func newReference() (string, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return "PR-" + strings.ToUpper(hex.EncodeToString(b[:])), nil
+}
+
+// CreatePaymentRequest opens a request for amount of token to payee. A
+// reference is generated when none is given.
+func (s *WalletsService) CreatePaymentRequest(ctx context.Context, token string, payee string, amount decimal.Decimal, memo string, reference string, expiresAt time.Time) (PaymentRequest, error) {
+	if !amount.IsPositive() {
+		return PaymentRequest{}, ErrorNonPositiveAmount
+	}
+	if !expiresAt.After(time.Now()) {
+		return PaymentRequest{}, ErrorInvalidExpiry
+	}
+	t, err := tokens.Get(ctx, s.DB, token)
+	if err != nil {
+		return PaymentRequest{}, err
+	}
+	if err = t.CheckPrecision(amount); err != nil {
+		return PaymentRequest{}, err
+	}
+	if reference == "" {
+		if reference, err = newReference(); err != nil {
+			return PaymentRequest{}, err
+		}
+	}
+
+	request, err := scanPaymentRequest(s.DB.QueryRowContext(ctx, `
+		INSERT INTO Payment_Requests (Token, Payee, Amount, Memo, Reference, Expires_At)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+		RETURNING `+paymentRequestColumns,
+		token, payee, amount, memo, reference, expiresAt))
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return PaymentRequest{}, ErrorReferenceExists
+	}
+	return request, err
+}
+
+// PayRequest pays amount of the request from payer, all that is outstanding
+// when amount is nil, as a regular transfer. The fee is charged to the payer
+// on top of amount, so the payee gets amount and the request is paid once
+// the payments add up to its amount.
+func (s *WalletsService) PayRequest(ctx context.Context, reference string, payer string, amount *decimal.Decimal) (PaymentRequest, Receipt, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return PaymentRequest{}, Receipt{}, err
+	}
+	defer tx.Rollback()
+
+	request, err := scanPaymentRequest(tx.QueryRowContext(ctx, "SELECT "+paymentRequestColumns+" FROM Payment_Requests WHERE Reference = $1 FOR UPDATE", reference))
+	if errors.Is(err, sql.ErrNoRows) {
+		return PaymentRequest{}, Receipt{}, ErrorPaymentRequestNotFound
+	}
+	if err != nil {
+		return PaymentRequest{}, Receipt{}, err
+	}
+	if request.Status == PaymentRequestExpired {
+		return PaymentRequest{}, Receipt{}, ErrorPaymentRequestExpired
+	}
+	if request.Status != PaymentRequestOpen {
+		return PaymentRequest{}, Receipt{}, ErrorPaymentRequestNotOpen
+	}
+
+	paid := request.Outstanding()
+	if amount != nil {
+		if !amount.IsPositive() {
+			return PaymentRequest{}, Receipt{}, ErrorNonPositiveAmount
+		}
+		if amount.GreaterThan(paid) {
+			return PaymentRequest{}, Receipt{}, ErrorPaymentExceedsRequest
+		}
+		paid = *amount
+	}
+
+	sheet, err := lockBalances(ctx, tx, []walletKey{{payer, request.Token}, {request.Payee, request.Token}})
+	if err != nil {
+		return PaymentRequest{}, Receipt{}, err
+	}
+	receipt, err := sheet.applyFeeOnTop(request.Token, payer, request.Payee, paid)
+	if err != nil {
+		return PaymentRequest{}, Receipt{}, err
+	}
+	if err = sheet.flush(ctx, tx); err != nil {
+		return PaymentRequest{}, Receipt{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO Payment_Request_Payments (Request_Id, Payer, Amount, Transfer_Entry_Id) VALUES ($1, $2, $3, $4)
+	`, request.ID, payer, paid, receipt.entry.ID)
+	if err != nil {
+		return PaymentRequest{}, Receipt{}, err
+	}
+
+	request, err = scanPaymentRequest(tx.QueryRowContext(ctx, `
+		UPDATE Payment_Requests
+		SET Paid_Amount = Paid_Amount + $2,
+			Status = CASE WHEN Paid_Amount + $2 = Amount THEN $3 ELSE Status END,
+			Updated_At = now()
+		WHERE Id = $1
+		RETURNING `+paymentRequestColumns,
+		request.ID, paid, PaymentRequestPaid))
+	if err != nil {
+		return PaymentRequest{}, Receipt{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return PaymentRequest{}, Receipt{}, err
+	}
+	return request, receipt.complete(), nil
+}
+
+// lockRequestPaidBy locks the payment request the transfer entryID paid, if
+// it paid one. found is false for other transfers.
+func lockRequestPaidBy(ctx context.Context, tx *sql.Tx, entryID int64) (id int64, found bool, err error) {
+	err = tx.QueryRowContext(ctx, `
+		SELECT r.Id FROM Payment_Requests r
+		JOIN Payment_Request_Payments p ON p.Request_Id = r.Id
+		WHERE p.Transfer_Entry_Id = $1
+		FOR UPDATE OF r
+	`, entryID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return id, err == nil, err
+}
+
+// refundRequest takes amount reversed from a payment off the paid amount of
+// request id. A paid request is open again for what is outstanding, a
+// cancelled one stays cancelled.
+func refundRequest(ctx context.Context, tx *sql.Tx, id int64, amount decimal.Decimal) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE Payment_Requests
+		SET Paid_Amount = Paid_Amount - $2,
+			Status = CASE WHEN Status = $3 THEN $4 ELSE Status END,
+			Updated_At = now()
+		WHERE Id = $1
+	`, id, amount, PaymentRequestPaid, PaymentRequestOpen)
+	return err
+}
+
+// CancelPaymentRequest closes an open request. Payments already made are
+// not refunded.
+func (s *WalletsService) CancelPaymentRequest(ctx context.Context, id int64) (PaymentRequest, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return PaymentRequest{}, err
+	}
+	defer tx.Rollback()
+
+	request, err := scanPaymentRequest(tx.QueryRowContext(ctx, "SELECT "+paymentRequestColumns+" FROM Payment_Requests WHERE Id = $1 FOR UPDATE", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return PaymentRequest{}, ErrorPaymentRequestNotFound
+	}
+	if err != nil {
+		return PaymentRequest{}, err
+	}
+	if request.Status != PaymentRequestOpen {
+		return PaymentRequest{}, ErrorPaymentRequestNotOpen
+	}
+
+	request, err = scanPaymentRequest(tx.QueryRowContext(ctx, `
+		UPDATE Payment_Requests SET Status = $2, Updated_At = now() WHERE Id = $1 RETURNING `+paymentRequestColumns,
+		id, PaymentRequestCancelled))
+	if err != nil {
+		return PaymentRequest{}, err
+	}
+	return request, tx.Commit()
+}
+
+func (s *WalletsService) GetPaymentRequest(ctx context.Context, id int64) (PaymentRequest, error) {
+	request, err := scanPaymentRequest(s.DB.QueryRowContext(ctx, "SELECT "+paymentRequestColumns+" FROM Payment_Requests WHERE Id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return PaymentRequest{}, ErrorPaymentRequestNotFound
+	}
+	return request, err
+}
+
+func (s *WalletsService) GetPaymentRequestByReference(ctx context.Context, reference string) (PaymentRequest, error) {
+	request, err := scanPaymentRequest(s.DB.QueryRowContext(ctx, "SELECT "+paymentRequestColumns+" FROM Payment_Requests WHERE Reference = $1", reference))
+	if errors.Is(err, sql.ErrNoRows) {
+		return PaymentRequest{}, ErrorPaymentRequestNotFound
+	}
+	return request, err
+}
+
+// ListPaymentRequests returns the requests of payee, newest first,
+// optionally only the ones in status.
+func (s *WalletsService) ListPaymentRequests(ctx context.Context, payee string, status string, limit int) ([]PaymentRequest, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+paymentRequestColumns+` FROM Payment_Requests
+		WHERE Payee = $1 AND ($2 = '' OR `+paymentRequestStatus+` = $2)
+		ORDER BY Id DESC
+		LIMIT $3
+	`, payee, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []PaymentRequest
+	for rows.Next() {
+		request, err := scanPaymentRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
+
+// Payments returns the payments made to a request, oldest first.
+func (s *WalletsService) Payments(ctx context.Context, requestID int64) ([]Payment, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT Payer, Amount, Transfer_Entry_Id, Created_At FROM Payment_Request_Payments
+		WHERE Request_Id = $1
+		ORDER BY Id ASC
+	`, requestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []Payment
+	for rows.Next() {
+		var p Payment
+		if err := rows.Scan(&p.Payer, &p.Amount, &p.TransferEntryID, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+	return payments, rows.Err()
+}
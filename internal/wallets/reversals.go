@@ -0,0 +1,140 @@
+package wallets
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"btp_tokens/internal/ledger"
+
+	"github.com/shopspring/decimal"
+)
+
+// Reversal links a transfer to the compensating entry that paid (part of)
+// it back to the sender. The original entry is never changed.
+type Reversal struct {
+	ID              int64
+	TransferEntryID int64
+	ReversalEntryID int64
+	Amount          decimal.Decimal
+	Reason          string
+	Operator        string
+	CreatedAt       time.Time
+}
+
+var ErrorTransferNotFound = errors.New("transfer not found")
+var ErrorAlreadyReversed = errors.New("transfer has already been reversed")
+var ErrorReversalExceedsTransfer = errors.New("reversal amount exceeds the amount the receiver got")
+var ErrorReversalReasonRequired = errors.New("reversal reason is required")
+var ErrorReceiverBalanceTooLow = errors.New("receiver no longer holds enough balance to reverse the transfer")
+var ErrorPayoutNotReversible = errors.New("payouts of escrows and HTLCs cannot be reversed")
+
+// ReverseTransfer pays a transfer back from its receiver to its sender with
+// a compensating journal entry. Without amount the whole net amount the
+// receiver got is returned, the fee stays with the treasury. A transfer can
+// only be reversed once, partially or fully. Frozen wallets do not stop a
+// reversal, denied addresses do. Payouts from system accounts, such as a
+// released escrow or a claimed HTLC, cannot be reversed: the refund would
+// land in an account that is settled and never pays out again. Reversing
+// the payment of a payment request takes the refund off what the request
+// was paid and reopens it.
+func (s *WalletsService) ReverseTransfer(ctx context.Context, entryID int64, amount *decimal.Decimal, reason string, operator string) (Reversal, error) {
+	if reason == "" {
+		return Reversal{}, ErrorReversalReasonRequired
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Reversal{}, err
+	}
+	defer tx.Rollback()
+
+	// the row lock serialises reversals of the same transfer
+	var original ledger.Entry
+	err = tx.QueryRowContext(ctx, `
+		SELECT Id, Token, From_Address, To_Address, Amount, Fee FROM Journal_Entries WHERE Id = $1 AND Kind = $2 FOR UPDATE
+	`, entryID, ledger.KindTransfer).Scan(&original.ID, &original.Token, &original.FromAddress, &original.ToAddress, &original.Amount, &original.Fee)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Reversal{}, ErrorTransferNotFound
+	}
+	if err != nil {
+		return Reversal{}, err
+	}
+	if systemAccount(original.FromAddress) {
+		return Reversal{}, ErrorPayoutNotReversible
+	}
+
+	var reversed bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM Transfer_Reversals WHERE Transfer_Entry_Id = $1)", entryID).Scan(&reversed)
+	if err != nil {
+		return Reversal{}, err
+	}
+	if reversed {
+		return Reversal{}, ErrorAlreadyReversed
+	}
+
+	received := original.Amount.Sub(original.Fee)
+	refund := received
+	if amount != nil {
+		if !amount.IsPositive() {
+			return Reversal{}, ErrorNonPositiveAmount
+		}
+		if amount.GreaterThan(received) {
+			return Reversal{}, ErrorReversalExceedsTransfer
+		}
+		refund = *amount
+	}
+
+	// a refunded payment no longer counts for its request, which is locked
+	// before the balances as PayRequest does
+	requestID, paidRequest, err := lockRequestPaidBy(ctx, tx, entryID)
+	if err != nil {
+		return Reversal{}, err
+	}
+
+	sheet, err := lockBalances(ctx, tx, []walletKey{{original.ToAddress, original.Token}, {original.FromAddress, original.Token}})
+	if err != nil {
+		return Reversal{}, err
+	}
+	entry, err := sheet.reverse(original.Token, original.ToAddress, original.FromAddress, refund)
+	if errors.Is(err, ErrorInsufficientBalance) || errors.Is(err, ErrorSenderNotFound) {
+		return Reversal{}, ErrorReceiverBalanceTooLow
+	}
+	if err != nil {
+		return Reversal{}, err
+	}
+	if err = sheet.flush(ctx, tx); err != nil {
+		return Reversal{}, err
+	}
+	if paidRequest {
+		if err = refundRequest(ctx, tx, requestID, refund); err != nil {
+			return Reversal{}, err
+		}
+	}
+
+	reversal := Reversal{TransferEntryID: entryID, ReversalEntryID: entry.ID, Amount: refund, Reason: reason, Operator: operator}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO Transfer_Reversals (Transfer_Entry_Id, Reversal_Entry_Id, Amount, Reason, Operator)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING Id, Created_At
+	`, reversal.TransferEntryID, reversal.ReversalEntryID, reversal.Amount, reversal.Reason, reversal.Operator).Scan(&reversal.ID, &reversal.CreatedAt)
+	if err != nil {
+		return Reversal{}, err
+	}
+
+	return reversal, tx.Commit()
+}
+
+// GetReversal returns the reversal of a transfer.
+func (s *WalletsService) GetReversal(ctx context.Context, transferEntryID int64) (Reversal, error) {
+	var r Reversal
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT Id, Transfer_Entry_Id, Reversal_Entry_Id, Amount, Reason, Operator, Created_At
+		FROM Transfer_Reversals WHERE Transfer_Entry_Id = $1
+	`, transferEntryID).Scan(&r.ID, &r.TransferEntryID, &r.ReversalEntryID, &r.Amount, &r.Reason, &r.Operator, &r.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Reversal{}, ErrorTransferNotFound
+	}
+	return r, err
+}
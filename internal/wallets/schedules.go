@@ -0,0 +1,403 @@
+package wallets
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"btp_tokens/internal/cron"
+	"btp_tokens/internal/tokens"
+
+	"github.com/shopspring/decimal"
+)
+
+// Scheduled transfer states.
+const (
+	ScheduleActive    = "active"
+	ScheduleCompleted = "completed"
+	ScheduleCancelled = "cancelled"
+)
+
+// Outcomes of a scheduled run.
+const (
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+const MinScheduleInterval = time.Minute
+
+// schedulerLockKey identifies the advisory lock held by the instance that
+// runs scheduled transfers.
+const schedulerLockKey = 7_028_002
+
+// ScheduledTransfer is a transfer run once at NextRunAt, or repeatedly
+// following Cron or every Interval until EndsAt or MaxRuns paid runs.
+type ScheduledTransfer struct {
+	ID          int64
+	Token       string
+	FromAddress string
+	ToAddress   string
+	Amount      decimal.Decimal
+	Cron        string
+	Interval    time.Duration
+	EndsAt      *time.Time
+	// MaxRuns is zero when the number of runs is not limited; RunCount only
+	// counts the runs that paid, failed runs do not use them up
+	MaxRuns  int
+	RunCount int
+	// NextRunAt is nil once the schedule is no longer active
+	NextRunAt *time.Time
+	Status    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ScheduleRun is the outcome of one run, Error explains a failed run.
+type ScheduleRun struct {
+	ID              int64
+	ScheduleID      int64
+	ScheduledFor    time.Time
+	Status          string
+	TransferEntryID int64
+	Error           string
+	CreatedAt       time.Time
+}
+
+var ErrorScheduleNotFound = errors.New("scheduled transfer not found")
+var ErrorScheduleNotActive = errors.New("scheduled transfer is no longer active")
+var ErrorInvalidSchedule = errors.New("invalid schedule")
+
+func (t ScheduledTransfer) recurring() bool {
+	return t.Cron != "" || t.Interval != 0
+}
+
+// following returns the run after the one due at scheduledFor. Runs missed
+// while no instance was running are skipped, the next one is always after
+// now.
+func (t ScheduledTransfer) following(scheduledFor time.Time, now time.Time) (time.Time, error) {
+	if t.Cron != "" {
+		expr, err := cron.Parse(t.Cron)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return expr.Next(now.UTC())
+	}
+
+	next := scheduledFor.Add(t.Interval)
+	if !next.After(now) {
+		missed := now.Sub(scheduledFor) / t.Interval
+		next = scheduledFor.Add((missed + 1) * t.Interval)
+	}
+	return next, nil
+}
+
+const scheduleColumns = `Id, Token, From_Address, To_Address, Amount, COALESCE(Cron, ''), COALESCE(Interval_Seconds, 0),
+	Ends_At, COALESCE(Max_Runs, 0), Run_Count, Next_Run_At, Status, Created_At, Updated_At`
+
+func scanSchedule(row interface{ Scan(...any) error }) (ScheduledTransfer, error) {
+	var t ScheduledTransfer
+	var intervalSeconds int64
+	var endsAt, nextRunAt sql.NullTime
+	err := row.Scan(&t.ID, &t.Token, &t.FromAddress, &t.ToAddress, &t.Amount, &t.Cron, &intervalSeconds,
+		&endsAt, &t.MaxRuns, &t.RunCount, &nextRunAt, &t.Status, &t.CreatedAt, &t.UpdatedAt)
+	t.Interval = time.Duration(intervalSeconds) * time.Second
+	if endsAt.Valid {
+		t.EndsAt = &endsAt.Time
+	}
+	if nextRunAt.Valid {
+		t.NextRunAt = &nextRunAt.Time
+	}
+	return t, err
+}
+
+// ScheduleTransfer stores a scheduled transfer. One-off transfers run at
+// terms.NextRunAt; for recurring ones NextRunAt is optional and delays the
+// first run to at least that time.
+func (s *WalletsService) ScheduleTransfer(ctx context.Context, terms ScheduledTransfer) (ScheduledTransfer, error) {
+	if !terms.Amount.IsPositive() {
+		return ScheduledTransfer{}, ErrorNonPositiveAmount
+	}
+	if terms.FromAddress == terms.ToAddress {
+		return ScheduledTransfer{}, ErrorSameAddress
+	}
+	if terms.MaxRuns < 0 {
+		return ScheduledTransfer{}, fmt.Errorf("%w: max runs must not be negative", ErrorInvalidSchedule)
+	}
+
+	now := time.Now()
+	var first time.Time
+	switch {
+	case terms.Cron != "" && terms.Interval != 0:
+		return ScheduledTransfer{}, fmt.Errorf("%w: set either a cron expression or an interval", ErrorInvalidSchedule)
+	case terms.Cron != "":
+		expr, err := cron.Parse(terms.Cron)
+		if err != nil {
+			return ScheduledTransfer{}, fmt.Errorf("%w: %v", ErrorInvalidSchedule, err)
+		}
+		after := now
+		if terms.NextRunAt != nil && terms.NextRunAt.After(now) {
+			after = terms.NextRunAt.Add(-time.Nanosecond)
+		}
+		if first, err = expr.Next(after.UTC()); err != nil {
+			return ScheduledTransfer{}, fmt.Errorf("%w: %v", ErrorInvalidSchedule, err)
+		}
+	case terms.Interval != 0:
+		if terms.Interval < MinScheduleInterval || terms.Interval%time.Second != 0 {
+			return ScheduledTransfer{}, fmt.Errorf("%w: interval must be whole seconds and at least %s", ErrorInvalidSchedule, MinScheduleInterval)
+		}
+		first = now.Add(terms.Interval)
+		if terms.NextRunAt != nil && terms.NextRunAt.After(now) {
+			first = *terms.NextRunAt
+		}
+	default:
+		if terms.NextRunAt == nil || !terms.NextRunAt.After(now) {
+			return ScheduledTransfer{}, fmt.Errorf("%w: run time must be in the future", ErrorInvalidSchedule)
+		}
+		if terms.EndsAt != nil || terms.MaxRuns != 0 {
+			return ScheduledTransfer{}, fmt.Errorf("%w: end date and run count only apply to recurring transfers", ErrorInvalidSchedule)
+		}
+		first = *terms.NextRunAt
+	}
+	if terms.EndsAt != nil && first.After(*terms.EndsAt) {
+		return ScheduledTransfer{}, fmt.Errorf("%w: the schedule ends before its first run", ErrorInvalidSchedule)
+	}
+
+	token, err := tokens.Get(ctx, s.DB, terms.Token)
+	if err != nil {
+		return ScheduledTransfer{}, err
+	}
+	if err := token.CheckPrecision(terms.Amount); err != nil {
+		return ScheduledTransfer{}, err
+	}
+
+	return scanSchedule(s.DB.QueryRowContext(ctx, `
+		INSERT INTO Scheduled_Transfers (Token, From_Address, To_Address, Amount, Cron, Interval_Seconds, Ends_At, Max_Runs, Next_Run_At)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, 0), $7, NULLIF($8, 0), $9)
+		RETURNING `+scheduleColumns,
+		terms.Token, terms.FromAddress, terms.ToAddress, terms.Amount, terms.Cron, int64(terms.Interval/time.Second),
+		terms.EndsAt, terms.MaxRuns, first))
+}
+
+// CancelScheduledTransfer stops an active schedule, past runs are kept.
+func (s *WalletsService) CancelScheduledTransfer(ctx context.Context, id int64) (ScheduledTransfer, error) {
+	schedule, err := scanSchedule(s.DB.QueryRowContext(ctx, `
+		UPDATE Scheduled_Transfers SET Status = $2, Next_Run_At = NULL, Updated_At = now()
+		WHERE Id = $1 AND Status = $3
+		RETURNING `+scheduleColumns,
+		id, ScheduleCancelled, ScheduleActive))
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := s.GetScheduledTransfer(ctx, id); err != nil {
+			return ScheduledTransfer{}, err
+		}
+		return ScheduledTransfer{}, ErrorScheduleNotActive
+	}
+	return schedule, err
+}
+
+// RunDueSchedules runs every scheduled transfer that is due and returns how
+// many runs it made. A transfer that cannot be made, for example for lack
+// of balance, is recorded as a failed run and does not stop the others.
+func (s *WalletsService) RunDueSchedules(ctx context.Context) (int, error) {
+	runs := 0
+	for {
+		ran, err := s.runNextSchedule(ctx)
+		if err != nil || !ran {
+			return runs, err
+		}
+		runs++
+	}
+}
+
+// runNextSchedule makes the earliest due run. The transfer, the run and the
+// schedule's next run are written in one transaction on the locked
+// schedule, so a run is never made twice.
+func (s *WalletsService) runNextSchedule(ctx context.Context) (bool, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	schedule, err := scanSchedule(tx.QueryRowContext(ctx, `
+		SELECT `+scheduleColumns+` FROM Scheduled_Transfers
+		WHERE Status = $1 AND Next_Run_At <= now()
+		ORDER BY Next_Run_At ASC, Id ASC
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	`, ScheduleActive))
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	run := ScheduleRun{ScheduleID: schedule.ID, ScheduledFor: *schedule.NextRunAt, Status: RunSucceeded}
+
+	sheet, err := lockBalances(ctx, tx, []walletKey{{schedule.FromAddress, schedule.Token}, {schedule.ToAddress, schedule.Token}})
+	if err != nil {
+		return false, err
+	}
+	receipt, err := sheet.apply(schedule.Token, schedule.FromAddress, schedule.ToAddress, schedule.Amount)
+	if err != nil {
+		run.Status, run.Error = RunFailed, err.Error()
+	} else {
+		if err = sheet.flush(ctx, tx); err != nil {
+			return false, err
+		}
+		run.TransferEntryID = receipt.entry.ID
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO Schedule_Runs (Schedule_Id, Scheduled_For, Status, Transfer_Entry_Id, Error)
+		VALUES ($1, $2, $3, NULLIF($4, 0), NULLIF($5, ''))
+	`, run.ScheduleID, run.ScheduledFor, run.Status, run.TransferEntryID, run.Error)
+	if err != nil {
+		return false, err
+	}
+
+	if run.Status == RunSucceeded {
+		schedule.RunCount++
+	}
+	status, next := ScheduleActive, sql.NullTime{}
+	if schedule.recurring() && (schedule.MaxRuns == 0 || schedule.RunCount < schedule.MaxRuns) {
+		following, err := schedule.following(run.ScheduledFor, time.Now())
+		if err == nil && (schedule.EndsAt == nil || !following.After(*schedule.EndsAt)) {
+			next = sql.NullTime{Time: following, Valid: true}
+		}
+	}
+	if !next.Valid {
+		status = ScheduleCompleted
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE Scheduled_Transfers SET Run_Count = $2, Next_Run_At = $3, Status = $4, Updated_At = now() WHERE Id = $1
+	`, schedule.ID, schedule.RunCount, next, status)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// RunScheduler runs due transfers every interval until ctx is done. Of all
+// the instances sharing the database only the one holding the scheduler's
+// advisory lock runs them; the others take over when it goes away.
+func (s *WalletsService) RunScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var leader *sql.Conn
+	defer func() {
+		if leader != nil {
+			resign(leader)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			leader = s.lead(ctx, leader)
+			if leader == nil {
+				continue
+			}
+			if _, err := s.RunDueSchedules(ctx); err != nil {
+				log.Println("scheduled transfers fail:", err)
+			}
+		}
+	}
+}
+
+// lead keeps the leadership held on conn, or tries to take it when conn is
+// nil or broken. It returns nil while another instance leads.
+func (s *WalletsService) lead(ctx context.Context, conn *sql.Conn) *sql.Conn {
+	if conn != nil {
+		if err := conn.PingContext(ctx); err == nil {
+			return conn
+		}
+		// the session, and with it the lock, is gone
+		conn.Close()
+	}
+
+	conn, err := s.DB.Conn(ctx)
+	if err != nil {
+		log.Println("scheduler leader election fail:", err)
+		return nil
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", schedulerLockKey).Scan(&acquired); err != nil || !acquired {
+		conn.Close()
+		return nil
+	}
+	return conn
+}
+
+// resign releases the session lock before the connection goes back to the
+// pool.
+func resign(conn *sql.Conn) {
+	if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", schedulerLockKey); err != nil {
+		log.Println("scheduler resign fail:", err)
+	}
+	conn.Close()
+}
+
+func (s *WalletsService) GetScheduledTransfer(ctx context.Context, id int64) (ScheduledTransfer, error) {
+	schedule, err := scanSchedule(s.DB.QueryRowContext(ctx, "SELECT "+scheduleColumns+" FROM Scheduled_Transfers WHERE Id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ScheduledTransfer{}, ErrorScheduleNotFound
+	}
+	return schedule, err
+}
+
+// ListScheduledTransfers returns the schedules paying from or to address,
+// newest first.
+func (s *WalletsService) ListScheduledTransfers(ctx context.Context, address string) ([]ScheduledTransfer, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+scheduleColumns+` FROM Scheduled_Transfers
+		WHERE From_Address = $1 OR To_Address = $1
+		ORDER BY Id DESC
+	`, address)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []ScheduledTransfer
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, rows.Err()
+}
+
+// ScheduleRuns returns the last runs of a schedule, newest first.
+func (s *WalletsService) ScheduleRuns(ctx context.Context, id int64, limit int) ([]ScheduleRun, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT Id, Schedule_Id, Scheduled_For, Status, COALESCE(Transfer_Entry_Id, 0), COALESCE(Error, ''), Created_At
+		FROM Schedule_Runs WHERE Schedule_Id = $1
+		ORDER BY Id DESC
+		LIMIT $2
+	`, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []ScheduleRun
+	for rows.Next() {
+		var r ScheduleRun
+		if err := rows.Scan(&r.ID, &r.ScheduleID, &r.ScheduledFor, &r.Status, &r.TransferEntryID, &r.Error, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
@@ -0,0 +1,554 @@
+package wallets
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
+	"btp_tokens/internal/compliance"
+	"btp_tokens/internal/fees"
+	"btp_tokens/internal/ledger"
+	"btp_tokens/internal/limits"
+	"btp_tokens/internal/policy"
+	"btp_tokens/internal/tokens"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// walletKey identifies a balance: one address holds one balance per token.
+type walletKey struct {
+	Address string
+	Token   string
+}
+
+func sortKeys(keys []walletKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Token != keys[j].Token {
+			return keys[i].Token < keys[j].Token
+		}
+		return keys[i].Address < keys[j].Address
+	})
+}
+
+// balanceSheet keeps the balances of wallets locked inside a transaction
+// together with the changes and journal entries that still have to be
+// written back.
+type balanceSheet struct {
+	tokens map[string]tokens.Token
+	fees   map[string]*fees.Policy
+	// restrictions are the freezes and denials of the addresses in keys
+	restrictions map[string]compliance.Restriction
+	// usage is what the addresses in keys sent against their tier's limits
+	usage map[limits.Key]*limits.Usage
+	// checkPolicies evaluates the active policy rules on a transfer, it is
+	// nil when there are none; flags are the flag rules that matched entries
+	// and pending the transfers applied so far, which rules count as sent
+	checkPolicies func(policy.Transfer) ([]*policy.Rule, error)
+	flags         map[*ledger.Entry][]*policy.Rule
+	pending       policy.Pending
+	// multisig are the multisig wallets in keys, they only send the
+	// transfer of the approved proposal of approved
+	multisig map[string]bool
+	approved string
+	balances map[walletKey]decimal.Decimal
+	// held is the part of a balance reserved by holds and staked the part
+	// locked by stakes, neither can be debited
+	held         map[walletKey]decimal.Decimal
+	staked       map[walletKey]decimal.Decimal
+	deltas       map[walletKey]decimal.Decimal
+	heldDeltas   map[walletKey]decimal.Decimal
+	stakedDeltas map[walletKey]decimal.Decimal
+	order        []walletKey
+	entries      []*ledger.Entry
+}
+
+// lockBalances loads the tokens involved with their fee policies and the
+// compliance restrictions of the addresses, and locks the existing wallets
+// among keys and the treasury wallets (in token and address order, so
+// concurrent transactions cannot deadlock).
+func lockBalances(ctx context.Context, tx *sql.Tx, keys []walletKey) (*balanceSheet, error) {
+	sheet := &balanceSheet{
+		tokens:       make(map[string]tokens.Token),
+		balances:     make(map[walletKey]decimal.Decimal),
+		held:         make(map[walletKey]decimal.Decimal),
+		staked:       make(map[walletKey]decimal.Decimal),
+		deltas:       make(map[walletKey]decimal.Decimal),
+		heldDeltas:   make(map[walletKey]decimal.Decimal),
+		stakedDeltas: make(map[walletKey]decimal.Decimal),
+	}
+
+	addresses := make([]string, 0, len(keys))
+	symbols := make([]string, 0, len(keys))
+	for _, key := range keys {
+		addresses = append(addresses, key.Address)
+		symbols = append(symbols, key.Token)
+	}
+
+	// shared, so the decimals cannot change before the sheet is written
+	tokenRows, err := tx.QueryContext(ctx, "SELECT Symbol, Name, Decimals FROM Tokens WHERE Symbol = ANY($1) ORDER BY Symbol FOR SHARE", pq.Array(symbols))
+	if err != nil {
+		return nil, err
+	}
+	for tokenRows.Next() {
+		var token tokens.Token
+		if err := tokenRows.Scan(&token.Symbol, &token.Name, &token.Decimals); err != nil {
+			tokenRows.Close()
+			return nil, err
+		}
+		sheet.tokens[token.Symbol] = token
+	}
+	tokenRows.Close()
+	if err := tokenRows.Err(); err != nil {
+		return nil, err
+	}
+
+	sheet.restrictions, err = compliance.Load(ctx, tx, addresses)
+	if err != nil {
+		return nil, err
+	}
+	sheet.multisig, err = loadMultisig(ctx, tx, addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	sheet.fees, err = fees.Load(ctx, tx, symbols)
+	if err != nil {
+		return nil, err
+	}
+	for _, policy := range sheet.fees {
+		addresses = append(addresses, policy.TreasuryAddress)
+		symbols = append(symbols, policy.Token)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT Address, Token, Balance, Held, Staked FROM Wallets
+		WHERE (Address, Token) IN (SELECT * FROM unnest($1::TEXT[], $2::TEXT[]))
+		ORDER BY Token ASC, Address ASC
+		FOR UPDATE
+	`, pq.Array(addresses), pq.Array(symbols))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key walletKey
+		var balance, held, staked decimal.Decimal
+		if err := rows.Scan(&key.Address, &key.Token, &balance, &held, &staked); err != nil {
+			return nil, err
+		}
+		sheet.balances[key] = balance
+		sheet.held[key] = held
+		sheet.staked[key] = staked
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// loaded under the wallet locks, so concurrent transfers of a sender
+	// see each other's amounts
+	addresses, symbols = addresses[:0], symbols[:0]
+	for _, key := range keys {
+		if !systemAccount(key.Address) {
+			addresses = append(addresses, key.Address)
+			symbols = append(symbols, key.Token)
+		}
+	}
+	sheet.usage, err = limits.Load(ctx, tx, addresses, symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := policy.Active(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) > 0 {
+		sheet.flags = make(map[*ledger.Entry][]*policy.Rule)
+		sheet.checkPolicies = func(t policy.Transfer) ([]*policy.Rule, error) {
+			// a failing attribute query would abort tx, which the other
+			// transfers of a batch still need
+			if _, err := tx.ExecContext(ctx, "SAVEPOINT policy_check"); err != nil {
+				return nil, err
+			}
+			flags, err := policy.Check(ctx, tx, rules, t, &sheet.pending)
+			if err != nil {
+				if _, rollbackErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT policy_check"); rollbackErr != nil {
+					return nil, rollbackErr
+				}
+				return nil, err
+			}
+			_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT policy_check")
+			return flags, err
+		}
+	}
+
+	return sheet, nil
+}
+
+// checkAmount makes sure the token exists and amount fits its decimals.
+func (b *balanceSheet) checkAmount(symbol string, amount decimal.Decimal) error {
+	token, ok := b.tokens[symbol]
+	if !ok {
+		return tokens.ErrorTokenNotFound
+	}
+	return token.CheckPrecision(amount)
+}
+
+// systemAccount reports accounts like escrow:<id> and htlc:<id>, which are
+// not subject to transfer limits.
+func systemAccount(address string) bool {
+	return strings.Contains(address, ":")
+}
+
+// checkReserved makes sure none of addresses is a system account, which
+// only move, fund and payOut may touch.
+func checkReserved(addresses ...string) error {
+	for _, address := range addresses {
+		if systemAccount(address) {
+			return ErrorReservedAddress
+		}
+	}
+	return nil
+}
+
+// checkLimit makes sure amount fits the limits of fromAddress.
+func (b *balanceSheet) checkLimit(token string, fromAddress string, amount decimal.Decimal) error {
+	if usage, ok := b.usage[limits.Key{Address: fromAddress, Token: token}]; ok {
+		return usage.Check(amount)
+	}
+	return nil
+}
+
+// spend counts amount as sent by fromAddress once it is debited, for later
+// transfers of the same sheet.
+func (b *balanceSheet) spend(token string, fromAddress string, amount decimal.Decimal) {
+	if usage, ok := b.usage[limits.Key{Address: fromAddress, Token: token}]; ok {
+		usage.Spend(amount)
+	}
+}
+
+// checkParties makes sure fromAddress may send and toAddress may receive,
+// neither frozen in that direction nor denied, and that multisig wallets
+// only send approved proposals.
+func (b *balanceSheet) checkParties(fromAddress string, toAddress string) error {
+	if b.multisig[fromAddress] && fromAddress != b.approved {
+		return ErrorMultisigRequired
+	}
+	if err := b.restrictions[fromAddress].CheckSend(); err != nil {
+		return err
+	}
+	return b.restrictions[toAddress].CheckReceive()
+}
+
+// apply validates a single transfer against the in-memory balances and
+// records it. The fee is taken out of amount and credited to the treasury.
+// A failed transfer leaves the sheet untouched. The returned receipt gets
+// its entry id once the sheet is flushed.
+func (b *balanceSheet) apply(token string, fromAddress string, toAddress string, amount decimal.Decimal) (*Receipt, error) {
+	return b.transfer(token, fromAddress, toAddress, amount, transferOptions{})
+}
+
+// applyFeeOnTop is apply with the fee on amount charged to the sender on
+// top of it, so toAddress gets amount exactly. Limits and policies see what
+// the sender pays.
+func (b *balanceSheet) applyFeeOnTop(token string, fromAddress string, toAddress string, amount decimal.Decimal) (*Receipt, error) {
+	return b.transfer(token, fromAddress, toAddress, amount, transferOptions{feeOnTop: true})
+}
+
+// payOut is apply for a system account paying out to the receiver it was
+// funded for. fund already checked the policies on that transfer, so they
+// are not checked, nor counted, again.
+func (b *balanceSheet) payOut(token string, account string, toAddress string, amount decimal.Decimal) (*Receipt, error) {
+	return b.transfer(token, account, toAddress, amount, transferOptions{funded: true})
+}
+
+type transferOptions struct {
+	feeOnTop bool
+	funded   bool
+}
+
+func (b *balanceSheet) transfer(token string, fromAddress string, toAddress string, amount decimal.Decimal, opts transferOptions) (*Receipt, error) {
+	if fromAddress == toAddress {
+		return nil, ErrorSameAddress
+	}
+	if !opts.funded {
+		if err := checkReserved(fromAddress, toAddress); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := b.checkAmount(token, amount); err != nil {
+		return nil, err
+	}
+	if err := b.checkParties(fromAddress, toAddress); err != nil {
+		return nil, err
+	}
+
+	quote, err := fees.Charge(b.fees[token], b.tokens[token], amount)
+	if err != nil {
+		return nil, err
+	}
+	if opts.feeOnTop {
+		amount = amount.Add(quote.Fee)
+		quote.Amount, quote.NetAmount = amount, quote.Amount
+	}
+
+	if err := b.checkLimit(token, fromAddress, amount); err != nil {
+		return nil, err
+	}
+
+	var flags []*policy.Rule
+	transfer := policy.Transfer{Token: token, FromAddress: fromAddress, ToAddress: toAddress, Amount: amount, Time: time.Now()}
+	checkPolicies := b.checkPolicies != nil && !opts.funded
+	if checkPolicies {
+		if flags, err = b.checkPolicies(transfer); err != nil {
+			return nil, err
+		}
+	}
+
+	from := walletKey{Address: fromAddress, Token: token}
+	if err := b.debit(from, amount); err != nil {
+		return nil, err
+	}
+	b.spend(token, fromAddress, amount)
+	if checkPolicies {
+		b.pending.Add(transfer)
+	}
+	b.credit(walletKey{Address: toAddress, Token: token}, quote.NetAmount)
+
+	postings := []ledger.Posting{
+		ledger.Debit(fromAddress, amount),
+		ledger.Credit(toAddress, quote.NetAmount),
+	}
+	if quote.Fee.IsPositive() {
+		b.credit(walletKey{Address: quote.TreasuryAddress, Token: token}, quote.Fee)
+		postings = append(postings, ledger.Credit(quote.TreasuryAddress, quote.Fee))
+	}
+
+	entry := &ledger.Entry{
+		Kind:        ledger.KindTransfer,
+		Token:       token,
+		FromAddress: fromAddress,
+		ToAddress:   toAddress,
+		Amount:      amount,
+		Fee:         quote.Fee,
+		Postings:    postings,
+	}
+	b.entries = append(b.entries, entry)
+	if len(flags) > 0 {
+		b.flags[entry] = flags
+	}
+
+	return &Receipt{entry: entry, SenderBalance: b.balances[from]}, nil
+}
+
+// move books amount between two accounts without a fee, for funds parked in
+// and returned from system accounts.
+func (b *balanceSheet) move(kind string, token string, fromAddress string, toAddress string, amount decimal.Decimal) (*ledger.Entry, error) {
+	if err := b.checkAmount(token, amount); err != nil {
+		return nil, err
+	}
+	if err := b.checkParties(fromAddress, toAddress); err != nil {
+		return nil, err
+	}
+	return b.book(kind, token, fromAddress, toAddress, amount)
+}
+
+// fund moves amount from fromAddress into a system account that pays it to
+// toAddress later, as an escrow or an HTLC does. The payout then comes from
+// the account, so the sender's limits and the policies are checked here,
+// on a transfer from fromAddress to toAddress.
+func (b *balanceSheet) fund(kind string, token string, account string, fromAddress string, toAddress string, amount decimal.Decimal) (*ledger.Entry, error) {
+	if err := checkReserved(fromAddress, toAddress); err != nil {
+		return nil, err
+	}
+	if err := b.checkLimit(token, fromAddress, amount); err != nil {
+		return nil, err
+	}
+	var flags []*policy.Rule
+	transfer := policy.Transfer{Token: token, FromAddress: fromAddress, ToAddress: toAddress, Amount: amount, Time: time.Now()}
+	if b.checkPolicies != nil {
+		var err error
+		if flags, err = b.checkPolicies(transfer); err != nil {
+			return nil, err
+		}
+	}
+
+	entry, err := b.move(kind, token, fromAddress, account, amount)
+	if err != nil {
+		return nil, err
+	}
+	b.spend(token, fromAddress, amount)
+	if b.checkPolicies != nil {
+		b.pending.Add(transfer)
+	}
+	if len(flags) > 0 {
+		b.flags[entry] = flags
+	}
+	return entry, nil
+}
+
+// reverse books an operator reversal of amount from fromAddress back to
+// toAddress without a fee. Funds may be taken from or returned to a frozen
+// wallet, as freezing is meant to stop the owner, not an operator
+// correction, but never to or from a denied address.
+func (b *balanceSheet) reverse(token string, fromAddress string, toAddress string, amount decimal.Decimal) (*ledger.Entry, error) {
+	if err := b.checkAmount(token, amount); err != nil {
+		return nil, err
+	}
+	if b.multisig[fromAddress] && fromAddress != b.approved {
+		return nil, ErrorMultisigRequired
+	}
+	if err := b.restrictions[fromAddress].CheckDenied(); err != nil {
+		return nil, err
+	}
+	if err := b.restrictions[toAddress].CheckDenied(); err != nil {
+		return nil, err
+	}
+	return b.book(ledger.KindReversal, token, fromAddress, toAddress, amount)
+}
+
+func (b *balanceSheet) book(kind string, token string, fromAddress string, toAddress string, amount decimal.Decimal) (*ledger.Entry, error) {
+	if err := b.debit(walletKey{Address: fromAddress, Token: token}, amount); err != nil {
+		return nil, err
+	}
+	b.credit(walletKey{Address: toAddress, Token: token}, amount)
+
+	entry := &ledger.Entry{
+		Kind:        kind,
+		Token:       token,
+		FromAddress: fromAddress,
+		ToAddress:   toAddress,
+		Amount:      amount,
+		Postings: []ledger.Posting{
+			ledger.Debit(fromAddress, amount),
+			ledger.Credit(toAddress, amount),
+		},
+	}
+	b.entries = append(b.entries, entry)
+	return entry, nil
+}
+
+func (b *balanceSheet) debit(key walletKey, amount decimal.Decimal) error {
+	balance, found := b.balances[key]
+	if !found {
+		return ErrorSenderNotFound
+	}
+
+	newBalance := balance.Sub(amount)
+	if newBalance.LessThan(b.held[key].Add(b.staked[key])) {
+		return ErrorInsufficientBalance
+	}
+
+	b.balances[key] = newBalance
+	b.addDelta(key, amount.Neg())
+	return nil
+}
+
+// available is the part of a balance that is neither held nor staked.
+func (b *balanceSheet) available(key walletKey) decimal.Decimal {
+	return b.balances[key].Sub(b.held[key]).Sub(b.staked[key])
+}
+
+// hold reserves amount of the available balance.
+func (b *balanceSheet) hold(key walletKey, amount decimal.Decimal) error {
+	if _, found := b.balances[key]; !found {
+		return ErrorWalletNotFound
+	}
+	if b.available(key).LessThan(amount) {
+		return ErrorInsufficientBalance
+	}
+
+	b.held[key] = b.held[key].Add(amount)
+	b.track(key)
+	b.heldDeltas[key] = b.heldDeltas[key].Add(amount)
+	return nil
+}
+
+// unhold makes a reserved amount available again.
+func (b *balanceSheet) unhold(key walletKey, amount decimal.Decimal) {
+	b.held[key] = b.held[key].Sub(amount)
+	b.track(key)
+	b.heldDeltas[key] = b.heldDeltas[key].Sub(amount)
+}
+
+// stake locks amount of the available balance.
+func (b *balanceSheet) stake(key walletKey, amount decimal.Decimal) error {
+	if _, found := b.balances[key]; !found {
+		return ErrorWalletNotFound
+	}
+	if b.available(key).LessThan(amount) {
+		return ErrorInsufficientBalance
+	}
+
+	b.staked[key] = b.staked[key].Add(amount)
+	b.track(key)
+	b.stakedDeltas[key] = b.stakedDeltas[key].Add(amount)
+	return nil
+}
+
+// unstake makes a staked amount available again.
+func (b *balanceSheet) unstake(key walletKey, amount decimal.Decimal) {
+	b.staked[key] = b.staked[key].Sub(amount)
+	b.track(key)
+	b.stakedDeltas[key] = b.stakedDeltas[key].Sub(amount)
+}
+
+func (b *balanceSheet) credit(key walletKey, amount decimal.Decimal) {
+	b.balances[key] = b.balances[key].Add(amount)
+	b.addDelta(key, amount)
+}
+
+func (b *balanceSheet) addDelta(key walletKey, delta decimal.Decimal) {
+	b.track(key)
+	b.deltas[key] = b.deltas[key].Add(delta)
+}
+
+// track remembers that key has changes to write back.
+func (b *balanceSheet) track(key walletKey) {
+	if _, ok := b.deltas[key]; !ok {
+		b.order = append(b.order, key)
+		b.deltas[key] = decimal.Zero
+	}
+}
+
+// flush writes the accumulated changes as increments, so receivers that did
+// not exist when the rows were locked are created safely, followed by the
+// journal entries describing them.
+func (b *balanceSheet) flush(ctx context.Context, tx *sql.Tx) error {
+	if err := b.writeBalances(ctx, tx); err != nil {
+		return err
+	}
+
+	for _, entry := range b.entries {
+		if err := ledger.Record(ctx, tx, entry); err != nil {
+			return err
+		}
+		if err := policy.RecordFlags(ctx, tx, entry.ID, b.flags[entry]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *balanceSheet) writeBalances(ctx context.Context, tx *sql.Tx) error {
+	sortKeys(b.order)
+	for _, key := range b.order {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO Wallets (Address, Token, Balance, Held, Staked)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (Address, Token)
+			DO UPDATE SET Balance = Wallets.Balance + EXCLUDED.Balance, Held = Wallets.Held + EXCLUDED.Held,
+				Staked = Wallets.Staked + EXCLUDED.Staked;
+		`, key.Address, key.Token, b.deltas[key], b.heldDeltas[key], b.stakedDeltas[key])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,452 @@
+package wallets
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"btp_tokens/internal/ledger"
+	"btp_tokens/internal/tokens"
+
+	"github.com/shopspring/decimal"
+)
+
+// Stake states. Staked stakes earn rewards, unbonding ones no longer do but
+// stay locked until UnbondsAt, withdrawn ones are unlocked.
+const (
+	StakeStaked    = "staked"
+	StakeUnbonding = "unbonding"
+	StakeWithdrawn = "withdrawn"
+)
+
+// rewardPrecision is the number of decimal places reward per token is
+// accrued with, far below the precision of any token.
+const rewardPrecision = 36
+
+var secondsPerYear = decimal.NewFromInt(int64(365 * 24 * time.Hour / time.Second))
+
+// StakingPool holds the reward terms of a token. Staked tokens earn APR a
+// year when it is set, otherwise RewardRate tokens a second are shared pro
+// rata among all staked tokens. RewardPerToken is what one token staked since
+// the pool was created earned until AccruedAt.
+type StakingPool struct {
+	Token          string
+	APR            *decimal.Decimal
+	RewardRate     *decimal.Decimal
+	Unbonding      time.Duration
+	TotalStaked    decimal.Decimal
+	RewardPerToken decimal.Decimal
+	AccruedAt      time.Time
+	// RewardsAvailable is the balance of the pool's account, rewards are
+	// paid from it
+	RewardsAvailable decimal.Decimal
+	UpdatedAt        time.Time
+	decimals         int32
+}
+
+// Stake locks Amount of Address's balance while it earns rewards. Rewards
+// are the rewards settled when the stake last changed, not yet claimed.
+type Stake struct {
+	ID                 int64
+	Token              string
+	Address            string
+	Amount             decimal.Decimal
+	Status             string
+	RewardPerTokenPaid decimal.Decimal
+	Rewards            decimal.Decimal
+	ClaimedRewards     decimal.Decimal
+	UnbondsAt          *time.Time
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+var ErrorStakingPoolNotFound = errors.New("token has no staking pool")
+var ErrorInvalidStakingPool = errors.New("staking pool needs either a non-negative apr or reward rate and a non-negative unbonding period")
+var ErrorStakeNotFound = errors.New("stake not found")
+var ErrorStakeNotStaked = errors.New("stake was already unstaked")
+var ErrorStakeNotUnbonding = errors.New("stake is not unbonding")
+var ErrorStillUnbonding = errors.New("stake is still unbonding")
+var ErrorNoRewards = errors.New("no rewards to claim")
+var ErrorInsufficientRewards = errors.New("staking pool has not enough rewards left")
+
+// StakingAccount is the system account the rewards of a token's stakes are
+// paid from.
+func StakingAccount(token string) string {
+	return "staking:" + token
+}
+
+// RewardPerTokenAt returns RewardPerToken accrued until at.
+func (p StakingPool) RewardPerTokenAt(at time.Time) decimal.Decimal {
+	elapsed := at.Sub(p.AccruedAt)
+	if elapsed <= 0 {
+		return p.RewardPerToken
+	}
+	seconds := decimal.New(int64(elapsed), -9)
+	switch {
+	case p.APR != nil:
+		return p.RewardPerToken.Add(p.APR.Mul(seconds).DivRound(secondsPerYear, rewardPrecision))
+	case p.RewardRate != nil && p.TotalStaked.IsPositive():
+		return p.RewardPerToken.Add(p.RewardRate.Mul(seconds).DivRound(p.TotalStaked, rewardPrecision))
+	}
+	return p.RewardPerToken
+}
+
+// PendingRewards returns the rewards s can claim at the given time, rounded
+// down to the token's precision.
+func (s Stake) PendingRewards(pool StakingPool, at time.Time) decimal.Decimal {
+	return s.earned(pool.RewardPerTokenAt(at)).Truncate(pool.decimals)
+}
+
+func (s Stake) earned(rewardPerToken decimal.Decimal) decimal.Decimal {
+	if s.Status != StakeStaked {
+		return s.Rewards
+	}
+	return s.Rewards.Add(s.Amount.Mul(rewardPerToken.Sub(s.RewardPerTokenPaid)))
+}
+
+const stakingPoolColumns = `Token, Apr, Reward_Rate, Unbonding_Seconds, Total_Staked, Reward_Per_Token, Accrued_At,
+	COALESCE((SELECT Balance FROM Wallets w WHERE w.Address = 'staking:' || Staking_Pools.Token AND w.Token = Staking_Pools.Token), 0),
+	Updated_At, (SELECT Decimals FROM Tokens WHERE Symbol = Staking_Pools.Token)`
+
+func scanStakingPool(row interface{ Scan(...any) error }) (StakingPool, error) {
+	var p StakingPool
+	var apr, rate decimal.NullDecimal
+	var unbonding int64
+	err := row.Scan(&p.Token, &apr, &rate, &unbonding, &p.TotalStaked, &p.RewardPerToken, &p.AccruedAt,
+		&p.RewardsAvailable, &p.UpdatedAt, &p.decimals)
+	if apr.Valid {
+		p.APR = &apr.Decimal
+	}
+	if rate.Valid {
+		p.RewardRate = &rate.Decimal
+	}
+	p.Unbonding = time.Duration(unbonding) * time.Second
+	return p, err
+}
+
+const stakeColumns = `Id, Token, Address, Amount, Status, Reward_Per_Token_Paid, Rewards, Claimed_Rewards, Unbonds_At,
+	Created_At, Updated_At`
+
+func scanStake(row interface{ Scan(...any) error }) (Stake, error) {
+	var s Stake
+	var unbondsAt sql.NullTime
+	err := row.Scan(&s.ID, &s.Token, &s.Address, &s.Amount, &s.Status, &s.RewardPerTokenPaid, &s.Rewards, &s.ClaimedRewards, &unbondsAt,
+		&s.CreatedAt, &s.UpdatedAt)
+	if unbondsAt.Valid {
+		s.UnbondsAt = &unbondsAt.Time
+	}
+	return s, err
+}
+
+// ConfigureStakingPool creates the staking pool of a token or changes its
+// terms. Only the Token, APR, RewardRate and Unbonding of terms are used;
+// rewards earned so far are accrued with the old terms.
+func (s *WalletsService) ConfigureStakingPool(ctx context.Context, terms StakingPool) (StakingPool, error) {
+	if (terms.APR == nil) == (terms.RewardRate == nil) || terms.Unbonding < 0 {
+		return StakingPool{}, ErrorInvalidStakingPool
+	}
+	if (terms.APR != nil && terms.APR.IsNegative()) || (terms.RewardRate != nil && terms.RewardRate.IsNegative()) {
+		return StakingPool{}, ErrorInvalidStakingPool
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return StakingPool{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err = tokens.Get(ctx, tx, terms.Token); err != nil {
+		return StakingPool{}, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO Staking_Pools (Token, Apr, Reward_Rate, Unbonding_Seconds) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (Token) DO NOTHING
+	`, terms.Token, terms.APR, terms.RewardRate, int64(terms.Unbonding/time.Second))
+	if err != nil {
+		return StakingPool{}, err
+	}
+
+	pool, err := lockStakingPool(ctx, tx, terms.Token, time.Now())
+	if err != nil {
+		return StakingPool{}, err
+	}
+	pool.APR, pool.RewardRate, pool.Unbonding = terms.APR, terms.RewardRate, terms.Unbonding
+	if pool, err = saveStakingPool(ctx, tx, pool); err != nil {
+		return StakingPool{}, err
+	}
+	return pool, tx.Commit()
+}
+
+// lockStakingPool locks the pool of token and accrues its rewards until now.
+func lockStakingPool(ctx context.Context, tx *sql.Tx, token string, now time.Time) (StakingPool, error) {
+	pool, err := scanStakingPool(tx.QueryRowContext(ctx, "SELECT "+stakingPoolColumns+" FROM Staking_Pools WHERE Token = $1 FOR UPDATE", token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return StakingPool{}, ErrorStakingPoolNotFound
+	}
+	if err != nil {
+		return StakingPool{}, err
+	}
+	pool.RewardPerToken = pool.RewardPerTokenAt(now)
+	pool.AccruedAt = now
+	return pool, nil
+}
+
+func saveStakingPool(ctx context.Context, tx *sql.Tx, pool StakingPool) (StakingPool, error) {
+	return scanStakingPool(tx.QueryRowContext(ctx, `
+		UPDATE Staking_Pools SET Apr = $2, Reward_Rate = $3, Unbonding_Seconds = $4, Total_Staked = $5,
+			Reward_Per_Token = $6, Accrued_At = $7, Updated_At = now()
+		WHERE Token = $1
+		RETURNING `+stakingPoolColumns,
+		pool.Token, pool.APR, pool.RewardRate, int64(pool.Unbonding/time.Second), pool.TotalStaked,
+		pool.RewardPerToken, pool.AccruedAt))
+}
+
+func (s *WalletsService) GetStakingPool(ctx context.Context, token string) (StakingPool, error) {
+	pool, err := scanStakingPool(s.DB.QueryRowContext(ctx, "SELECT "+stakingPoolColumns+" FROM Staking_Pools WHERE Token = $1", token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return StakingPool{}, ErrorStakingPoolNotFound
+	}
+	return pool, err
+}
+
+// FundStakingRewards moves amount from fromAddress into the account the
+// pool of token pays rewards from.
+func (s *WalletsService) FundStakingRewards(ctx context.Context, token string, fromAddress string, amount decimal.Decimal) (StakingPool, error) {
+	if !amount.IsPositive() {
+		return StakingPool{}, ErrorNonPositiveAmount
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return StakingPool{}, err
+	}
+	defer tx.Rollback()
+
+	pool, err := lockStakingPool(ctx, tx, token, time.Now())
+	if err != nil {
+		return StakingPool{}, err
+	}
+	sheet, err := lockBalances(ctx, tx, []walletKey{{fromAddress, token}, {StakingAccount(token), token}})
+	if err != nil {
+		return StakingPool{}, err
+	}
+	if _, err = sheet.move(ledger.KindStaking, token, fromAddress, StakingAccount(token), amount); err != nil {
+		return StakingPool{}, err
+	}
+	if err = sheet.flush(ctx, tx); err != nil {
+		return StakingPool{}, err
+	}
+
+	if pool, err = saveStakingPool(ctx, tx, pool); err != nil {
+		return StakingPool{}, err
+	}
+	return pool, tx.Commit()
+}
+
+// Stake locks amount of address's available balance in a new stake that
+// earns rewards until it is unstaked.
+func (s *WalletsService) Stake(ctx context.Context, token string, address string, amount decimal.Decimal) (Stake, error) {
+	if !amount.IsPositive() {
+		return Stake{}, ErrorNonPositiveAmount
+	}
+	if err := checkReserved(address); err != nil {
+		return Stake{}, err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Stake{}, err
+	}
+	defer tx.Rollback()
+
+	pool, err := lockStakingPool(ctx, tx, token, time.Now())
+	if err != nil {
+		return Stake{}, err
+	}
+
+	key := walletKey{Address: address, Token: token}
+	sheet, err := lockBalances(ctx, tx, []walletKey{key})
+	if err != nil {
+		return Stake{}, err
+	}
+	if err = sheet.checkAmount(token, amount); err != nil {
+		return Stake{}, err
+	}
+	// staking locks funds like sending them, so frozen, denied and multisig
+	// wallets cannot stake
+	if err = sheet.checkParties(address, StakingAccount(token)); err != nil {
+		return Stake{}, err
+	}
+	if err = sheet.stake(key, amount); err != nil {
+		return Stake{}, err
+	}
+	if err = sheet.flush(ctx, tx); err != nil {
+		return Stake{}, err
+	}
+
+	stake, err := scanStake(tx.QueryRowContext(ctx, `
+		INSERT INTO Stakes (Token, Address, Amount, Reward_Per_Token_Paid)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+stakeColumns,
+		token, address, amount, pool.RewardPerToken))
+	if err != nil {
+		return Stake{}, err
+	}
+
+	pool.TotalStaked = pool.TotalStaked.Add(amount)
+	if _, err = saveStakingPool(ctx, tx, pool); err != nil {
+		return Stake{}, err
+	}
+	return stake, tx.Commit()
+}
+
+// Unstake stops the rewards of a stake. Its amount stays locked for the
+// unbonding period of the pool, it is unlocked right away without one.
+func (s *WalletsService) Unstake(ctx context.Context, id int64) (Stake, error) {
+	return s.updateStake(ctx, id, func(tx *sql.Tx, stake *Stake, pool *StakingPool, now time.Time) error {
+		if stake.Status != StakeStaked {
+			return ErrorStakeNotStaked
+		}
+		pool.TotalStaked = pool.TotalStaked.Sub(stake.Amount)
+
+		unbondsAt := now.Add(pool.Unbonding)
+		stake.UnbondsAt = &unbondsAt
+		if pool.Unbonding > 0 {
+			stake.Status = StakeUnbonding
+			return nil
+		}
+		stake.Status = StakeWithdrawn
+		return unlockStake(ctx, tx, *stake)
+	})
+}
+
+// WithdrawStake unlocks the amount of a stake once it unbonded.
+func (s *WalletsService) WithdrawStake(ctx context.Context, id int64) (Stake, error) {
+	return s.updateStake(ctx, id, func(tx *sql.Tx, stake *Stake, pool *StakingPool, now time.Time) error {
+		if stake.Status != StakeUnbonding {
+			return ErrorStakeNotUnbonding
+		}
+		if now.Before(*stake.UnbondsAt) {
+			return ErrorStillUnbonding
+		}
+		stake.Status = StakeWithdrawn
+		return unlockStake(ctx, tx, *stake)
+	})
+}
+
+func unlockStake(ctx context.Context, tx *sql.Tx, stake Stake) error {
+	key := walletKey{Address: stake.Address, Token: stake.Token}
+	sheet, err := lockBalances(ctx, tx, []walletKey{key})
+	if err != nil {
+		return err
+	}
+	sheet.unstake(key, stake.Amount)
+	return sheet.flush(ctx, tx)
+}
+
+// ClaimStakingRewards pays the rewards a stake earned so far, rounded down
+// to the token's precision, from the pool's account.
+func (s *WalletsService) ClaimStakingRewards(ctx context.Context, id int64) (Stake, error) {
+	return s.updateStake(ctx, id, func(tx *sql.Tx, stake *Stake, pool *StakingPool, now time.Time) error {
+		rewards := stake.Rewards.Truncate(pool.decimals)
+		if !rewards.IsPositive() {
+			return ErrorNoRewards
+		}
+
+		account := StakingAccount(stake.Token)
+		sheet, err := lockBalances(ctx, tx, []walletKey{{account, stake.Token}, {stake.Address, stake.Token}})
+		if err != nil {
+			return err
+		}
+		_, err = sheet.move(ledger.KindStaking, stake.Token, account, stake.Address, rewards)
+		if errors.Is(err, ErrorInsufficientBalance) || errors.Is(err, ErrorSenderNotFound) {
+			return ErrorInsufficientRewards
+		}
+		if err != nil {
+			return err
+		}
+		if err = sheet.flush(ctx, tx); err != nil {
+			return err
+		}
+
+		stake.Rewards = stake.Rewards.Sub(rewards)
+		stake.ClaimedRewards = stake.ClaimedRewards.Add(rewards)
+		return nil
+	})
+}
+
+// updateStake locks a stake and the pool of its token, settles the rewards
+// the stake earned until now and stores what update changed.
+func (s *WalletsService) updateStake(ctx context.Context, id int64, update func(tx *sql.Tx, stake *Stake, pool *StakingPool, now time.Time) error) (Stake, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Stake{}, err
+	}
+	defer tx.Rollback()
+
+	stake, err := scanStake(tx.QueryRowContext(ctx, "SELECT "+stakeColumns+" FROM Stakes WHERE Id = $1 FOR UPDATE", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Stake{}, ErrorStakeNotFound
+	}
+	if err != nil {
+		return Stake{}, err
+	}
+
+	now := time.Now()
+	pool, err := lockStakingPool(ctx, tx, stake.Token, now)
+	if err != nil {
+		return Stake{}, err
+	}
+	stake.Rewards = stake.earned(pool.RewardPerToken)
+	stake.RewardPerTokenPaid = pool.RewardPerToken
+
+	if err = update(tx, &stake, &pool, now); err != nil {
+		return Stake{}, err
+	}
+
+	stake, err = scanStake(tx.QueryRowContext(ctx, `
+		UPDATE Stakes SET Status = $2, Reward_Per_Token_Paid = $3, Rewards = $4, Claimed_Rewards = $5, Unbonds_At = $6,
+			Updated_At = now()
+		WHERE Id = $1
+		RETURNING `+stakeColumns,
+		id, stake.Status, stake.RewardPerTokenPaid, stake.Rewards, stake.ClaimedRewards, stake.UnbondsAt))
+	if err != nil {
+		return Stake{}, err
+	}
+	if _, err = saveStakingPool(ctx, tx, pool); err != nil {
+		return Stake{}, err
+	}
+	return stake, tx.Commit()
+}
+
+func (s *WalletsService) GetStake(ctx context.Context, id int64) (Stake, error) {
+	stake, err := scanStake(s.DB.QueryRowContext(ctx, "SELECT "+stakeColumns+" FROM Stakes WHERE Id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Stake{}, ErrorStakeNotFound
+	}
+	return stake, err
+}
+
+// ListStakes returns the stakes of address in token, newest first.
+func (s *WalletsService) ListStakes(ctx context.Context, address string, token string) ([]Stake, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+stakeColumns+` FROM Stakes
+		WHERE Address = $1 AND Token = $2
+		ORDER BY Id DESC
+	`, address, token)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stakes []Stake
+	for rows.Next() {
+		stake, err := scanStake(rows)
+		if err != nil {
+			return nil, err
+		}
+		stakes = append(stakes, stake)
+	}
+	return stakes, rows.Err()
+}
@@ -0,0 +1,261 @@
+package wallets
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"btp_tokens/internal/ledger"
+
+	"github.com/shopspring/decimal"
+)
+
+// VestingGrant locks Total in a system account and releases it to
+// Beneficiary linearly over Duration from StartAt, nothing being vested
+// before the cliff. Revoking a revocable grant stops the vesting and returns
+// the unvested part to Funder.
+type VestingGrant struct {
+	ID          int64
+	Token       string
+	Funder      string
+	Beneficiary string
+	Total       decimal.Decimal
+	Claimed     decimal.Decimal
+	// Returned is the unvested amount paid back to the funder on revocation
+	Returned  decimal.Decimal
+	StartAt   time.Time
+	Cliff     time.Duration
+	Duration  time.Duration
+	Revocable bool
+	RevokedAt *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	decimals  int32
+}
+
+var ErrorVestingGrantNotFound = errors.New("vesting grant not found")
+var ErrorInvalidVestingGrant = errors.New("vesting duration must be positive and at most 100 years, and the cliff must be within it")
+var ErrorNothingToClaim = errors.New("no vested tokens to claim")
+var ErrorGrantNotRevocable = errors.New("vesting grant is not revocable")
+var ErrorGrantRevoked = errors.New("vesting grant was already revoked")
+
+// VestingAccount is the system account holding the unclaimed tokens of a
+// grant.
+func VestingAccount(id int64) string {
+	return "vesting:" + strconv.FormatInt(id, 10)
+}
+
+// VestedAt returns the amount vested at the given time, rounded down to the
+// token's precision. Vesting stops when the grant is revoked.
+func (g VestingGrant) VestedAt(at time.Time) decimal.Decimal {
+	if g.RevokedAt != nil && at.After(*g.RevokedAt) {
+		return g.Total.Sub(g.Returned)
+	}
+	elapsed := at.Sub(g.StartAt)
+	if elapsed < g.Cliff {
+		return decimal.Zero
+	}
+	if elapsed >= g.Duration {
+		return g.Total
+	}
+	vested, _ := g.Total.Mul(decimal.NewFromInt(int64(elapsed))).QuoRem(decimal.NewFromInt(int64(g.Duration)), g.decimals)
+	return vested
+}
+
+// MaxVestingDuration bounds grants well below the 292 years a
+// time.Duration can hold.
+const MaxVestingDuration = 100 * 365 * 24 * time.Hour
+
+// VestingSeconds converts seconds of a cliff or duration, rejecting values
+// that are negative or longer than MaxVestingDuration before they can
+// overflow.
+func VestingSeconds(seconds int64) (time.Duration, error) {
+	if seconds < 0 || seconds > int64(MaxVestingDuration/time.Second) {
+		return 0, ErrorInvalidVestingGrant
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+const vestingColumns = `Id, Token, Funder, Beneficiary, Total, Claimed, Returned, Start_At, Cliff_Seconds, Duration_Seconds,
+	Revocable, Revoked_At, Created_At, Updated_At, (SELECT Decimals FROM Tokens WHERE Symbol = Token)`
+
+func scanVestingGrant(row interface{ Scan(...any) error }) (VestingGrant, error) {
+	var g VestingGrant
+	var cliff, duration int64
+	var revokedAt sql.NullTime
+	err := row.Scan(&g.ID, &g.Token, &g.Funder, &g.Beneficiary, &g.Total, &g.Claimed, &g.Returned, &g.StartAt, &cliff, &duration,
+		&g.Revocable, &revokedAt, &g.CreatedAt, &g.UpdatedAt, &g.decimals)
+	g.Cliff = time.Duration(cliff) * time.Second
+	g.Duration = time.Duration(duration) * time.Second
+	if revokedAt.Valid {
+		g.RevokedAt = &revokedAt.Time
+	}
+	return g, err
+}
+
+// CreateVestingGrant moves the total of the grant from the funder into a new
+// vesting grant. Only the Token, Funder, Beneficiary, Total, StartAt, Cliff,
+// Duration and Revocable of terms are used; the durations are whole seconds.
+func (s *WalletsService) CreateVestingGrant(ctx context.Context, terms VestingGrant) (VestingGrant, error) {
+	if !terms.Total.IsPositive() {
+		return VestingGrant{}, ErrorNonPositiveAmount
+	}
+	if terms.Funder == terms.Beneficiary {
+		return VestingGrant{}, ErrorSameAddress
+	}
+	if terms.Duration < time.Second || terms.Duration > MaxVestingDuration || terms.Cliff < 0 || terms.Cliff > terms.Duration {
+		return VestingGrant{}, ErrorInvalidVestingGrant
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return VestingGrant{}, err
+	}
+	defer tx.Rollback()
+
+	grant, err := scanVestingGrant(tx.QueryRowContext(ctx, `
+		INSERT INTO Vesting_Grants (Token, Funder, Beneficiary, Total, Start_At, Cliff_Seconds, Duration_Seconds, Revocable)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING `+vestingColumns,
+		terms.Token, terms.Funder, terms.Beneficiary, terms.Total, terms.StartAt,
+		int64(terms.Cliff/time.Second), int64(terms.Duration/time.Second), terms.Revocable))
+	if err != nil {
+		return VestingGrant{}, err
+	}
+
+	account := VestingAccount(grant.ID)
+	sheet, err := lockBalances(ctx, tx, []walletKey{{grant.Funder, grant.Token}, {account, grant.Token}})
+	if err != nil {
+		return VestingGrant{}, err
+	}
+	// funding a grant counts against the funder's transfer limits and is
+	// checked by the policies as a transfer to the beneficiary
+	if _, err = sheet.fund(ledger.KindVesting, grant.Token, account, grant.Funder, grant.Beneficiary, grant.Total); err != nil {
+		return VestingGrant{}, err
+	}
+	if err = sheet.flush(ctx, tx); err != nil {
+		return VestingGrant{}, err
+	}
+
+	return grant, tx.Commit()
+}
+
+// ClaimVested pays everything vested and not yet claimed to the beneficiary.
+func (s *WalletsService) ClaimVested(ctx context.Context, id int64) (VestingGrant, error) {
+	return s.updateVestingGrant(ctx, id, func(tx *sql.Tx, g *VestingGrant, now time.Time) error {
+		claimable := g.VestedAt(now).Sub(g.Claimed)
+		if !claimable.IsPositive() {
+			return ErrorNothingToClaim
+		}
+
+		account := VestingAccount(g.ID)
+		sheet, err := lockBalances(ctx, tx, []walletKey{{account, g.Token}, {g.Beneficiary, g.Token}})
+		if err != nil {
+			return err
+		}
+		if _, err = sheet.move(ledger.KindVesting, g.Token, account, g.Beneficiary, claimable); err != nil {
+			return err
+		}
+		if err = sheet.flush(ctx, tx); err != nil {
+			return err
+		}
+		g.Claimed = g.Claimed.Add(claimable)
+		return nil
+	})
+}
+
+// RevokeVestingGrant stops a revocable grant and returns its unvested part to
+// the funder. What vested until now stays claimable by the beneficiary.
+func (s *WalletsService) RevokeVestingGrant(ctx context.Context, id int64) (VestingGrant, error) {
+	return s.updateVestingGrant(ctx, id, func(tx *sql.Tx, g *VestingGrant, now time.Time) error {
+		if !g.Revocable {
+			return ErrorGrantNotRevocable
+		}
+		if g.RevokedAt != nil {
+			return ErrorGrantRevoked
+		}
+
+		unvested := g.Total.Sub(g.VestedAt(now))
+		if unvested.IsPositive() {
+			account := VestingAccount(g.ID)
+			sheet, err := lockBalances(ctx, tx, []walletKey{{account, g.Token}, {g.Funder, g.Token}})
+			if err != nil {
+				return err
+			}
+			if _, err = sheet.move(ledger.KindVesting, g.Token, account, g.Funder, unvested); err != nil {
+				return err
+			}
+			if err = sheet.flush(ctx, tx); err != nil {
+				return err
+			}
+		}
+		g.Returned = unvested
+		g.RevokedAt = &now
+		return nil
+	})
+}
+
+// updateVestingGrant locks the grant, so concurrent claims cannot pay the
+// same tokens twice, and stores what update changed.
+func (s *WalletsService) updateVestingGrant(ctx context.Context, id int64, update func(tx *sql.Tx, g *VestingGrant, now time.Time) error) (VestingGrant, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return VestingGrant{}, err
+	}
+	defer tx.Rollback()
+
+	grant, err := scanVestingGrant(tx.QueryRowContext(ctx, "SELECT "+vestingColumns+" FROM Vesting_Grants WHERE Id = $1 FOR UPDATE", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return VestingGrant{}, ErrorVestingGrantNotFound
+	}
+	if err != nil {
+		return VestingGrant{}, err
+	}
+
+	if err = update(tx, &grant, time.Now()); err != nil {
+		return VestingGrant{}, err
+	}
+
+	grant, err = scanVestingGrant(tx.QueryRowContext(ctx, `
+		UPDATE Vesting_Grants SET Claimed = $2, Returned = $3, Revoked_At = $4, Updated_At = now()
+		WHERE Id = $1
+		RETURNING `+vestingColumns,
+		id, grant.Claimed, grant.Returned, grant.RevokedAt))
+	if err != nil {
+		return VestingGrant{}, err
+	}
+	return grant, tx.Commit()
+}
+
+func (s *WalletsService) GetVestingGrant(ctx context.Context, id int64) (VestingGrant, error) {
+	grant, err := scanVestingGrant(s.DB.QueryRowContext(ctx, "SELECT "+vestingColumns+" FROM Vesting_Grants WHERE Id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return VestingGrant{}, ErrorVestingGrantNotFound
+	}
+	return grant, err
+}
+
+// ListVestingGrants returns the grants of beneficiary, newest first.
+func (s *WalletsService) ListVestingGrants(ctx context.Context, beneficiary string) ([]VestingGrant, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+vestingColumns+` FROM Vesting_Grants
+		WHERE Beneficiary = $1
+		ORDER BY Id DESC
+	`, beneficiary)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var grants []VestingGrant
+	for rows.Next() {
+		grant, err := scanVestingGrant(rows)
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, grant)
+	}
+	return grants, rows.Err()
+}
@@ -4,14 +4,50 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
+	"btp_tokens/internal/ledger"
+	"btp_tokens/internal/tokens"
+
+	"github.com/lib/pq"
 	"github.com/shopspring/decimal"
 )
 
 
 type Wallet struct {
 	Address string
+	Token   string
 	Balance decimal.Decimal
+	// Held is reserved by active holds and Staked locked by stakes,
+	// Balance - Held - Staked is available
+	Held   decimal.Decimal
+	Staked decimal.Decimal
+}
+
+func (w Wallet) Available() decimal.Decimal {
+	return w.Balance.Sub(w.Held).Sub(w.Staked)
+}
+
+// Receipt describes a committed transfer, EntryID is the id of its journal
+// entry.
+type Receipt struct {
+	EntryID     int64
+	Token       string
+	FromAddress string
+	ToAddress   string
+	Amount      decimal.Decimal
+	Fee         decimal.Decimal
+	NetAmount   decimal.Decimal
+	CreatedAt   time.Time
+	// SenderBalance is only known right after the transfer, it is zero in
+	// the history
+	SenderBalance decimal.Decimal
+	// ReversalOf is the transfer a reversal pays back, ReversedBy the
+	// reversal of a transfer; both are only set in the history
+	ReversalOf int64
+	ReversedBy int64
+
+	entry *ledger.Entry
 }
 
 type WalletsService struct {
@@ -19,91 +55,326 @@ type WalletsService struct {
 }
 
 var ErrorInsufficientBalance = errors.New("insufficient wallet balance")
+var ErrorSameAddress = errors.New("cannot transfer to the same address")
+var ErrorSenderNotFound = errors.New("sender wallet not found")
+var ErrorNonPositiveAmount = errors.New("amount must be positive")
+var ErrorWalletNotFound = errors.New("Wallet not found")
+var ErrorReservedAddress = errors.New("address is reserved for system accounts")
 
+// Transfer moves BTP tokens, see TransferToken.
 func (s *WalletsService) Transfer(ctx context.Context, fromAddress string, toAddress string, amount decimal.Decimal) (decimal.Decimal, error){
-	tx, err := s.DB.BeginTx(ctx, nil)
+	return s.TransferToken(ctx, tokens.DefaultSymbol, fromAddress, toAddress, amount)
+}
+
+// TransferToken moves amount of token between two wallets and returns the
+// sender's updated balance, see Send.
+func (s *WalletsService) TransferToken(ctx context.Context, token string, fromAddress string, toAddress string, amount decimal.Decimal) (decimal.Decimal, error) {
+	receipt, err := s.Send(ctx, token, fromAddress, toAddress, amount)
 	if err != nil {
 		return decimal.Decimal{}, err
 	}
+	return receipt.SenderBalance, nil
+}
+
+// Send moves amount of token between two wallets. The token's transfer fee
+// is deducted from amount and paid to the treasury in the same transaction.
+func (s *WalletsService) Send(ctx context.Context, token string, fromAddress string, toAddress string, amount decimal.Decimal) (Receipt, error) {
+	if fromAddress == toAddress {
+		return Receipt{}, ErrorSameAddress
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Receipt{}, err
+	}
 
 	defer tx.Rollback()
 
+	sheet, err := lockBalances(ctx, tx, []walletKey{{fromAddress, token}, {toAddress, token}})
+	if err != nil {
+		return Receipt{}, err
+	}
+
+	receipt, err := sheet.apply(token, fromAddress, toAddress, amount)
+	if err != nil {
+		return Receipt{}, err
+	}
+
+	if err = sheet.flush(ctx, tx); err != nil {
+		return Receipt{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Receipt{}, err
+	}
+
+	return receipt.complete(), nil
+}
+
+// Simulation is what a transfer would do if it was sent now.
+type Simulation struct {
+	Receipt         Receipt
+	ReceiverBalance decimal.Decimal
+}
+
+// Simulate runs a transfer like Send, including the balance writes, and rolls
+// it back. The journal is left alone, so simulations neither wait for the
+// chain lock nor use up entry ids; the receipt has no EntryID.
+func (s *WalletsService) Simulate(ctx context.Context, token string, fromAddress string, toAddress string, amount decimal.Decimal) (Simulation, error) {
 	if fromAddress == toAddress {
-        return decimal.Decimal{}, errors.New("cannot transfer to the same address")
-    }
+		return Simulation{}, ErrorSameAddress
+	}
 
-	var senderBalance decimal.Decimal
-	queryFrom := "SELECT Address, Balance FROM Wallets WHERE Address IN ($1, $2) ORDER BY Address ASC FOR UPDATE"
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Simulation{}, err
+	}
 
-	rows, err := tx.QueryContext(ctx, queryFrom, fromAddress, toAddress)
+	defer tx.Rollback()
 
+	sheet, err := lockBalances(ctx, tx, []walletKey{{fromAddress, token}, {toAddress, token}})
 	if err != nil {
-		return decimal.Decimal{}, err
+		return Simulation{}, err
 	}
-	defer rows.Close()
 
-	foundSender := false
+	receipt, err := sheet.apply(token, fromAddress, toAddress, amount)
+	if err != nil {
+		return Simulation{}, err
+	}
+
+	if err = sheet.writeBalances(ctx, tx); err != nil {
+		return Simulation{}, err
+	}
+	for _, entry := range sheet.entries {
+		if err := entry.Validate(); err != nil {
+			return Simulation{}, err
+		}
+	}
+
+	result := Simulation{Receipt: receiptOf(receipt.entry, receipt.SenderBalance)}
+	err = tx.QueryRowContext(ctx, "SELECT Balance FROM Wallets WHERE Address = $1 AND Token = $2", toAddress, token).Scan(&result.ReceiverBalance)
+	if err != nil {
+		return Simulation{}, err
+	}
+	return result, nil
+}
+
+// complete fills the receipt from its journal entry once it is recorded.
+func (r *Receipt) complete() Receipt {
+	if r.entry != nil {
+		*r = receiptOf(r.entry, r.SenderBalance)
+	}
+	return *r
+}
+
+func receiptOf(entry *ledger.Entry, senderBalance decimal.Decimal) Receipt {
+	return Receipt{
+		EntryID:       entry.ID,
+		Token:         entry.Token,
+		FromAddress:   entry.FromAddress,
+		ToAddress:     entry.ToAddress,
+		Amount:        entry.Amount,
+		Fee:           entry.Fee,
+		NetAmount:     entry.Amount.Sub(entry.Fee),
+		CreatedAt:     entry.CreatedAt,
+		SenderBalance: senderBalance,
+	}
+}
+
+// History returns the transfers and reversals address sent or received in
+// token, newest first.
+func (s *WalletsService) History(ctx context.Context, address string, token string, limit int) ([]Receipt, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT e.Id, e.Token, e.From_Address, e.To_Address, e.Amount, e.Fee, e.Created_At,
+			COALESCE(reversal_of.Transfer_Entry_Id, 0), COALESCE(reversed_by.Reversal_Entry_Id, 0)
+		FROM Journal_Entries e
+		LEFT JOIN Transfer_Reversals reversal_of ON reversal_of.Reversal_Entry_Id = e.Id
+		LEFT JOIN Transfer_Reversals reversed_by ON reversed_by.Transfer_Entry_Id = e.Id
+		WHERE e.Kind = ANY($1) AND e.Token = $2 AND (e.From_Address = $3 OR e.To_Address = $3)
+		ORDER BY e.Id DESC
+		LIMIT $4
+	`, pq.Array([]string{ledger.KindTransfer, ledger.KindReversal}), token, address, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
+	var history []Receipt
 	for rows.Next() {
-		var address string
-		var balance decimal.Decimal
-		err := rows.Scan(&address, &balance)
+		var entry ledger.Entry
+		var reversalOf, reversedBy int64
+		if err := rows.Scan(&entry.ID, &entry.Token, &entry.FromAddress, &entry.ToAddress, &entry.Amount, &entry.Fee, &entry.CreatedAt, &reversalOf, &reversedBy); err != nil {
+			return nil, err
+		}
+		receipt := receiptOf(&entry, decimal.Zero)
+		receipt.ReversalOf, receipt.ReversedBy = reversalOf, reversedBy
+		history = append(history, receipt)
+	}
+	return history, rows.Err()
+}
+
+// Mint issues new tokens to address, creating the wallet when needed, and
+// returns its updated balance. The token's supply cap is enforced under a
+// lock on the token, so concurrent mints cannot exceed it together.
+func (s *WalletsService) Mint(ctx context.Context, token string, toAddress string, amount decimal.Decimal) (decimal.Decimal, error) {
+	if !amount.IsPositive() {
+		return decimal.Decimal{}, ErrorNonPositiveAmount
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+
+	defer tx.Rollback()
+
+	var supplyCap decimal.NullDecimal
+	err = tx.QueryRowContext(ctx, "SELECT Supply_Cap FROM Tokens WHERE Symbol = $1 FOR UPDATE", token).Scan(&supplyCap)
+	if errors.Is(err, sql.ErrNoRows) {
+		return decimal.Decimal{}, tokens.ErrorTokenNotFound
+	}
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+
+	if supplyCap.Valid {
+		supply, err := tokens.TotalSupply(ctx, tx, token)
 		if err != nil {
 			return decimal.Decimal{}, err
 		}
-
-		if address == fromAddress{
-			senderBalance = balance
-			foundSender = true
+		if supply.Add(amount).GreaterThan(supplyCap.Decimal) {
+			return decimal.Decimal{}, tokens.ErrorSupplyCapExceeded
 		}
 	}
 
-	if rows.Err() != nil {
-		return decimal.Decimal{}, rows.Err()
+	key := walletKey{Address: toAddress, Token: token}
+	sheet, err := lockBalances(ctx, tx, []walletKey{key})
+	if err != nil {
+		return decimal.Decimal{}, err
 	}
 
-	if !foundSender {
-		return decimal.Decimal{}, errors.New("sender wallet not found")
+	if err = sheet.checkAmount(token, amount); err != nil {
+		return decimal.Decimal{}, err
 	}
+	if err = sheet.restrictions[toAddress].CheckReceive(); err != nil {
+		return decimal.Decimal{}, err
+	}
+	sheet.credit(key, amount)
+	sheet.entries = append(sheet.entries, &ledger.Entry{
+		Kind:      ledger.KindMint,
+		Token:     token,
+		ToAddress: toAddress,
+		Amount:    amount,
+		Postings: []ledger.Posting{
+			ledger.Debit(ledger.IssuanceAccount, amount),
+			ledger.Credit(toAddress, amount),
+		},
+	})
 
-	newSenderBalance := senderBalance.Sub(amount)
-	if newSenderBalance.IsNegative() {
-		return decimal.Decimal{}, ErrorInsufficientBalance
+	if err = sheet.flush(ctx, tx); err != nil {
+		return decimal.Decimal{}, err
 	}
 
-	_, err = tx.ExecContext(ctx, "UPDATE Wallets SET Balance = $1 WHERE Address = $2", newSenderBalance, fromAddress)
-	if err != nil {
+	if err = tx.Commit(); err != nil {
 		return decimal.Decimal{}, err
 	}
 
-	_, err = tx.ExecContext(ctx, `
-        INSERT INTO Wallets (Address, Balance) 
-        VALUES ($2, $1)
-        ON CONFLICT (Address) 
-        DO UPDATE SET Balance = Wallets.Balance + EXCLUDED.Balance;
-    `, amount, toAddress)
+	return sheet.balances[key], nil
+}
+
+// Burn destroys tokens held by address and returns its updated balance.
+func (s *WalletsService) Burn(ctx context.Context, token string, fromAddress string, amount decimal.Decimal) (decimal.Decimal, error) {
+	if !amount.IsPositive() {
+		return decimal.Decimal{}, ErrorNonPositiveAmount
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return decimal.Decimal{}, err
 	}
-	
-	err = tx.Commit()
+
+	defer tx.Rollback()
+
+	key := walletKey{Address: fromAddress, Token: token}
+	sheet, err := lockBalances(ctx, tx, []walletKey{key})
 	if err != nil {
 		return decimal.Decimal{}, err
 	}
 
-	return newSenderBalance, nil
+	if err = sheet.checkAmount(token, amount); err != nil {
+		return decimal.Decimal{}, err
+	}
+	if err = sheet.debit(key, amount); err != nil {
+		return decimal.Decimal{}, err
+	}
+	sheet.entries = append(sheet.entries, &ledger.Entry{
+		Kind:        ledger.KindBurn,
+		Token:       token,
+		FromAddress: fromAddress,
+		Amount:      amount,
+		Postings: []ledger.Posting{
+			ledger.Debit(fromAddress, amount),
+			ledger.Credit(ledger.IssuanceAccount, amount),
+		},
+	})
+
+	if err = sheet.flush(ctx, tx); err != nil {
+		return decimal.Decimal{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return decimal.Decimal{}, err
+	}
+
+	return sheet.balances[key], nil
 }
 
+// GetWalletBalance returns the BTP balance of address, see GetTokenBalance.
 func (s *WalletsService) GetWalletBalance(ctx context.Context, address string) (decimal.Decimal, error) {
+	return s.GetTokenBalance(ctx, address, tokens.DefaultSymbol)
+}
+
+func (s *WalletsService) GetTokenBalance(ctx context.Context, address string, token string) (decimal.Decimal, error) {
 	var balance decimal.Decimal
-	query := "SELECT Balance FROM Wallets WHERE Address = $1"
-	err := s.DB.QueryRowContext(ctx, query, address).Scan(&balance)
+	query := "SELECT Balance FROM Wallets WHERE Address = $1 AND Token = $2"
+	err := s.DB.QueryRowContext(ctx, query, address, token).Scan(&balance)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return decimal.Zero, errors.New("Wallet not found")
+			return decimal.Zero, ErrorWalletNotFound
 		}
 		return decimal.Decimal{}, err
 	}
 	return balance, nil
 }
 
+// GetWallet returns the balance of address in token with the held and staked
+// parts.
+func (s *WalletsService) GetWallet(ctx context.Context, address string, token string) (Wallet, error) {
+	w := Wallet{Address: address, Token: token}
+	query := "SELECT Balance, Held, Staked FROM Wallets WHERE Address = $1 AND Token = $2"
+	err := s.DB.QueryRowContext(ctx, query, address, token).Scan(&w.Balance, &w.Held, &w.Staked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Wallet{}, ErrorWalletNotFound
+	}
+	return w, err
+}
+
+// ListWallets returns the balances address holds in every token.
+func (s *WalletsService) ListWallets(ctx context.Context, address string) ([]Wallet, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT Address, Token, Balance, Held, Staked FROM Wallets WHERE Address = $1 ORDER BY Token ASC", address)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Wallet
+	for rows.Next() {
+		var w Wallet
+		if err := rows.Scan(&w.Address, &w.Token, &w.Balance, &w.Held, &w.Staked); err != nil {
+			return nil, err
+		}
+		list = append(list, w)
+	}
+	return list, rows.Err()
+}
@@ -2,15 +2,27 @@ package main
 
 import (
 	"btp_tokens/graph"
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/go-chi/chi/v5"
 
+	"btp_tokens/internal/alerts"
+	"btp_tokens/internal/auth"
+	"btp_tokens/internal/compliance"
+	"btp_tokens/internal/fees"
+	"btp_tokens/internal/ledger"
+	"btp_tokens/internal/limits"
+	"btp_tokens/internal/policy"
 	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/snapshots"
+	"btp_tokens/internal/tokens"
 	"btp_tokens/internal/wallets"
 
 	"github.com/joho/godotenv"
@@ -18,6 +30,23 @@ import (
 
 const defaultPort = "8080"
 const dbURLKey = "DATABASE_URL"
+const batchSizeKey = "TRANSFER_BATCH_SIZE"
+const batchWaitKey = "TRANSFER_BATCH_WAIT"
+const operatorsKey = "OPERATOR_TOKENS"
+const signingKeyKey = "LEDGER_SIGNING_KEY"
+const verifyKeyKey = "LEDGER_VERIFY_KEY"
+const checkpointIntervalKey = "LEDGER_CHECKPOINT_INTERVAL"
+const snapshotIntervalKey = "SNAPSHOT_INTERVAL"
+const btpDecimalsKey = "BTP_DECIMALS"
+const holdExpiryIntervalKey = "HOLD_EXPIRY_INTERVAL"
+const schedulerIntervalKey = "SCHEDULER_INTERVAL"
+const alertScanIntervalKey = "ALERT_SCAN_INTERVAL"
+const distributionIntervalKey = "DISTRIBUTION_INTERVAL"
+
+const defaultHoldExpiryInterval = time.Minute
+const defaultSchedulerInterval = 10 * time.Second
+const defaultAlertScanInterval = 30 * time.Second
+const defaultDistributionInterval = 10 * time.Second
 
 func main() {
 	if err := godotenv.Load(); err != nil {
@@ -41,16 +70,109 @@ func main() {
 	defer database.CloseDB()
 	database.Migrate("internal/pkg/db/migrations/postgres")
 
-	walletsService := &wallets.WalletsService{DB: db}
+	operators, err := auth.ParseOperators(os.Getenv(operatorsKey))
+	if err != nil {
+		log.Fatalf("error: invalid %s: %v", operatorsKey, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ledgerService := &ledger.LedgerService{DB: db}
+	if seed := os.Getenv(signingKeyKey); seed != "" {
+		ledgerService.SigningKey, err = ledger.ParseSigningKey(seed)
+		if err != nil {
+			log.Fatalf("error: invalid %s: %v", signingKeyKey, err)
+		}
+	}
+	if publicKey := os.Getenv(verifyKeyKey); publicKey != "" {
+		ledgerService.VerifyKey, err = ledger.ParseVerifyKey(publicKey)
+		if err != nil {
+			log.Fatalf("error: invalid %s: %v", verifyKeyKey, err)
+		}
+	}
+	if err := ledgerService.CheckKeys(); err != nil {
+		log.Fatalf("error: %s and %s do not match: %v", signingKeyKey, verifyKeyKey, err)
+	}
+	if err := ledgerService.SealUnhashed(ctx); err != nil {
+		log.Fatalf("error: couldnt seal ledger entries: %v", err)
+	}
+	if interval := durationEnv(checkpointIntervalKey); interval > 0 && ledgerService.SigningKey != nil {
+		go ledgerService.RunCheckpoints(ctx, interval)
+	}
+
+	snapshotsService := &snapshots.SnapshotsService{DB: db}
+	if interval := durationEnv(snapshotIntervalKey); interval > 0 {
+		go snapshotsService.RunPeriodic(ctx, interval)
+	}
 
+	tokensService := &tokens.TokensService{DB: db}
+	if value := os.Getenv(btpDecimalsKey); value != "" {
+		decimals, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			log.Fatalf("error: invalid %s: %v", btpDecimalsKey, err)
+		}
+		if _, err := tokensService.SetDecimals(ctx, tokens.DefaultSymbol, int32(decimals)); err != nil {
+			log.Fatalf("error: couldnt set %s decimals: %v", tokens.DefaultSymbol, err)
+		}
+	}
 
+	walletsService := &wallets.WalletsService{DB: db}
+	go walletsService.RunHoldExpiry(ctx, durationEnvOr(holdExpiryIntervalKey, defaultHoldExpiryInterval))
+	go walletsService.RunScheduler(ctx, durationEnvOr(schedulerIntervalKey, defaultSchedulerInterval))
+	go walletsService.RunDistributions(ctx, durationEnvOr(distributionIntervalKey, defaultDistributionInterval))
+
+	alertsService := &alerts.AlertsService{DB: db}
+	go alertsService.RunScanner(ctx, durationEnvOr(alertScanIntervalKey, defaultAlertScanInterval))
+
+	resolver := &graph.Resolver{
+		WalletsService:    walletsService,
+		LedgerService:     ledgerService,
+		SnapshotsService:  snapshotsService,
+		TokensService:     tokensService,
+		FeesService:       &fees.FeesService{DB: db},
+		ComplianceService: &compliance.ComplianceService{DB: db},
+		LimitsService:     &limits.LimitsService{DB: db},
+		PolicyService:     &policy.PolicyService{DB: db},
+		AlertsService:     alertsService,
+	}
 
-	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: &graph.Resolver{ WalletsService: walletsService}}))
+	if batchSize := os.Getenv(batchSizeKey); batchSize != "" {
+		size, err := strconv.Atoi(batchSize)
+		if err != nil {
+			log.Fatalf("error: invalid %s: %v", batchSizeKey, err)
+		}
+		resolver.TransferBatcher = wallets.NewTransferBatcher(walletsService, size, durationEnv(batchWaitKey))
+		defer resolver.TransferBatcher.Close()
+	}
 
+	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: resolver}))
+	srv.SetErrorPresenter(graph.ErrorPresenter)
 
+	router.Use(auth.Middleware(operators))
 	router.Handle("/", playground.Handler("GraphQL playground", "/query"))
 	router.Handle("/query", srv)
 
 	log.Printf("connect to http://localhost:%s/ for GraphQL playground", port)
 	log.Fatal(http.ListenAndServe(":"+port, router))
 }
+
+// durationEnv parses an optional duration variable, zero when it is unset.
+func durationEnv(key string) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("error: invalid %s: %v", key, err)
+	}
+	return d
+}
+
+func durationEnvOr(key string, fallback time.Duration) time.Duration {
+	if d := durationEnv(key); d > 0 {
+		return d
+	}
+	return fallback
+}
@@ -0,0 +1,126 @@
+package test
+
+import (
+	"btp_tokens/internal/merkle"
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/wallets"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAirdropList(t *testing.T) {
+	leaves, err := wallets.ParseAirdropList("address,amount\n0x01, 10\n0x02,2.5\n")
+	require.NoError(t, err)
+	require.Equal(t, []merkle.Leaf{
+		{Address: "0x01", Amount: decimal.NewFromInt(10)},
+		{Address: "0x02", Amount: decimal.RequireFromString("2.5")},
+	}, leaves)
+
+	for _, list := range []string{"", "address,amount", "0x01,1\n0x01,2", "0x01", "0x01,0", "0x01,1\n0x02,abc", "vesting:1,1"} {
+		_, err = wallets.ParseAirdropList(list)
+		require.ErrorIs(t, err, wallets.ErrorInvalidAirdropList, list)
+	}
+}
+
+func TestAirdrop(t *testing.T) {
+	treasury := "0x0000000000000000000000000000000000000001"
+	alice := "0x0000000000000000000000000000000000000002"
+	bob := "0x0000000000000000000000000000000000000003"
+	carol := "0x0000000000000000000000000000000000000004"
+	db, server := SetUpTest(t, []Wallet{{Address: treasury, Balance: decimal.NewFromInt(1000)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+
+	create := fmt.Sprintf(`mutation {
+		createAirdrop(input: {funder: "%s", recipients: "%s,100\n%s,50\n%s,25", expires_at: "%s"}) { id root total leaf_count }
+	}`, treasury, alice, bob, carol, time.Now().Add(time.Hour).Format(time.RFC3339))
+	require.Contains(t, doMutation(t, server.URL, create), "errors")
+	resp := doOperatorMutation(t, server.URL, create)
+	require.NotContains(t, resp, "errors")
+	airdrop := resp["data"].(map[string]interface{})["createAirdrop"].(map[string]interface{})
+	require.Equal(t, "175", airdrop["total"])
+	require.Equal(t, float64(3), airdrop["leaf_count"])
+	id := airdrop["id"].(string)
+	requireBalance(t, walletsService, treasury, 825)
+	requireBalance(t, walletsService, wallets.AirdropAccount(1), 175)
+
+	proofOf := func(address string) map[string]interface{} {
+		resp := doMutation(t, server.URL, fmt.Sprintf(`query {
+			airdropProof(id: "%s", address: "%s") { address amount root proof { hash side } claimed_at }
+		}`, id, address))
+		require.NotContains(t, resp, "errors")
+		claim, _ := resp["data"].(map[string]interface{})["airdropProof"].(map[string]interface{})
+		return claim
+	}
+	claimWith := func(address string, amount string, proof []interface{}) map[string]interface{} {
+		steps := make([]string, 0, len(proof))
+		for _, step := range proof {
+			step := step.(map[string]interface{})
+			steps = append(steps, fmt.Sprintf(`{hash: "%s", side: %s}`, step["hash"], step["side"]))
+		}
+		return doMutation(t, server.URL, fmt.Sprintf(`mutation {
+			claimAirdrop(input: {airdrop_id: "%s", address: "%s", amount: "%s", proof: [%s]}) { amount claimed_at transfer_id }
+		}`, id, address, amount, strings.Join(steps, ", ")))
+	}
+
+	claim := proofOf(alice)
+	require.Equal(t, airdrop["root"], claim["root"])
+	require.Equal(t, "100", claim["amount"])
+	require.Nil(t, claim["claimed_at"])
+	require.Nil(t, proofOf(treasury))
+
+	// the proof only holds for the amount of the leaf and its own address
+	requireCode(t, claimWith(alice, "150", claim["proof"].([]interface{})), "INVALID_PROOF")
+	requireCode(t, claimWith(bob, "100", claim["proof"].([]interface{})), "INVALID_PROOF")
+
+	resp = claimWith(alice, "100", claim["proof"].([]interface{}))
+	require.NotContains(t, resp, "errors")
+	paid := resp["data"].(map[string]interface{})["claimAirdrop"].(map[string]interface{})
+	require.Equal(t, "100", paid["amount"])
+	require.NotNil(t, paid["transfer_id"])
+	requireBalance(t, walletsService, alice, 100)
+	requireCode(t, claimWith(alice, "100", claim["proof"].([]interface{})), "ALREADY_CLAIMED")
+	requireBalance(t, walletsService, alice, 100)
+	require.NotNil(t, proofOf(alice)["claimed_at"])
+
+	claim = proofOf(bob)
+	require.NotContains(t, claimWith(bob, "50", claim["proof"].([]interface{})), "errors")
+	requireBalance(t, walletsService, bob, 50)
+
+	requireCode(t, doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { sweepAirdrop(id: "%s") { id } }`, id)), "AIRDROP_NOT_EXPIRED")
+
+	_, err := db.Exec("UPDATE Airdrops SET Expires_At = now() - INTERVAL '1 second' WHERE Id = $1", 1)
+	require.NoError(t, err)
+	claim = proofOf(carol)
+	requireCode(t, claimWith(carol, "25", claim["proof"].([]interface{})), "AIRDROP_EXPIRED")
+
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { sweepAirdrop(id: "%s") { swept swept_at claimed claim_count } }`, id))
+	require.NotContains(t, resp, "errors")
+	swept := resp["data"].(map[string]interface{})["sweepAirdrop"].(map[string]interface{})
+	require.Equal(t, "25", swept["swept"])
+	require.Equal(t, "150", swept["claimed"])
+	require.Equal(t, float64(2), swept["claim_count"])
+	requireBalance(t, walletsService, treasury, 850)
+	requireBalance(t, walletsService, wallets.AirdropAccount(1), 0)
+	requireCode(t, doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { sweepAirdrop(id: "%s") { id } }`, id)), "AIRDROP_SWEPT")
+
+	requireCode(t, doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation {
+		createAirdrop(input: {funder: "%s", recipients: "%s,1000", expires_at: "%s"}) { id }
+	}`, treasury, alice, time.Now().Add(time.Hour).Format(time.RFC3339))), "INSUFFICIENT_BALANCE")
+	requireCode(t, doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation {
+		createAirdrop(input: {funder: "%s", recipients: "%s,1\n%s,2", expires_at: "%s"}) { id }
+	}`, treasury, alice, alice, time.Now().Add(time.Hour).Format(time.RFC3339))), "INVALID_AIRDROP")
+
+	list, err := walletsService.ListAirdrops(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+}
@@ -0,0 +1,121 @@
+package test
+
+import (
+	"btp_tokens/internal/alerts"
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/tokens"
+	"btp_tokens/internal/wallets"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuspiciousActivityAlerts(t *testing.T) {
+	alice := "0x0000000000000000000000000000000000000001"
+	bob := "0x0000000000000000000000000000000000000002"
+	carol := "0x0000000000000000000000000000000000000003"
+	dave := "0x0000000000000000000000000000000000000004"
+	erin := "0x0000000000000000000000000000000000000005"
+	frank := "0x0000000000000000000000000000000000000006"
+	db, server := SetUpTest(t, []Wallet{
+		{Address: alice, Balance: decimal.NewFromInt(1000)},
+		{Address: bob, Balance: decimal.NewFromInt(1000)},
+		{Address: dave, Balance: decimal.NewFromInt(1000)},
+	})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+	alertsService := &alerts.AlertsService{DB: db}
+	send := func(from, to string, amount int64) {
+		_, err := walletsService.Send(ctx, tokens.DefaultSymbol, from, to, decimal.NewFromInt(amount))
+		require.NoError(t, err)
+	}
+
+	resp := doOperatorMutation(t, server.URL, `mutation {
+		a: configureAlertDetector(input: {name: "fan_out", thresholds: [{name: "receivers", value: "3"}]}) { name }
+		b: configureAlertDetector(input: {name: "structuring", severity: LOW, thresholds: [{name: "limit", value: "100"}]}) { name }
+	}`)
+	require.NotContains(t, resp, "errors")
+	resp = doOperatorMutation(t, server.URL, `mutation {
+		configureAlertDetector(input: {name: "fan_out", thresholds: [{name: "unknown", value: "3"}]}) { name }
+	}`)
+	extensions := resp["errors"].([]interface{})[0].(map[string]interface{})["extensions"].(map[string]interface{})
+	require.Equal(t, "INVALID_DETECTOR", extensions["code"])
+	requireCode(t, doOperatorMutation(t, server.URL, `mutation {
+		configureAlertDetector(input: {name: "circular", thresholds: [{name: "max_length", value: "50"}]}) { name }
+	}`), "INVALID_DETECTOR")
+
+	// alice fans out, dave structures just under 100 and bob's funds come back
+	// to him through carol and frank
+	send(alice, erin, 10)
+	send(alice, carol, 10)
+	send(alice, frank, 10)
+	send(dave, erin, 95)
+	send(dave, erin, 92)
+	send(dave, erin, 99)
+	send(bob, carol, 50)
+	send(carol, frank, 40)
+	send(frank, bob, 30)
+
+	raised, err := alertsService.Scan(ctx)
+	require.NoError(t, err)
+	byDetector := make(map[string]alerts.Alert)
+	for _, alert := range raised {
+		byDetector[alert.Detector] = alert
+	}
+	require.Len(t, byDetector, 3)
+	require.Equal(t, alice, byDetector[alerts.DetectorFanOut].Address)
+	require.Len(t, byDetector[alerts.DetectorFanOut].EvidenceEntryIDs, 3)
+	require.Equal(t, dave, byDetector[alerts.DetectorStructuring].Address)
+	require.Equal(t, alerts.SeverityLow, byDetector[alerts.DetectorStructuring].Severity)
+	require.Equal(t, frank, byDetector[alerts.DetectorCircular].Address)
+	require.Len(t, byDetector[alerts.DetectorCircular].EvidenceEntryIDs, 3)
+
+	// entries are scanned once and open alerts are not raised again
+	raised, err = alertsService.Scan(ctx)
+	require.NoError(t, err)
+	require.Empty(t, raised)
+	send(alice, dave, 10)
+	raised, err = alertsService.Scan(ctx)
+	require.NoError(t, err)
+	require.Empty(t, raised)
+
+	id := byDetector[alerts.DetectorFanOut].ID
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation {
+		a: acknowledgeAlert(id: "%d") { status }
+		b: escalateAlert(id: "%d", note: "many new receivers", severity: CRITICAL) { status severity }
+	}`, id, id))
+	require.NotContains(t, resp, "errors")
+	escalated := resp["data"].(map[string]interface{})["b"].(map[string]interface{})
+	require.Equal(t, "ESCALATED", escalated["status"])
+	require.Equal(t, "CRITICAL", escalated["severity"])
+
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { acknowledgeAlert(id: "%d") { status } }`, id))
+	extensions = resp["errors"].([]interface{})[0].(map[string]interface{})["extensions"].(map[string]interface{})
+	require.Equal(t, "INVALID_ALERT_CHANGE", extensions["code"])
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { closeAlert(id: "%d", note: " ") { status } }`, id))
+	require.Contains(t, resp, "errors")
+
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation {
+		closeAlert(id: "%d", note: "payroll wallet") { status history { action status note operator } }
+	}`, id))
+	require.NotContains(t, resp, "errors")
+	closed := resp["data"].(map[string]interface{})["closeAlert"].(map[string]interface{})
+	require.Equal(t, "CLOSED", closed["status"])
+	history := closed["history"].([]interface{})
+	require.Len(t, history, 4)
+	require.Equal(t, "raise", history[0].(map[string]interface{})["action"])
+	require.Equal(t, testOperator, history[3].(map[string]interface{})["operator"])
+
+	resp = doOperatorMutation(t, server.URL, `query { alerts(status: OPEN) { detector address evidence_transfer_ids } }`)
+	require.NotContains(t, resp, "errors")
+	require.Len(t, resp["data"].(map[string]interface{})["alerts"], 2)
+
+	resp = doMutation(t, server.URL, `query { alerts { id } }`)
+	require.Contains(t, resp, "errors")
+}
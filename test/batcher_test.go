@@ -0,0 +1,100 @@
+package test
+
+import (
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/wallets"
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+const benchSender = "0x0000000000000000000000000000000000000001"
+
+func benchTransfers(b *testing.B, transfer func(ctx context.Context, from, to string, amount decimal.Decimal) (decimal.Decimal, error)) {
+	var counter atomic.Int64
+	amount := decimal.NewFromInt(1)
+
+	b.SetParallelism(16)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			to := fmt.Sprintf("0x%040d", 2+counter.Add(1)%64)
+			if _, err := transfer(context.Background(), benchSender, to, amount); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkTransferDirect(b *testing.B) {
+	db, server := SetUpTest(b, []Wallet{
+		{Address: benchSender, Balance: decimal.NewFromInt(1000000000)},
+	})
+	defer database.CloseDB()
+	defer server.Close()
+
+	walletsService := &wallets.WalletsService{DB: db}
+	benchTransfers(b, walletsService.Transfer)
+}
+
+func BenchmarkTransferBatched(b *testing.B) {
+	db, server := SetUpTest(b, []Wallet{
+		{Address: benchSender, Balance: decimal.NewFromInt(1000000000)},
+	})
+	defer database.CloseDB()
+	defer server.Close()
+
+	batcher := wallets.NewTransferBatcher(&wallets.WalletsService{DB: db}, 64, 2*time.Millisecond)
+	defer batcher.Close()
+	benchTransfers(b, batcher.Transfer)
+}
+
+func TestTransferBatcherIsolatesFailures(t *testing.T) {
+	initial_wallets := []Wallet{
+		{Address: "0x0000000000000000000000000000000000000001", Balance: decimal.NewFromInt(10)},
+		{Address: "0x0000000000000000000000000000000000000002", Balance: decimal.NewFromInt(0)},
+	}
+	db, server := SetUpTest(t, initial_wallets)
+	defer database.CloseDB()
+	defer server.Close()
+
+	walletsService := &wallets.WalletsService{DB: db}
+	batcher := wallets.NewTransferBatcher(walletsService, 8, 50*time.Millisecond)
+	defer batcher.Close()
+
+	amounts := []int64{4, 4, 4, 100}
+	errs := make([]error, len(amounts))
+	done := make(chan int, len(amounts))
+	for i, amount := range amounts {
+		go func(i int, amount int64) {
+			_, errs[i] = batcher.Transfer(context.Background(), initial_wallets[0].Address, initial_wallets[1].Address, decimal.NewFromInt(amount))
+			done <- i
+		}(i, amount)
+	}
+	for range amounts {
+		<-done
+	}
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			require.ErrorIs(t, err, wallets.ErrorInsufficientBalance)
+			failed++
+		}
+	}
+	require.Equal(t, 2, failed)
+
+	senderBalance, err := walletsService.GetWalletBalance(context.Background(), initial_wallets[0].Address)
+	require.NoError(t, err)
+	require.True(t, senderBalance.Equal(decimal.NewFromInt(2)), "sender balance: %s", senderBalance)
+
+	receiverBalance, err := walletsService.GetWalletBalance(context.Background(), initial_wallets[1].Address)
+	require.NoError(t, err)
+	require.True(t, receiverBalance.Equal(decimal.NewFromInt(8)), "receiver balance: %s", receiverBalance)
+}
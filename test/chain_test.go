@@ -0,0 +1,142 @@
+package test
+
+import (
+	"btp_tokens/internal/ledger"
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/wallets"
+	"context"
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func setUpChainTest(t *testing.T) *ledger.LedgerService {
+	initial_wallets := []Wallet{
+		{Address: "0x0000000000000000000000000000000000000001", Balance: decimal.NewFromInt(100)},
+		{Address: "0x0000000000000000000000000000000000000002", Balance: decimal.NewFromInt(50)},
+	}
+	db, server := SetUpTest(t, initial_wallets)
+	t.Cleanup(func() {
+		server.Close()
+		database.CloseDB()
+	})
+
+	walletsService := &wallets.WalletsService{DB: db}
+	for i := 0; i < 3; i++ {
+		_, err := walletsService.Transfer(context.Background(), initial_wallets[0].Address, initial_wallets[1].Address, decimal.NewFromInt(10))
+		require.NoError(t, err)
+	}
+
+	return &ledger.LedgerService{DB: db, SigningKey: ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))}
+}
+
+func TestLedgerChainIsValid(t *testing.T) {
+	ledgerService := setUpChainTest(t)
+
+	checkpoint, err := ledgerService.Checkpoint(context.Background())
+	require.NoError(t, err)
+	require.EqualValues(t, 5, checkpoint.EntryID)
+
+	result, err := ledgerService.VerifyChain(context.Background())
+	require.NoError(t, err)
+	require.True(t, result.Valid, result.Reason)
+	require.Equal(t, 5, result.EntriesChecked)
+	require.Equal(t, 1, result.CheckpointsChecked)
+	require.Equal(t, checkpoint.Hash, result.HeadHash)
+}
+
+func TestLedgerChainDetectsEditedEntry(t *testing.T) {
+	ledgerService := setUpChainTest(t)
+
+	_, err := ledgerService.DB.Exec("UPDATE Postings SET Credit = 1000 WHERE Entry_Id = 4 AND Credit > 0")
+	require.NoError(t, err)
+	_, err = ledgerService.DB.Exec("UPDATE Postings SET Debit = 1000 WHERE Entry_Id = 4 AND Debit > 0")
+	require.NoError(t, err)
+
+	result, err := ledgerService.VerifyChain(context.Background())
+	require.NoError(t, err)
+	require.False(t, result.Valid)
+	require.EqualValues(t, 4, result.BrokenEntryID)
+	require.Equal(t, "entry contents do not match its hash", result.Reason)
+}
+
+func TestLedgerChainDetectsDeletedEntry(t *testing.T) {
+	ledgerService := setUpChainTest(t)
+
+	_, err := ledgerService.DB.Exec("DELETE FROM Postings WHERE Entry_Id = 3")
+	require.NoError(t, err)
+	_, err = ledgerService.DB.Exec("DELETE FROM Journal_Entries WHERE Id = 3")
+	require.NoError(t, err)
+
+	result, err := ledgerService.VerifyChain(context.Background())
+	require.NoError(t, err)
+	require.False(t, result.Valid)
+	require.EqualValues(t, 4, result.BrokenEntryID)
+}
+
+func TestLedgerChainDetectsForgedCheckpoint(t *testing.T) {
+	ledgerService := setUpChainTest(t)
+
+	_, err := ledgerService.Checkpoint(context.Background())
+	require.NoError(t, err)
+	_, err = ledgerService.DB.Exec("UPDATE Ledger_Checkpoints SET Signature = repeat('00', 64)")
+	require.NoError(t, err)
+
+	result, err := ledgerService.VerifyChain(context.Background())
+	require.NoError(t, err)
+	require.False(t, result.Valid)
+	require.Equal(t, "checkpoint signature is invalid", result.Reason)
+}
+
+func TestLedgerChainVerifiedWithPublicKey(t *testing.T) {
+	ledgerService := setUpChainTest(t)
+
+	_, err := ledgerService.Checkpoint(context.Background())
+	require.NoError(t, err)
+
+	auditor := &ledger.LedgerService{DB: ledgerService.DB}
+	_, err = auditor.VerifyChain(context.Background())
+	require.ErrorIs(t, err, ledger.ErrorVerifyKeyMissing)
+
+	auditor.VerifyKey = ledgerService.SigningKey.Public().(ed25519.PublicKey)
+	result, err := auditor.VerifyChain(context.Background())
+	require.NoError(t, err)
+	require.True(t, result.Valid, result.Reason)
+	require.Equal(t, 1, result.CheckpointsChecked)
+
+	otherKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	auditor.VerifyKey = otherKey
+	result, err = auditor.VerifyChain(context.Background())
+	require.NoError(t, err)
+	require.False(t, result.Valid)
+	require.Equal(t, "checkpoint signature is invalid", result.Reason)
+}
+
+func TestVerifyLedgerRequiresOperator(t *testing.T) {
+	_, server := SetUpTest(t, nil)
+	defer database.CloseDB()
+	defer server.Close()
+
+	resp := doMutation(t, server.URL, `query { verifyLedger { valid } }`)
+	assertGraphQLError(t, resp, "operator authorization required")
+
+	resp = doOperatorMutation(t, server.URL, `query { verifyLedger { valid } }`)
+	require.Equal(t, true, resp["data"].(map[string]interface{})["verifyLedger"].(map[string]interface{})["valid"])
+}
+
+func TestLedgerKeysMustMatch(t *testing.T) {
+	signingKey := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	ledgerService := &ledger.LedgerService{SigningKey: signingKey}
+	require.NoError(t, ledgerService.CheckKeys())
+
+	ledgerService.VerifyKey = signingKey.Public().(ed25519.PublicKey)
+	require.NoError(t, ledgerService.CheckKeys())
+
+	otherKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	ledgerService.VerifyKey = otherKey
+	require.ErrorIs(t, ledgerService.CheckKeys(), ledger.ErrorKeyMismatch)
+}
@@ -0,0 +1,113 @@
+package test
+
+import (
+	"btp_tokens/internal/compliance"
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/tokens"
+	"btp_tokens/internal/wallets"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFreezeWallet(t *testing.T) {
+	alice := "0x0000000000000000000000000000000000000001"
+	bob := "0x0000000000000000000000000000000000000002"
+	db, server := SetUpTest(t, []Wallet{
+		{Address: alice, Balance: decimal.NewFromInt(100)},
+		{Address: bob, Balance: decimal.NewFromInt(100)},
+	})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+
+	resp := doMutation(t, server.URL, fmt.Sprintf(`mutation { freezeWallet(address: "%s", reason: "fraud") { status } }`, alice))
+	assertGraphQLError(t, resp, "operator authorization required")
+
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation {
+		freezeWallet(address: "%s", status: FROZEN_OUTGOING, reason: "account takeover") { status reason updated_by }
+	}`, alice))
+	require.NotContains(t, resp, "errors")
+	status := resp["data"].(map[string]interface{})["freezeWallet"].(map[string]interface{})
+	require.Equal(t, "FROZEN_OUTGOING", status["status"])
+	require.Equal(t, "account takeover", status["reason"])
+	require.Equal(t, testOperator, status["updated_by"])
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation {
+		sendTransfer(input: {from_address: "%s", to_address: "%s", amount: "10"}) { id }
+	}`, alice, bob))
+	extensions := resp["errors"].([]interface{})[0].(map[string]interface{})["extensions"].(map[string]interface{})
+	require.Equal(t, "WALLET_FROZEN", extensions["code"])
+
+	// incoming transfers still work
+	_, err := walletsService.Send(ctx, tokens.DefaultSymbol, bob, alice, decimal.NewFromInt(10))
+	require.NoError(t, err)
+
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { freezeWallet(address: "%s", reason: "investigation") { status } }`, alice))
+	require.NotContains(t, resp, "errors")
+	_, err = walletsService.Send(ctx, tokens.DefaultSymbol, bob, alice, decimal.NewFromInt(10))
+	require.ErrorIs(t, err, compliance.ErrorReceiverFrozen)
+	_, err = walletsService.Mint(ctx, tokens.DefaultSymbol, alice, decimal.NewFromInt(10))
+	require.ErrorIs(t, err, compliance.ErrorReceiverFrozen)
+
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { unfreezeWallet(address: "%s", reason: "cleared") { status } }`, alice))
+	require.NotContains(t, resp, "errors")
+	_, err = walletsService.Send(ctx, tokens.DefaultSymbol, alice, bob, decimal.NewFromInt(10))
+	require.NoError(t, err)
+	requireBalance(t, walletsService, alice, 100)
+
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`query { addressStatus(address: "%s") { status history { action status reason operator } } }`, alice))
+	require.NotContains(t, resp, "errors")
+	history := resp["data"].(map[string]interface{})["addressStatus"].(map[string]interface{})["history"].([]interface{})
+	require.Len(t, history, 3)
+	require.Equal(t, "freeze", history[0].(map[string]interface{})["action"])
+	require.Equal(t, "unfreeze", history[2].(map[string]interface{})["action"])
+	require.Equal(t, "cleared", history[2].(map[string]interface{})["reason"])
+}
+
+func TestDenyList(t *testing.T) {
+	alice := "0x0000000000000000000000000000000000000001"
+	sanctioned := "0x00000000000000000000000000000000000000aa"
+	db, server := SetUpTest(t, []Wallet{
+		{Address: alice, Balance: decimal.NewFromInt(100)},
+		{Address: sanctioned, Balance: decimal.NewFromInt(100)},
+	})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+	complianceService := &compliance.ComplianceService{DB: db}
+
+	resp := doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation {
+		importDenyList(list: "ofac", addresses: ["%s", "0x00000000000000000000000000000000000000bb"], reason: "sanctions update")
+	}`, sanctioned))
+	require.NotContains(t, resp, "errors")
+	require.Equal(t, float64(2), resp["data"].(map[string]interface{})["importDenyList"])
+
+	// denied addresses can neither send nor receive
+	_, err := walletsService.Send(ctx, tokens.DefaultSymbol, alice, sanctioned, decimal.NewFromInt(10))
+	require.ErrorIs(t, err, compliance.ErrorAddressDenied)
+	_, err = walletsService.Send(ctx, tokens.DefaultSymbol, sanctioned, alice, decimal.NewFromInt(10))
+	require.ErrorIs(t, err, compliance.ErrorAddressDenied)
+	requireBalance(t, walletsService, alice, 100)
+
+	// importing again with replace drops the addresses missing from the list
+	added, err := complianceService.ImportDenyList(ctx, "ofac", []string{"0x00000000000000000000000000000000000000bb"}, true, "delisted", testOperator)
+	require.NoError(t, err)
+	require.Equal(t, 0, added)
+	denied, err := complianceService.DeniedAddresses(ctx, "ofac")
+	require.NoError(t, err)
+	require.Equal(t, []string{"0x00000000000000000000000000000000000000bb"}, denied)
+
+	_, err = walletsService.Send(ctx, tokens.DefaultSymbol, sanctioned, alice, decimal.NewFromInt(10))
+	require.NoError(t, err)
+
+	err = complianceService.RemoveFromDenyList(ctx, sanctioned, "again", testOperator)
+	require.ErrorIs(t, err, compliance.ErrorNotDenied)
+}
@@ -0,0 +1,49 @@
+package test
+
+import (
+	"btp_tokens/internal/cron"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronNext(t *testing.T) {
+	// a Wednesday
+	from := time.Date(2026, 1, 14, 10, 7, 30, 0, time.UTC)
+
+	cases := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2026, 1, 14, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 1, 14, 10, 15, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2026, 1, 15, 9, 0, 0, 0, time.UTC)},
+		{"30 8-17/3 * * *", time.Date(2026, 1, 14, 11, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 1-5", time.Date(2026, 1, 14, 12, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2026, 1, 18, 12, 0, 0, 0, time.UTC)},
+		// day of month or day of week when both are set
+		{"0 0 20 * 5", time.Date(2026, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"5,10 10 * * *", time.Date(2026, 1, 14, 10, 10, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		schedule, err := cron.Parse(c.expr)
+		require.NoError(t, err, c.expr)
+		next, err := schedule.Next(from)
+		require.NoError(t, err, c.expr)
+		require.Equal(t, c.next, next, c.expr)
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := cron.Parse(expr)
+		require.Error(t, err, expr)
+	}
+
+	never, err := cron.Parse("0 0 30 2 *")
+	require.NoError(t, err)
+	_, err = never.Next(from)
+	require.ErrorIs(t, err, cron.ErrorNoNextTime)
+}
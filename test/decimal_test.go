@@ -0,0 +1,100 @@
+package test
+
+import (
+	"btp_tokens/graph/model"
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func marshalDecimal(d model.Decimal) string {
+	var buf bytes.Buffer
+	d.MarshalGQL(&buf)
+	return buf.String()
+}
+
+func TestDecimalUnmarshalInputs(t *testing.T) {
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+
+	accepted := []struct {
+		input    interface{}
+		expected string
+	}{
+		{"12.5", "12.5"},
+		{json.Number("9007199254740993"), "9007199254740993"},
+		{json.Number("115792089237316195423570985008687907853269984665640564039457584007913129639935"), "115792089237316195423570985008687907853269984665640564039457584007913129639935"},
+		{json.Number("0.000000000000000001"), "0.000000000000000001"},
+		{int64(-42), "-42"},
+		{uint64(math.MaxUint64), "18446744073709551615"},
+		{uint8(7), "7"},
+		{huge, "123456789012345678901234567890"},
+		{float64(1.5), "1.5"},
+		{float64(0.1), "0.1"},
+		{float64(9007199254740992), "9007199254740992"},
+	}
+	for _, c := range accepted {
+		var d model.Decimal
+		require.NoError(t, d.UnmarshalGQL(c.input), "input %v (%T)", c.input, c.input)
+		require.Equal(t, strconv.Quote(c.expected), marshalDecimal(d))
+	}
+
+	rejected := []interface{}{
+		float64(9007199254740994),
+		float64(0.1234567890123456),
+		math.NaN(),
+		math.Inf(1),
+		json.Number("1e999999999"),
+		"1e-200",
+		strings.Repeat("9", model.MaxDecimalDigits+1),
+		"abc",
+		true,
+	}
+	for _, input := range rejected {
+		var d model.Decimal
+		require.Error(t, d.UnmarshalGQL(input), "input %v (%T)", input, input)
+	}
+}
+
+func FuzzDecimalRoundTrip(f *testing.F) {
+	for _, seed := range []string{"0", "1", "-1.5", "0.000000000000000001", "9007199254740993", "1e10", "1.23E-5", strings.Repeat("9", model.MaxDecimalDigits)} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		var d model.Decimal
+		if err := d.UnmarshalGQL(input); err != nil {
+			return
+		}
+
+		out, err := strconv.Unquote(marshalDecimal(d))
+		require.NoError(t, err)
+
+		var again model.Decimal
+		require.NoError(t, again.UnmarshalGQL(json.Number(out)), "marshalled %q from %q", out, input)
+		require.True(t, decimal.Decimal(d).Equal(decimal.Decimal(again)), "%q became %q", input, out)
+		require.Equal(t, out, marshalDecimal(again)[1:len(marshalDecimal(again))-1])
+	})
+}
+
+func FuzzDecimalFloatInput(f *testing.F) {
+	for _, seed := range []float64{0, 1.5, 0.1, -2.25, 1e-7, 9007199254740992, 1e300} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input float64) {
+		var d model.Decimal
+		if err := d.UnmarshalGQL(input); err != nil {
+			return
+		}
+		// an accepted float must be exactly the number it was parsed from
+		back, _ := decimal.Decimal(d).Float64()
+		require.Equal(t, input, back)
+	})
+}
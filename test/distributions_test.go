@@ -0,0 +1,240 @@
+package test
+
+import (
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/snapshots"
+	"btp_tokens/internal/wallets"
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func decimals(values ...string) []decimal.Decimal {
+	list := make([]decimal.Decimal, len(values))
+	for i, value := range values {
+		list[i] = decimal.RequireFromString(value)
+	}
+	return list
+}
+
+func TestAllocate(t *testing.T) {
+	cases := []struct {
+		total    string
+		weights  []string
+		decimals int32
+		shares   []string
+	}{
+		{"100", []string{"1", "1", "1"}, 0, []string{"34", "33", "33"}},
+		// the leftover unit goes to the largest remainder, not the first weight
+		{"10", []string{"1", "2", "4"}, 0, []string{"1", "3", "6"}},
+		{"10", []string{"3", "3", "3", "1"}, 0, []string{"3", "3", "3", "1"}},
+		{"1", []string{"1", "1", "1"}, 2, []string{"0.34", "0.33", "0.33"}},
+		{"2", []string{"1", "1", "1"}, 0, []string{"1", "1", "0"}},
+		// the total is truncated to the decimals first
+		{"0.5", []string{"1", "1"}, 0, []string{"0", "0"}},
+		{"10.9", []string{"1", "1"}, 0, []string{"5", "5"}},
+		{"100", []string{"0", "0"}, 0, []string{"0", "0"}},
+	}
+	for _, c := range cases {
+		shares := wallets.Allocate(decimal.RequireFromString(c.total), decimals(c.weights...), c.decimals)
+		for i, share := range shares {
+			require.True(t, share.Equal(decimal.RequireFromString(c.shares[i])), "%s over %v: got %v", c.total, c.weights, shares)
+		}
+	}
+
+	// shares always add up to the total
+	weights := decimals("7", "13", "0.5", "1000", "3.25", "42")
+	for total := int64(1); total < 500; total += 7 {
+		sum := decimal.Zero
+		for _, share := range wallets.Allocate(decimal.NewFromInt(total), weights, 0) {
+			sum = sum.Add(share)
+		}
+		require.True(t, sum.Equal(decimal.NewFromInt(total)), "total %d", total)
+	}
+}
+
+func TestParseRecipientList(t *testing.T) {
+	weights, err := wallets.ParseRecipientList("address,weight\n0x01, 2\n0x02\n0x03,0.5\n")
+	require.NoError(t, err)
+	require.Len(t, weights, 3)
+	require.Equal(t, "2", weights["0x01"].String())
+	require.Equal(t, "1", weights["0x02"].String())
+	require.Equal(t, "0.5", weights["0x03"].String())
+
+	weights, err = wallets.ParseRecipientList("address\n0x01\n0x02\n")
+	require.NoError(t, err)
+	require.Len(t, weights, 2)
+	require.NotContains(t, weights, "address")
+
+	for _, list := range []string{"0x01,1\n0x01,2", "0x01,1\n0x02,abc", "0x01,0", "0x01,-1", "0x01,1,1", ",1"} {
+		_, err = wallets.ParseRecipientList(list)
+		require.ErrorIs(t, err, wallets.ErrorInvalidRecipientList, list)
+	}
+}
+
+func TestDistribution(t *testing.T) {
+	treasury := "0x0000000000000000000000000000000000000001"
+	holders := []string{
+		"0x0000000000000000000000000000000000000002",
+		"0x0000000000000000000000000000000000000003",
+		"0x0000000000000000000000000000000000000004",
+		"0x0000000000000000000000000000000000000005",
+	}
+	db, server := SetUpTest(t, []Wallet{
+		{Address: treasury, Balance: decimal.NewFromInt(1000)},
+		{Address: holders[0], Balance: decimal.NewFromInt(10)},
+		{Address: holders[1], Balance: decimal.NewFromInt(10)},
+		{Address: holders[2], Balance: decimal.NewFromInt(10)},
+	})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+	snapshotsService := &snapshots.SnapshotsService{DB: db}
+
+	snapshot, err := snapshotsService.Create(ctx)
+	require.NoError(t, err)
+	// balances changing after the snapshot do not change the shares
+	_, err = walletsService.Transfer(ctx, holders[2], holders[3], decimal.NewFromInt(10))
+	require.NoError(t, err)
+
+	create := fmt.Sprintf(`mutation {
+		createDistribution(input: {from_address: "%s", total: "100", snapshot_id: "%d"}) {
+			id status recipient_count recipients { address amount status }
+		}
+	}`, treasury, snapshot.ID)
+	require.Contains(t, doMutation(t, server.URL, create), "errors")
+	resp := doOperatorMutation(t, server.URL, create)
+	require.NotContains(t, resp, "errors")
+	distribution := resp["data"].(map[string]interface{})["createDistribution"].(map[string]interface{})
+	require.Equal(t, "PENDING", distribution["status"])
+	// the treasury is a holder too but gets no share of its own distribution
+	require.Equal(t, float64(3), distribution["recipient_count"])
+	recipients := distribution["recipients"].([]interface{})
+	require.Equal(t, "34", recipients[0].(map[string]interface{})["amount"])
+	require.Equal(t, "33", recipients[1].(map[string]interface{})["amount"])
+	require.Equal(t, holders[2], recipients[2].(map[string]interface{})["address"])
+	requireBalance(t, walletsService, treasury, 1000)
+
+	id, err := strconv.ParseInt(distribution["id"].(string), 10, 64)
+	require.NoError(t, err)
+	// starting reserves the total
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { startDistribution(id: "%d") { status started_at } }`, id))
+	require.NotContains(t, resp, "errors")
+	require.Equal(t, "RUNNING", resp["data"].(map[string]interface{})["startDistribution"].(map[string]interface{})["status"])
+	requireCode(t, doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { startDistribution(id: "%d") { id } }`, id)), "DISTRIBUTION_NOT_PENDING")
+	requireBalance(t, walletsService, treasury, 900)
+	requireBalance(t, walletsService, wallets.DistributionAccount(id), 100)
+
+	// the runner stops after a first batch, as if it crashed, and resumes
+	more, err := walletsService.RunDistributionBatch(ctx, id, 2)
+	require.NoError(t, err)
+	require.True(t, more)
+	requireBalance(t, walletsService, holders[0], 44)
+	requireBalance(t, walletsService, holders[1], 43)
+	requireBalance(t, walletsService, holders[2], 0)
+	pending, err := walletsService.DistributionRecipients(ctx, id, wallets.RecipientPending, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { freezeWallet(address: "%s", reason: "investigation") { status } }`, holders[2]))
+	require.NotContains(t, resp, "errors")
+	result, err := walletsService.ProcessDistribution(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, wallets.DistributionCompleted, result.Status)
+	require.Equal(t, 2, result.PaidCount)
+	require.Equal(t, 1, result.FailedCount)
+	require.Equal(t, "67", result.PaidAmount.String())
+	requireBalance(t, walletsService, treasury, 900)
+	requireBalance(t, walletsService, wallets.DistributionAccount(id), 33)
+	requireBalance(t, walletsService, holders[2], 0)
+
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`query {
+		distribution(id: "%d") { status completed_at recipients(status: FAILED) { address status error transfer_id } }
+	}`, id))
+	require.NotContains(t, resp, "errors")
+	failed := resp["data"].(map[string]interface{})["distribution"].(map[string]interface{})["recipients"].([]interface{})
+	require.Len(t, failed, 1)
+	require.Equal(t, holders[2], failed[0].(map[string]interface{})["address"])
+	require.NotEmpty(t, failed[0].(map[string]interface{})["error"])
+	require.Nil(t, failed[0].(map[string]interface{})["transfer_id"])
+
+	// a completed distribution is not run again
+	more, err = walletsService.RunDistributionBatch(ctx, id, 2)
+	require.NoError(t, err)
+	require.False(t, more)
+	requireBalance(t, walletsService, wallets.DistributionAccount(id), 33)
+
+	// the failed recipient is paid from the reserve once it is unfrozen
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { unfreezeWallet(address: "%s", reason: "cleared") { status } }`, holders[2]))
+	require.NotContains(t, resp, "errors")
+	retry := fmt.Sprintf(`mutation { retryDistribution(id: "%d") { status failed_count } }`, id)
+	require.Contains(t, doMutation(t, server.URL, retry), "errors")
+	resp = doOperatorMutation(t, server.URL, retry)
+	require.NotContains(t, resp, "errors")
+	require.Equal(t, "RUNNING", resp["data"].(map[string]interface{})["retryDistribution"].(map[string]interface{})["status"])
+	result, err = walletsService.ProcessDistribution(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, 3, result.PaidCount)
+	require.Equal(t, "100", result.PaidAmount.String())
+	requireBalance(t, walletsService, holders[2], 33)
+	requireBalance(t, walletsService, wallets.DistributionAccount(id), 0)
+	requireCode(t, doOperatorMutation(t, server.URL, retry), "INVALID_DISTRIBUTION_STATE")
+
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation {
+		createDistribution(input: {from_address: "%s", total: "10", recipients: "address,weight\n%s,1\n%s,3"}) { id recipients { amount } }
+	}`, treasury, holders[0], holders[3]))
+	require.NotContains(t, resp, "errors")
+	created := resp["data"].(map[string]interface{})["createDistribution"].(map[string]interface{})
+	recipients = created["recipients"].([]interface{})
+	require.Equal(t, "3", recipients[0].(map[string]interface{})["amount"])
+	require.Equal(t, "7", recipients[1].(map[string]interface{})["amount"])
+
+	// closing returns the shares of the failed recipients
+	listID, err := strconv.ParseInt(created["id"].(string), 10, 64)
+	require.NoError(t, err)
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { freezeWallet(address: "%s", reason: "investigation") { status } }`, holders[3]))
+	require.NotContains(t, resp, "errors")
+	require.NotContains(t, doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { startDistribution(id: "%d") { id } }`, listID)), "errors")
+	requireCode(t, doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { closeDistribution(id: "%d") { id } }`, listID)), "INVALID_DISTRIBUTION_STATE")
+	_, err = walletsService.ProcessDistribution(ctx, listID)
+	require.NoError(t, err)
+	requireBalance(t, walletsService, treasury, 890)
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { closeDistribution(id: "%d") { status returned paid_amount } }`, listID))
+	require.NotContains(t, resp, "errors")
+	closed := resp["data"].(map[string]interface{})["closeDistribution"].(map[string]interface{})
+	require.Equal(t, "CLOSED", closed["status"])
+	require.Equal(t, "7", closed["returned"])
+	require.Equal(t, "3", closed["paid_amount"])
+	requireBalance(t, walletsService, treasury, 897)
+	requireBalance(t, walletsService, wallets.DistributionAccount(listID), 0)
+	requireCode(t, doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { retryDistribution(id: "%d") { id } }`, listID)), "INVALID_DISTRIBUTION_STATE")
+
+	// a distribution the funder cannot cover does not start
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation {
+		createDistribution(input: {from_address: "%s", total: "5000", recipients: "%s"}) { id }
+	}`, treasury, holders[0]))
+	require.NotContains(t, resp, "errors")
+	large := resp["data"].(map[string]interface{})["createDistribution"].(map[string]interface{})["id"]
+	requireCode(t, doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { startDistribution(id: "%s") { id } }`, large)), "INSUFFICIENT_BALANCE")
+	requireBalance(t, walletsService, treasury, 897)
+
+	requireCode(t, doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation {
+		createDistribution(input: {from_address: "%s", total: "10"}) { id }
+	}`, treasury)), "INVALID_DISTRIBUTION")
+	requireCode(t, doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation {
+		createDistribution(input: {from_address: "%s", total: "10", recipients: "%s,1\n%s,2"}) { id }
+	}`, treasury, holders[0], holders[0])), "INVALID_DISTRIBUTION")
+	requireCode(t, doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation {
+		createDistribution(input: {from_address: "%s", total: "10", snapshot_id: "%d"}) { id }
+	}`, treasury, snapshot.ID+100)), "SNAPSHOT_NOT_FOUND")
+
+	resp = doOperatorMutation(t, server.URL, `query { distributions { id status } }`)
+	require.NotContains(t, resp, "errors")
+	require.Len(t, resp["data"].(map[string]interface{})["distributions"], 3)
+}
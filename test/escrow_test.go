@@ -0,0 +1,181 @@
+package test
+
+import (
+	"btp_tokens/internal/ledger"
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/tokens"
+	"btp_tokens/internal/wallets"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	escrowBuyer   = "0x0000000000000000000000000000000000000001"
+	escrowSeller  = "0x0000000000000000000000000000000000000002"
+	escrowArbiter = "0x0000000000000000000000000000000000000003"
+)
+
+func newTestEscrow(t *testing.T, s *wallets.WalletsService, amount int64, deadline time.Time) wallets.Escrow {
+	escrow, err := s.CreateEscrow(context.Background(), wallets.Escrow{
+		Token:    tokens.DefaultSymbol,
+		Buyer:    escrowBuyer,
+		Seller:   escrowSeller,
+		Arbiter:  escrowArbiter,
+		Amount:   decimal.NewFromInt(amount),
+		Deadline: deadline,
+	})
+	require.NoError(t, err)
+	return escrow
+}
+
+func requireBalance(t *testing.T, s *wallets.WalletsService, address string, expected int64) {
+	balance, err := s.GetWalletBalance(context.Background(), address)
+	require.NoError(t, err)
+	require.True(t, balance.Equal(decimal.NewFromInt(expected)), "balance of %s: expected %d, got %s", address, expected, balance)
+}
+
+func TestEscrowReleasedAfterBothApprove(t *testing.T) {
+	db, server := SetUpTest(t, []Wallet{{Address: escrowBuyer, Balance: decimal.NewFromInt(100)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	resp := doMutation(t, server.URL, fmt.Sprintf(`mutation {
+		createEscrow(input: {buyer: "%s", seller: "%s", arbiter: "%s", amount: "60", deadline: "%s"}) { id status }
+	}`, escrowBuyer, escrowSeller, escrowArbiter, time.Now().Add(time.Hour).Format(time.RFC3339)))
+	require.NotContains(t, resp, "errors")
+	created := resp["data"].(map[string]interface{})["createEscrow"].(map[string]interface{})
+	require.Equal(t, "FUNDED", created["status"])
+	id := created["id"].(string)
+
+	walletsService := &wallets.WalletsService{DB: db}
+	requireBalance(t, walletsService, escrowBuyer, 40)
+	requireBalance(t, walletsService, wallets.EscrowAccount(1), 60)
+
+	// not approved by both yet
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation { releaseEscrow(id: "%s") { status } }`, id))
+	extensions := resp["errors"].([]interface{})[0].(map[string]interface{})["extensions"].(map[string]interface{})
+	require.Equal(t, "INVALID_ESCROW_STATE", extensions["code"])
+
+	// a party cannot approve without an operator
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation { approveEscrow(id: "%s", party: "%s") { status } }`, id, escrowBuyer))
+	requireCode(t, resp, "UNAUTHORIZED")
+
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { approveEscrow(id: "%s", party: "%s") { status } }`, id, escrowArbiter))
+	extensions = resp["errors"].([]interface{})[0].(map[string]interface{})["extensions"].(map[string]interface{})
+	require.Equal(t, "NOT_ESCROW_PARTY", extensions["code"])
+
+	for _, party := range []string{escrowBuyer, escrowSeller} {
+		resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { approveEscrow(id: "%s", party: "%s") { status } }`, id, party))
+		require.NotContains(t, resp, "errors")
+	}
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation { releaseEscrow(id: "%s") { status } }`, id))
+	require.NotContains(t, resp, "errors")
+
+	requireBalance(t, walletsService, escrowSeller, 60)
+	requireBalance(t, walletsService, wallets.EscrowAccount(1), 0)
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`query { escrow(id: "%s") { status history { action from_status to_status actor transfer_id } } }`, id))
+	escrow := resp["data"].(map[string]interface{})["escrow"].(map[string]interface{})
+	require.Equal(t, "RELEASED", escrow["status"])
+
+	history := escrow["history"].([]interface{})
+	require.Len(t, history, 4)
+	actions := []string{"create", "approve", "approve", "release"}
+	statuses := []string{"FUNDED", "FUNDED", "APPROVED", "RELEASED"}
+	for i, raw := range history {
+		event := raw.(map[string]interface{})
+		require.Equal(t, actions[i], event["action"])
+		require.Equal(t, statuses[i], event["to_status"])
+	}
+	require.Nil(t, history[0].(map[string]interface{})["from_status"])
+	require.NotNil(t, history[3].(map[string]interface{})["transfer_id"])
+
+	mismatches, err := (&ledger.LedgerService{DB: db}).VerifyBalances(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, mismatches)
+}
+
+func TestEscrowDisputeAndRefund(t *testing.T) {
+	db, server := SetUpTest(t, []Wallet{{Address: escrowBuyer, Balance: decimal.NewFromInt(100)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+
+	disputed := newTestEscrow(t, walletsService, 30, time.Now().Add(time.Hour))
+	_, err := walletsService.DisputeEscrow(ctx, disputed.ID, escrowSeller, "never paid")
+	require.NoError(t, err)
+
+	_, err = walletsService.ApproveEscrow(ctx, disputed.ID, escrowBuyer)
+	require.ErrorIs(t, err, wallets.ErrorInvalidEscrowTransition)
+	_, err = walletsService.ArbitrateEscrow(ctx, disputed.ID, escrowBuyer, true, "")
+	require.ErrorIs(t, err, wallets.ErrorNotEscrowArbiter)
+
+	settled, err := walletsService.ArbitrateEscrow(ctx, disputed.ID, escrowArbiter, false, "goods not delivered")
+	require.NoError(t, err)
+	require.Equal(t, wallets.EscrowRefunded, settled.Status)
+	requireBalance(t, walletsService, escrowBuyer, 100)
+
+	_, err = walletsService.ArbitrateEscrow(ctx, disputed.ID, escrowArbiter, true, "")
+	require.ErrorIs(t, err, wallets.ErrorInvalidEscrowTransition)
+
+	expiring := newTestEscrow(t, walletsService, 50, time.Now().Add(time.Hour))
+	_, err = walletsService.RefundEscrow(ctx, expiring.ID)
+	require.ErrorIs(t, err, wallets.ErrorDeadlineNotReached)
+
+	_, err = db.Exec("UPDATE Escrows SET Deadline = now() - INTERVAL '1 minute' WHERE Id = $1", expiring.ID)
+	require.NoError(t, err)
+
+	refunded, err := walletsService.RefundEscrow(ctx, expiring.ID)
+	require.NoError(t, err)
+	require.Equal(t, wallets.EscrowRefunded, refunded.Status)
+	requireBalance(t, walletsService, escrowBuyer, 100)
+
+	_, err = walletsService.CreateEscrow(ctx, wallets.Escrow{
+		Token:    tokens.DefaultSymbol,
+		Buyer:    escrowBuyer,
+		Seller:   escrowSeller,
+		Arbiter:  escrowArbiter,
+		Amount:   decimal.NewFromInt(101),
+		Deadline: time.Now().Add(time.Hour),
+	})
+	require.ErrorIs(t, err, wallets.ErrorInsufficientBalance)
+
+	history, err := walletsService.EscrowHistory(ctx, disputed.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	require.Equal(t, "never paid", history[1].Note)
+	require.Equal(t, wallets.EscrowDisputed, history[2].FromStatus)
+}
+
+func TestEscrowAccountIsReserved(t *testing.T) {
+	thief := "0x00000000000000000000000000000000000000ff"
+	db, server := SetUpTest(t, []Wallet{{Address: escrowBuyer, Balance: decimal.NewFromInt(100)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+	escrow := newTestEscrow(t, walletsService, 60, time.Now().Add(time.Hour))
+	account := wallets.EscrowAccount(escrow.ID)
+
+	resp := doMutation(t, server.URL, fmt.Sprintf(`mutation {
+		sendTransfer(input: {from_address: "%s", to_address: "%s", amount: "60"}) { id }
+	}`, account, thief))
+	requireCode(t, resp, "RESERVED_ADDRESS")
+	_, err := walletsService.Simulate(ctx, tokens.DefaultSymbol, account, thief, decimal.NewFromInt(60))
+	require.ErrorIs(t, err, wallets.ErrorReservedAddress)
+	_, err = walletsService.Send(ctx, tokens.DefaultSymbol, escrowBuyer, account, decimal.NewFromInt(10))
+	require.ErrorIs(t, err, wallets.ErrorReservedAddress)
+
+	requireBalance(t, walletsService, account, 60)
+	_, err = walletsService.GetWalletBalance(ctx, thief)
+	require.ErrorIs(t, err, wallets.ErrorWalletNotFound)
+}
@@ -0,0 +1,120 @@
+package test
+
+import (
+	"btp_tokens/internal/fees"
+	"btp_tokens/internal/ledger"
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/tokens"
+	"btp_tokens/internal/wallets"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+const treasury = "0x00000000000000000000000000000000000000fe"
+
+func TestFeePolicyTiersAndClamping(t *testing.T) {
+	minFee := decimal.NewFromInt(2)
+	maxFee := decimal.NewFromInt(50)
+	policy := fees.Policy{
+		TreasuryAddress: treasury,
+		Flat:            decimal.NewFromInt(1),
+		BasisPoints:     100,
+		MinFee:          &minFee,
+		MaxFee:          &maxFee,
+		Tiers: []fees.Tier{
+			{From: decimal.NewFromInt(1000), BasisPoints: 50},
+			{From: decimal.NewFromInt(100000), Flat: decimal.NewFromInt(10)},
+		},
+	}
+	require.NoError(t, policy.Validate())
+
+	cases := map[int64]int64{
+		10:     2,  // 1 + 0.1, raised to the minimum
+		500:    6,  // 1 + 1%
+		2000:   10, // 0.5% tier
+		20000:  50, // 100 capped at the maximum
+		200000: 10, // flat tier
+	}
+	for amount, expected := range cases {
+		fee := policy.Fee(decimal.NewFromInt(amount), 0)
+		require.True(t, fee.Equal(decimal.NewFromInt(expected)), "fee of %d: expected %d, got %s", amount, expected, fee)
+	}
+
+	// rounded down to the token decimals
+	fee := fees.Policy{TreasuryAddress: treasury, BasisPoints: 30}.Fee(decimal.RequireFromString("1.2345"), 2)
+	require.Equal(t, "0", fee.String())
+	// exact for tokens with many decimals
+	fee = fees.Policy{TreasuryAddress: treasury, BasisPoints: 7}.Fee(decimal.New(1, -30), 36)
+	require.True(t, fee.Equal(decimal.New(7, -34)), fee.String())
+
+	_, err := fees.Charge(&fees.Policy{TreasuryAddress: treasury, Flat: decimal.NewFromInt(5)}, tokens.Token{}, decimal.NewFromInt(5))
+	require.ErrorIs(t, err, fees.ErrorFeeExceedsAmount)
+
+	invalid := fees.Policy{TreasuryAddress: treasury, MinFee: &maxFee, MaxFee: &minFee}
+	require.ErrorIs(t, invalid.Validate(), fees.ErrorInvalidFeePolicy)
+}
+
+func TestTransferPaysFeeToTreasury(t *testing.T) {
+	sender := "0x0000000000000000000000000000000000000001"
+	receiver := "0x0000000000000000000000000000000000000002"
+	db, server := SetUpTest(t, []Wallet{{Address: sender, Balance: decimal.NewFromInt(1000)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	_, err := (&fees.FeesService{DB: db}).Set(ctx, fees.Policy{
+		Token:           tokens.DefaultSymbol,
+		TreasuryAddress: treasury,
+		Flat:            decimal.NewFromInt(1),
+		BasisPoints:     100,
+	})
+	require.NoError(t, err)
+
+	// BTP has no decimals in tests, a minimum of 0.5 could not be charged
+	minFee := decimal.RequireFromString("0.5")
+	_, err = (&fees.FeesService{DB: db}).Set(ctx, fees.Policy{Token: tokens.DefaultSymbol, TreasuryAddress: treasury, MinFee: &minFee})
+	require.ErrorIs(t, err, fees.ErrorInvalidFeePolicy)
+
+	walletsService := &wallets.WalletsService{DB: db}
+	receipt, err := walletsService.Send(ctx, tokens.DefaultSymbol, sender, receiver, decimal.NewFromInt(200))
+	require.NoError(t, err)
+	require.True(t, receipt.Fee.Equal(decimal.NewFromInt(3)))
+	require.True(t, receipt.NetAmount.Equal(decimal.NewFromInt(197)))
+	require.True(t, receipt.SenderBalance.Equal(decimal.NewFromInt(800)))
+
+	treasuryBalance, err := walletsService.GetWalletBalance(ctx, treasury)
+	require.NoError(t, err)
+	require.True(t, treasuryBalance.Equal(decimal.NewFromInt(3)))
+
+	history, err := walletsService.History(ctx, receiver, tokens.DefaultSymbol, 10)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, receipt.EntryID, history[0].EntryID)
+	require.True(t, history[0].Fee.Equal(decimal.NewFromInt(3)))
+
+	mismatches, err := (&ledger.LedgerService{DB: db}).VerifyBalances(ctx)
+	require.NoError(t, err)
+	require.Empty(t, mismatches)
+
+	resp := doMutation(t, server.URL, `query { quoteTransfer(amount: "100") { fee net_amount treasury_address } }`)
+	quote := resp["data"].(map[string]interface{})["quoteTransfer"].(map[string]interface{})
+	require.Equal(t, "2", quote["fee"])
+	require.Equal(t, "98", quote["net_amount"])
+	require.Equal(t, treasury, quote["treasury_address"])
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation {
+		sendTransfer(input: {from_address: "%s", to_address: "%s", amount: "100"}) { fee net_amount sender_balance }
+	}`, sender, receiver))
+	sent := resp["data"].(map[string]interface{})["sendTransfer"].(map[string]interface{})
+	require.Equal(t, "2", sent["fee"])
+	require.Equal(t, "700", sent["sender_balance"])
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation {
+		transfer(input: {from_address: "%s", to_address: "%s", amount: "1"})
+	}`, sender, receiver))
+	assertGraphQLError(t, resp, fees.ErrorFeeExceedsAmount.Error())
+}
@@ -0,0 +1,136 @@
+package test
+
+import (
+	"btp_tokens/internal/ledger"
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/tokens"
+	"btp_tokens/internal/wallets"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHoldCaptureAndRelease(t *testing.T) {
+	buyer := "0x0000000000000000000000000000000000000001"
+	merchant := "0x0000000000000000000000000000000000000002"
+	db, server := SetUpTest(t, []Wallet{{Address: buyer, Balance: decimal.NewFromInt(100)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+
+	resp := doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation {
+		createHold(input: {address: "%s", payee: "%s", amount: "70", reference: "order-1"}) { id status amount }
+	}`, buyer, merchant))
+	require.NotContains(t, resp, "errors")
+	created := resp["data"].(map[string]interface{})["createHold"].(map[string]interface{})
+	require.Equal(t, "ACTIVE", created["status"])
+	holdID := created["id"].(string)
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`query { wallet(address: "%s") { balance held_balance available_balance } }`, buyer))
+	wallet := resp["data"].(map[string]interface{})["wallet"].(map[string]interface{})
+	require.Equal(t, "100", wallet["balance"])
+	require.Equal(t, "70", wallet["held_balance"])
+	require.Equal(t, "30", wallet["available_balance"])
+
+	// held funds cannot be spent
+	_, err := walletsService.Send(ctx, tokens.DefaultSymbol, buyer, merchant, decimal.NewFromInt(31))
+	require.ErrorIs(t, err, wallets.ErrorInsufficientBalance)
+	_, err = walletsService.CreateHold(ctx, tokens.DefaultSymbol, buyer, merchant, decimal.NewFromInt(31), "", time.Now().Add(time.Hour))
+	require.ErrorIs(t, err, wallets.ErrorInsufficientBalance)
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation { captureHold(id: "%s") { id } }`, holdID))
+	assertGraphQLError(t, resp, "operator authorization required")
+
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation {
+		captureHold(id: "%s", amount: "50") { status captured_amount transfer_id }
+	}`, holdID))
+	require.NotContains(t, resp, "errors")
+	captured := resp["data"].(map[string]interface{})["captureHold"].(map[string]interface{})
+	require.Equal(t, "CAPTURED", captured["status"])
+	require.Equal(t, "50", captured["captured_amount"])
+	require.NotNil(t, captured["transfer_id"])
+
+	// the uncaptured 20 are released with the capture
+	buyerWallet, err := walletsService.GetWallet(ctx, buyer, tokens.DefaultSymbol)
+	require.NoError(t, err)
+	require.True(t, buyerWallet.Balance.Equal(decimal.NewFromInt(50)))
+	require.True(t, buyerWallet.Held.IsZero())
+
+	merchantBalance, err := walletsService.GetWalletBalance(ctx, merchant)
+	require.NoError(t, err)
+	require.True(t, merchantBalance.Equal(decimal.NewFromInt(50)))
+
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { releaseHold(id: "%s") { id } }`, holdID))
+	extensions := resp["errors"].([]interface{})[0].(map[string]interface{})["extensions"].(map[string]interface{})
+	require.Equal(t, "HOLD_NOT_ACTIVE", extensions["code"])
+
+	hold, err := walletsService.CreateHold(ctx, tokens.DefaultSymbol, buyer, merchant, decimal.NewFromInt(50), "", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	_, _, err = walletsService.CaptureHold(ctx, hold.ID, decimalPtr(decimal.NewFromInt(51)))
+	require.ErrorIs(t, err, wallets.ErrorCaptureExceedsHold)
+
+	released, err := walletsService.ReleaseHold(ctx, hold.ID)
+	require.NoError(t, err)
+	require.Equal(t, wallets.HoldReleased, released.Status)
+
+	buyerWallet, err = walletsService.GetWallet(ctx, buyer, tokens.DefaultSymbol)
+	require.NoError(t, err)
+	require.True(t, buyerWallet.Available().Equal(decimal.NewFromInt(50)))
+
+	mismatches, err := (&ledger.LedgerService{DB: db}).VerifyBalances(ctx)
+	require.NoError(t, err)
+	require.Empty(t, mismatches)
+}
+
+func TestExpiredHoldsAreReleased(t *testing.T) {
+	buyer := "0x0000000000000000000000000000000000000001"
+	merchant := "0x0000000000000000000000000000000000000002"
+	db, server := SetUpTest(t, []Wallet{{Address: buyer, Balance: decimal.NewFromInt(100)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+
+	expiring, err := walletsService.CreateHold(ctx, tokens.DefaultSymbol, buyer, merchant, decimal.NewFromInt(60), "", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	kept, err := walletsService.CreateHold(ctx, tokens.DefaultSymbol, buyer, merchant, decimal.NewFromInt(10), "", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	_, err = db.Exec("UPDATE Holds SET Expires_At = now() - INTERVAL '1 minute' WHERE Id = $1", expiring.ID)
+	require.NoError(t, err)
+
+	_, _, err = walletsService.CaptureHold(ctx, expiring.ID, nil)
+	require.ErrorIs(t, err, wallets.ErrorHoldExpired)
+
+	expired, err := walletsService.ExpireHolds(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, expired)
+
+	hold, err := walletsService.GetHold(ctx, expiring.ID)
+	require.NoError(t, err)
+	require.Equal(t, wallets.HoldExpired, hold.Status)
+
+	hold, err = walletsService.GetHold(ctx, kept.ID)
+	require.NoError(t, err)
+	require.Equal(t, wallets.HoldActive, hold.Status)
+
+	wallet, err := walletsService.GetWallet(ctx, buyer, tokens.DefaultSymbol)
+	require.NoError(t, err)
+	require.True(t, wallet.Held.Equal(decimal.NewFromInt(10)))
+
+	active, err := walletsService.ListHolds(ctx, buyer, tokens.DefaultSymbol, wallets.HoldActive)
+	require.NoError(t, err)
+	require.Len(t, active, 1)
+	require.Equal(t, kept.ID, active[0].ID)
+}
+
+func decimalPtr(d decimal.Decimal) *decimal.Decimal {
+	return &d
+}
@@ -0,0 +1,159 @@
+package test
+
+import (
+	"btp_tokens/internal/ledger"
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/tokens"
+	"btp_tokens/internal/wallets"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	htlcSender    = "0x0000000000000000000000000000000000000001"
+	htlcRecipient = "0x0000000000000000000000000000000000000002"
+	htlcPreimage  = "736563726574"
+)
+
+func htlcHashlock() string {
+	raw, _ := hex.DecodeString(htlcPreimage)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestHtlcClaimAndRefund(t *testing.T) {
+	db, server := SetUpTest(t, []Wallet{{Address: htlcSender, Balance: decimal.NewFromInt(100)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+
+	resp := doMutation(t, server.URL, fmt.Sprintf(`mutation {
+		lockHtlc(input: {sender: "%s", recipient: "%s", amount: "40", hashlock: "%s", timeout_at: "%s"}) { id status }
+	}`, htlcSender, htlcRecipient, htlcHashlock(), time.Now().Add(time.Hour).Format(time.RFC3339)))
+	require.NotContains(t, resp, "errors")
+	id := resp["data"].(map[string]interface{})["lockHtlc"].(map[string]interface{})["id"].(string)
+	requireBalance(t, walletsService, htlcSender, 60)
+	requireBalance(t, walletsService, wallets.HtlcAccount(1), 40)
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation { claimHtlc(id: "%s", preimage: "00") { status } }`, id))
+	extensions := resp["errors"].([]interface{})[0].(map[string]interface{})["extensions"].(map[string]interface{})
+	require.Equal(t, "INVALID_PREIMAGE", extensions["code"])
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation { refundHtlc(id: "%s") { status } }`, id))
+	extensions = resp["errors"].([]interface{})[0].(map[string]interface{})["extensions"].(map[string]interface{})
+	require.Equal(t, "HTLC_NOT_TIMED_OUT", extensions["code"])
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation { claimHtlc(id: "%s", preimage: "%s") { status preimage transfer_id } }`, id, htlcPreimage))
+	require.NotContains(t, resp, "errors")
+	claimed := resp["data"].(map[string]interface{})["claimHtlc"].(map[string]interface{})
+	require.Equal(t, "CLAIMED", claimed["status"])
+	require.Equal(t, htlcPreimage, claimed["preimage"])
+	requireBalance(t, walletsService, htlcRecipient, 40)
+
+	// timed out transfers cannot be claimed, only refunded
+	expiring, err := walletsService.LockHtlc(ctx, tokens.DefaultSymbol, htlcSender, htlcRecipient, decimal.NewFromInt(25), htlcHashlock(), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	_, err = db.Exec("UPDATE Htlcs SET Timeout_At = now() - INTERVAL '1 second' WHERE Id = $1", expiring.ID)
+	require.NoError(t, err)
+
+	_, err = walletsService.ClaimHtlc(ctx, expiring.ID, htlcPreimage)
+	require.ErrorIs(t, err, wallets.ErrorHtlcTimedOut)
+
+	refunded, err := walletsService.RefundHtlc(ctx, expiring.ID)
+	require.NoError(t, err)
+	require.Equal(t, wallets.HtlcRefunded, refunded.Status)
+	require.Empty(t, refunded.Preimage)
+	requireBalance(t, walletsService, htlcSender, 60)
+
+	_, err = walletsService.RefundHtlc(ctx, expiring.ID)
+	require.ErrorIs(t, err, wallets.ErrorHtlcNotLocked)
+
+	_, err = walletsService.LockHtlc(ctx, tokens.DefaultSymbol, htlcSender, htlcRecipient, decimal.NewFromInt(1), "abc", time.Now().Add(time.Hour))
+	require.ErrorIs(t, err, wallets.ErrorInvalidHashlock)
+}
+
+// raceHtlc runs claims and refunds of the same transfer concurrently and
+// checks that exactly one of them went through.
+func raceHtlc(t *testing.T, walletsService *wallets.WalletsService, id int64, claims int, refunds int, start time.Time) string {
+	var wg sync.WaitGroup
+	results := make(chan string, claims+refunds)
+
+	run := func(settle func() (wallets.Htlc, error)) {
+		defer wg.Done()
+		time.Sleep(time.Until(start))
+		htlc, err := settle()
+		if err != nil {
+			results <- "error: " + err.Error()
+			return
+		}
+		results <- htlc.Status
+	}
+
+	wg.Add(claims + refunds)
+	for i := 0; i < claims; i++ {
+		go run(func() (wallets.Htlc, error) { return walletsService.ClaimHtlc(context.Background(), id, htlcPreimage) })
+	}
+	for i := 0; i < refunds; i++ {
+		go run(func() (wallets.Htlc, error) { return walletsService.RefundHtlc(context.Background(), id) })
+	}
+	wg.Wait()
+	close(results)
+
+	settled := ""
+	for r := range results {
+		if r == wallets.HtlcClaimed || r == wallets.HtlcRefunded {
+			require.Empty(t, settled, "settled twice")
+			settled = r
+		}
+	}
+	require.NotEmpty(t, settled)
+	return settled
+}
+
+func TestHtlcClaimRefundRace(t *testing.T) {
+	db, server := SetUpTest(t, []Wallet{{Address: htlcSender, Balance: decimal.NewFromInt(100)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+
+	// many claims of the same transfer pay it once
+	htlc, err := walletsService.LockHtlc(ctx, tokens.DefaultSymbol, htlcSender, htlcRecipient, decimal.NewFromInt(10), htlcHashlock(), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, wallets.HtlcClaimed, raceHtlc(t, walletsService, htlc.ID, 10, 0, time.Now()))
+	requireBalance(t, walletsService, htlcRecipient, 10)
+
+	// claims and refunds racing around the timeout: one of them wins
+	for i := 0; i < 5; i++ {
+		timeout := time.Now().Add(300 * time.Millisecond)
+		htlc, err := walletsService.LockHtlc(ctx, tokens.DefaultSymbol, htlcSender, htlcRecipient, decimal.NewFromInt(10), htlcHashlock(), timeout)
+		require.NoError(t, err)
+
+		settled := raceHtlc(t, walletsService, htlc.ID, 5, 5, timeout)
+		stored, err := walletsService.GetHtlc(ctx, htlc.ID)
+		require.NoError(t, err)
+		require.Equal(t, settled, stored.Status)
+		requireBalance(t, walletsService, wallets.HtlcAccount(htlc.ID), 0)
+	}
+
+	sender, err := walletsService.GetWalletBalance(ctx, htlcSender)
+	require.NoError(t, err)
+	recipient, err := walletsService.GetWalletBalance(ctx, htlcRecipient)
+	require.NoError(t, err)
+	require.True(t, sender.Add(recipient).Equal(decimal.NewFromInt(100)))
+
+	mismatches, err := (&ledger.LedgerService{DB: db}).VerifyBalances(ctx)
+	require.NoError(t, err)
+	require.Empty(t, mismatches)
+}
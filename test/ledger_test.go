@@ -0,0 +1,92 @@
+package test
+
+import (
+	"btp_tokens/internal/ledger"
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/tokens"
+	"btp_tokens/internal/wallets"
+	"context"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLedgerTrialBalanceAfterOperations(t *testing.T) {
+	initial_wallets := []Wallet{
+		{Address: "0x0000000000000000000000000000000000000001", Balance: decimal.NewFromInt(100)},
+		{Address: "0x0000000000000000000000000000000000000002", Balance: decimal.NewFromInt(50)},
+	}
+	db, server := SetUpTest(t, initial_wallets)
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+	ledgerService := &ledger.LedgerService{DB: db}
+
+	_, err := walletsService.Transfer(ctx, initial_wallets[0].Address, initial_wallets[1].Address, decimal.NewFromInt(30))
+	require.NoError(t, err)
+	_, err = walletsService.Mint(ctx, tokens.DefaultSymbol, "0x0000000000000000000000000000000000000003", decimal.NewFromInt(25))
+	require.NoError(t, err)
+	balance, err := walletsService.Burn(ctx, tokens.DefaultSymbol, initial_wallets[1].Address, decimal.NewFromInt(10))
+	require.NoError(t, err)
+	require.True(t, balance.Equal(decimal.NewFromInt(70)), "balance after burn: %s", balance)
+
+	trial, err := ledgerService.TrialBalance(ctx, nil)
+	require.NoError(t, err)
+	require.True(t, trial.Balanced(), "debits %s, credits %s", trial.TotalDebit, trial.TotalCredit)
+
+	for _, account := range trial.Accounts {
+		if account.Account == ledger.IssuanceAccount {
+			require.True(t, account.Debit.Sub(account.Credit).Equal(decimal.NewFromInt(165)))
+		}
+	}
+
+	mismatches, err := ledgerService.VerifyBalances(ctx)
+	require.NoError(t, err)
+	require.Empty(t, mismatches)
+}
+
+func TestLedgerDetectsEditedBalance(t *testing.T) {
+	initial_wallets := []Wallet{
+		{Address: "0x0000000000000000000000000000000000000001", Balance: decimal.NewFromInt(100)},
+	}
+	db, server := SetUpTest(t, initial_wallets)
+	defer database.CloseDB()
+	defer server.Close()
+
+	_, err := db.Exec("UPDATE Wallets SET Balance = 1000 WHERE Address = $1", initial_wallets[0].Address)
+	require.NoError(t, err)
+
+	mismatches, err := (&ledger.LedgerService{DB: db}).VerifyBalances(context.Background())
+	require.NoError(t, err)
+	require.Len(t, mismatches, 1)
+	require.True(t, mismatches[0].LedgerBalance.Equal(decimal.NewFromInt(100)))
+}
+
+func TestLedgerRejectsUnbalancedEntry(t *testing.T) {
+	entry := ledger.Entry{
+		Kind: ledger.KindTransfer,
+		Postings: []ledger.Posting{
+			ledger.Debit("0x0000000000000000000000000000000000000001", decimal.NewFromInt(5)),
+			ledger.Credit("0x0000000000000000000000000000000000000002", decimal.NewFromInt(4)),
+		},
+	}
+	require.ErrorIs(t, entry.Validate(), ledger.ErrorUnbalancedEntry)
+}
+
+func TestTrialBalanceQuery(t *testing.T) {
+	initial_wallets := []Wallet{
+		{Address: "0x0000000000000000000000000000000000000001", Balance: decimal.NewFromInt(100)},
+	}
+	_, server := SetUpTest(t, initial_wallets)
+	defer database.CloseDB()
+	defer server.Close()
+
+	resp := doMutation(t, server.URL, `query { trialBalance { total_debit total_credit balanced } }`)
+	trial := resp["data"].(map[string]interface{})["trialBalance"].(map[string]interface{})
+
+	require.Equal(t, true, trial["balanced"])
+	require.Equal(t, "100", trial["total_debit"])
+}
@@ -0,0 +1,95 @@
+package test
+
+import (
+	"btp_tokens/internal/limits"
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/tokens"
+	"btp_tokens/internal/wallets"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageCheck(t *testing.T) {
+	usage := limits.Usage{
+		Limits:      limits.Limits{Tier: "unverified", PerTransfer: decimalPtr(decimal.NewFromInt(50)), Daily: decimalPtr(decimal.NewFromInt(100)), Monthly: decimalPtr(decimal.NewFromInt(120))},
+		SentDaily:   decimal.NewFromInt(30),
+		SentMonthly: decimal.NewFromInt(90),
+	}
+	require.True(t, usage.Remaining().Equal(decimal.NewFromInt(30)))
+	require.NoError(t, usage.Check(decimal.NewFromInt(30)))
+
+	err := usage.Check(decimal.NewFromInt(60))
+	var exceeded *limits.ExceededError
+	require.True(t, errors.As(err, &exceeded))
+	require.Equal(t, limits.PerTransfer, exceeded.Limit)
+	require.True(t, exceeded.Remaining.Equal(decimal.NewFromInt(30)))
+
+	err = usage.Check(decimal.NewFromInt(31))
+	require.ErrorIs(t, err, limits.ErrorLimitExceeded)
+	require.True(t, errors.As(err, &exceeded))
+	require.Equal(t, limits.Monthly, exceeded.Limit)
+
+	require.Nil(t, limits.Usage{}.Remaining())
+	require.NoError(t, limits.Usage{}.Check(decimal.NewFromInt(1000000)))
+}
+
+func TestTierLimits(t *testing.T) {
+	alice := "0x0000000000000000000000000000000000000001"
+	bob := "0x0000000000000000000000000000000000000002"
+	db, server := SetUpTest(t, []Wallet{{Address: alice, Balance: decimal.NewFromInt(1000)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+
+	resp := doOperatorMutation(t, server.URL, `mutation {
+		setTierLimits(input: {tier: "unverified", per_transfer: "100", daily: "150"}) { tier daily monthly }
+	}`)
+	require.NotContains(t, resp, "errors")
+	resp = doOperatorMutation(t, server.URL, `mutation { setTierLimits(input: {tier: "verified", daily: "500"}) { tier } }`)
+	require.NotContains(t, resp, "errors")
+
+	_, err := walletsService.Send(ctx, tokens.DefaultSymbol, alice, bob, decimal.NewFromInt(120))
+	require.ErrorIs(t, err, limits.ErrorLimitExceeded)
+	_, err = walletsService.Send(ctx, tokens.DefaultSymbol, alice, bob, decimal.NewFromInt(100))
+	require.NoError(t, err)
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation {
+		sendTransfer(input: {from_address: "%s", to_address: "%s", amount: "60"}) { id }
+	}`, alice, bob))
+	extensions := resp["errors"].([]interface{})[0].(map[string]interface{})["extensions"].(map[string]interface{})
+	require.Equal(t, "LIMIT_EXCEEDED", extensions["code"])
+	require.Equal(t, "daily", extensions["limit"])
+	require.Equal(t, "50", extensions["remaining"])
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`query {
+		walletLimits(address: "%s") { tier daily_limit daily_used monthly_used remaining }
+	}`, alice))
+	require.NotContains(t, resp, "errors")
+	usage := resp["data"].(map[string]interface{})["walletLimits"].(map[string]interface{})
+	require.Equal(t, "unverified", usage["tier"])
+	require.Equal(t, "150", usage["daily_limit"])
+	require.Equal(t, "100", usage["daily_used"])
+	require.Equal(t, "100", usage["monthly_used"])
+	require.Equal(t, "50", usage["remaining"])
+
+	// verified wallets get the verified tier's limits
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { setWalletTier(address: "%s", tier: "verified") }`, alice))
+	require.NotContains(t, resp, "errors")
+	_, err = walletsService.Send(ctx, tokens.DefaultSymbol, alice, bob, decimal.NewFromInt(300))
+	require.NoError(t, err)
+	_, err = walletsService.Send(ctx, tokens.DefaultSymbol, alice, bob, decimal.NewFromInt(101))
+	require.ErrorIs(t, err, limits.ErrorLimitExceeded)
+
+	// transfers older than the window no longer count
+	_, err = db.Exec("UPDATE Journal_Entries SET Created_At = now() - INTERVAL '2 days' WHERE From_Address = $1", alice)
+	require.NoError(t, err)
+	_, err = walletsService.Send(ctx, tokens.DefaultSymbol, alice, bob, decimal.NewFromInt(101))
+	require.NoError(t, err)
+}
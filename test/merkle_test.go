@@ -0,0 +1,111 @@
+package test
+
+import (
+	"btp_tokens/internal/merkle"
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/snapshots"
+	"btp_tokens/internal/tokens"
+	"btp_tokens/internal/wallets"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerkleProofsVerifyForEveryLeaf(t *testing.T) {
+	for size := 1; size <= 9; size++ {
+		var leaves []merkle.Leaf
+		for i := 0; i < size; i++ {
+			leaves = append(leaves, merkle.Leaf{Address: fmt.Sprintf("0x%040d", i), Amount: decimal.NewFromInt(int64(i * 10))})
+		}
+
+		tree, err := merkle.NewTree(leaves)
+		require.NoError(t, err)
+
+		for _, leaf := range leaves {
+			_, proof, err := tree.Proof(leaf.Address)
+			require.NoError(t, err)
+			require.True(t, merkle.Verify(tree.Root(), leaf.Address, leaf.Amount, proof), "size %d, leaf %s", size, leaf.Address)
+			require.False(t, merkle.Verify(tree.Root(), leaf.Address, leaf.Amount.Add(decimal.NewFromInt(1)), proof))
+		}
+	}
+}
+
+func TestMerkleRootIgnoresInputOrder(t *testing.T) {
+	a := merkle.Leaf{Address: "0x01", Amount: decimal.NewFromInt(1)}
+	b := merkle.Leaf{Address: "0x02", Amount: decimal.RequireFromString("2.50")}
+
+	first, err := merkle.NewTree([]merkle.Leaf{a, b})
+	require.NoError(t, err)
+	second, err := merkle.NewTree([]merkle.Leaf{b, a})
+	require.NoError(t, err)
+
+	require.Equal(t, first.Root(), second.Root())
+	require.True(t, merkle.Verify(first.Root(), "0x02", decimal.RequireFromString("2.5"), mustProof(t, first, "0x02")))
+}
+
+func mustProof(t *testing.T, tree *merkle.Tree, address string) []merkle.ProofStep {
+	_, proof, err := tree.Proof(address)
+	require.NoError(t, err)
+	return proof
+}
+
+func TestSnapshotBalanceProof(t *testing.T) {
+	initial_wallets := []Wallet{
+		{Address: "0x0000000000000000000000000000000000000001", Balance: decimal.NewFromInt(100)},
+		{Address: "0x0000000000000000000000000000000000000002", Balance: decimal.NewFromInt(50)},
+		{Address: "0x0000000000000000000000000000000000000003", Balance: decimal.NewFromInt(5)},
+	}
+	db, server := SetUpTest(t, initial_wallets)
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	snapshotsService := &snapshots.SnapshotsService{DB: db}
+	snapshot, err := snapshotsService.Create(ctx)
+	require.NoError(t, err)
+	root, ok := snapshot.Root(tokens.DefaultSymbol)
+	require.True(t, ok)
+	require.Equal(t, 3, root.WalletCount)
+
+	// later transfers do not change the snapshot
+	_, err = (&wallets.WalletsService{DB: db}).Transfer(ctx, initial_wallets[0].Address, initial_wallets[1].Address, decimal.NewFromInt(40))
+	require.NoError(t, err)
+
+	proof, err := snapshotsService.Proof(ctx, initial_wallets[1].Address, tokens.DefaultSymbol, snapshot.ID)
+	require.NoError(t, err)
+	require.True(t, proof.Balance.Equal(decimal.NewFromInt(50)))
+	require.True(t, merkle.Verify(root.MerkleRoot, proof.Address, proof.Balance, proof.Proof))
+
+	_, err = snapshotsService.Proof(ctx, "0x0000000000000000000000000000000000000009", tokens.DefaultSymbol, snapshot.ID)
+	require.ErrorIs(t, err, merkle.ErrorLeafNotFound)
+}
+
+func TestBalanceProofQuery(t *testing.T) {
+	initial_wallets := []Wallet{
+		{Address: "0x0000000000000000000000000000000000000001", Balance: decimal.NewFromInt(100)},
+		{Address: "0x0000000000000000000000000000000000000002", Balance: decimal.NewFromInt(50)},
+	}
+	_, server := SetUpTest(t, initial_wallets)
+	defer database.CloseDB()
+	defer server.Close()
+
+	resp := doOperatorMutation(t, server.URL, `mutation { createSnapshot { id merkle_root } }`)
+	snapshot := resp["data"].(map[string]interface{})["createSnapshot"].(map[string]interface{})
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`query {
+		balanceProof(address: "%s", snapshot: "%s") { balance root proof { hash side } }
+	}`, initial_wallets[0].Address, snapshot["id"]))
+	proof := resp["data"].(map[string]interface{})["balanceProof"].(map[string]interface{})
+
+	var steps []merkle.ProofStep
+	for _, step := range proof["proof"].([]interface{}) {
+		s := step.(map[string]interface{})
+		steps = append(steps, merkle.ProofStep{Hash: s["hash"].(string), Left: s["side"] == "LEFT"})
+	}
+
+	require.Equal(t, snapshot["merkle_root"], proof["root"])
+	require.True(t, merkle.Verify(proof["root"].(string), initial_wallets[0].Address, decimal.RequireFromString(proof["balance"].(string)), steps))
+}
@@ -0,0 +1,141 @@
+package test
+
+import (
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/tokens"
+	"btp_tokens/internal/wallets"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultisigWallet(t *testing.T) {
+	treasury := "0x0000000000000000000000000000000000000001"
+	bob := "0x0000000000000000000000000000000000000002"
+	signers := []string{
+		"0x00000000000000000000000000000000000000a1",
+		"0x00000000000000000000000000000000000000a2",
+		"0x00000000000000000000000000000000000000a3",
+	}
+	keys := make([]ed25519.PrivateKey, len(signers))
+	for i := range keys {
+		seed := make([]byte, ed25519.SeedSize)
+		seed[0] = byte(i + 1)
+		keys[i] = ed25519.NewKeyFromSeed(seed)
+	}
+	sign := func(i int, message string) string {
+		return hex.EncodeToString(ed25519.Sign(keys[i], []byte(message)))
+	}
+
+	db, server := SetUpTest(t, []Wallet{{Address: treasury, Balance: decimal.NewFromInt(1000)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+
+	resp := doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation {
+		createMultisigWallet(input: {address: "%s", threshold: 2, signers: [
+			{address: "%s", public_key: "%s"},
+			{address: "%s", public_key: "%s"},
+			{address: "%s", public_key: "%s"}
+		]}) { threshold signers { address } }
+	}`, treasury,
+		signers[0], hex.EncodeToString(keys[0].Public().(ed25519.PublicKey)),
+		signers[1], hex.EncodeToString(keys[1].Public().(ed25519.PublicKey)),
+		signers[2], hex.EncodeToString(keys[2].Public().(ed25519.PublicKey))))
+	require.NotContains(t, resp, "errors")
+
+	_, err := walletsService.Send(ctx, tokens.DefaultSymbol, treasury, bob, decimal.NewFromInt(10))
+	require.ErrorIs(t, err, wallets.ErrorMultisigRequired)
+
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	proposeWith := func(amount string, signature string) map[string]interface{} {
+		return doMutation(t, server.URL, fmt.Sprintf(`mutation {
+			proposeMultisigTransfer(input: {wallet: "%s", to_address: "%s", amount: "%s", proposer: "%s", expires_at: "%s", signature: "%s"}) {
+				id status approval_message cancel_message approvals { signer }
+			}
+		}`, treasury, bob, amount, signers[0], expiresAt.Format(time.RFC3339), signature))
+	}
+	proposalSignature := func(amount string) string {
+		return sign(0, wallets.ProposalMessage(treasury, tokens.DefaultSymbol, bob, decimal.RequireFromString(amount), expiresAt))
+	}
+	// every proposal expires a second later than the one before, so its
+	// signature differs
+	propose := func(amount string) map[string]interface{} {
+		expiresAt = expiresAt.Add(time.Second)
+		resp := proposeWith(amount, proposalSignature(amount))
+		require.NotContains(t, resp, "errors")
+		return resp["data"].(map[string]interface{})["proposeMultisigTransfer"].(map[string]interface{})
+	}
+	approve := func(id interface{}, signer string, signature string) map[string]interface{} {
+		return doMutation(t, server.URL, fmt.Sprintf(`mutation {
+			approveMultisigTransfer(id: "%s", signer: "%s", signature: "%s") { status transfer_id error approvals { signer } }
+		}`, id, signer, signature))
+	}
+
+	// a proposal needs the proposer's signature, which counts as its
+	// approval and cannot be replayed
+	requireCode(t, proposeWith("100", sign(1, wallets.ProposalMessage(treasury, tokens.DefaultSymbol, bob, decimal.NewFromInt(100), expiresAt))), "INVALID_SIGNATURE")
+	requireCode(t, proposeWith("100", proposalSignature("200")), "INVALID_SIGNATURE")
+	proposal := propose("100")
+	require.Equal(t, "PENDING", proposal["status"])
+	require.Len(t, proposal["approvals"], 1)
+	requireCode(t, proposeWith("100", proposalSignature("100")), "INVALID_SIGNATURE")
+	message := proposal["approval_message"].(string)
+
+	requireCode(t, approve(proposal["id"], signers[1], sign(2, message)), "INVALID_SIGNATURE")
+	requireCode(t, approve(proposal["id"], bob, sign(0, message)), "NOT_SIGNER")
+	requireCode(t, approve(proposal["id"], signers[0], sign(0, message)), "ALREADY_APPROVED")
+
+	// the second approval meets the threshold and sends the transfer
+	resp = approve(proposal["id"], signers[1], sign(1, message))
+	require.NotContains(t, resp, "errors")
+	executed := resp["data"].(map[string]interface{})["approveMultisigTransfer"].(map[string]interface{})
+	require.Equal(t, "EXECUTED", executed["status"])
+	require.NotNil(t, executed["transfer_id"])
+	require.Len(t, executed["approvals"], 2)
+	requireBalance(t, walletsService, bob, 100)
+	requireBalance(t, walletsService, treasury, 900)
+	requireCode(t, approve(proposal["id"], signers[2], sign(2, message)), "PROPOSAL_NOT_PENDING")
+
+	// a transfer that cannot be sent keeps the approvals and can be retried
+	proposal = propose("2000")
+	message = proposal["approval_message"].(string)
+	resp = approve(proposal["id"], signers[2], sign(2, message))
+	require.NotContains(t, resp, "errors")
+	failed := resp["data"].(map[string]interface{})["approveMultisigTransfer"].(map[string]interface{})
+	require.Equal(t, "PENDING", failed["status"])
+	require.NotNil(t, failed["error"])
+	requireBalance(t, walletsService, treasury, 900)
+
+	_, err = walletsService.Mint(ctx, tokens.DefaultSymbol, treasury, decimal.NewFromInt(1100))
+	require.NoError(t, err)
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation { executeMultisigTransfer(id: "%s") { status error } }`, proposal["id"]))
+	require.NotContains(t, resp, "errors")
+	require.Equal(t, "EXECUTED", resp["data"].(map[string]interface{})["executeMultisigTransfer"].(map[string]interface{})["status"])
+	requireBalance(t, walletsService, bob, 2100)
+
+	// cancelled and expired proposals cannot be approved
+	proposal = propose("10")
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation {
+		cancelMultisigTransfer(id: "%s", signer: "%s", signature: "%s") { status }
+	}`, proposal["id"], signers[2], sign(2, proposal["cancel_message"].(string))))
+	require.NotContains(t, resp, "errors")
+	require.Equal(t, "CANCELLED", resp["data"].(map[string]interface{})["cancelMultisigTransfer"].(map[string]interface{})["status"])
+
+	proposal = propose("10")
+	_, err = db.Exec("UPDATE Multisig_Proposals SET Expires_At = now() - INTERVAL '1 minute' WHERE Id = $1", proposal["id"])
+	require.NoError(t, err)
+	requireCode(t, approve(proposal["id"], signers[1], sign(1, proposal["approval_message"].(string))), "PROPOSAL_NOT_PENDING")
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`query { multisigProposals(wallet: "%s", status: EXPIRED) { id } }`, treasury))
+	require.NotContains(t, resp, "errors")
+	require.Len(t, resp["data"].(map[string]interface{})["multisigProposals"], 1)
+}
@@ -0,0 +1,178 @@
+package test
+
+import (
+	"btp_tokens/internal/fees"
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/tokens"
+	"btp_tokens/internal/wallets"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaymentRequests(t *testing.T) {
+	merchant := "0x0000000000000000000000000000000000000001"
+	alice := "0x0000000000000000000000000000000000000002"
+	bob := "0x0000000000000000000000000000000000000003"
+	db, server := SetUpTest(t, []Wallet{
+		{Address: alice, Balance: decimal.NewFromInt(100)},
+		{Address: bob, Balance: decimal.NewFromInt(100)},
+	})
+	defer database.CloseDB()
+	defer server.Close()
+
+	walletsService := &wallets.WalletsService{DB: db}
+	create := func(reference string) map[string]interface{} {
+		return doMutation(t, server.URL, fmt.Sprintf(`mutation {
+			createPaymentRequest(input: {payee: "%s", amount: "100", memo: "order 42"%s}) { id reference status }
+		}`, merchant, reference))
+	}
+	pay := func(reference string, payer string, amount string) map[string]interface{} {
+		return doMutation(t, server.URL, fmt.Sprintf(`mutation {
+			payRequest(reference: "%s", payer: "%s"%s) { status paid_amount outstanding payments { payer amount transfer_id } }
+		}`, reference, payer, amount))
+	}
+
+	resp := create(`, reference: "INV-1"`)
+	require.NotContains(t, resp, "errors")
+	require.Equal(t, "OPEN", resp["data"].(map[string]interface{})["createPaymentRequest"].(map[string]interface{})["status"])
+	requireCode(t, create(`, reference: "INV-1"`), "REFERENCE_EXISTS")
+
+	// a partial payment leaves the request open
+	resp = pay("INV-1", alice, `, amount: "40"`)
+	require.NotContains(t, resp, "errors")
+	request := resp["data"].(map[string]interface{})["payRequest"].(map[string]interface{})
+	require.Equal(t, "OPEN", request["status"])
+	require.Equal(t, "40", request["paid_amount"])
+	require.Equal(t, "60", request["outstanding"])
+	requireCode(t, pay("INV-1", bob, `, amount: "70"`), "INVALID_AMOUNT")
+	requireCode(t, pay("INV-1", merchant, ""), "SAME_ADDRESS")
+
+	resp = pay("INV-1", bob, "")
+	require.NotContains(t, resp, "errors")
+	request = resp["data"].(map[string]interface{})["payRequest"].(map[string]interface{})
+	require.Equal(t, "PAID", request["status"])
+	require.Equal(t, "0", request["outstanding"])
+	payments := request["payments"].([]interface{})
+	require.Len(t, payments, 2)
+	require.Equal(t, alice, payments[0].(map[string]interface{})["payer"])
+	require.Equal(t, "60", payments[1].(map[string]interface{})["amount"])
+	requireBalance(t, walletsService, merchant, 100)
+	requireBalance(t, walletsService, alice, 60)
+	requireBalance(t, walletsService, bob, 40)
+	requireCode(t, pay("INV-1", bob, `, amount: "1"`), "PAYMENT_REQUEST_NOT_OPEN")
+
+	resp = create("")
+	require.NotContains(t, resp, "errors")
+	cancelled := resp["data"].(map[string]interface{})["createPaymentRequest"].(map[string]interface{})
+	require.True(t, strings.HasPrefix(cancelled["reference"].(string), "PR-"))
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation { cancelPaymentRequest(id: "%s") { status } }`, cancelled["id"]))
+	require.NotContains(t, resp, "errors")
+	require.Equal(t, "CANCELLED", resp["data"].(map[string]interface{})["cancelPaymentRequest"].(map[string]interface{})["status"])
+	requireCode(t, pay(cancelled["reference"].(string), alice, ""), "PAYMENT_REQUEST_NOT_OPEN")
+
+	resp = create(`, reference: "INV-3"`)
+	require.NotContains(t, resp, "errors")
+	expiring := resp["data"].(map[string]interface{})["createPaymentRequest"].(map[string]interface{})
+	_, err := db.Exec("UPDATE Payment_Requests SET Expires_At = now() - INTERVAL '1 second' WHERE Reference = 'INV-3'")
+	require.NoError(t, err)
+	requireCode(t, pay("INV-3", alice, ""), "PAYMENT_REQUEST_EXPIRED")
+	requireCode(t, doMutation(t, server.URL, fmt.Sprintf(`mutation { cancelPaymentRequest(id: "%s") { status } }`, expiring["id"])), "PAYMENT_REQUEST_NOT_OPEN")
+	requireBalance(t, walletsService, alice, 60)
+
+	resp = doMutation(t, server.URL, `query { paymentRequest(reference: "INV-3") { status memo } }`)
+	require.NotContains(t, resp, "errors")
+	require.Equal(t, "EXPIRED", resp["data"].(map[string]interface{})["paymentRequest"].(map[string]interface{})["status"])
+	require.Equal(t, "order 42", resp["data"].(map[string]interface{})["paymentRequest"].(map[string]interface{})["memo"])
+
+	list := func(status string) []interface{} {
+		resp := doMutation(t, server.URL, fmt.Sprintf(`query { paymentRequests(payee: "%s"%s) { reference status } }`, merchant, status))
+		require.NotContains(t, resp, "errors")
+		return resp["data"].(map[string]interface{})["paymentRequests"].([]interface{})
+	}
+	all := list("")
+	require.Len(t, all, 3)
+	require.Equal(t, "INV-3", all[0].(map[string]interface{})["reference"])
+	require.Len(t, list(", status: PAID"), 1)
+	require.Len(t, list(", status: EXPIRED"), 1)
+	require.Len(t, list(", status: OPEN"), 0)
+}
+
+func TestPaymentRequestFeeOnTop(t *testing.T) {
+	merchant := "0x0000000000000000000000000000000000000001"
+	alice := "0x0000000000000000000000000000000000000002"
+	treasury := "0x00000000000000000000000000000000000000fe"
+	db, server := SetUpTest(t, []Wallet{{Address: alice, Balance: decimal.NewFromInt(100)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	_, err := (&fees.FeesService{DB: db}).Set(ctx, fees.Policy{
+		Token:           tokens.DefaultSymbol,
+		TreasuryAddress: treasury,
+		Flat:            decimal.NewFromInt(2),
+	})
+	require.NoError(t, err)
+
+	walletsService := &wallets.WalletsService{DB: db}
+	request, err := walletsService.CreatePaymentRequest(ctx, tokens.DefaultSymbol, merchant, decimal.NewFromInt(50), "", "INV-FEE", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	// the payer pays the fee on top, the request counts what the payee got
+	forty := decimal.NewFromInt(40)
+	request, receipt, err := walletsService.PayRequest(ctx, request.Reference, alice, &forty)
+	require.NoError(t, err)
+	require.Equal(t, "42", receipt.Amount.String())
+	require.Equal(t, "40", receipt.NetAmount.String())
+	require.Equal(t, "40", request.PaidAmount.String())
+	require.Equal(t, wallets.PaymentRequestOpen, request.Status)
+
+	request, _, err = walletsService.PayRequest(ctx, request.Reference, alice, nil)
+	require.NoError(t, err)
+	require.Equal(t, wallets.PaymentRequestPaid, request.Status)
+	requireBalance(t, walletsService, merchant, 50)
+	requireBalance(t, walletsService, alice, 46)
+	requireBalance(t, walletsService, treasury, 4)
+
+	payments, err := walletsService.Payments(ctx, request.ID)
+	require.NoError(t, err)
+	require.Len(t, payments, 2)
+	require.Equal(t, "10", payments[1].Amount.String())
+}
+
+func TestReversedPaymentReopensRequest(t *testing.T) {
+	merchant := "0x0000000000000000000000000000000000000001"
+	alice := "0x0000000000000000000000000000000000000002"
+	db, server := SetUpTest(t, []Wallet{{Address: alice, Balance: decimal.NewFromInt(100)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+	request, err := walletsService.CreatePaymentRequest(ctx, tokens.DefaultSymbol, merchant, decimal.NewFromInt(50), "", "INV-1", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	request, receipt, err := walletsService.PayRequest(ctx, "INV-1", alice, nil)
+	require.NoError(t, err)
+	require.Equal(t, wallets.PaymentRequestPaid, request.Status)
+
+	// a partial refund reopens the request for what was refunded
+	refund := decimal.NewFromInt(20)
+	_, err = walletsService.ReverseTransfer(ctx, receipt.EntryID, &refund, "chargeback", "ops")
+	require.NoError(t, err)
+	request, err = walletsService.GetPaymentRequest(ctx, request.ID)
+	require.NoError(t, err)
+	require.Equal(t, wallets.PaymentRequestOpen, request.Status)
+	require.Equal(t, "30", request.PaidAmount.String())
+	require.Equal(t, "20", request.Outstanding().String())
+
+	request, _, err = walletsService.PayRequest(ctx, "INV-1", alice, nil)
+	require.NoError(t, err)
+	require.Equal(t, wallets.PaymentRequestPaid, request.Status)
+	requireBalance(t, walletsService, merchant, 50)
+	requireBalance(t, walletsService, alice, 50)
+}
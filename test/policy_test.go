@@ -0,0 +1,228 @@
+package test
+
+import (
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/policy"
+	"btp_tokens/internal/tokens"
+	"btp_tokens/internal/wallets"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyCompile(t *testing.T) {
+	expr, err := policy.Compile(`amount > 1000 && (to in ['0xdead', '0xbeef'] || lower(tier) == 'unverified')`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"to", "amount", "tier"}, expr.Attributes)
+
+	expr, err = policy.Compile(`!starts_with(from, '0x00') && counterpart_transfers == 0`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"from", "counterpart_transfers"}, expr.Attributes)
+
+	for _, source := range []string{
+		`amount`,
+		`amount > 'ten'`,
+		`balance > 10`,
+		`amount > 10 &&`,
+		`to in [from]`,
+		`upper(to) == 'A'`,
+		`to == 'unterminated`,
+		``,
+		`   `,
+		`amount >`,
+	} {
+		_, err := policy.Compile(source)
+		require.ErrorIs(t, err, policy.ErrorInvalidExpression, source)
+	}
+
+	// the error points at the end, not at the last token
+	_, err = policy.Compile(`amount >`)
+	require.EqualError(t, err, `invalid policy expression: unexpected "end of expression" at 8`)
+
+	rule := policy.Rule{Name: "blank", Expression: "  ", Action: policy.ActionDeny}
+	require.ErrorIs(t, rule.Validate(), policy.ErrorInvalidExpression)
+}
+
+func TestPolicyRules(t *testing.T) {
+	alice := "0x0000000000000000000000000000000000000001"
+	bob := "0x0000000000000000000000000000000000000002"
+	carol := "0x0000000000000000000000000000000000000003"
+	db, server := SetUpTest(t, []Wallet{{Address: alice, Balance: decimal.NewFromInt(1000)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+
+	resp := doOperatorMutation(t, server.URL, `mutation {
+		savePolicyRule(input: {name: "large", expression: "amount > 500", action: DENY, message: "too large"}) { name version action }
+	}`)
+	require.NotContains(t, resp, "errors")
+	resp = doOperatorMutation(t, server.URL, `mutation {
+		savePolicyRule(input: {name: "new-counterpart", expression: "counterpart_transfers == 0", action: FLAG, priority: 50}) { name }
+	}`)
+	require.NotContains(t, resp, "errors")
+
+	resp = doOperatorMutation(t, server.URL, `mutation {
+		savePolicyRule(input: {name: "broken", expression: "amount > 'x'", action: DENY}) { name }
+	}`)
+	extensions := resp["errors"].([]interface{})[0].(map[string]interface{})["extensions"].(map[string]interface{})
+	require.Equal(t, "INVALID_POLICY_RULE", extensions["code"])
+
+	_, err := walletsService.Send(ctx, tokens.DefaultSymbol, alice, bob, decimal.NewFromInt(600))
+	require.ErrorIs(t, err, policy.ErrorPolicyDenied)
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation {
+		sendTransfer(input: {from_address: "%s", to_address: "%s", amount: "600"}) { id }
+	}`, alice, bob))
+	extensions = resp["errors"].([]interface{})[0].(map[string]interface{})["extensions"].(map[string]interface{})
+	require.Equal(t, "POLICY_DENIED", extensions["code"])
+	require.Equal(t, "large", extensions["rule"])
+
+	// the first transfer to bob is flagged, the second is not
+	first, err := walletsService.Send(ctx, tokens.DefaultSymbol, alice, bob, decimal.NewFromInt(100))
+	require.NoError(t, err)
+	_, err = walletsService.Send(ctx, tokens.DefaultSymbol, alice, bob, decimal.NewFromInt(100))
+	require.NoError(t, err)
+	requireBalance(t, walletsService, bob, 200)
+
+	resp = doOperatorMutation(t, server.URL, `query { flaggedTransfers { transfer_id rule { name } } }`)
+	require.NotContains(t, resp, "errors")
+	flags := resp["data"].(map[string]interface{})["flaggedTransfers"].([]interface{})
+	require.Len(t, flags, 1)
+	require.Equal(t, fmt.Sprint(first.EntryID), flags[0].(map[string]interface{})["transfer_id"])
+
+	// a dry run with a candidate version of large
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`query {
+		evaluatePolicies(
+			input: {from_address: "%s", to_address: "%s", amount: "300"},
+			rules: [{name: "large", expression: "amount > 200", action: DENY}]
+		) { action rule { name version } flags { name } results { rule { name } matched error } attributes { name value } }
+	}`, alice, carol))
+	require.NotContains(t, resp, "errors")
+	evaluation := resp["data"].(map[string]interface{})["evaluatePolicies"].(map[string]interface{})
+	require.Equal(t, "DENY", evaluation["action"])
+	require.Equal(t, "large", evaluation["rule"].(map[string]interface{})["name"])
+	require.Len(t, evaluation["flags"], 1)
+	require.Len(t, evaluation["results"], 2)
+	require.Contains(t, evaluation["attributes"], map[string]interface{}{"name": "counterpart_transfers", "value": "0"})
+
+	// saving a new version applies it to the next transfer
+	resp = doOperatorMutation(t, server.URL, `mutation { disablePolicyRule(name: "large") { version enabled } }`)
+	require.NotContains(t, resp, "errors")
+	disabled := resp["data"].(map[string]interface{})["disablePolicyRule"].(map[string]interface{})
+	require.Equal(t, float64(2), disabled["version"])
+	require.Equal(t, false, disabled["enabled"])
+	_, err = walletsService.Send(ctx, tokens.DefaultSymbol, alice, carol, decimal.NewFromInt(600))
+	require.NoError(t, err)
+
+	resp = doOperatorMutation(t, server.URL, `query { policyRuleVersions(name: "large") { version enabled expression } }`)
+	require.NotContains(t, resp, "errors")
+	require.Len(t, resp["data"].(map[string]interface{})["policyRuleVersions"], 2)
+
+	resp = doMutation(t, server.URL, `query { policyRules { name } }`)
+	require.Contains(t, resp, "errors")
+}
+
+func TestPolicyCountsBatchedTransfers(t *testing.T) {
+	alice := "0x0000000000000000000000000000000000000001"
+	bob := "0x0000000000000000000000000000000000000002"
+	db, server := SetUpTest(t, []Wallet{{Address: alice, Balance: decimal.NewFromInt(100)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	_, err := (&policy.PolicyService{DB: db}).SaveRule(ctx, policy.Rule{Name: "daily", Expression: "sent_24h + amount > 10", Action: policy.ActionDeny, Enabled: true}, "ops")
+	require.NoError(t, err)
+
+	// the transfers of one batch see what the earlier ones sent
+	walletsService := &wallets.WalletsService{DB: db}
+	batcher := wallets.NewTransferBatcher(walletsService, 8, 50*time.Millisecond)
+	defer batcher.Close()
+
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		go func() {
+			_, err := batcher.Transfer(ctx, alice, bob, decimal.NewFromInt(4))
+			errs <- err
+		}()
+	}
+	denied := 0
+	for i := 0; i < 4; i++ {
+		if err := <-errs; err != nil {
+			require.ErrorIs(t, err, policy.ErrorPolicyDenied)
+			denied++
+		}
+	}
+	require.Equal(t, 2, denied)
+	requireBalance(t, walletsService, bob, 8)
+}
+
+func TestPolicyChecksEscrowAndHtlcFunding(t *testing.T) {
+	db, server := SetUpTest(t, []Wallet{{Address: escrowBuyer, Balance: decimal.NewFromInt(100)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	_, err := (&policy.PolicyService{DB: db}).SaveRule(ctx, policy.Rule{Name: "seller", Expression: fmt.Sprintf("to == '%s' && sent_24h + amount > 10", escrowSeller), Action: policy.ActionDeny, Enabled: true}, "ops")
+	require.NoError(t, err)
+
+	// the policies see the seller as the recipient, not the escrow account
+	walletsService := &wallets.WalletsService{DB: db}
+	funded := newTestEscrow(t, walletsService, 10, time.Now().Add(time.Hour))
+	_, err = walletsService.CreateEscrow(ctx, wallets.Escrow{
+		Token:    tokens.DefaultSymbol,
+		Buyer:    escrowBuyer,
+		Seller:   escrowSeller,
+		Arbiter:  escrowArbiter,
+		Amount:   decimal.NewFromInt(1),
+		Deadline: time.Now().Add(time.Hour),
+	})
+	require.ErrorIs(t, err, policy.ErrorPolicyDenied)
+
+	// and count the escrow as sent when an HTLC is locked afterwards
+	_, err = walletsService.LockHtlc(ctx, tokens.DefaultSymbol, escrowBuyer, escrowSeller, decimal.NewFromInt(1), htlcHashlock(), time.Now().Add(time.Hour))
+	require.ErrorIs(t, err, policy.ErrorPolicyDenied)
+	requireBalance(t, walletsService, escrowBuyer, 90)
+
+	// the payout of an escrow checked when it was funded is not checked again
+	_, err = (&policy.PolicyService{DB: db}).SaveRule(ctx, policy.Rule{Name: "seller", Expression: fmt.Sprintf("to == '%s'", escrowSeller), Action: policy.ActionDeny, Enabled: true}, "ops")
+	require.NoError(t, err)
+	for _, party := range []string{escrowBuyer, escrowSeller} {
+		_, err = walletsService.ApproveEscrow(ctx, funded.ID, party)
+		require.NoError(t, err)
+	}
+	_, err = walletsService.ReleaseEscrow(ctx, funded.ID)
+	require.NoError(t, err)
+	requireBalance(t, walletsService, escrowSeller, 10)
+}
+
+func TestPolicyConcurrentSaves(t *testing.T) {
+	db, server := SetUpTest(t, nil)
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	policyService := &policy.PolicyService{DB: db}
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		go func(i int) {
+			_, err := policyService.SaveRule(ctx, policy.Rule{Name: "large", Expression: fmt.Sprintf("amount > %d", i), Action: policy.ActionDeny, Enabled: true}, "ops")
+			errs <- err
+		}(i)
+	}
+	for i := 0; i < 8; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	versions, err := policyService.RuleVersions(ctx, "large")
+	require.NoError(t, err)
+	require.Len(t, versions, 8)
+	for i, version := range versions {
+		require.Equal(t, i+1, version.Version)
+	}
+}
@@ -0,0 +1,180 @@
+package test
+
+import (
+	"btp_tokens/internal/compliance"
+	"btp_tokens/internal/fees"
+	"btp_tokens/internal/ledger"
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/tokens"
+	"btp_tokens/internal/wallets"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReverseTransfer(t *testing.T) {
+	sender := "0x0000000000000000000000000000000000000001"
+	receiver := "0x0000000000000000000000000000000000000002"
+	treasury := "0x00000000000000000000000000000000000000fe"
+	db, server := SetUpTest(t, []Wallet{{Address: sender, Balance: decimal.NewFromInt(100)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	_, err := (&fees.FeesService{DB: db}).Set(ctx, fees.Policy{
+		Token:           tokens.DefaultSymbol,
+		TreasuryAddress: treasury,
+		Flat:            decimal.NewFromInt(2),
+	})
+	require.NoError(t, err)
+
+	walletsService := &wallets.WalletsService{DB: db}
+	receipt, err := walletsService.Send(ctx, tokens.DefaultSymbol, sender, receiver, decimal.NewFromInt(50))
+	require.NoError(t, err)
+	requireBalance(t, walletsService, receiver, 48)
+
+	mutation := fmt.Sprintf(`mutation { reverseTransfer(transferId: "%d", reason: "fraud") { transfer_id reversal_id amount operator } }`, receipt.EntryID)
+	resp := doMutation(t, server.URL, mutation)
+	assertGraphQLError(t, resp, "operator authorization required")
+
+	// the receiver got 48, the fee is not refunded
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { reverseTransfer(transferId: "%d", reason: "fraud", amount: "49") { id } }`, receipt.EntryID))
+	extensions := resp["errors"].([]interface{})[0].(map[string]interface{})["extensions"].(map[string]interface{})
+	require.Equal(t, "INVALID_AMOUNT", extensions["code"])
+
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { reverseTransfer(transferId: "%d", reason: "fraud", amount: "30") { transfer_id amount reason } }`, receipt.EntryID))
+	require.NotContains(t, resp, "errors")
+	reversal := resp["data"].(map[string]interface{})["reverseTransfer"].(map[string]interface{})
+	require.Equal(t, fmt.Sprint(receipt.EntryID), reversal["transfer_id"])
+	require.Equal(t, "30", reversal["amount"])
+	require.Equal(t, "fraud", reversal["reason"])
+	requireBalance(t, walletsService, sender, 80)
+	requireBalance(t, walletsService, receiver, 18)
+	requireBalance(t, walletsService, treasury, 2)
+
+	// a transfer is reversed at most once, even partially
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { reverseTransfer(transferId: "%d", reason: "again") { id } }`, receipt.EntryID))
+	extensions = resp["errors"].([]interface{})[0].(map[string]interface{})["extensions"].(map[string]interface{})
+	require.Equal(t, "ALREADY_REVERSED", extensions["code"])
+
+	// the original entry is untouched, the history links both ways
+	history, err := walletsService.History(ctx, receiver, tokens.DefaultSymbol, 10)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	require.Equal(t, receipt.EntryID, history[0].ReversalOf)
+	require.Equal(t, history[0].EntryID, history[1].ReversedBy)
+	require.True(t, history[1].Amount.Equal(decimal.NewFromInt(50)))
+
+	mismatches, err := (&ledger.LedgerService{DB: db}).VerifyBalances(ctx)
+	require.NoError(t, err)
+	require.Empty(t, mismatches)
+}
+
+func TestReverseTransferReceiverSpent(t *testing.T) {
+	sender := "0x0000000000000000000000000000000000000001"
+	receiver := "0x0000000000000000000000000000000000000002"
+	other := "0x0000000000000000000000000000000000000003"
+	db, server := SetUpTest(t, []Wallet{{Address: sender, Balance: decimal.NewFromInt(100)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+	receipt, err := walletsService.Send(ctx, tokens.DefaultSymbol, sender, receiver, decimal.NewFromInt(40))
+	require.NoError(t, err)
+	_, err = walletsService.Send(ctx, tokens.DefaultSymbol, receiver, other, decimal.NewFromInt(30))
+	require.NoError(t, err)
+
+	_, err = walletsService.ReverseTransfer(ctx, receipt.EntryID, nil, "chargeback", "ops")
+	require.ErrorIs(t, err, wallets.ErrorReceiverBalanceTooLow)
+	requireBalance(t, walletsService, sender, 60)
+	requireBalance(t, walletsService, receiver, 10)
+
+	// nothing was recorded, a smaller reversal still works
+	ten := decimal.NewFromInt(10)
+	reversal, err := walletsService.ReverseTransfer(ctx, receipt.EntryID, &ten, "chargeback", "ops")
+	require.NoError(t, err)
+	require.Equal(t, "ops", reversal.Operator)
+	requireBalance(t, walletsService, sender, 70)
+	requireBalance(t, walletsService, receiver, 0)
+
+	// reversals themselves cannot be reversed
+	_, err = walletsService.ReverseTransfer(ctx, reversal.ReversalEntryID, nil, "undo", "ops")
+	require.ErrorIs(t, err, wallets.ErrorTransferNotFound)
+
+	_, err = walletsService.ReverseTransfer(ctx, receipt.EntryID+100, nil, "missing", "ops")
+	require.ErrorIs(t, err, wallets.ErrorTransferNotFound)
+}
+
+func TestReverseTransferFrozenWallets(t *testing.T) {
+	sender := "0x0000000000000000000000000000000000000001"
+	receiver := "0x0000000000000000000000000000000000000002"
+	db, server := SetUpTest(t, []Wallet{{Address: sender, Balance: decimal.NewFromInt(100)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+	complianceService := &compliance.ComplianceService{DB: db}
+	first, err := walletsService.Send(ctx, tokens.DefaultSymbol, sender, receiver, decimal.NewFromInt(40))
+	require.NoError(t, err)
+	second, err := walletsService.Send(ctx, tokens.DefaultSymbol, sender, receiver, decimal.NewFromInt(20))
+	require.NoError(t, err)
+	third, err := walletsService.Send(ctx, tokens.DefaultSymbol, sender, receiver, decimal.NewFromInt(10))
+	require.NoError(t, err)
+
+	// a frozen receiver cannot send, but the operator can still take the
+	// funds back, also into a frozen sender
+	_, err = complianceService.SetStatus(ctx, receiver, compliance.StatusFrozen, "fraud", "ops")
+	require.NoError(t, err)
+	_, err = walletsService.Send(ctx, tokens.DefaultSymbol, receiver, sender, decimal.NewFromInt(1))
+	require.ErrorIs(t, err, compliance.ErrorSenderFrozen)
+	_, err = walletsService.ReverseTransfer(ctx, first.EntryID, nil, "fraud", "ops")
+	require.NoError(t, err)
+	requireBalance(t, walletsService, receiver, 30)
+
+	_, err = complianceService.SetStatus(ctx, sender, compliance.StatusFrozenIncoming, "investigation", "ops")
+	require.NoError(t, err)
+	_, err = walletsService.ReverseTransfer(ctx, second.EntryID, nil, "fraud", "ops")
+	require.NoError(t, err)
+	requireBalance(t, walletsService, sender, 70)
+	requireBalance(t, walletsService, receiver, 10)
+
+	// the deny-list still applies
+	_, err = complianceService.ImportDenyList(ctx, "ofac", []string{sender}, false, "sanctioned", "ops")
+	require.NoError(t, err)
+	_, err = walletsService.ReverseTransfer(ctx, third.EntryID, nil, "fraud", "ops")
+	require.ErrorIs(t, err, compliance.ErrorAddressDenied)
+	requireBalance(t, walletsService, receiver, 10)
+}
+
+func TestReverseEscrowPayout(t *testing.T) {
+	db, server := SetUpTest(t, []Wallet{{Address: escrowBuyer, Balance: decimal.NewFromInt(100)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+	escrow := newTestEscrow(t, walletsService, 60, time.Now().Add(time.Hour))
+	for _, party := range []string{escrowBuyer, escrowSeller} {
+		_, err := walletsService.ApproveEscrow(ctx, escrow.ID, party)
+		require.NoError(t, err)
+	}
+	_, err := walletsService.ReleaseEscrow(ctx, escrow.ID)
+	require.NoError(t, err)
+
+	history, err := walletsService.History(ctx, escrowSeller, tokens.DefaultSymbol, 10)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, wallets.EscrowAccount(escrow.ID), history[0].FromAddress)
+
+	// the escrow is settled, a refund into its account would be stuck
+	_, err = walletsService.ReverseTransfer(ctx, history[0].EntryID, nil, "mistake", "ops")
+	require.ErrorIs(t, err, wallets.ErrorPayoutNotReversible)
+	requireBalance(t, walletsService, escrowSeller, 60)
+	requireBalance(t, walletsService, wallets.EscrowAccount(escrow.ID), 0)
+}
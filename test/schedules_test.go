@@ -0,0 +1,194 @@
+package test
+
+import (
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/tokens"
+	"btp_tokens/internal/wallets"
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	payer = "0x0000000000000000000000000000000000000001"
+	payee = "0x0000000000000000000000000000000000000002"
+)
+
+// makeDue moves the next run of a schedule into the past.
+func makeDue(t *testing.T, s *wallets.WalletsService, id int64) {
+	_, err := s.DB.Exec("UPDATE Scheduled_Transfers SET Next_Run_At = now() - INTERVAL '1 second' WHERE Id = $1", id)
+	require.NoError(t, err)
+}
+
+func TestRecurringTransferRunsAndRecordsFailures(t *testing.T) {
+	db, server := SetUpTest(t, []Wallet{{Address: payer, Balance: decimal.NewFromInt(25)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+
+	resp := doMutation(t, server.URL, fmt.Sprintf(`mutation {
+		scheduleTransfer(input: {from_address: "%s", to_address: "%s", amount: "10", interval_seconds: 3600, max_runs: 3}) {
+			id status next_run_at
+		}
+	}`, payer, payee))
+	require.NotContains(t, resp, "errors")
+	created := resp["data"].(map[string]interface{})["scheduleTransfer"].(map[string]interface{})
+	require.Equal(t, "ACTIVE", created["status"])
+	id, err := strconv.ParseInt(created["id"].(string), 10, 64)
+	require.NoError(t, err)
+
+	runs, err := walletsService.RunDueSchedules(ctx)
+	require.NoError(t, err)
+	require.Zero(t, runs, "not due yet")
+
+	for i := 0; i < 3; i++ {
+		makeDue(t, walletsService, id)
+		runs, err := walletsService.RunDueSchedules(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, runs)
+	}
+
+	// the third run lacked balance and does not count against max_runs
+	requireBalance(t, walletsService, payee, 20)
+	requireBalance(t, walletsService, payer, 5)
+
+	schedule, err := walletsService.GetScheduledTransfer(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, wallets.ScheduleActive, schedule.Status)
+	require.Equal(t, 2, schedule.RunCount)
+
+	_, err = walletsService.Mint(ctx, tokens.DefaultSymbol, payer, decimal.NewFromInt(5))
+	require.NoError(t, err)
+	makeDue(t, walletsService, id)
+	runs, err = walletsService.RunDueSchedules(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, runs)
+	requireBalance(t, walletsService, payee, 30)
+
+	schedule, err = walletsService.GetScheduledTransfer(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, wallets.ScheduleCompleted, schedule.Status)
+	require.Equal(t, 3, schedule.RunCount)
+	require.Nil(t, schedule.NextRunAt)
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`query {
+		scheduledTransfer(id: "%d") { runs { status transfer_id error } }
+	}`, id))
+	history := resp["data"].(map[string]interface{})["scheduledTransfer"].(map[string]interface{})["runs"].([]interface{})
+	require.Len(t, history, 4)
+	failed := history[1].(map[string]interface{})
+	require.Equal(t, "FAILED", failed["status"])
+	require.Nil(t, failed["transfer_id"])
+	require.Equal(t, wallets.ErrorInsufficientBalance.Error(), failed["error"])
+	require.Equal(t, "SUCCEEDED", history[0].(map[string]interface{})["status"])
+	require.Equal(t, "SUCCEEDED", history[3].(map[string]interface{})["status"])
+}
+
+func TestScheduledTransferValidationAndCancel(t *testing.T) {
+	db, server := SetUpTest(t, []Wallet{{Address: payer, Balance: decimal.NewFromInt(100)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+	terms := func(cron string, interval time.Duration, runAt *time.Time) wallets.ScheduledTransfer {
+		return wallets.ScheduledTransfer{
+			Token:       tokens.DefaultSymbol,
+			FromAddress: payer,
+			ToAddress:   payee,
+			Amount:      decimal.NewFromInt(1),
+			Cron:        cron,
+			Interval:    interval,
+			NextRunAt:   runAt,
+		}
+	}
+
+	past := time.Now().Add(-time.Hour)
+	for _, invalid := range []wallets.ScheduledTransfer{
+		terms("", 0, nil),
+		terms("", 0, &past),
+		terms("* * *", 0, nil),
+		terms("0 9 * * *", time.Hour, nil),
+		terms("", time.Second, nil),
+	} {
+		_, err := walletsService.ScheduleTransfer(ctx, invalid)
+		require.ErrorIs(t, err, wallets.ErrorInvalidSchedule)
+	}
+
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	monthly, err := walletsService.ScheduleTransfer(ctx, terms("0 9 1 * *", 0, &start))
+	require.NoError(t, err)
+	require.True(t, monthly.NextRunAt.Equal(time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)))
+
+	later := time.Now().Add(time.Hour)
+	once, err := walletsService.ScheduleTransfer(ctx, terms("", 0, &later))
+	require.NoError(t, err)
+
+	cancelled, err := walletsService.CancelScheduledTransfer(ctx, once.ID)
+	require.NoError(t, err)
+	require.Equal(t, wallets.ScheduleCancelled, cancelled.Status)
+
+	makeDue(t, walletsService, once.ID)
+	runs, err := walletsService.RunDueSchedules(ctx)
+	require.NoError(t, err)
+	require.Zero(t, runs)
+
+	_, err = walletsService.CancelScheduledTransfer(ctx, once.ID)
+	require.ErrorIs(t, err, wallets.ErrorScheduleNotActive)
+}
+
+func TestSchedulersRunEachTransferOnce(t *testing.T) {
+	db, server := SetUpTest(t, []Wallet{{Address: payer, Balance: decimal.NewFromInt(1000)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+
+	const scheduled = 20
+	runAt := time.Now().Add(time.Hour)
+	for i := 0; i < scheduled; i++ {
+		schedule, err := walletsService.ScheduleTransfer(ctx, wallets.ScheduledTransfer{
+			Token:       tokens.DefaultSymbol,
+			FromAddress: payer,
+			ToAddress:   payee,
+			Amount:      decimal.NewFromInt(1),
+			NextRunAt:   &runAt,
+		})
+		require.NoError(t, err)
+		makeDue(t, walletsService, schedule.ID)
+	}
+
+	// several instances polling the same database
+	runCtx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			(&wallets.WalletsService{DB: db}).RunScheduler(runCtx, 20*time.Millisecond)
+		}()
+	}
+
+	require.Eventually(t, func() bool {
+		var runs int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM Schedule_Runs").Scan(&runs))
+		return runs == scheduled
+	}, 10*time.Second, 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	var runs int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM Schedule_Runs").Scan(&runs))
+	require.Equal(t, scheduled, runs)
+	requireBalance(t, walletsService, payee, scheduled)
+}
@@ -0,0 +1,62 @@
+package test
+
+import (
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/wallets"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulateTransferMovesNothing(t *testing.T) {
+	sender := "0x0000000000000000000000000000000000000001"
+	receiver := "0x0000000000000000000000000000000000000002"
+	db, server := SetUpTest(t, []Wallet{
+		{Address: sender, Balance: decimal.NewFromInt(100)},
+		{Address: receiver, Balance: decimal.NewFromInt(5)},
+	})
+	defer database.CloseDB()
+	defer server.Close()
+
+	simulate := func(amount string) map[string]interface{} {
+		resp := doMutation(t, server.URL, fmt.Sprintf(`query {
+			simulateTransfer(input: {from_address: "%s", to_address: "%s", amount: "%s"}) {
+				ok error_code error fee sender_balance receiver_balance
+			}
+		}`, sender, receiver, amount))
+		require.NotContains(t, resp, "errors")
+		return resp["data"].(map[string]interface{})["simulateTransfer"].(map[string]interface{})
+	}
+
+	result := simulate("40")
+	require.Equal(t, true, result["ok"])
+	require.Nil(t, result["error_code"])
+	require.Equal(t, "60", result["sender_balance"])
+	require.Equal(t, "45", result["receiver_balance"])
+
+	result = simulate("400")
+	require.Equal(t, false, result["ok"])
+	require.Equal(t, "INSUFFICIENT_BALANCE", result["error_code"])
+
+	result = simulate("1.5")
+	require.Equal(t, "INVALID_AMOUNT", result["error_code"])
+
+	walletsService := &wallets.WalletsService{DB: db}
+	balance, err := walletsService.GetWalletBalance(context.Background(), sender)
+	require.NoError(t, err)
+	require.True(t, balance.Equal(decimal.NewFromInt(100)))
+
+	var entries int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM Journal_Entries WHERE Kind = 'transfer'").Scan(&entries))
+	require.Zero(t, entries)
+
+	// the real transfer reports the same code in the error extensions
+	resp := doMutation(t, server.URL, fmt.Sprintf(`mutation {
+		transfer(input: {from_address: "%s", to_address: "%s", amount: "400"})
+	}`, sender, receiver))
+	extensions := resp["errors"].([]interface{})[0].(map[string]interface{})["extensions"].(map[string]interface{})
+	require.Equal(t, "INSUFFICIENT_BALANCE", extensions["code"])
+}
@@ -0,0 +1,101 @@
+package test
+
+import (
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/snapshots"
+	"btp_tokens/internal/tokens"
+	"btp_tokens/internal/wallets"
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func dbNow(t *testing.T, db *sql.DB) time.Time {
+	var now time.Time
+	require.NoError(t, db.QueryRow("SELECT clock_timestamp()").Scan(&now))
+	return now
+}
+
+func requireBalanceAt(t *testing.T, s *snapshots.SnapshotsService, address string, at time.Time, expected int64) {
+	balance, err := s.BalanceAt(context.Background(), address, tokens.DefaultSymbol, at)
+	require.NoError(t, err)
+	require.True(t, balance.Equal(decimal.NewFromInt(expected)), "balance at %s: expected %d, got %s", at, expected, balance)
+}
+
+func TestHistoricalBalancesStayCorrect(t *testing.T) {
+	sender := "0x0000000000000000000000000000000000000001"
+	receiver := "0x0000000000000000000000000000000000000002"
+	db, server := SetUpTest(t, []Wallet{{Address: sender, Balance: decimal.NewFromInt(100)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+	snapshotsService := &snapshots.SnapshotsService{DB: db}
+
+	beforeTransfers := dbNow(t, db)
+
+	_, err := walletsService.Transfer(ctx, sender, receiver, decimal.NewFromInt(10))
+	require.NoError(t, err)
+	afterFirst := dbNow(t, db)
+
+	snapshot, err := snapshotsService.Create(ctx)
+	require.NoError(t, err)
+
+	_, err = walletsService.Transfer(ctx, sender, receiver, decimal.NewFromInt(20))
+	require.NoError(t, err)
+	afterSecond := dbNow(t, db)
+
+	_, err = walletsService.Transfer(ctx, receiver, sender, decimal.NewFromInt(5))
+	require.NoError(t, err)
+
+	requireBalanceAt(t, snapshotsService, sender, beforeTransfers, 100)
+	requireBalanceAt(t, snapshotsService, receiver, beforeTransfers, 0)
+	requireBalanceAt(t, snapshotsService, sender, afterFirst, 90)
+	requireBalanceAt(t, snapshotsService, receiver, afterFirst, 10)
+	requireBalanceAt(t, snapshotsService, sender, afterSecond, 70)
+	requireBalanceAt(t, snapshotsService, receiver, afterSecond, 30)
+	requireBalanceAt(t, snapshotsService, sender, dbNow(t, db), 75)
+
+	atSnapshot, err := snapshotsService.BalanceAtSnapshot(ctx, receiver, tokens.DefaultSymbol, snapshot.ID)
+	require.NoError(t, err)
+	require.True(t, atSnapshot.Equal(decimal.NewFromInt(10)))
+
+	// a second snapshot does not change earlier answers
+	_, err = snapshotsService.Create(ctx)
+	require.NoError(t, err)
+	requireBalanceAt(t, snapshotsService, receiver, afterFirst, 10)
+	requireBalanceAt(t, snapshotsService, receiver, afterSecond, 30)
+}
+
+func TestWalletHistoricalBalanceQuery(t *testing.T) {
+	sender := "0x0000000000000000000000000000000000000001"
+	receiver := "0x0000000000000000000000000000000000000002"
+	db, server := SetUpTest(t, []Wallet{
+		{Address: sender, Balance: decimal.NewFromInt(100)},
+		{Address: receiver, Balance: decimal.NewFromInt(0)},
+	})
+	defer database.CloseDB()
+	defer server.Close()
+
+	snapshot, err := (&snapshots.SnapshotsService{DB: db}).Create(context.Background())
+	require.NoError(t, err)
+	before := dbNow(t, db)
+
+	_, err = (&wallets.WalletsService{DB: db}).Transfer(context.Background(), sender, receiver, decimal.NewFromInt(60))
+	require.NoError(t, err)
+
+	resp := doMutation(t, server.URL, fmt.Sprintf(`query {
+		wallet(address: "%s") { balance balanceAt(time: "%s") balanceAtSnapshot(id: "%d") }
+	}`, receiver, before.Format(time.RFC3339Nano), snapshot.ID))
+	wallet := resp["data"].(map[string]interface{})["wallet"].(map[string]interface{})
+
+	require.Equal(t, "60", wallet["balance"])
+	require.Equal(t, "0", wallet["balanceAt"])
+	require.Equal(t, "0", wallet["balanceAtSnapshot"])
+}
@@ -0,0 +1,156 @@
+package test
+
+import (
+	"btp_tokens/internal/compliance"
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/tokens"
+	"btp_tokens/internal/wallets"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStakingRewards(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	stake := wallets.Stake{Amount: decimal.NewFromInt(1000), Status: wallets.StakeStaked}
+
+	apr := decimal.RequireFromString("0.1")
+	pool := wallets.StakingPool{APR: &apr, AccruedAt: start}
+	require.Equal(t, "100", stake.PendingRewards(pool, start.Add(365*24*time.Hour)).String())
+	// rounded down to the token's precision
+	require.True(t, stake.PendingRewards(pool, start.Add(24*time.Hour)).IsZero())
+
+	// a reward pool is shared pro rata among everything staked
+	rate := decimal.NewFromInt(1)
+	pool = wallets.StakingPool{RewardRate: &rate, TotalStaked: decimal.NewFromInt(4000), AccruedAt: start}
+	require.Equal(t, "250", stake.PendingRewards(pool, start.Add(1000*time.Second)).String())
+
+	stake.Status = wallets.StakeUnbonding
+	stake.Rewards = decimal.NewFromInt(7)
+	require.Equal(t, "7", stake.PendingRewards(pool, start.Add(1000*time.Second)).String())
+}
+
+func TestStaking(t *testing.T) {
+	treasury := "0x0000000000000000000000000000000000000001"
+	alice := "0x0000000000000000000000000000000000000002"
+	bob := "0x0000000000000000000000000000000000000003"
+	db, server := SetUpTest(t, []Wallet{
+		{Address: treasury, Balance: decimal.NewFromInt(10000)},
+		{Address: alice, Balance: decimal.NewFromInt(1000)},
+	})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+
+	requireCode(t, doMutation(t, server.URL, fmt.Sprintf(`mutation { stake(input: {address: "%s", amount: "600"}) { id } }`, alice)), "STAKING_POOL_NOT_FOUND")
+	require.Contains(t, doMutation(t, server.URL, `mutation { configureStakingPool(input: {apr: "0.1"}) { token } }`), "errors")
+	requireCode(t, doOperatorMutation(t, server.URL, `mutation { configureStakingPool(input: {apr: "0.1", reward_rate: "1"}) { token } }`), "INVALID_STAKING_POOL")
+	resp := doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation {
+		a: configureStakingPool(input: {apr: "0.1", unbonding_seconds: 3600}) { token }
+		b: fundStakingRewards(from_address: "%s", amount: "50") { rewards_available }
+	}`, treasury))
+	require.NotContains(t, resp, "errors")
+	require.Equal(t, "50", resp["data"].(map[string]interface{})["b"].(map[string]interface{})["rewards_available"])
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation { stake(input: {address: "%s", amount: "600"}) { id status } }`, alice))
+	require.NotContains(t, resp, "errors")
+	stake := resp["data"].(map[string]interface{})["stake"].(map[string]interface{})
+	require.Equal(t, "STAKED", stake["status"])
+	id := stake["id"]
+
+	// staked tokens stay in the balance but cannot be sent
+	wallet, err := walletsService.GetWallet(ctx, alice, "BTP")
+	require.NoError(t, err)
+	require.True(t, wallet.Staked.Equal(decimal.NewFromInt(600)))
+	require.True(t, wallet.Available().Equal(decimal.NewFromInt(400)))
+	_, err = walletsService.Transfer(ctx, alice, bob, decimal.NewFromInt(500))
+	require.ErrorIs(t, err, wallets.ErrorInsufficientBalance)
+	_, err = walletsService.Transfer(ctx, alice, bob, decimal.NewFromInt(400))
+	require.NoError(t, err)
+
+	// a year later the stake earned 10%
+	_, err = db.Exec("UPDATE Staking_Pools SET Accrued_At = Accrued_At - INTERVAL '365 days'")
+	require.NoError(t, err)
+	resp = doMutation(t, server.URL, fmt.Sprintf(`query { pendingStakingRewards(address: "%s") }`, alice))
+	require.NotContains(t, resp, "errors")
+	pending := decimal.RequireFromString(resp["data"].(map[string]interface{})["pendingStakingRewards"].(string))
+	require.True(t, pending.GreaterThanOrEqual(decimal.NewFromInt(60)) && pending.LessThan(decimal.RequireFromString("60.01")), pending.String())
+
+	requireCode(t, doMutation(t, server.URL, fmt.Sprintf(`mutation { claimStakingRewards(id: "%s") { id } }`, id)), "INSUFFICIENT_REWARDS")
+	require.NotContains(t, doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation {
+		fundStakingRewards(from_address: "%s", amount: "100") { token }
+	}`, treasury)), "errors")
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation { claimStakingRewards(id: "%s") { claimed_rewards } }`, id))
+	require.NotContains(t, resp, "errors")
+	claimed := decimal.RequireFromString(resp["data"].(map[string]interface{})["claimStakingRewards"].(map[string]interface{})["claimed_rewards"].(string))
+	require.True(t, claimed.GreaterThanOrEqual(decimal.NewFromInt(60)))
+	balance, err := walletsService.GetWalletBalance(ctx, alice)
+	require.NoError(t, err)
+	require.True(t, balance.Equal(decimal.NewFromInt(600).Add(claimed)))
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation { unstake(id: "%s") { status unbonds_at } }`, id))
+	require.NotContains(t, resp, "errors")
+	require.Equal(t, "UNBONDING", resp["data"].(map[string]interface{})["unstake"].(map[string]interface{})["status"])
+	requireCode(t, doMutation(t, server.URL, fmt.Sprintf(`mutation { unstake(id: "%s") { id } }`, id)), "INVALID_STAKE_STATE")
+	requireCode(t, doMutation(t, server.URL, fmt.Sprintf(`mutation { withdrawStake(id: "%s") { id } }`, id)), "STILL_UNBONDING")
+
+	_, err = db.Exec("UPDATE Stakes SET Unbonds_At = now() - INTERVAL '1 second' WHERE Id = $1", id)
+	require.NoError(t, err)
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation { withdrawStake(id: "%s") { status } }`, id))
+	require.NotContains(t, resp, "errors")
+	require.Equal(t, "WITHDRAWN", resp["data"].(map[string]interface{})["withdrawStake"].(map[string]interface{})["status"])
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`query {
+		wallet(address: "%s") { staked_balance available_balance }
+		stakingPool { total_staked }
+	}`, alice))
+	require.NotContains(t, resp, "errors")
+	require.Equal(t, "0", resp["data"].(map[string]interface{})["wallet"].(map[string]interface{})["staked_balance"])
+	require.Equal(t, "0", resp["data"].(map[string]interface{})["stakingPool"].(map[string]interface{})["total_staked"])
+}
+
+func TestStakeChecksSender(t *testing.T) {
+	frozen := "0x0000000000000000000000000000000000000001"
+	multisig := "0x0000000000000000000000000000000000000002"
+	signer := "0x00000000000000000000000000000000000000a1"
+	db, server := SetUpTest(t, []Wallet{
+		{Address: frozen, Balance: decimal.NewFromInt(100)},
+		{Address: multisig, Balance: decimal.NewFromInt(100)},
+	})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+
+	seed := make([]byte, ed25519.SeedSize)
+	key := ed25519.NewKeyFromSeed(seed)
+	resp := doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation {
+		a: configureStakingPool(input: {apr: "0.1", unbonding_seconds: 3600}) { token }
+		b: freezeWallet(address: "%s", status: FROZEN_OUTGOING, reason: "account takeover") { status }
+		c: createMultisigWallet(input: {address: "%s", threshold: 1, signers: [{address: "%s", public_key: "%s"}]}) { threshold }
+	}`, frozen, multisig, signer, hex.EncodeToString(key.Public().(ed25519.PublicKey))))
+	require.NotContains(t, resp, "errors")
+
+	_, err := walletsService.Stake(ctx, tokens.DefaultSymbol, frozen, decimal.NewFromInt(50))
+	require.ErrorIs(t, err, compliance.ErrorSenderFrozen)
+	requireCode(t, doMutation(t, server.URL, fmt.Sprintf(`mutation { stake(input: {address: "%s", amount: "50"}) { id } }`, frozen)), "WALLET_FROZEN")
+
+	// a multisig wallet cannot lock its balance without its signers
+	_, err = walletsService.Stake(ctx, tokens.DefaultSymbol, multisig, decimal.NewFromInt(50))
+	require.ErrorIs(t, err, wallets.ErrorMultisigRequired)
+
+	for _, address := range []string{frozen, multisig} {
+		wallet, err := walletsService.GetWallet(ctx, address, tokens.DefaultSymbol)
+		require.NoError(t, err)
+		require.True(t, wallet.Staked.IsZero())
+	}
+}
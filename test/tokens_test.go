@@ -0,0 +1,159 @@
+package test
+
+import (
+	"btp_tokens/internal/limits"
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/tokens"
+	"btp_tokens/internal/wallets"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenSupplyCapAndBalances(t *testing.T) {
+	sender := "0x0000000000000000000000000000000000000001"
+	receiver := "0x0000000000000000000000000000000000000002"
+	db, server := SetUpTest(t, []Wallet{{Address: sender, Balance: decimal.NewFromInt(100)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	tokensService := &tokens.TokensService{DB: db}
+	walletsService := &wallets.WalletsService{DB: db}
+
+	supplyCap := decimal.NewFromInt(1000)
+	_, err := tokensService.Register(ctx, tokens.Token{Symbol: "GOLD", Name: "Gold", SupplyCap: &supplyCap})
+	require.NoError(t, err)
+
+	_, err = tokensService.Register(ctx, tokens.Token{Symbol: "GOLD", Name: "Gold again"})
+	require.ErrorIs(t, err, tokens.ErrorTokenExists)
+	_, err = tokensService.Register(ctx, tokens.Token{Symbol: "gold!", Name: "Bad"})
+	require.ErrorIs(t, err, tokens.ErrorInvalidSymbol)
+
+	_, err = walletsService.Mint(ctx, "GOLD", sender, decimal.NewFromInt(800))
+	require.NoError(t, err)
+	_, err = walletsService.Mint(ctx, "GOLD", receiver, decimal.NewFromInt(201))
+	require.ErrorIs(t, err, tokens.ErrorSupplyCapExceeded)
+
+	balance, err := walletsService.TransferToken(ctx, "GOLD", sender, receiver, decimal.NewFromInt(300))
+	require.NoError(t, err)
+	require.True(t, balance.Equal(decimal.NewFromInt(500)))
+
+	// BTP balances are kept apart from GOLD
+	btp, err := walletsService.GetWalletBalance(ctx, sender)
+	require.NoError(t, err)
+	require.True(t, btp.Equal(decimal.NewFromInt(100)))
+	_, err = walletsService.GetWalletBalance(ctx, receiver)
+	require.ErrorIs(t, err, wallets.ErrorWalletNotFound)
+
+	_, err = walletsService.TransferToken(ctx, "SILVER", sender, receiver, decimal.NewFromInt(1))
+	require.ErrorIs(t, err, tokens.ErrorTokenNotFound)
+
+	supply, err := tokensService.TotalSupply(ctx, "GOLD")
+	require.NoError(t, err)
+	require.True(t, supply.Equal(decimal.NewFromInt(800)))
+}
+
+func TestTokenMutations(t *testing.T) {
+	sender := "0x0000000000000000000000000000000000000001"
+	receiver := "0x0000000000000000000000000000000000000002"
+	_, server := SetUpTest(t, []Wallet{{Address: sender, Balance: decimal.NewFromInt(10)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	resp := doMutation(t, server.URL, `mutation { registerToken(input: {symbol: "GOLD", name: "Gold", decimals: 0}) { symbol } }`)
+	assertGraphQLError(t, resp, "operator authorization required")
+
+	resp = doOperatorMutation(t, server.URL, `mutation {
+		registerToken(input: {symbol: "GOLD", name: "Gold", decimals: 0, supply_cap: "500"}) { symbol supply_cap }
+	}`)
+	require.NotContains(t, resp, "errors")
+
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation {
+		mint(input: {to_address: "%s", amount: "50", token: "GOLD"}) { token balance }
+	}`, sender))
+	require.Equal(t, "50", resp["data"].(map[string]interface{})["mint"].(map[string]interface{})["balance"])
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation {
+		transfer(input: {from_address: "%s", to_address: "%s", amount: "20", token: "GOLD"})
+	}`, sender, receiver))
+	require.Equal(t, "30", resp["data"].(map[string]interface{})["transfer"])
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`query {
+		wallets(address: "%s") { token balance }
+		token(symbol: "GOLD") { total_supply }
+	}`, sender))
+	data := resp["data"].(map[string]interface{})
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"token": "BTP", "balance": "10"},
+		map[string]interface{}{"token": "GOLD", "balance": "30"},
+	}, data["wallets"])
+	require.Equal(t, "50", data["token"].(map[string]interface{})["total_supply"])
+}
+
+func TestFractionalAmounts(t *testing.T) {
+	sender := "0x0000000000000000000000000000000000000001"
+	receiver := "0x0000000000000000000000000000000000000002"
+	db, server := SetUpTest(t, []Wallet{{Address: sender, Balance: decimal.NewFromInt(10)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	tokensService := &tokens.TokensService{DB: db}
+	_, err := tokensService.SetDecimals(context.Background(), tokens.DefaultSymbol, 18)
+	require.NoError(t, err)
+
+	resp := doMutation(t, server.URL, fmt.Sprintf(`mutation {
+		transfer(input: {from_address: "%s", to_address: "%s", amount: "1.5"})
+	}`, sender, receiver))
+	require.Equal(t, "8.5", resp["data"].(map[string]interface{})["transfer"])
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation {
+		transfer(input: {from_address: "%s", to_address: "%s", amount: "500000000000000000", unit: BASE})
+	}`, sender, receiver))
+	require.Equal(t, "8", resp["data"].(map[string]interface{})["transfer"])
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation {
+		transfer(input: {from_address: "%s", to_address: "%s", amount: "0.0000000000000000001"})
+	}`, sender, receiver))
+	assertGraphQLError(t, resp, "amount must have at most 18 decimal places")
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`query {
+		wallet(address: "%s") { balance balance_base_units }
+	}`, receiver))
+	wallet := resp["data"].(map[string]interface{})["wallet"].(map[string]interface{})
+	require.Equal(t, "2", wallet["balance"])
+	require.Equal(t, "2000000000000000000", wallet["balance_base_units"])
+
+	_, err = (&wallets.WalletsService{DB: db}).Transfer(context.Background(), sender, receiver, decimal.RequireFromString("0.25"))
+	require.NoError(t, err)
+	_, err = tokensService.SetDecimals(context.Background(), tokens.DefaultSymbol, 1)
+	require.ErrorIs(t, err, tokens.ErrorDecimalsTooSmall)
+}
+
+func TestLowerDecimalsChecksStoredAmounts(t *testing.T) {
+	sender := "0x0000000000000000000000000000000000000001"
+	db, server := SetUpTest(t, []Wallet{{Address: sender, Balance: decimal.NewFromInt(10)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	tokensService := &tokens.TokensService{DB: db}
+	_, err := tokensService.SetDecimals(ctx, tokens.DefaultSymbol, 2)
+	require.NoError(t, err)
+
+	// every balance is whole, but a limit is not
+	limitsService := &limits.LimitsService{DB: db}
+	perTransfer := decimal.RequireFromString("0.5")
+	_, err = limitsService.SetLimits(ctx, limits.Limits{Tier: "unverified", Token: tokens.DefaultSymbol, PerTransfer: &perTransfer})
+	require.NoError(t, err)
+	_, err = tokensService.SetDecimals(ctx, tokens.DefaultSymbol, 0)
+	require.ErrorIs(t, err, tokens.ErrorDecimalsTooSmall)
+
+	require.NoError(t, limitsService.RemoveLimits(ctx, "unverified", tokens.DefaultSymbol))
+	token, err := tokensService.SetDecimals(ctx, tokens.DefaultSymbol, 0)
+	require.NoError(t, err)
+	require.Equal(t, int32(0), token.Decimals)
+}
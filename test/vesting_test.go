@@ -0,0 +1,157 @@
+package test
+
+import (
+	"btp_tokens/internal/limits"
+	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/policy"
+	"btp_tokens/internal/tokens"
+	"btp_tokens/internal/wallets"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVestedAt(t *testing.T) {
+	day := 24 * time.Hour
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	grant := wallets.VestingGrant{Total: decimal.NewFromInt(1000), StartAt: start, Cliff: 10 * day, Duration: 100 * day}
+
+	require.True(t, grant.VestedAt(start.Add(-day)).IsZero())
+	require.True(t, grant.VestedAt(start.Add(10*day-time.Second)).IsZero())
+	require.Equal(t, "100", grant.VestedAt(start.Add(10*day)).String())
+	// rounded down to the token's precision
+	require.Equal(t, "250", grant.VestedAt(start.Add(25*day+time.Hour)).String())
+	require.Equal(t, "1000", grant.VestedAt(start.Add(200*day)).String())
+
+	revokedAt := start.Add(30 * day)
+	grant.RevokedAt = &revokedAt
+	grant.Returned = decimal.NewFromInt(700)
+	require.Equal(t, "250", grant.VestedAt(start.Add(25*day)).String())
+	require.Equal(t, "300", grant.VestedAt(start.Add(50*day)).String())
+}
+
+func TestVestingSeconds(t *testing.T) {
+	duration, err := wallets.VestingSeconds(86400)
+	require.NoError(t, err)
+	require.Equal(t, 24*time.Hour, duration)
+
+	// 300 years would overflow a time.Duration
+	for _, seconds := range []int64{-1, int64(wallets.MaxVestingDuration/time.Second) + 1, 300 * 365 * 86400} {
+		_, err = wallets.VestingSeconds(seconds)
+		require.ErrorIs(t, err, wallets.ErrorInvalidVestingGrant, seconds)
+	}
+}
+
+func TestVestingGrant(t *testing.T) {
+	treasury := "0x0000000000000000000000000000000000000001"
+	bob := "0x0000000000000000000000000000000000000002"
+	db, server := SetUpTest(t, []Wallet{{Address: treasury, Balance: decimal.NewFromInt(10000)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+	day := 24 * time.Hour
+	start := time.Now().Add(-50 * day).UTC().Truncate(time.Second)
+
+	create := fmt.Sprintf(`mutation {
+		createVestingGrant(input: {funder: "%s", beneficiary: "%s", total: "1000", start_at: "%s",
+			cliff_seconds: 864000, duration_seconds: 8640000, revocable: true}) { id vested claimable }
+	}`, treasury, bob, start.Format(time.RFC3339))
+	require.Contains(t, doMutation(t, server.URL, create), "errors")
+	resp := doOperatorMutation(t, server.URL, create)
+	require.NotContains(t, resp, "errors")
+	id := resp["data"].(map[string]interface{})["createVestingGrant"].(map[string]interface{})["id"]
+	requireBalance(t, walletsService, treasury, 9000)
+	requireBalance(t, walletsService, bob, 0)
+
+	query := func(at time.Time) map[string]interface{} {
+		resp := doMutation(t, server.URL, fmt.Sprintf(`query {
+			vestingGrant(id: "%s", at: "%s") { vested unvested claimed claimable }
+		}`, id, at.Format(time.RFC3339)))
+		require.NotContains(t, resp, "errors")
+		return resp["data"].(map[string]interface{})["vestingGrant"].(map[string]interface{})
+	}
+	grant := query(start.Add(5 * day))
+	require.Equal(t, "0", grant["vested"])
+	require.Equal(t, "1000", grant["unvested"])
+	grant = query(start.Add(25 * day))
+	require.Equal(t, "250", grant["vested"])
+	require.Equal(t, "750", grant["unvested"])
+	require.Equal(t, "250", grant["claimable"])
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`mutation { claimVested(id: "%s") { claimed } }`, id))
+	require.NotContains(t, resp, "errors")
+	claimed := decimal.RequireFromString(resp["data"].(map[string]interface{})["claimVested"].(map[string]interface{})["claimed"].(string))
+	require.True(t, claimed.GreaterThanOrEqual(decimal.NewFromInt(500)) && claimed.LessThan(decimal.NewFromInt(501)), claimed.String())
+	balance, err := walletsService.GetWalletBalance(ctx, bob)
+	require.NoError(t, err)
+	require.True(t, balance.Equal(claimed))
+
+	// revoking returns the unvested part and stops the vesting
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { revokeVestingGrant(id: "%s") { returned revoked_at } }`, id))
+	require.NotContains(t, resp, "errors")
+	returned := decimal.RequireFromString(resp["data"].(map[string]interface{})["revokeVestingGrant"].(map[string]interface{})["returned"].(string))
+	balance, err = walletsService.GetWalletBalance(ctx, treasury)
+	require.NoError(t, err)
+	require.True(t, balance.Equal(decimal.NewFromInt(9000).Add(returned)))
+	grant = query(start.Add(200 * day))
+	require.Equal(t, decimal.NewFromInt(1000).Sub(returned).String(), grant["vested"])
+	requireCode(t, doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { revokeVestingGrant(id: "%s") { id } }`, id)), "GRANT_NOT_REVOCABLE")
+
+	resp = doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation {
+		createVestingGrant(input: {funder: "%s", beneficiary: "%s", total: "100", start_at: "%s", duration_seconds: 86400}) { id }
+	}`, treasury, bob, time.Now().Add(day).Format(time.RFC3339)))
+	require.NotContains(t, resp, "errors")
+	id = resp["data"].(map[string]interface{})["createVestingGrant"].(map[string]interface{})["id"]
+	requireCode(t, doMutation(t, server.URL, fmt.Sprintf(`mutation { claimVested(id: "%s") { id } }`, id)), "NOTHING_TO_CLAIM")
+	requireCode(t, doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation { revokeVestingGrant(id: "%s") { id } }`, id)), "GRANT_NOT_REVOCABLE")
+
+	requireCode(t, doOperatorMutation(t, server.URL, fmt.Sprintf(`mutation {
+		createVestingGrant(input: {funder: "%s", beneficiary: "%s", total: "100", cliff_seconds: 100, duration_seconds: 10}) { id }
+	}`, treasury, bob)), "INVALID_VESTING_GRANT")
+
+	resp = doMutation(t, server.URL, fmt.Sprintf(`query { vestingGrants(beneficiary: "%s") { id } }`, bob))
+	require.NotContains(t, resp, "errors")
+	require.Len(t, resp["data"].(map[string]interface{})["vestingGrants"], 2)
+}
+
+func TestVestingGrantChecksFunder(t *testing.T) {
+	treasury := "0x0000000000000000000000000000000000000001"
+	bob := "0x0000000000000000000000000000000000000002"
+	carol := "0x0000000000000000000000000000000000000003"
+	db, server := SetUpTest(t, []Wallet{{Address: treasury, Balance: decimal.NewFromInt(10000)}})
+	defer database.CloseDB()
+	defer server.Close()
+
+	ctx := context.Background()
+	walletsService := &wallets.WalletsService{DB: db}
+	resp := doOperatorMutation(t, server.URL, `mutation { setTierLimits(input: {tier: "unverified", daily: "1500"}) { tier } }`)
+	require.NotContains(t, resp, "errors")
+	_, err := (&policy.PolicyService{DB: db}).SaveRule(ctx, policy.Rule{Name: "carol", Expression: fmt.Sprintf("to == '%s'", carol), Action: policy.ActionDeny, Enabled: true}, "ops")
+	require.NoError(t, err)
+
+	grant := func(beneficiary string, total int64) error {
+		_, err := walletsService.CreateVestingGrant(ctx, wallets.VestingGrant{
+			Token:       tokens.DefaultSymbol,
+			Funder:      treasury,
+			Beneficiary: beneficiary,
+			Total:       decimal.NewFromInt(total),
+			StartAt:     time.Now(),
+			Duration:    time.Hour,
+		})
+		return err
+	}
+
+	// the policies see the beneficiary as the recipient
+	require.ErrorIs(t, grant(carol, 100), policy.ErrorPolicyDenied)
+
+	// and the funder's daily limit counts the grants
+	require.NoError(t, grant(bob, 1000))
+	require.ErrorIs(t, grant(bob, 1000), limits.ErrorLimitExceeded)
+	requireBalance(t, walletsService, treasury, 9000)
+}
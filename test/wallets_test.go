@@ -2,7 +2,16 @@ package test
 
 import (
 	"btp_tokens/graph"
+	"btp_tokens/internal/alerts"
+	"btp_tokens/internal/auth"
+	"btp_tokens/internal/compliance"
+	"btp_tokens/internal/fees"
+	"btp_tokens/internal/ledger"
+	"btp_tokens/internal/limits"
+	"btp_tokens/internal/policy"
 	database "btp_tokens/internal/pkg/db/migrations/postgres"
+	"btp_tokens/internal/snapshots"
+	"btp_tokens/internal/tokens"
 	"btp_tokens/internal/wallets"
 	"bytes"
 	"context"
@@ -27,6 +36,9 @@ type Wallet struct {
 	Balance decimal.Decimal
 }
 
+const testOperator = "test-operator"
+const testOperatorToken = "test-operator-token"
+
 type Transfer struct {
     FromAddress string
     ToAddress string
@@ -34,7 +46,7 @@ type Transfer struct {
 }
 
 
-func setupTestDB(t *testing.T) *sql.DB {
+func setupTestDB(t testing.TB) *sql.DB {
     if err := godotenv.Load(); err != nil {
 		log.Println("cant load .env")
 	}
@@ -55,20 +67,27 @@ func setupTestDB(t *testing.T) *sql.DB {
 }
 
 func ResetTestDB() {
-    _, _ = database.Db.Exec("TRUNCATE TABLE wallets RESTART IDENTITY CASCADE;")
+    _, _ = database.Db.Exec("TRUNCATE TABLE wallets, postings, journal_entries, ledger_checkpoints, snapshots, snapshot_balances, snapshot_roots, fee_policies, fee_tiers, holds, escrows, escrow_events, htlcs, scheduled_transfers, schedule_runs, transfer_reversals, address_statuses, deny_list, compliance_events, tier_limits, address_tiers, policy_rules, policy_flags, alert_detectors, alert_cursor, alerts, alert_events, multisig_wallets, multisig_signers, multisig_proposals, multisig_approvals, vesting_grants, staking_pools, stakes, distributions, distribution_recipients, airdrops, airdrop_leaves, payment_requests, payment_request_payments RESTART IDENTITY CASCADE;")
+    _, _ = database.Db.Exec("DELETE FROM tokens WHERE symbol <> 'BTP'")
+    _, _ = database.Db.Exec("UPDATE tokens SET decimals = 0 WHERE symbol = 'BTP'")
 }
 
-func SetWallets(wallets []Wallet) {
-    for _, w := range wallets{
+func SetWallets(initialWallets []Wallet) {
+    walletsService := &wallets.WalletsService{DB: database.Db}
+    for _, w := range initialWallets{
         _, _ = database.Db.Exec(`
         INSERT INTO wallets (address, balance) VALUES
-        ($1, $2) 
-        ON CONFLICT (address) DO UPDATE SET balance = $2
-    `, w.Address, w.Balance)
+        ($1, 0) 
+        ON CONFLICT (address, token) DO UPDATE SET balance = 0
+    `, w.Address)
+
+        if w.Balance.IsPositive() {
+            _, _ = walletsService.Mint(context.Background(), tokens.DefaultSymbol, w.Address, w.Balance)
+        }
     }
 }
 
-func SetUpTest(t *testing.T, wallets []Wallet) (*sql.DB, *httptest.Server) {
+func SetUpTest(t testing.TB, wallets []Wallet) (*sql.DB, *httptest.Server) {
     db := setupTestDB(t)
     ResetTestDB()
     SetWallets(wallets)
@@ -80,15 +99,38 @@ func SetUpTest(t *testing.T, wallets []Wallet) (*sql.DB, *httptest.Server) {
 func startTestServer(db *sql.DB) *httptest.Server {
     resolver := &graph.Resolver{
         WalletsService: &wallets.WalletsService{DB: db},
+        LedgerService: &ledger.LedgerService{DB: db},
+        SnapshotsService: &snapshots.SnapshotsService{DB: db},
+        TokensService: &tokens.TokensService{DB: db},
+        FeesService: &fees.FeesService{DB: db},
+        ComplianceService: &compliance.ComplianceService{DB: db},
+        LimitsService: &limits.LimitsService{DB: db},
+        PolicyService: &policy.PolicyService{DB: db},
+        AlertsService: &alerts.AlertsService{DB: db},
     }
     srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: resolver}))
-    server := httptest.NewServer(srv)
+    srv.SetErrorPresenter(graph.ErrorPresenter)
+    server := httptest.NewServer(auth.Middleware(auth.Operators{testOperator: testOperatorToken})(srv))
     return server
 }
 
 func doMutation(t *testing.T, serverURL, mutation string) map[string]interface{} {
+    return doRequest(t, serverURL, mutation, "")
+}
+
+func doOperatorMutation(t *testing.T, serverURL, mutation string) map[string]interface{} {
+    return doRequest(t, serverURL, mutation, testOperatorToken)
+}
+
+func doRequest(t *testing.T, serverURL, mutation, token string) map[string]interface{} {
     body, _ := json.Marshal(map[string]string{"query": mutation})
-    resp, err := http.Post(serverURL, "application/json", bytes.NewBuffer(body))
+    req, err := http.NewRequest(http.MethodPost, serverURL, bytes.NewBuffer(body))
+    require.NoError(t, err)
+    req.Header.Set("Content-Type", "application/json")
+    if token != "" {
+        req.Header.Set("Authorization", "Bearer "+token)
+    }
+    resp, err := http.DefaultClient.Do(req)
 
     require.NoError(t, err)
     defer resp.Body.Close()
@@ -109,6 +151,13 @@ func assertGraphQLError(t *testing.T, resp map[string]interface{}, expectedMsg s
     require.Equal(t, expectedMsg, msg)
 }
 
+func requireCode(t *testing.T, resp map[string]interface{}, code string) {
+    require.Contains(t, resp, "errors")
+    extensions := resp["errors"].([]interface{})[0].(map[string]interface{})["extensions"].(map[string]interface{})
+
+    require.Equal(t, code, extensions["code"])
+}
+
 type transferTestArgs struct {
     t *testing.T 
     fromAddress string
@@ -336,7 +385,7 @@ func TestTransferFloat(t *testing.T) {
         amount: "100.7",
         expectedKey: "errors",
         expectedValue: "",
-        expectedErrorMsg: "decimal must be given as an int or a string, received float64",
+        expectedErrorMsg: "amount must be an integer (cant be floating point)",
     }
     _, server := transferTest(args, initial_wallets)
     defer database.CloseDB()